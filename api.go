@@ -0,0 +1,58 @@
+package smtp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxAPIErrorBody limits how much of an error response is kept in an APIError.
+const maxAPIErrorBody = 1 << 10
+
+// APIError is returned by the HTTP API transports, such as SESTransport, when the provider rejects a request.
+type APIError struct {
+	// Provider is ses, sendgrid, or mailgun.
+	Provider   string
+	StatusCode int
+	// Message is the start of the response body, which usually explains the rejection.
+	Message string
+}
+
+// Error returns the status and the provider's explanation.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s error, api answered %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the request is worth retrying: the provider is rate limiting or failing.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// doAPI sends the request with client, or a client with a 30 second timeout when nil, and returns the response
+// body of a 2xx reply. Other replies fail with an *APIError.
+func doAPI(client *http.Client, req *http.Request, provider string) ([]byte, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s error, failed to call api; %w", provider, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message := strings.TrimSpace(string(body))
+		if len(message) > maxAPIErrorBody {
+			message = message[:maxAPIErrorBody]
+		}
+		return nil, &APIError{Provider: provider, StatusCode: resp.StatusCode, Message: message}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s error, failed to read api response; %w", provider, err)
+	}
+	return body, nil
+}
@@ -0,0 +1,85 @@
+package smtp
+
+import (
+	"sync"
+	"time"
+)
+
+// ArchiveRecord describes a sent or failed email.
+type ArchiveRecord struct {
+	Time    time.Time
+	From    string
+	To      []string
+	Cc      []string
+	Bcc     []string
+	Subject string
+	Profile string
+	Error   string
+	// Message holds the wire representation of the message; it is empty when the profile skips body archiving.
+	Message []byte
+}
+
+// Archiver defines the methods that any archive backend must implement.
+type Archiver interface {
+	Archive(record ArchiveRecord) error
+}
+
+// archive hands the outcome of a send to the configured archiver.
+func (c *SMTP) archive(email Email, msg *Message, sendErr error) {
+	if c.archiver == nil {
+		return
+	}
+
+	record := ArchiveRecord{
+		Time:    time.Now(),
+		From:    c.senderAddress,
+		To:      email.To,
+		Cc:      email.Cc,
+		Bcc:     email.Bcc,
+		Subject: email.Subject,
+	}
+	if sendErr != nil {
+		record.Error = sendErr.Error()
+	}
+	if email.Profile != nil {
+		record.Profile = email.Profile.Name
+	}
+	if email.Profile == nil || !email.Profile.SkipArchiveBody {
+		record.Message = msg.Bytes()
+	}
+
+	if err := c.archiver.Archive(record); err != nil {
+		c.log().Error("smtp archive failed", "error", err)
+	}
+}
+
+// MemoryArchiver keeps archive records in memory, e.g. to inspect the messages of a dry run.
+type MemoryArchiver struct {
+	mu      sync.Mutex
+	records []ArchiveRecord
+}
+
+// Archive stores the record.
+func (a *MemoryArchiver) Archive(record ArchiveRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.records = append(a.records, record)
+	return nil
+}
+
+// Records returns the stored records in the order they were archived.
+func (a *MemoryArchiver) Records() []ArchiveRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]ArchiveRecord(nil), a.records...)
+}
+
+// Reset removes every stored record.
+func (a *MemoryArchiver) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.records = nil
+}
@@ -0,0 +1,343 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Attachment represents a file attached to an email.
+type Attachment struct {
+	Filename string
+	// ContentType is the media type, e.g. "application/pdf". When empty, it follows the filename extension, or
+	// is detected from Data when the extension is missing or unknown.
+	ContentType string
+	Data        []byte
+
+	// Open streams the content instead of Data when set. It is called whenever the message is written,
+	// e.g. once per delivery attempt, and must return the same content every time.
+	Open func() (io.ReadCloser, error)
+	// Size is the length of the content returned by Open, used for the SIZE check; 0 means it is measured.
+	Size int64
+	// oneShot marks content that cannot be read twice, so it is never read just to measure it.
+	oneShot bool
+	// Ref identifies streamed content kept elsewhere, e.g. a file:// URL set by AttachFile or an object
+	// storage key. It is what JSON carries instead of the content; see Email.MarshalJSON.
+	Ref string
+
+	// Encoding overrides the base64 Content-Transfer-Encoding, e.g. EncodingQuotedPrintable for a text file
+	// that should stay readable in the raw message. Streamed attachments are always base64 encoded.
+	Encoding Encoding
+
+	// Inline displays the attachment within the HTML body, which refers to it as cid:ContentID.
+	Inline    bool
+	ContentID string
+}
+
+// attachmentTypes maps the extensions of common attachments to their types, for systems whose MIME tables lack
+// them, such as minimal containers.
+var attachmentTypes = map[string]string{
+	".csv":  "text/csv",
+	".txt":  "text/plain; charset=utf-8",
+	".md":   "text/markdown",
+	".ics":  "text/calendar",
+	".vcf":  "text/vcard",
+	".rtf":  "application/rtf",
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xls":  "application/vnd.ms-excel",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".zip":  "application/zip",
+	".gz":   "application/gzip",
+	".tar":  "application/x-tar",
+	".7z":   "application/x-7z-compressed",
+	".heic": "image/heic",
+	".mp3":  "audio/mpeg",
+	".mp4":  "video/mp4",
+	".wav":  "audio/wav",
+}
+
+// contentType returns the declared content type, falling back to the filename extension and then to the type
+// detected from the content. Streamed content is not read to detect its type.
+func (a Attachment) contentType() string {
+	if a.ContentType != "" {
+		return a.ContentType
+	}
+	ext := strings.ToLower(filepath.Ext(a.Filename))
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	if t, ok := attachmentTypes[ext]; ok {
+		return t
+	}
+	if a.Open == nil && len(a.Data) > 0 {
+		return http.DetectContentType(a.Data)
+	}
+	return "application/octet-stream"
+}
+
+// mimePart is a MIME entity: its header fields in order and its body, whose content is encoded when the
+// message is written.
+type mimePart struct {
+	header   []HeaderField
+	segments []segment
+}
+
+// buildBody returns the MIME structure of an email with an HTML body, a calendar, or attachments:
+// the text and HTML bodies and the calendar form a multipart/alternative, inline attachments join the HTML part
+// in a multipart/related, and regular attachments wrap everything in a multipart/mixed.
+func buildBody(email Email, boundary func() string) mimePart {
+	var inline, attached []Attachment
+	for _, a := range email.Attachments {
+		if a.Inline && email.HTMLBody != "" {
+			inline = append(inline, a)
+		} else {
+			attached = append(attached, a)
+		}
+	}
+
+	var alternatives []mimePart
+	if email.Body != "" || email.HTMLBody == "" {
+		alternatives = append(alternatives, textPart("text/plain", email.Body, email.BodyEncoding))
+	}
+	if email.HTMLBody != "" {
+		html := textPart("text/html", email.HTMLBody, email.HTMLEncoding)
+		if len(inline) != 0 {
+			parts := []mimePart{html}
+			for _, a := range inline {
+				parts = append(parts, attachmentPart(a))
+			}
+			html = multipartPart("related", parts, boundary())
+		}
+		alternatives = append(alternatives, html)
+	}
+	if email.Calendar != nil {
+		alternatives = append(alternatives, email.Calendar.part())
+		attached = append(attached, email.Calendar.attachment())
+	}
+
+	content := alternatives[0]
+	if len(alternatives) > 1 {
+		content = multipartPart("alternative", alternatives, boundary())
+	}
+
+	if len(attached) == 0 {
+		return content
+	}
+
+	parts := []mimePart{content}
+	for _, a := range attached {
+		parts = append(parts, attachmentPart(a))
+	}
+	return multipartPart("mixed", parts, boundary())
+}
+
+// textPart returns a text entity encoded with enc, or with the encoding chosen from the content for EncodingAuto.
+func textPart(mediaType, text string, enc Encoding) mimePart {
+	enc = fitEncoding(normalizeEncoding(enc), text)
+	return mimePart{
+		header: []HeaderField{
+			{Name: "Content-Type", Value: mediaType + "; charset=utf-8"},
+			{Name: "Content-Transfer-Encoding", Value: string(enc)},
+		},
+		segments: []segment{{text: text, enc: enc}},
+	}
+}
+
+// hasLongLine reports whether text has a line longer than the 998 characters allowed by RFC 5322.
+func hasLongLine(text string) bool {
+	n := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\r' || text[i] == '\n' {
+			n = 0
+			continue
+		}
+		if n++; n > maxLineLength {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentPart returns the base64 encoded entity of an attachment.
+func attachmentPart(a Attachment) mimePart {
+	filename := safeFilename(a.Filename)
+
+	// The name parameter predates RFC 2231, and the clients that still read it expect RFC 2047 encoded words
+	name := filename
+	if !isASCII(name) {
+		name = encodeWords(name)
+	}
+	// Parameters such as the charset of a detected text type are kept
+	mediaType, params, err := mime.ParseMediaType(a.contentType())
+	if err != nil {
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+	params["name"] = name
+	contentType := mime.FormatMediaType(mediaType, params)
+	if contentType == "" {
+		contentType = mime.FormatMediaType("application/octet-stream", map[string]string{"name": name})
+	}
+
+	disposition := "attachment"
+	if a.Inline {
+		disposition = "inline"
+	}
+
+	enc := normalizeEncoding(a.Encoding)
+	if enc == EncodingAuto || a.Open != nil {
+		enc = EncodingBase64
+	} else if enc != EncodingBase64 {
+		enc = fitEncoding(enc, string(a.Data))
+	}
+
+	part := mimePart{header: []HeaderField{
+		{Name: "Content-Type", Value: contentType},
+		{Name: "Content-Disposition", Value: formatFilename(disposition, filename)},
+		{Name: "Content-Transfer-Encoding", Value: string(enc)},
+	}}
+	if a.ContentID != "" {
+		part.header = append(part.header, HeaderField{Name: "Content-ID", Value: "<" + sanitizeHeaderValue(strings.Trim(a.ContentID, "<>")) + ">"})
+	}
+	if a.Open != nil {
+		part.segments = []segment{{enc: enc, open: a.Open, size: a.Size, oneShot: a.oneShot}}
+		return part
+	}
+	part.segments = []segment{{enc: enc, data: a.Data}}
+	return part
+}
+
+// multipartPart returns a multipart entity of the given subtype holding the parts, separated by boundary.
+func multipartPart(subtype string, parts []mimePart, boundary string) mimePart {
+	// Literal text between the content segments, such as part headers and boundaries, is joined
+	var b strings.Builder
+	var segments []segment
+	for _, part := range parts {
+		b.WriteString("--" + boundary + "\r\n")
+		for _, f := range part.header {
+			writeHeaderField(&b, f.Name, f.Value)
+		}
+		b.WriteString("\r\n")
+
+		for _, seg := range part.segments {
+			if seg.literal() {
+				b.WriteString(seg.text)
+				continue
+			}
+			segments = append(segments, segment{text: b.String()}, seg)
+			b.Reset()
+		}
+	}
+	b.WriteString("--" + boundary + "--\r\n")
+
+	return mimePart{
+		header:   []HeaderField{{Name: "Content-Type", Value: mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": boundary})}},
+		segments: append(segments, segment{text: b.String()}),
+	}
+}
+
+// maxParamLength is the longest parameter value written in one piece; longer filenames are split into RFC 2231
+// continuations, so that the header can be folded into lines of at most 78 characters.
+const maxParamLength = 60
+
+// formatFilename returns the disposition with the filename parameter. Short ASCII filenames are quoted as usual.
+// Others are split into RFC 2231 continuations, and non-ASCII filenames are percent-encoded UTF-8, e.g.
+//
+//	filename*0*=utf-8''Jahresbericht%202024%20%E2%80%93%20...; filename*1*=...
+func formatFilename(disposition, filename string) string {
+	ascii := isASCII(filename)
+	if ascii && len(filename) <= maxParamLength {
+		return mime.FormatMediaType(disposition, map[string]string{"filename": filename})
+	}
+
+	// Each piece is a character, or a percent-encoded byte that must not be split
+	var pieces []string
+	if ascii {
+		for i := 0; i < len(filename); i++ {
+			pieces = append(pieces, filename[i:i+1])
+		}
+	} else {
+		pieces = append(pieces, "utf-8''")
+		for i := 0; i < len(filename); i++ {
+			if c := filename[i]; isAttributeChar(c) {
+				pieces = append(pieces, string(c))
+			} else {
+				pieces = append(pieces, fmt.Sprintf("%%%02X", c))
+			}
+		}
+	}
+
+	var sections []string
+	var section strings.Builder
+	for _, piece := range pieces {
+		if section.Len()+len(piece) > maxParamLength {
+			sections = append(sections, section.String())
+			section.Reset()
+		}
+		section.WriteString(piece)
+	}
+	sections = append(sections, section.String())
+
+	var b strings.Builder
+	b.WriteString(disposition)
+	for i, s := range sections {
+		name := "filename"
+		if len(sections) > 1 {
+			name += "*" + strconv.Itoa(i)
+		}
+		if ascii {
+			b.WriteString("; " + name + `="` + s + `"`)
+		} else {
+			b.WriteString("; " + name + "*=" + s)
+		}
+	}
+	return b.String()
+}
+
+// isAttributeChar reports whether c may appear unencoded in an RFC 2231 extended parameter value.
+func isAttributeChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
+
+// safeFilename drops characters that cannot appear in a MIME parameter value.
+func safeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if !unicode.IsPrint(r) || r == '"' || r == '\\' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" {
+		return "attachment"
+	}
+	return name
+}
+
+// encodeQuotedPrintable encodes s as quoted-printable text.
+func encodeQuotedPrintable(s string) string {
+	var b bytes.Buffer
+	w := quotedprintable.NewWriter(&b)
+	w.Write([]byte(s))
+	w.Close()
+	return b.String()
+}
+
+// newBoundary returns a random multipart boundary.
+func newBoundary() string {
+	b := make([]byte, 15)
+	rand.Read(b)
+	return "=_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,83 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Result is the outcome of one email sent with SendMailMulti.
+type Result struct {
+	SendResult
+	// Err is nil when the email was sent or every recipient was suppressed.
+	Err error
+}
+
+// batchKey is the context key of the batch whose session is kept between the sends of SendMailMulti.
+type batchKey struct{}
+
+// batch holds the session that the next email of a batch reuses. It is only used by one goroutine at a time.
+// owner is the client the session belongs to, since routes send part of a batch through other clients.
+type batch struct {
+	sess  *session
+	owner *SMTP
+}
+
+// keep holds the session of owner for the next email and parks the session held before.
+func (b *batch) keep(owner *SMTP, sess *session) {
+	b.close()
+	b.sess, b.owner = sess, owner
+}
+
+// take returns the held session when it belongs to owner and was opened to the relay, or nil.
+func (b *batch) take(owner *SMTP, relay string) *session {
+	if b == nil || b.sess == nil || b.owner != owner || b.sess.relay != relay {
+		return nil
+	}
+	sess := b.sess
+	b.sess, b.owner = nil, nil
+	return sess
+}
+
+// close parks the held session.
+func (b *batch) close() {
+	if b.sess != nil {
+		b.owner.park(b.sess)
+		b.sess, b.owner = nil, nil
+	}
+}
+
+// batchFrom returns the batch of the send, or nil outside of SendMailMulti.
+func batchFrom(ctx context.Context) *batch {
+	b, _ := ctx.Value(batchKey{}).(*batch)
+	return b
+}
+
+// SendMailMulti sends the emails one after another on a single authenticated session, resetting it with RSET
+// between them, and reports the outcome of each email at the same index. A failed email does not stop the batch;
+// when the session breaks, the next email opens a new one. Every email passes through the middlewares, retries,
+// and suppression like SendMailContext. The error is non-nil when at least one email failed, and once ctx is done
+// the remaining emails fail with its error.
+func (c *SMTP) SendMailMulti(ctx context.Context, emails []Email) ([]Result, error) {
+	b := &batch{}
+	defer b.close()
+	ctx = context.WithValue(ctx, batchKey{}, b)
+
+	results := make([]Result, len(emails))
+	var errs []error
+	for i, email := range emails {
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+		} else {
+			results[i].SendResult, results[i].Err = c.SendMailResult(ctx, email)
+		}
+		if results[i].Err != nil {
+			errs = append(errs, fmt.Errorf("email %d; %w", i, results[i].Err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return results, fmt.Errorf("send error, %d of %d emails failed; %w", len(errs), len(emails), errors.Join(errs...))
+	}
+	return results, nil
+}
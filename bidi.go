@@ -0,0 +1,94 @@
+package smtp
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Text directions of Email.Direction.
+const (
+	DirectionLTR = "ltr"
+	DirectionRTL = "rtl"
+)
+
+// Unicode bidirectional control characters.
+const (
+	rightToLeftMark       = "\u200f"
+	firstStrongIsolate    = "\u2068"
+	popDirectionalIsolate = "\u2069"
+)
+
+// rtl reports whether the email is written right to left, as set by Direction or implied by Locale.
+func (e Email) rtl() bool {
+	if e.Direction != "" {
+		return strings.EqualFold(e.Direction, DirectionRTL)
+	}
+	return e.Locale != "" && LookupLocale(e.Locale).RTL
+}
+
+// isRTL reports whether r is a strong right-to-left character.
+func isRTL(r rune) bool {
+	return unicode.In(r, unicode.Hebrew, unicode.Arabic, unicode.Syriac, unicode.Thaana, unicode.Nko)
+}
+
+// firstStrong returns the direction of the first strong character of s, or an empty string when there is none.
+func firstStrong(s string) string {
+	for _, r := range s {
+		switch {
+		case isRTL(r):
+			return DirectionRTL
+		case unicode.IsLetter(r):
+			return DirectionLTR
+		}
+	}
+	return ""
+}
+
+// bidiSubject prefixes a right-to-left subject with a right-to-left mark when it would otherwise be shown
+// left to right, e.g. "Order #42 شُحن" starting with Latin text.
+func bidiSubject(subject string) string {
+	if subject == "" || strings.HasPrefix(subject, rightToLeftMark) || firstStrong(subject) == DirectionRTL {
+		return subject
+	}
+	return rightToLeftMark + subject
+}
+
+// bidiIsolate wraps text in first strong isolate marks so it cannot reorder the text around it,
+// e.g. a Latin name inside an Arabic sentence.
+func bidiIsolate(s string) string {
+	return firstStrongIsolate + s + popDirectionalIsolate
+}
+
+var (
+	htmlOpenTag  = regexp.MustCompile(`(?i)<html\b[^>]*>`)
+	htmlDirAttr  = regexp.MustCompile(`(?i)\sdir\s*=`)
+	htmlLangAttr = regexp.MustCompile(`(?i)\slang\s*=`)
+)
+
+// directHTML declares the direction, and the language when known, on the html element of a document.
+// A fragment without an html element is wrapped in one. Existing dir and lang attributes are kept.
+func directHTML(html, dir, lang string) string {
+	attrs := ` dir="` + dir + `"`
+	if lang != "" {
+		attrs += ` lang="` + lang + `"`
+	}
+
+	loc := htmlOpenTag.FindStringIndex(html)
+	if loc == nil {
+		return "<html" + attrs + "><body dir=\"" + dir + "\">" + html + "</body></html>"
+	}
+
+	tag := html[loc[0]:loc[1]]
+	var add string
+	if !htmlDirAttr.MatchString(tag) {
+		add += ` dir="` + dir + `"`
+	}
+	if lang != "" && !htmlLangAttr.MatchString(tag) {
+		add += ` lang="` + lang + `"`
+	}
+	if add == "" {
+		return html
+	}
+	return html[:loc[0]+len("<html")] + add + html[loc[0]+len("<html"):]
+}
@@ -0,0 +1,433 @@
+package smtp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Report types.
+const (
+	// ReportDSN is a delivery status notification (RFC 3464).
+	ReportDSN = "dsn"
+	// ReportARF is an abuse feedback report (RFC 5965), e.g. a spam complaint.
+	ReportARF = "arf"
+)
+
+// BounceReport is one recipient entry of a delivery status notification or feedback report.
+type BounceReport struct {
+	Type string
+	// Recipient is the final recipient of a DSN or the original recipient of a feedback report.
+	Recipient         string
+	OriginalRecipient string
+	// Action is the DSN action: failed, delayed, delivered, relayed, or expanded.
+	Action string
+	// Status is the enhanced status code of a DSN, e.g. 5.1.1. When the report omits it, it is taken from the
+	// diagnostic text.
+	Status     string
+	Diagnostic string
+	// ReportingMTA is the server that issued the DSN and RemoteMTA the server that rejected the message.
+	ReportingMTA string
+	RemoteMTA    string
+	// LastAttempt is when delivery was last attempted, or zero when the DSN does not say.
+	LastAttempt time.Time
+	// FeedbackType is the type of a feedback report, e.g. abuse.
+	FeedbackType string
+	// MessageID is the Message-ID of the original message when the report includes its header.
+	MessageID string
+	// VERPRecipient is the recipient decoded from the VERP address the report was returned to, see WithVERP.
+	VERPRecipient string
+}
+
+// Permanent reports whether the recipient should no longer be mailed: a failed DSN or any complaint.
+func (r BounceReport) Permanent() bool {
+	if r.Type == ReportARF {
+		return true
+	}
+	return r.Action == "failed"
+}
+
+// ParseReport extracts the recipient entries of a multipart/report message carrying a
+// message/delivery-status or message/feedback-report part. The report may also be nested in another
+// multipart entity, as some servers wrap it in a multipart/mixed.
+func ParseReport(data []byte) ([]BounceReport, error) {
+	root, err := parseEntity(data)
+	if err != nil {
+		return nil, fmt.Errorf("report error, failed to parse message; %w", err)
+	}
+	e := findReport(root)
+	if e == nil {
+		return nil, fmt.Errorf("report error, message is %s, not multipart/report", root.mediaType)
+	}
+
+	var reports []BounceReport
+	messageID := ""
+	for _, part := range e.parts {
+		switch part.mediaType {
+		case "message/delivery-status", "message/global-delivery-status":
+			dsn, err := parseDeliveryStatus(part.content)
+			if err != nil {
+				return nil, err
+			}
+			reports = append(reports, dsn...)
+		case "message/feedback-report":
+			arf, err := parseFeedbackReport(part.content)
+			if err != nil {
+				return nil, err
+			}
+			reports = append(reports, arf)
+		case "message/rfc822", "text/rfc822-headers", "message/global", "message/global-headers":
+			if msg, err := mail.ReadMessage(bytes.NewReader(append(part.content, "\r\n\r\n"...))); err == nil {
+				messageID = msg.Header.Get("Message-Id")
+			}
+		}
+	}
+
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("report error, no delivery status or feedback report found")
+	}
+	verp := ""
+	for _, name := range []string{"Delivered-To", "X-Original-To", "To"} {
+		if recipient, ok := DecodeVERP(root.header.Get(name)); ok {
+			verp = recipient
+			break
+		}
+	}
+	for i := range reports {
+		reports[i].MessageID = messageID
+		reports[i].VERPRecipient = verp
+	}
+	return reports, nil
+}
+
+// findReport returns the first multipart/report entity of the tree, depth first.
+func findReport(e *entity) *entity {
+	if e.mediaType == "multipart/report" {
+		return e
+	}
+	for _, part := range e.parts {
+		if report := findReport(part); report != nil {
+			return report
+		}
+	}
+	return nil
+}
+
+// readFieldGroups reads the blank line separated header groups of a report part.
+func readFieldGroups(content []byte) ([]textproto.MIMEHeader, error) {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(normalizeCRLF(content))))
+
+	var groups []textproto.MIMEHeader
+	for {
+		// Skip blank lines between groups
+		for {
+			b, err := r.R.Peek(1)
+			if err != nil || (b[0] != '\r' && b[0] != '\n') {
+				break
+			}
+			r.ReadLine()
+		}
+
+		header, err := r.ReadMIMEHeader()
+		if len(header) != 0 {
+			groups = append(groups, header)
+		}
+		if err == io.EOF {
+			return groups, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("report error, failed to read report fields; %w", err)
+		}
+	}
+}
+
+// parseDeliveryStatus returns one report per recipient group of a message/delivery-status part.
+func parseDeliveryStatus(content []byte) ([]BounceReport, error) {
+	groups, err := readFieldGroups(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []BounceReport
+	reportingMTA := ""
+	for i, g := range groups {
+		// The first group holds the per-message fields
+		if i == 0 && g.Get("Final-Recipient") == "" {
+			reportingMTA = typedValue(g.Get("Reporting-MTA"))
+			continue
+		}
+
+		report := BounceReport{
+			Type:              ReportDSN,
+			Recipient:         typedValue(g.Get("Final-Recipient")),
+			OriginalRecipient: typedValue(g.Get("Original-Recipient")),
+			Action:            strings.ToLower(strings.TrimSpace(g.Get("Action"))),
+			Status:            statusCode(g.Get("Status")),
+			Diagnostic:        typedValue(g.Get("Diagnostic-Code")),
+			ReportingMTA:      reportingMTA,
+			RemoteMTA:         typedValue(g.Get("Remote-MTA")),
+		}
+		if t, err := mail.ParseDate(g.Get("Last-Attempt-Date")); err == nil {
+			report.LastAttempt = t
+		}
+
+		// Some servers leave out the status or the action; both follow from the reply in the diagnostic
+		if report.Status == "" {
+			report.Status = statusCode(report.Diagnostic)
+		}
+		if report.Action == "" {
+			switch {
+			case strings.HasPrefix(report.Status, "5."):
+				report.Action = "failed"
+			case strings.HasPrefix(report.Status, "4."):
+				report.Action = "delayed"
+			}
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// enhancedStatus matches an enhanced status code (RFC 3463) and basicReply a three digit reply code.
+var (
+	enhancedStatus = regexp.MustCompile(`\b([245])\.(\d{1,3})\.(\d{1,3})\b`)
+	basicReply     = regexp.MustCompile(`^\s*([245])\d\d\b`)
+)
+
+// statusCode returns the enhanced status code in s, e.g. "5.1.1" from "550 5.1.1 User unknown", falling back
+// to the class of a bare reply code such as "550" as "5.0.0".
+func statusCode(s string) string {
+	if m := enhancedStatus.FindStringSubmatch(s); m != nil {
+		return m[1] + "." + m[2] + "." + m[3]
+	}
+	if m := basicReply.FindStringSubmatch(s); m != nil {
+		return m[1] + ".0.0"
+	}
+	return ""
+}
+
+// parseFeedbackReport returns the report of a message/feedback-report part.
+func parseFeedbackReport(content []byte) (BounceReport, error) {
+	groups, err := readFieldGroups(content)
+	if err != nil {
+		return BounceReport{}, err
+	}
+	if len(groups) == 0 {
+		return BounceReport{}, fmt.Errorf("report error, empty feedback report")
+	}
+
+	g := groups[0]
+	return BounceReport{
+		Type:         ReportARF,
+		Recipient:    strings.Trim(strings.TrimSpace(g.Get("Original-Rcpt-To")), "<>"),
+		FeedbackType: strings.ToLower(strings.TrimSpace(g.Get("Feedback-Type"))),
+	}, nil
+}
+
+// typedValue strips the type prefix of a "type; value" field such as "rfc822; user@example.com".
+func typedValue(s string) string {
+	if _, value, ok := strings.Cut(s, ";"); ok {
+		s = value
+	}
+	return strings.Trim(strings.TrimSpace(s), "<>")
+}
+
+// Mailbox fetches messages from a mailbox such as the bounce address of a sender.
+type Mailbox interface {
+	// Poll calls handle for every message in the mailbox and deletes the ones handle returns nil for.
+	// Messages handle returns an error for are kept and do not stop the poll.
+	Poll(ctx context.Context, handle func(data []byte) error) error
+}
+
+// BouncePoller periodically fetches a bounce mailbox, parses the reports it contains, and suppresses
+// recipients that bounced permanently or complained. Messages that are not reports are left in the mailbox.
+type BouncePoller struct {
+	mailbox     Mailbox
+	suppression SuppressionStore
+	interval    time.Duration
+
+	// OnReport is called for every parsed report.
+	OnReport func(report BounceReport)
+	// OnError is called when a poll fails in the background.
+	OnError func(err error)
+
+	// events is the client whose event handlers receive bounces and complaints
+	events *SMTP
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewBouncePoller initializes and returns a poller that adds bounced recipients to suppression.
+// The suppression store may be nil when reports are only handled through OnReport.
+func NewBouncePoller(mailbox Mailbox, suppression SuppressionStore) *BouncePoller {
+	return &BouncePoller{
+		mailbox:     mailbox,
+		suppression: suppression,
+		interval:    time.Minute,
+	}
+}
+
+// SetInterval changes how often the mailbox is polled.
+func (p *BouncePoller) SetInterval(interval time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.interval = interval
+}
+
+// SetEvents passes an EventBounced or EventComplained event for every permanent bounce or complaint to the
+// event handlers of client, see WithEvents. The event carries the Message-ID of the original message when
+// the report includes it.
+func (p *BouncePoller) SetEvents(client *SMTP) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = client
+}
+
+// Poll fetches and processes the mailbox once. A message is deleted once its reports have been handled;
+// it is kept when a recipient cannot be suppressed so the next poll tries again.
+func (p *BouncePoller) Poll(ctx context.Context) error {
+	p.mu.Lock()
+	events := p.events
+	p.mu.Unlock()
+
+	return p.mailbox.Poll(ctx, func(data []byte) error {
+		reports, err := ParseReport(data)
+		if err != nil {
+			return err
+		}
+
+		for _, report := range reports {
+			// The VERP address names the recipient as it was sent to, before any forwarding
+			recipient := report.Recipient
+			if report.VERPRecipient != "" {
+				recipient = report.VERPRecipient
+			}
+			if p.suppression != nil && report.Permanent() && recipient != "" {
+				reason := "bounce " + report.Status
+				if report.Type == ReportARF {
+					reason = "complaint " + report.FeedbackType
+				}
+				if err = p.suppression.Suppress(recipient, strings.TrimSpace(reason)); err != nil {
+					err = fmt.Errorf("suppression error, failed to suppress %s; %w", recipient, err)
+					if p.OnError != nil {
+						p.OnError(err)
+					}
+					return err
+				}
+			}
+			if events != nil && report.Permanent() {
+				events.emitReport(report, recipient)
+			}
+			if p.OnReport != nil {
+				p.OnReport(report)
+			}
+		}
+		return nil
+	})
+}
+
+// emitReport emits the event of a permanent bounce or complaint for recipient, unless the client was shut down.
+func (c *SMTP) emitReport(report BounceReport, recipient string) {
+	if len(c.events) == 0 {
+		return
+	}
+	_, end, err := c.life.begin(context.Background())
+	if err != nil {
+		return
+	}
+	defer end()
+
+	event := WebhookEvent{
+		Type:      EventBounced,
+		Time:      time.Now(),
+		MessageID: report.MessageID,
+		From:      c.senderAddress,
+		Status:    report.Status,
+		Error:     report.Diagnostic,
+	}
+	if recipient != "" {
+		event.Recipients = []string{recipient}
+	}
+	if report.Type == ReportARF {
+		event.Type = EventComplained
+		event.Status = report.FeedbackType
+	}
+	c.emit(event)
+}
+
+// Start begins polling the mailbox in the background.
+func (p *BouncePoller) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stop != nil {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go p.run(p.interval, p.stop, p.done)
+}
+
+// Stop halts the background loop and waits for an in-progress poll to finish.
+func (p *BouncePoller) Stop() {
+	p.mu.Lock()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// Shutdown stops the background loop like Stop, but only waits for an in-progress poll until ctx is done.
+func (p *BouncePoller) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.mu.Unlock()
+
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *BouncePoller) run(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := p.Poll(context.Background()); err != nil && p.OnError != nil {
+				p.OnError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"net/textproto"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the server while the circuit breaker is open. It is not
+// temporary, so the send fails at once instead of waiting out its retries.
+var ErrCircuitOpen = errors.New("send error, circuit breaker is open")
+
+// CircuitState is the state of the circuit breaker.
+type CircuitState int
+
+// Circuit breaker states.
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+// String returns the name of the state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreakerConfig controls when sends are short-circuited after repeated relay failures.
+type CircuitBreakerConfig struct {
+	// Threshold is the number of consecutive relay failures, network errors or 4xx replies, that opens the circuit.
+	Threshold int
+	// Cooldown is how long the circuit stays open before probes are let through.
+	Cooldown time.Duration
+	// HalfOpenProbes is the number of sends allowed while half-open; it defaults to 1.
+	HalfOpenProbes int
+}
+
+// circuitBreaker tracks consecutive relay failures.
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+// allow reports whether a send may contact the server.
+func (b *circuitBreaker) allow() error {
+	if b.config.Threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen {
+		if time.Since(b.openedAt) < b.config.Cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		b.probes = 0
+	}
+
+	if b.state == CircuitHalfOpen {
+		probes := b.config.HalfOpenProbes
+		if probes <= 0 {
+			probes = 1
+		}
+		if b.probes >= probes {
+			return ErrCircuitOpen
+		}
+		b.probes++
+	}
+
+	return nil
+}
+
+// record updates the breaker with the outcome of a send. Only relay failures count; a success or a 5xx reply
+// proves the relay is up. Cancellation and local errors, such as invalid messages, say nothing about the relay,
+// so one bad caller cannot open the circuit for everyone; a half-open probe that ends with one is given back.
+func (b *circuitBreaker) record(err error) {
+	if b.config.Threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var protoErr *textproto.Error
+	switch {
+	case relayFailure(err):
+		b.failures++
+		if b.state == CircuitHalfOpen || b.failures >= b.config.Threshold {
+			b.state = CircuitOpen
+			b.openedAt = time.Now()
+		}
+	case err == nil || errors.As(err, &protoErr):
+		b.state = CircuitClosed
+		b.failures = 0
+	case b.state == CircuitHalfOpen && b.probes > 0:
+		b.probes--
+	}
+}
+
+// relayFailure reports whether err shows the relay failing: a network error, a connection it closed, or a
+// 4xx reply.
+func relayFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 400 && protoErr.Code < 500
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// CircuitState returns the current state of the circuit breaker.
+func (c *SMTP) CircuitState() CircuitState {
+	c.breaker.mu.Lock()
+	defer c.breaker.mu.Unlock()
+
+	if c.breaker.state == CircuitOpen && time.Since(c.breaker.openedAt) >= c.breaker.config.Cooldown {
+		return CircuitHalfOpen
+	}
+	return c.breaker.state
+}
@@ -0,0 +1,158 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var errRefused = &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+func TestCircuitBreakerTrip(t *testing.T) {
+	b := &circuitBreaker{config: CircuitBreakerConfig{Threshold: 3, Cooldown: time.Minute}}
+
+	for i := 0; i < 2; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("send %d: %v", i+1, err)
+		}
+		b.record(errRefused)
+	}
+	if b.state != CircuitClosed {
+		t.Fatalf("state after 2 failures is %s, want closed", b.state)
+	}
+
+	// A success in between starts the count again
+	b.record(nil)
+	for i := 0; i < 2; i++ {
+		b.record(&textproto.Error{Code: 421, Msg: "4.3.2 service not available"})
+	}
+	if b.state != CircuitClosed {
+		t.Fatalf("state after reset and 2 failures is %s, want closed", b.state)
+	}
+
+	b.record(errRefused)
+	if b.state != CircuitOpen {
+		t.Fatalf("state after 3 failures is %s, want open", b.state)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow while open returned %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestCircuitBreakerIgnoresLocalErrors(t *testing.T) {
+	b := &circuitBreaker{config: CircuitBreakerConfig{Threshold: 2, Cooldown: time.Minute}}
+
+	local := []error{
+		context.Canceled,
+		fmt.Errorf("send error; %w", context.DeadlineExceeded),
+		&ValidationError{Violations: []Violation{{Rule: "to"}}},
+		&ComplianceError{},
+		&MessageSizeError{Size: 2, Limit: 1},
+		errors.New("template error, missing parameter"),
+	}
+	for _, err := range local {
+		b.record(err)
+		b.record(err)
+		if b.state != CircuitClosed || b.failures != 0 {
+			t.Fatalf("%v counted as a relay failure: state %s, %d failures", err, b.state, b.failures)
+		}
+	}
+
+	// A 5xx reply proves the relay is up
+	b.record(errRefused)
+	b.record(&textproto.Error{Code: 550, Msg: "5.1.1 user unknown"})
+	if b.failures != 0 {
+		t.Fatalf("5xx reply did not reset the failures, %d left", b.failures)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	b := &circuitBreaker{config: CircuitBreakerConfig{Threshold: 1, Cooldown: 10 * time.Millisecond, HalfOpenProbes: 1}}
+
+	b.record(errRefused)
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow while open returned %v, want ErrCircuitOpen", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe after cooldown: %v", err)
+	}
+	if b.state != CircuitHalfOpen {
+		t.Fatalf("state during probe is %s, want half-open", b.state)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second send during probe returned %v, want ErrCircuitOpen", err)
+	}
+
+	// A probe canceled by its caller is given back instead of deciding the state
+	b.record(context.Canceled)
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe after canceled probe: %v", err)
+	}
+
+	// A failed probe opens the circuit again
+	b.record(errRefused)
+	if b.state != CircuitOpen {
+		t.Fatalf("state after failed probe is %s, want open", b.state)
+	}
+}
+
+func TestCircuitBreakerRecovery(t *testing.T) {
+	b := &circuitBreaker{config: CircuitBreakerConfig{Threshold: 1, Cooldown: 10 * time.Millisecond}}
+
+	b.record(errRefused)
+	time.Sleep(20 * time.Millisecond)
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe after cooldown: %v", err)
+	}
+	b.record(nil)
+
+	if b.state != CircuitClosed || b.failures != 0 {
+		t.Fatalf("state after successful probe is %s with %d failures, want closed", b.state, b.failures)
+	}
+	for i := 0; i < 3; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("send %d after recovery: %v", i+1, err)
+		}
+	}
+}
+
+func TestCircuitOpenFailsWithoutRetrying(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+
+	c, err := New("app@example.com", "", "127.0.0.1", addr.Port,
+		WithRetry(RetryPolicy{MaxAttempts: 5, Backoff: time.Second}),
+		WithCircuitBreaker(CircuitBreakerConfig{Threshold: 1, Cooldown: time.Minute}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := Email{To: []string{"user@example.com"}, Subject: "Hello", Body: "Hi"}
+
+	// The first attempt is refused and opens the circuit, so the retry fails at once
+	start := time.Now()
+	if err = c.SendMail(email); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("first send returned %v, want ErrCircuitOpen", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("first send took %s, want a single backoff", d)
+	}
+
+	start = time.Now()
+	if err = c.SendMail(email); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("send while open returned %v, want ErrCircuitOpen", err)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatalf("send while open took %s, want it to fail at once", d)
+	}
+}
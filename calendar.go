@@ -0,0 +1,172 @@
+package smtp
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Calendar methods of RFC 5546.
+const (
+	MethodRequest = "REQUEST"
+	MethodCancel  = "CANCEL"
+	MethodReply   = "REPLY"
+	MethodPublish = "PUBLISH"
+)
+
+// Calendar is an iCalendar object sent with an email, such as a meeting invitation.
+// It is added as a text/calendar alternative with the method parameter, which Outlook and Gmail require
+// to show the invitation, and as an invite.ics attachment for other clients.
+type Calendar struct {
+	// Method is the iTIP method, e.g. MethodRequest. When empty, it is taken from the METHOD property of Data.
+	Method string
+	// Data is the iCalendar object, e.g. as returned by Event.Invite.
+	Data []byte
+}
+
+// method returns the iTIP method of the calendar, defaulting to REQUEST.
+func (c Calendar) method() string {
+	method := c.Method
+	if method == "" {
+		for _, line := range strings.Split(string(c.Data), "\n") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(line), "METHOD:"); ok {
+				method = value
+				break
+			}
+		}
+	}
+
+	method = strings.ToUpper(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-' {
+			return r
+		}
+		return -1
+	}, method))
+	if method == "" {
+		return MethodRequest
+	}
+	return method
+}
+
+// part returns the text/calendar alternative. Non-ASCII content is base64 encoded rather than quoted-printable,
+// which some calendar clients fail to decode.
+func (c Calendar) part() mimePart {
+	data := string(normalizeCRLF(c.Data))
+	enc := EncodingAuto
+	if !isASCII(data) {
+		enc = EncodingBase64
+	}
+	return textPart("text/calendar; method="+c.method(), data, enc)
+}
+
+// attachment returns the calendar as an invite.ics attachment.
+func (c Calendar) attachment() Attachment {
+	return Attachment{Filename: "invite.ics", ContentType: "application/ics", Data: normalizeCRLF(c.Data)}
+}
+
+// Event describes a meeting for which Invite and Cancel build the iCalendar object.
+type Event struct {
+	// UID identifies the event across updates and cancellations. It must stay the same for every message about the event.
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	// Organizer and Attendees are email addresses, optionally as "Name <addr>".
+	Organizer string
+	Attendees []string
+	// Sequence is the revision of the event; increase it for every update or cancellation.
+	Sequence int
+}
+
+// Invite returns the event as a meeting request.
+func (e Event) Invite() Calendar {
+	return Calendar{Method: MethodRequest, Data: e.ics(MethodRequest, "CONFIRMED")}
+}
+
+// Cancel returns the cancellation of the event. Send it with a higher Sequence than the invitation.
+func (e Event) Cancel() Calendar {
+	return Calendar{Method: MethodCancel, Data: e.ics(MethodCancel, "CANCELLED")}
+}
+
+// ics renders the event as an iCalendar object (RFC 5545).
+func (e Event) ics(method, status string) []byte {
+	var b bytes.Buffer
+	line := func(s string) { b.WriteString(foldICS(s) + "\r\n") }
+
+	line("BEGIN:VCALENDAR")
+	line("PRODID:-//go-smtp//" + Version + "//EN")
+	line("VERSION:2.0")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:" + method)
+	line("BEGIN:VEVENT")
+	line("UID:" + escapeICS(e.UID))
+	line("DTSTAMP:" + icsTime(time.Now()))
+	line("DTSTART:" + icsTime(e.Start))
+	if !e.End.IsZero() {
+		line("DTEND:" + icsTime(e.End))
+	}
+	line(fmt.Sprintf("SEQUENCE:%d", e.Sequence))
+	line("STATUS:" + status)
+	if e.Summary != "" {
+		line("SUMMARY:" + escapeICS(e.Summary))
+	}
+	if e.Description != "" {
+		line("DESCRIPTION:" + escapeICS(e.Description))
+	}
+	if e.Location != "" {
+		line("LOCATION:" + escapeICS(e.Location))
+	}
+	if e.Organizer != "" {
+		line("ORGANIZER" + icsAddress(e.Organizer))
+	}
+	for _, attendee := range e.Attendees {
+		line("ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE" + icsAddress(attendee))
+	}
+	line("END:VEVENT")
+	line("END:VCALENDAR")
+	return b.Bytes()
+}
+
+// icsTime formats t as a UTC date-time.
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// icsAddress returns the CN parameter and mailto value of an address given as "Name <addr>" or a bare address.
+func icsAddress(addr string) string {
+	name := ""
+	if lt := strings.LastIndex(addr, "<"); lt >= 0 && strings.HasSuffix(addr, ">") {
+		name = strings.Trim(strings.TrimSpace(addr[:lt]), `"`)
+		addr = addr[lt+1 : len(addr)-1]
+	}
+
+	value := ":mailto:" + strings.TrimSpace(addr)
+	if name == "" {
+		return value
+	}
+	return `;CN="` + strings.NewReplacer(`"`, "", "\r", "", "\n", "").Replace(name) + `"` + value
+}
+
+// escapeICS escapes a TEXT value as described in RFC 5545 section 3.3.11.
+func escapeICS(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// foldICS folds a content line at 75 octets without splitting a UTF-8 sequence.
+func foldICS(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		n := len(string(r))
+		if width+n > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	return b.String()
+}
@@ -0,0 +1,438 @@
+package smtp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CampaignRecipient is one row of a recipient list.
+type CampaignRecipient struct {
+	Recipient
+	// Fields are the merge fields of the row. They are passed to the template together with "email" and "name".
+	Fields map[string]interface{}
+	// Line is the line of the row in the source, starting at 1.
+	Line int
+}
+
+// RecipientSource defines the methods that any streamed recipient list must implement. Next returns the next
+// row, or io.EOF after the last one. A *RowError reports an invalid row; Next may be called again to skip it.
+// Any other error ends the list.
+type RecipientSource interface {
+	Next() (CampaignRecipient, error)
+}
+
+// RowError is returned for a row of a recipient list that cannot be sent to.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("campaign error, invalid row at line %d; %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ColumnMapping maps the columns of a CSV file, or the keys of NDJSON objects, to recipients. Names are matched
+// case-insensitively.
+type ColumnMapping struct {
+	// Address is the column of the email address, "email" by default.
+	Address string
+	// Name is the column of the recipient name, "name" by default.
+	Name string
+	// Locale is the column of the recipient locale, "locale" by default.
+	Locale string
+	// Fields maps merge field names to columns. When nil, every other column is a merge field under its own name.
+	Fields map[string]string
+	// Required lists the merge fields that must not be empty.
+	Required []string
+	// Comma is the CSV field delimiter, ',' by default, e.g. ';' for spreadsheets exported in many European
+	// locales. NDJSON ignores it.
+	Comma rune
+}
+
+func (m ColumnMapping) withDefaults() ColumnMapping {
+	if m.Address == "" {
+		m.Address = "email"
+	}
+	if m.Name == "" {
+		m.Name = "name"
+	}
+	if m.Locale == "" {
+		m.Locale = "locale"
+	}
+	if m.Comma == 0 {
+		m.Comma = ','
+	}
+	return m
+}
+
+// recipient builds the recipient of a row from lookup, which returns the value of a column and whether the row
+// has it, and validates it.
+func (m ColumnMapping) recipient(line int, columns []string, lookup func(column string) (interface{}, bool)) (CampaignRecipient, error) {
+	text := func(column string) string {
+		v, _ := lookup(column)
+		if v == nil {
+			return ""
+		}
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+
+	r := CampaignRecipient{Line: line, Fields: map[string]interface{}{}}
+	r.Address, r.Name, r.Locale = text(m.Address), text(m.Name), text(m.Locale)
+
+	if m.Fields != nil {
+		for field, column := range m.Fields {
+			if v, ok := lookup(column); ok {
+				r.Fields[field] = v
+			}
+		}
+	} else {
+		for _, column := range columns {
+			if column == m.Address || column == m.Name || column == m.Locale {
+				continue
+			}
+			if v, ok := lookup(column); ok {
+				r.Fields[column] = v
+			}
+		}
+	}
+
+	if r.Address == "" {
+		return r, &RowError{Line: line, Err: fmt.Errorf("no address in column %s", m.Address)}
+	}
+	if parsed, err := mail.ParseAddress(r.Address); err != nil || parsed.Address != r.Address {
+		return r, &RowError{Line: line, Err: fmt.Errorf("%q is not a bare email address", r.Address)}
+	}
+	if problem := addressProblem(r.Address); problem != "" {
+		return r, &RowError{Line: line, Err: fmt.Errorf("address %q %s", r.Address, problem)}
+	}
+	for _, field := range m.Required {
+		if v, ok := r.Fields[field]; !ok || v == nil || v == "" {
+			return r, &RowError{Line: line, Err: fmt.Errorf("required field %s is empty", field)}
+		}
+	}
+	return r, nil
+}
+
+// CSVRecipients streams recipients from a CSV file whose first record is a header naming the columns.
+// Only the current record is held in memory.
+type CSVRecipients struct {
+	r       *csv.Reader
+	mapping ColumnMapping
+	// columns are the normalized header names; index maps them to their position
+	columns []string
+	index   map[string]int
+}
+
+// NewCSVRecipients initializes and returns a recipient source reading CSV from r. The header is read with the
+// first row.
+func NewCSVRecipients(r io.Reader, mapping ColumnMapping) *CSVRecipients {
+	mapping = mapping.withDefaults()
+	reader := csv.NewReader(r)
+	reader.Comma = mapping.Comma
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	return &CSVRecipients{r: reader, mapping: normalizeMapping(mapping)}
+}
+
+// Next returns the next recipient.
+func (s *CSVRecipients) Next() (CampaignRecipient, error) {
+	if s.index == nil {
+		if err := s.readHeader(); err != nil {
+			return CampaignRecipient{}, err
+		}
+	}
+
+	record, err := s.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return CampaignRecipient{Line: parseErr.StartLine}, &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+		}
+		if err == io.EOF {
+			return CampaignRecipient{}, err
+		}
+		return CampaignRecipient{}, fmt.Errorf("campaign error, failed to read csv; %w", err)
+	}
+	line, _ := s.r.FieldPos(0)
+
+	return s.mapping.recipient(line, s.columns, func(column string) (interface{}, bool) {
+		i, ok := s.index[column]
+		if !ok || i >= len(record) {
+			return nil, false
+		}
+		return strings.TrimSpace(record[i]), true
+	})
+}
+
+func (s *CSVRecipients) readHeader() error {
+	header, err := s.r.Read()
+	if err == io.EOF {
+		return fmt.Errorf("campaign error, csv has no header")
+	}
+	if err != nil {
+		return fmt.Errorf("campaign error, failed to read csv header; %w", err)
+	}
+
+	s.index = map[string]int{}
+	for i, column := range header {
+		if i == 0 {
+			// Spreadsheets often save UTF-8 with a byte order mark
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		column = normalizeColumn(column)
+		if _, ok := s.index[column]; ok {
+			return fmt.Errorf("campaign error, duplicate column %s in csv header", column)
+		}
+		s.index[column] = i
+		s.columns = append(s.columns, column)
+	}
+	if _, ok := s.index[s.mapping.Address]; !ok {
+		return fmt.Errorf("campaign error, no %s column in csv header", s.mapping.Address)
+	}
+	return nil
+}
+
+// NDJSONRecipients streams recipients from newline-delimited JSON, one object per line. Values keep their JSON
+// types, so numbers reach the template as float64. Only the current line is held in memory.
+type NDJSONRecipients struct {
+	r       *bufio.Reader
+	mapping ColumnMapping
+	line    int
+}
+
+// NewNDJSONRecipients initializes and returns a recipient source reading NDJSON from r.
+func NewNDJSONRecipients(r io.Reader, mapping ColumnMapping) *NDJSONRecipients {
+	return &NDJSONRecipients{r: bufio.NewReader(r), mapping: normalizeMapping(mapping.withDefaults())}
+}
+
+// Next returns the next recipient. Blank lines are skipped.
+func (s *NDJSONRecipients) Next() (CampaignRecipient, error) {
+	for {
+		data, err := s.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return CampaignRecipient{}, fmt.Errorf("campaign error, failed to read ndjson; %w", err)
+		}
+		if len(data) == 0 && err == io.EOF {
+			return CampaignRecipient{}, io.EOF
+		}
+		s.line++
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+
+		var object map[string]interface{}
+		if jsonErr := json.Unmarshal(data, &object); jsonErr != nil {
+			return CampaignRecipient{Line: s.line}, &RowError{Line: s.line, Err: jsonErr}
+		}
+
+		values := make(map[string]interface{}, len(object))
+		columns := make([]string, 0, len(object))
+		for key, v := range object {
+			key = normalizeColumn(key)
+			values[key] = v
+			columns = append(columns, key)
+		}
+		return s.mapping.recipient(s.line, columns, func(column string) (interface{}, bool) {
+			v, ok := values[column]
+			return v, ok
+		})
+	}
+}
+
+func normalizeColumn(column string) string {
+	return strings.ToLower(strings.TrimSpace(column))
+}
+
+func normalizeMapping(m ColumnMapping) ColumnMapping {
+	m.Address, m.Name, m.Locale = normalizeColumn(m.Address), normalizeColumn(m.Name), normalizeColumn(m.Locale)
+	if m.Fields != nil {
+		fields := make(map[string]string, len(m.Fields))
+		for field, column := range m.Fields {
+			fields[field] = normalizeColumn(column)
+		}
+		m.Fields = fields
+	} else {
+		// Merge fields are named after their normalized columns
+		required := make([]string, len(m.Required))
+		for i, field := range m.Required {
+			required[i] = normalizeColumn(field)
+		}
+		m.Required = required
+	}
+	return m
+}
+
+// Campaign describes a mail merge: one email per recipient, rendered from a registered template.
+type Campaign struct {
+	// Template is the name of the template rendered for every recipient.
+	Template string
+	// Email is the base of every email, e.g. with the From name, Profile, Headers, and Categories. To is set to
+	// the recipient, and Locale too when the row has one.
+	Email Email
+	// OnResult is called after every row with its outcome: err is nil when the email was sent, a *RowError
+	// when the row is invalid, or the send error.
+	OnResult func(recipient CampaignRecipient, result SendResult, err error)
+}
+
+// CampaignReport counts the outcomes of the rows of a campaign.
+type CampaignReport struct {
+	Sent    int
+	Invalid int
+	Failed  int
+}
+
+// SendCampaign reads the recipients from source one at a time and sends each its own rendered copy of the
+// campaign, reusing one session like SendMailMulti, so lists of any size are sent without holding them in memory.
+// Invalid rows and failed sends do not stop the campaign; they are counted and reported to OnResult. Merge
+// fields read as text are converted to the types the template's schema declares.
+//
+// The error is non-nil when the source fails or ctx is done; the report counts the rows handled until then.
+func (c *SMTP) SendCampaign(ctx context.Context, source RecipientSource, campaign Campaign) (CampaignReport, error) {
+	var report CampaignReport
+
+	if _, _, ok := c.template(campaign.Template, campaign.Email.Locale); !ok {
+		return report, fmt.Errorf("template error, unknown template %s", campaign.Template)
+	}
+
+	b := &batch{}
+	defer b.close()
+	ctx = context.WithValue(ctx, batchKey{}, b)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		recipient, err := source.Next()
+		if err == io.EOF {
+			return report, nil
+		}
+		var rowErr *RowError
+		if err != nil && !errors.As(err, &rowErr) {
+			return report, err
+		}
+
+		var result SendResult
+		if err == nil {
+			result, err = c.sendCampaignRow(ctx, campaign, recipient)
+			if err != nil && !errors.As(err, &rowErr) && ctx.Err() != nil {
+				// Interrupted rather than failed
+				return report, ctx.Err()
+			}
+		}
+
+		switch {
+		case err == nil:
+			report.Sent++
+		case errors.As(err, &rowErr):
+			report.Invalid++
+		default:
+			report.Failed++
+		}
+		if campaign.OnResult != nil {
+			campaign.OnResult(recipient, result, err)
+		}
+	}
+}
+
+// sendCampaignRow renders and sends the email of one recipient in the recipient's locale.
+func (c *SMTP) sendCampaignRow(ctx context.Context, campaign Campaign, recipient CampaignRecipient) (SendResult, error) {
+	email := campaign.Email
+	email.To, email.Cc, email.Bcc = []string{recipient.Address}, nil, nil
+	if recipient.Locale != "" {
+		email.Locale = recipient.Locale
+	}
+
+	// Translations may declare different schemas, so the fields are converted for the one that is rendered
+	tmpl, _, _ := c.template(campaign.Template, email.Locale)
+	params := make(map[string]interface{}, len(recipient.Fields)+2)
+	params["email"], params["name"] = recipient.Address, recipient.Name
+	for field, v := range recipient.Fields {
+		params[field] = v
+	}
+	if err := coerceParams(params, tmpl.Schema); err != nil {
+		return SendResult{}, &RowError{Line: recipient.Line, Err: err}
+	}
+	if err := c.RenderTemplate(&email, campaign.Template, params); err != nil {
+		return SendResult{}, &RowError{Line: recipient.Line, Err: err}
+	}
+	return c.SendMailResult(ctx, email)
+}
+
+// coerceParams converts the text and JSON number parameters to the types declared by schema.
+func coerceParams(params map[string]interface{}, schema map[string]Param) error {
+	for key, param := range schema {
+		v, ok := params[key]
+		if !ok {
+			continue
+		}
+
+		var err error
+		switch value := v.(type) {
+		case string:
+			if value == "" && param.Optional {
+				delete(params, key)
+				continue
+			}
+			params[key], err = parseParam(value, param.Type)
+		case float64:
+			if param.Type == ParamInt {
+				if value != math.Trunc(value) {
+					err = fmt.Errorf("%v is not an integer", value)
+				}
+				params[key] = int64(value)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("field %s; %w", key, err)
+		}
+	}
+	return nil
+}
+
+// parseParam parses text as a parameter of type t.
+func parseParam(text string, t ParamType) (interface{}, error) {
+	var (
+		v   interface{}
+		err error
+	)
+	switch t {
+	case ParamInt:
+		v, err = strconv.ParseInt(text, 10, 64)
+	case ParamFloat:
+		v, err = strconv.ParseFloat(text, 64)
+	case ParamBool:
+		v, err = strconv.ParseBool(text)
+	case ParamTime:
+		for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, text); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a date", text)
+	default:
+		return text, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a valid %s", text, t)
+	}
+	return v, nil
+}
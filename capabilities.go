@@ -0,0 +1,70 @@
+package smtp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Capabilities describes the extensions advertised by the server in its EHLO reply.
+type Capabilities struct {
+	// Extensions maps each upper-cased extension keyword to its parameters.
+	Extensions map[string]string
+	// StartTLS reports whether the server offered STARTTLS before the session was encrypted.
+	StartTLS bool
+	// Auth lists the supported authentication mechanisms.
+	Auth []string
+	// Size is the maximum message size in bytes, or 0 when the server does not declare one.
+	Size int64
+}
+
+// Has reports whether the server advertises the named extension.
+func (c Capabilities) Has(name string) bool {
+	_, ok := c.Extensions[strings.ToUpper(name)]
+	return ok
+}
+
+// Capabilities connects to the server and returns the extensions it advertises after STARTTLS.
+func (c *SMTP) Capabilities(ctx context.Context) (Capabilities, error) {
+	sess, err := c.connect(ctx, c.endpoints())
+	if err != nil {
+		return Capabilities{}, err
+	}
+	client := sess.client
+	defer client.Close()
+
+	_, reply, err := command(client, 250, "EHLO %s", "localhost")
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("client error, failed to read capabilities; %w", err)
+	}
+
+	caps := parseCapabilities(reply)
+	caps.StartTLS = sess.startTLS
+
+	client.Quit()
+	return caps, nil
+}
+
+// parseCapabilities parses the text of an EHLO reply; the first line is the server greeting.
+func parseCapabilities(reply string) Capabilities {
+	caps := Capabilities{Extensions: map[string]string{}}
+
+	lines := strings.Split(reply, "\n")
+	for _, line := range lines[1:] {
+		keyword, params, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if keyword == "" {
+			continue
+		}
+		caps.Extensions[strings.ToUpper(keyword)] = params
+	}
+
+	if auth, ok := caps.Extensions["AUTH"]; ok {
+		caps.Auth = strings.Fields(auth)
+	}
+	if size, ok := caps.Extensions["SIZE"]; ok {
+		caps.Size, _ = strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+	}
+
+	return caps
+}
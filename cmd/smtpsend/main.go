@@ -0,0 +1,125 @@
+// Command smtpsend sends a single email through a relay, for testing relays from a shell.
+//
+// Usage:
+//
+//	smtpsend -host smtp.example.com -from me@example.com -to you@example.com -subject hi -body "hello"
+//
+// The connection settings default to the environment variables read by smtp.ConfigFromEnv. -verbose prints the SMTP transcript, with credentials masked.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dexterdmonkey/go-smtp"
+)
+
+// listFlag collects a repeatable flag; each value may also be a comma-separated list.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "smtpsend:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stderr io.Writer) error {
+	fs := flag.NewFlagSet("smtpsend", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	cfg, err := smtp.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = smtp.Duration(30 * time.Second)
+	}
+
+	fs.StringVar(&cfg.Host, "host", cfg.Host, "relay host, or unix:///path for a socket [$SMTP_HOST]")
+	fs.IntVar(&cfg.Port, "port", cfg.Port, "relay port, 587 or 465 for implicit TLS by default [$SMTP_PORT]")
+	fs.StringVar(&cfg.From, "from", cfg.From, "sender address [$SMTP_FROM]")
+	fs.StringVar(&cfg.EnvelopeFrom, "envelope-from", cfg.EnvelopeFrom, "MAIL FROM address for bounces, the sender address by default [$SMTP_ENVELOPE_FROM]")
+	fs.StringVar(&cfg.Username, "username", cfg.Username, "username, the sender address by default [$SMTP_USERNAME]")
+	fs.Func("password", "password [$SMTP_PASSWORD]", func(s string) error { return cfg.Password.UnmarshalText([]byte(s)) })
+	fs.BoolVar(&cfg.Anonymous, "anonymous", cfg.Anonymous, "never authenticate, for relays that trust this host [$SMTP_ANONYMOUS]")
+	fs.TextVar(&cfg.Auth, "auth", cfg.Auth, "authentication mechanism, plain or ntlm with a DOMAIN\\user username [$SMTP_AUTH]")
+	fs.TextVar(&cfg.TLSMode, "tls", cfg.TLSMode, "starttls, implicit, opportunistic, or none [$SMTP_TLS]")
+	fs.TextVar(&cfg.Timeout, "timeout", cfg.Timeout, "time limit for the whole send [$SMTP_TIMEOUT]")
+
+	var to, cc, bcc, attach listFlag
+	fs.Var(&to, "to", "recipient, repeatable or comma-separated")
+	fs.Var(&cc, "cc", "carbon copy recipient, repeatable or comma-separated")
+	fs.Var(&bcc, "bcc", "blind carbon copy recipient, repeatable or comma-separated")
+	fs.Var(&attach, "attach", "file to attach, repeatable")
+	subject := fs.String("subject", "smtpsend test", "subject")
+	body := fs.String("body", "", `plain text body; "-" reads it from stdin`)
+	html := fs.String("html", "", "HTML body")
+	verbose := fs.Bool("verbose", false, "print the SMTP transcript and debug logs")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(to)+len(cc)+len(bcc) == 0 {
+		return errors.New("at least one -to, -cc, or -bcc recipient is required")
+	}
+
+	if *body == "-" {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read body from stdin; %w", err)
+		}
+		*body = string(data)
+	}
+
+	email := smtp.Email{To: to, Cc: cc, Bcc: bcc, Subject: *subject, Body: *body, HTMLBody: *html}
+	for _, path := range attach {
+		a, err := smtp.AttachFile(path)
+		if err != nil {
+			return err
+		}
+		email.Attachments = append(email.Attachments, a)
+	}
+
+	var opts []smtp.Option
+	if *verbose {
+		opts = append(opts,
+			smtp.WithLogger(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+			smtp.WithTranscript(func(ctx context.Context, email smtp.Email, transcript smtp.Transcript, err error) {
+				fmt.Fprintln(stderr, transcript)
+			}),
+		)
+	}
+
+	client, err := smtp.NewFromConfig(cfg, opts...)
+	if err != nil {
+		return err
+	}
+
+	result, err := client.SendMailResult(context.Background(), email)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "sent %s to %s\n", result.MessageID, strings.Join(result.Recipients, ", "))
+	return nil
+}
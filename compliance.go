@@ -0,0 +1,247 @@
+package smtp
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+)
+
+// maxLineLength is the hard line length limit of RFC 5322 section 2.1.1, excluding CRLF.
+const maxLineLength = 998
+
+// singleHeaders lists header fields that RFC 5322 section 3.6 allows at most once.
+var singleHeaders = []string{"Date", "From", "Sender", "Reply-To", "To", "Cc", "Bcc", "Message-ID", "In-Reply-To", "References", "Subject"}
+
+// Violation describes a single compliance problem found in a message.
+type Violation struct {
+	// Line is the 1-based line number within the message, or 0 when the problem is not tied to a line.
+	Line    int
+	Rule    string
+	Message string
+}
+
+// String returns a human readable description of the violation.
+func (v Violation) String() string {
+	if v.Line == 0 {
+		return v.Rule + ": " + v.Message
+	}
+	return fmt.Sprintf("line %d: %s: %s", v.Line, v.Rule, v.Message)
+}
+
+// ComplianceError is returned when a message violates RFC 5322 or RFC 2045 rules.
+type ComplianceError struct {
+	Violations []Violation
+}
+
+// Error returns every violation on its own line.
+func (e *ComplianceError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		lines[i] = v.String()
+	}
+	return "compliance error, message violates rfc rules;\n" + strings.Join(lines, "\n")
+}
+
+// ValidateMessage checks a serialized message against RFC 5322 and RFC 2045 rules.
+// Non-ASCII header text is only accepted when allowUTF8 is true, as permitted by SMTPUTF8.
+func ValidateMessage(data []byte, allowUTF8 bool) error {
+	var violations []Violation
+	add := func(line int, rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Line: line, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	data = normalizeCRLF(data)
+	lines := strings.Split(string(data), "\r\n")
+	for i, line := range lines {
+		if len(line) > maxLineLength {
+			add(i+1, "line-length", "line is %d characters long, the limit is %d", len(line), maxLineLength)
+		}
+	}
+
+	header, bodyStart, ok := parseHeaderLines(lines, 1, add)
+	if !ok {
+		add(0, "structure", "message has no empty line separating header and body")
+		return &ComplianceError{Violations: violations}
+	}
+
+	for _, f := range header {
+		if !allowUTF8 && !isASCII(f.value) {
+			add(f.line, "header-encoding", "%s contains non-ASCII text without an encoded-word", f.name)
+		}
+	}
+
+	counts := map[string]int{}
+	for _, f := range header {
+		counts[strings.ToLower(f.name)]++
+	}
+	for _, required := range []string{"Date", "From"} {
+		if counts[strings.ToLower(required)] == 0 {
+			add(0, "required-header", "%s header is missing", required)
+		}
+	}
+	for _, name := range singleHeaders {
+		if n := counts[strings.ToLower(name)]; n > 1 {
+			add(0, "duplicate-header", "%s header appears %d times", name, n)
+		}
+	}
+
+	if counts["content-type"] != 0 && counts["mime-version"] == 0 {
+		add(0, "mime-version", "Content-Type is set but MIME-Version is missing")
+	}
+
+	body := strings.Join(lines[bodyStart-1:], "\r\n")
+	validateEntity(header, body, bodyStart, add)
+
+	if len(violations) != 0 {
+		return &ComplianceError{Violations: violations}
+	}
+	return nil
+}
+
+// headerLine is a parsed header field with the line it starts on.
+type headerLine struct {
+	name  string
+	value string
+	line  int
+}
+
+// parseHeaderLines parses the header block starting at lines[0], which is line number first.
+// It returns the fields and the line number at which the body starts.
+func parseHeaderLines(lines []string, first int, add func(int, string, string, ...interface{})) ([]headerLine, int, bool) {
+	var fields []headerLine
+	for i, line := range lines {
+		lineNo := first + i
+		if line == "" {
+			return fields, lineNo + 1, true
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(fields) == 0 {
+				add(lineNo, "header-syntax", "continuation line without a preceding field")
+				continue
+			}
+			fields[len(fields)-1].value += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found || !validFieldName(name) {
+			add(lineNo, "header-syntax", "invalid header field %q", line)
+			continue
+		}
+		fields = append(fields, headerLine{name: name, value: strings.TrimSpace(value), line: lineNo})
+	}
+	return fields, 0, false
+}
+
+// validFieldName reports whether name only contains printable ASCII other than colon.
+func validFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < 33 || name[i] > 126 || name[i] == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// validateEntity checks the encoding declarations and multipart structure of a MIME entity.
+func validateEntity(header []headerLine, body string, bodyStart int, add func(int, string, string, ...interface{})) {
+	get := func(name string) (string, int) {
+		for _, f := range header {
+			if strings.EqualFold(f.name, name) {
+				return f.value, f.line
+			}
+		}
+		return "", 0
+	}
+
+	encoding, encodingLine := get("Content-Transfer-Encoding")
+	encoding = strings.ToLower(encoding)
+	switch encoding {
+	case "", "7bit", "8bit", "binary", "quoted-printable", "base64":
+	default:
+		add(encodingLine, "transfer-encoding", "unknown Content-Transfer-Encoding %q", encoding)
+	}
+
+	if !isASCII(body) && encoding != "8bit" && encoding != "binary" {
+		add(bodyStart, "transfer-encoding", "body contains 8-bit data but Content-Transfer-Encoding is %q", encodingOrDefault(encoding))
+	}
+
+	if encoding == "base64" || encoding == "quoted-printable" {
+		for i, line := range strings.Split(body, "\r\n") {
+			if len(line) > 76 {
+				add(bodyStart+i, "line-length", "%s line is %d characters long, the limit is 76", encoding, len(line))
+			}
+		}
+	}
+
+	contentType, typeLine := get("Content-Type")
+	if contentType == "" {
+		return
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		add(typeLine, "content-type", "invalid Content-Type; %s", err.Error())
+		return
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return
+	}
+
+	boundary := params["boundary"]
+	if boundary == "" {
+		add(typeLine, "boundary", "%s has no boundary parameter", mediaType)
+		return
+	}
+	if len(boundary) > 70 {
+		add(typeLine, "boundary", "boundary is %d characters long, the limit is 70", len(boundary))
+	}
+	if !strings.Contains(body, "--"+boundary+"\r\n") {
+		add(bodyStart, "boundary", "no part starts with boundary %q", boundary)
+		return
+	}
+	if !strings.Contains(body, "--"+boundary+"--") {
+		add(bodyStart, "boundary", "closing boundary %q is missing", boundary)
+		return
+	}
+
+	r := multipart.NewReader(strings.NewReader(body), boundary)
+	for {
+		part, err := r.NextRawPart()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			add(bodyStart, "multipart", "failed to read part; %s", err.Error())
+			return
+		}
+
+		content, err := io.ReadAll(part)
+		if err != nil {
+			add(bodyStart, "multipart", "failed to read part; %s", err.Error())
+			return
+		}
+
+		var partHeader []headerLine
+		for name, values := range part.Header {
+			for _, value := range values {
+				partHeader = append(partHeader, headerLine{name: name, value: value, line: bodyStart})
+			}
+		}
+		validateEntity(partHeader, string(content), bodyStart, add)
+	}
+}
+
+// encodingOrDefault returns the effective transfer encoding name.
+func encodingOrDefault(encoding string) string {
+	if encoding == "" {
+		return "7bit"
+	}
+	return encoding
+}
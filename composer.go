@@ -0,0 +1,213 @@
+package smtp
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// Composer builds an email step by step and validates every step: recipients must be bare addresses or
+// resolver identifiers, and header fields must be well formed. The first error encountered is kept and returned
+// by Build and Send; later steps are still recorded.
+type Composer struct {
+	client *SMTP
+	email  Email
+	err    error
+}
+
+// Compose starts a new email bound to the client, which Send sends it through and Template renders with.
+func (c *SMTP) Compose() *Composer {
+	return &Composer{client: c}
+}
+
+// NewEmail starts a new email that is not bound to a client. Build returns it ready for SendMail on any client.
+func NewEmail() *Composer {
+	return &Composer{}
+}
+
+// fail keeps err when it is the first error.
+func (m *Composer) fail(err error) {
+	if m.err == nil {
+		m.err = err
+	}
+}
+
+// recipients validates addrs and appends them to list.
+func (m *Composer) recipients(list *[]string, field string, addrs []string) *Composer {
+	for _, addr := range addrs {
+		addr = strings.TrimSpace(addr)
+		if !isIdentifier(addr) {
+			if parsed, err := mail.ParseAddress(addr); err != nil || parsed.Address != addr {
+				m.fail(fmt.Errorf("compose error, invalid %s address %q", field, addr))
+				continue
+			}
+		}
+		*list = append(*list, addr)
+	}
+	return m
+}
+
+// To adds recipients to the To header.
+func (m *Composer) To(addrs ...string) *Composer {
+	return m.recipients(&m.email.To, "to", addrs)
+}
+
+// Cc adds recipients to the Cc header.
+func (m *Composer) Cc(addrs ...string) *Composer {
+	return m.recipients(&m.email.Cc, "cc", addrs)
+}
+
+// Bcc adds blind carbon copy recipients.
+func (m *Composer) Bcc(addrs ...string) *Composer {
+	return m.recipients(&m.email.Bcc, "bcc", addrs)
+}
+
+// Subject sets the subject.
+func (m *Composer) Subject(subject string) *Composer {
+	m.email.Subject = subject
+	return m
+}
+
+// Body sets the plain text body.
+func (m *Composer) Body(body string) *Composer {
+	m.email.Body = body
+	return m
+}
+
+// HTML sets the HTML body, sent as an alternative to the plain text body.
+func (m *Composer) HTML(html string) *Composer {
+	m.email.HTMLBody = html
+	return m
+}
+
+// InlineCSS moves the rules of the <style> blocks of the HTML body into style attributes, see Email.InlineCSS.
+func (m *Composer) InlineCSS() *Composer {
+	m.email.InlineCSS = true
+	return m
+}
+
+// FromName sets the display name of the From header.
+func (m *Composer) FromName(name string) *Composer {
+	m.email.FromName = name
+	return m
+}
+
+// Categories adds categories for the X-Categories header.
+func (m *Composer) Categories(categories ...string) *Composer {
+	m.email.Categories = append(m.email.Categories, categories...)
+	return m
+}
+
+// Priority sets the priority of the email.
+func (m *Composer) Priority(priority Priority) *Composer {
+	m.email.Priority = priority
+	return m
+}
+
+// IdempotencyKey sets the key that keeps application retries from sending the email twice, see Email.IdempotencyKey.
+func (m *Composer) IdempotencyKey(key string) *Composer {
+	m.email.IdempotencyKey = key
+	return m
+}
+
+// Header sets a custom header field.
+func (m *Composer) Header(name, value string) *Composer {
+	if !validFieldName(name) {
+		m.fail(fmt.Errorf("compose error, invalid header name %q", name))
+		return m
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		m.fail(fmt.Errorf("compose error, header %s must not contain line breaks", name))
+		return m
+	}
+	if m.email.Headers == nil {
+		m.email.Headers = map[string]string{}
+	}
+	m.email.Headers[name] = value
+	return m
+}
+
+// Profile applies a send profile such as ProfileOTP.
+func (m *Composer) Profile(profile Profile) *Composer {
+	m.email.Profile = &profile
+	return m
+}
+
+// Template renders the subject and body from a template registered on the client.
+func (m *Composer) Template(name string, parameters map[string]interface{}) *Composer {
+	if m.client == nil {
+		m.fail(fmt.Errorf("compose error, template %s needs a client; start with SMTP.Compose", name))
+		return m
+	}
+	if m.err == nil {
+		m.err = m.client.RenderTemplate(&m.email, name, parameters)
+	}
+	return m
+}
+
+// Attach adds an attachment with the given content.
+func (m *Composer) Attach(filename string, data []byte) *Composer {
+	if strings.TrimSpace(filename) == "" {
+		m.fail(fmt.Errorf("compose error, attachment without a filename"))
+		return m
+	}
+	m.email.Attachments = append(m.email.Attachments, Attachment{Filename: filename, Data: data})
+	return m
+}
+
+// AttachFile adds the file at path as an attachment that is streamed from disk, see AttachFile.
+func (m *Composer) AttachFile(path string) *Composer {
+	a, err := AttachFile(path)
+	if err != nil {
+		m.fail(err)
+		return m
+	}
+	m.email.Attachments = append(m.email.Attachments, a)
+	return m
+}
+
+// Invite adds a meeting invitation for the event.
+func (m *Composer) Invite(event Event) *Composer {
+	calendar := event.Invite()
+	m.email.Calendar = &calendar
+	return m
+}
+
+// ListUnsubscribe sets the List-Unsubscribe headers, see Email.SetListUnsubscribe.
+func (m *Composer) ListUnsubscribe(mailto, httpsURL string) *Composer {
+	if err := m.email.SetListUnsubscribe(mailto, httpsURL); err != nil {
+		m.fail(err)
+	}
+	return m
+}
+
+// Reply makes the email a reply to the message with the given Message-ID and References, see Email.Reply.
+func (m *Composer) Reply(messageID string, references ...string) *Composer {
+	m.email.Reply(messageID, references...)
+	return m
+}
+
+// Email returns the email composed so far.
+func (m *Composer) Email() (Email, error) {
+	return m.email, m.err
+}
+
+// Build returns the composed email once it also passes Email.Validate, e.g. has a recipient, subject, and body.
+func (m *Composer) Build() (Email, error) {
+	if m.err != nil {
+		return m.email, m.err
+	}
+	return m.email, m.email.Validate()
+}
+
+// Send sends the composed email through the client.
+func (m *Composer) Send(ctx context.Context) error {
+	if m.err != nil {
+		return m.err
+	}
+	if m.client == nil {
+		return fmt.Errorf("compose error, no client to send with; start with SMTP.Compose or pass Build to SendMail")
+	}
+	return m.client.SendMailContext(ctx, m.email)
+}
@@ -0,0 +1,183 @@
+package smtp_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dexterdmonkey/go-smtp"
+	"github.com/dexterdmonkey/go-smtp/smtptest"
+)
+
+// These tests share one client between goroutines; run them with go test -race.
+
+const (
+	workers   = 8
+	perWorker = 5
+)
+
+func newConcurrentClient(t *testing.T, opts ...smtp.Option) (*smtp.SMTP, *smtptest.Server) {
+	t.Helper()
+
+	srv := smtptest.NewServer()
+	t.Cleanup(srv.Close)
+
+	mail, err := smtp.New("app@example.com", "secret", srv.Host(), srv.Port(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mail.Close() })
+	return mail, srv
+}
+
+// assertReceived checks that every subject was received exactly once.
+func assertReceived(t *testing.T, srv *smtptest.Server, subjects []string) {
+	t.Helper()
+
+	messages, err := srv.Wait(len(subjects), 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != len(subjects) {
+		t.Fatalf("received %d messages, want %d", len(messages), len(subjects))
+	}
+
+	received := map[string]int{}
+	for _, msg := range messages {
+		email, err := smtp.ParseMessage(msg.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		received[email.Subject]++
+	}
+	for _, subject := range subjects {
+		if received[subject] != 1 {
+			t.Errorf("%q received %d times, want once", subject, received[subject])
+		}
+	}
+}
+
+func TestConcurrentSendMail(t *testing.T) {
+	mail, srv := newConcurrentClient(t)
+
+	var subjects []string
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker)
+	for w := 0; w < workers; w++ {
+		for i := 0; i < perWorker; i++ {
+			subjects = append(subjects, fmt.Sprintf("worker %d mail %d", w, i))
+		}
+
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				email := smtp.Email{To: []string{"user@example.com"}, Subject: fmt.Sprintf("worker %d mail %d", w, i), Body: "Hi"}
+				if err := mail.SendMail(email); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	assertReceived(t, srv, subjects)
+}
+
+func TestConcurrentSendMailMulti(t *testing.T) {
+	mail, srv := newConcurrentClient(t, smtp.WithPool(smtp.PoolConfig{MaxIdle: 2}))
+
+	var subjects []string
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		emails := make([]smtp.Email, perWorker)
+		for i := range emails {
+			emails[i] = smtp.Email{To: []string{"user@example.com"}, Subject: fmt.Sprintf("batch %d mail %d", w, i), Body: "Hi"}
+			subjects = append(subjects, emails[i].Subject)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, err := mail.SendMailMulti(context.Background(), emails)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if len(results) != len(emails) {
+				errs <- fmt.Errorf("got %d results for %d emails", len(results), len(emails))
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	assertReceived(t, srv, subjects)
+}
+
+func TestConcurrentPool(t *testing.T) {
+	mail, srv := newConcurrentClient(t, smtp.WithPool(smtp.PoolConfig{
+		MaxIdle:     2,
+		IdleTimeout: 20 * time.Millisecond,
+		KeepAlive:   5 * time.Millisecond,
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Settings and metrics are read and changed while sessions are taken from and returned to the pool
+	var background sync.WaitGroup
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		for ctx.Err() == nil {
+			if err := mail.ApplyConfig(smtp.RuntimeConfig{DomainInterval: 0}); err != nil {
+				t.Error(err)
+				return
+			}
+			mail.Metrics()
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	var subjects []string
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker)
+	for w := 0; w < workers; w++ {
+		for i := 0; i < perWorker; i++ {
+			subjects = append(subjects, fmt.Sprintf("pooled %d mail %d", w, i))
+		}
+
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				email := smtp.Email{To: []string{"user@example.com"}, Subject: fmt.Sprintf("pooled %d mail %d", w, i), Body: "Hi"}
+				if err := mail.SendMailContext(ctx, email); err != nil {
+					errs <- err
+				}
+				// Let some sessions idle long enough to be probed or closed by the pool
+				time.Sleep(time.Duration(w) * 3 * time.Millisecond)
+			}
+		}(w)
+	}
+	wg.Wait()
+	cancel()
+	background.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	assertReceived(t, srv, subjects)
+}
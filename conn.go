@@ -0,0 +1,123 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/smtp"
+)
+
+// Conn is an authenticated session with the server, for flows that SendMail does not cover.
+// Each method performs a single protocol step; the caller is responsible for the order of the steps.
+// A Conn is not safe for concurrent use.
+type Conn struct {
+	sess *session
+}
+
+// Dial connects to the first reachable host, starts TLS, and authenticates like SendMail, and returns the session.
+// The deadline of ctx, if any, bounds the whole session. The caller must Quit or Close the connection.
+func (c *SMTP) Dial(ctx context.Context) (*Conn, error) {
+	sess, err := c.dial(ctx, c.endpoints())
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{sess: sess}, nil
+}
+
+// Client returns the underlying net/smtp client.
+func (c *Conn) Client() *smtp.Client {
+	return c.sess.client
+}
+
+// Host returns the host name of the server the session is connected to.
+func (c *Conn) Host() string {
+	return c.sess.host
+}
+
+// TLSConnectionState returns the state of the TLS connection, if any.
+func (c *Conn) TLSConnectionState() (tls.ConnectionState, bool) {
+	return c.sess.client.TLSConnectionState()
+}
+
+// Extension reports whether the server advertises the named extension and returns its parameters.
+func (c *Conn) Extension(name string) (bool, string) {
+	return c.sess.client.Extension(name)
+}
+
+// Capabilities sends EHLO again and returns every extension the server advertises.
+// It must not be called during a mail transaction, since EHLO resets it.
+func (c *Conn) Capabilities() (Capabilities, error) {
+	_, reply, err := command(c.sess.client, 250, "EHLO %s", "localhost")
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("client error, failed to read capabilities; %w", err)
+	}
+
+	caps := parseCapabilities(reply)
+	caps.StartTLS = c.sess.startTLS
+	return caps, nil
+}
+
+// Command sends a raw command and reads the reply, expecting the given status code. An expected code
+// with fewer digits matches by prefix, e.g. 2 accepts any 2xx reply.
+func (c *Conn) Command(expectCode int, format string, args ...interface{}) (int, string, error) {
+	return command(c.sess.client, expectCode, format, args...)
+}
+
+// Mail starts a transaction with MAIL FROM and the given extension parameters, e.g. "BODY=8BITMIME".
+func (c *Conn) Mail(from string, params ...string) error {
+	return mailFrom(c.sess.client, from, params)
+}
+
+// Rcpt adds a recipient with RCPT TO and the given extension parameters, e.g. "NOTIFY=FAILURE".
+func (c *Conn) Rcpt(to string, params ...string) error {
+	return rcptTo(c.sess.client, to, params)
+}
+
+// Data transfers the message with DATA and returns the server's reply to it.
+func (c *Conn) Data(msg []byte) error {
+	w, err := c.sess.client.Data()
+	if err != nil {
+		return fmt.Errorf("send error, failed to create data; %w", err)
+	}
+	if _, err = w.Write(msg); err != nil {
+		w.Close()
+		return fmt.Errorf("send error, failed to write data; %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("send error, failed to close email writer; %w", err)
+	}
+	return nil
+}
+
+// Bdat transfers the message with BDAT in chunks of the given size, or 1 MiB when chunkSize is zero.
+// The server must advertise CHUNKING.
+func (c *Conn) Bdat(msg []byte, chunkSize int) error {
+	_, err := bdat(c.sess.client, bytes.NewReader(normalizeCRLF(msg)), chunkSize)
+	return err
+}
+
+// Reset aborts the current transaction with RSET.
+func (c *Conn) Reset() error {
+	return c.sess.client.Reset()
+}
+
+// Noop sends NOOP, e.g. to keep the session alive.
+func (c *Conn) Noop() error {
+	return c.sess.client.Noop()
+}
+
+// Verify asks the server to verify an address with VRFY. Many servers decline to answer.
+func (c *Conn) Verify(addr string) error {
+	return c.sess.client.Verify(addr)
+}
+
+// Quit sends QUIT and closes the connection.
+func (c *Conn) Quit() error {
+	return c.sess.client.Quit()
+}
+
+// Close closes the connection without sending QUIT.
+func (c *Conn) Close() error {
+	return c.sess.client.Close()
+}
@@ -0,0 +1,84 @@
+package smtp
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// CredentialsProvider returns the current username and password, e.g. from a secret store that rotates them.
+// It is called concurrently when several sends open sessions at the same time.
+type CredentialsProvider func(ctx context.Context) (username, password string, err error)
+
+// sessionAuth returns the authentication for a new session with host, asking the credentials provider when one
+// is configured. PLAIN auth only proceeds when host is the server the session is connected to, so a session
+// with a fallback endpoint or relay authenticates for that host rather than the configured one.
+func (c *SMTP) sessionAuth(ctx context.Context, host string) (smtp.Auth, error) {
+	if c.credentials == nil {
+		if host == c.host {
+			return c.auth, nil
+		}
+		return c.newAuth(c.username, c.password.Reveal(), host), nil
+	}
+
+	username, password, err := c.credentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("auth error, failed to get credentials; %w", err)
+	}
+	return c.newAuth(username, password, host), nil
+}
+
+// newAuth returns the authentication of the configured mechanism for the credentials with host.
+func (c *SMTP) newAuth(username, password, host string) smtp.Auth {
+	if c.mechanism == AuthNTLM {
+		return NTLMAuth(username, password)
+	}
+	return smtp.PlainAuth("", username, password, host)
+}
+
+// AuthMechanism selects how sessions authenticate with the relay.
+type AuthMechanism int
+
+// Authentication mechanisms.
+const (
+	// AuthPlain sends the username and password with the PLAIN mechanism, only over TLS or to localhost.
+	AuthPlain AuthMechanism = iota
+	// AuthNTLM authenticates with NTLMv2, e.g. with an on-premises Exchange server. The username is
+	// "DOMAIN\user" or a user principal name, see NTLMAuth.
+	AuthNTLM
+)
+
+// String returns the name of the mechanism as accepted by ParseAuthMechanism.
+func (m AuthMechanism) String() string {
+	if m == AuthNTLM {
+		return "ntlm"
+	}
+	return "plain"
+}
+
+// MarshalText implements encoding.TextMarshaler so that mechanisms read and write as their names in JSON and YAML.
+func (m AuthMechanism) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using ParseAuthMechanism.
+func (m *AuthMechanism) UnmarshalText(text []byte) error {
+	mechanism, err := ParseAuthMechanism(string(text))
+	if err != nil {
+		return err
+	}
+	*m = mechanism
+	return nil
+}
+
+// ParseAuthMechanism parses "plain" or "ntlm", ignoring case.
+func ParseAuthMechanism(s string) (AuthMechanism, error) {
+	switch strings.ToLower(s) {
+	case "", "plain":
+		return AuthPlain, nil
+	case "ntlm":
+		return AuthNTLM, nil
+	}
+	return AuthPlain, fmt.Errorf("config error, unknown auth mechanism %q", s)
+}
@@ -0,0 +1,388 @@
+package smtp
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	cssStyleBlock = regexp.MustCompile(`(?is)<style\b([^>]*)>(.*?)</style\s*>`)
+	cssComment    = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssCompound   = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9-]*|\*)?((?:[.#][a-zA-Z0-9_-]+)*)$`)
+	cssSimple     = regexp.MustCompile(`[.#][^.#]+`)
+	cssStyleAttr  = regexp.MustCompile(`(?is)\sstyle\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	htmlAnyTag    = regexp.MustCompile(`(?is)<!--.*?-->|<(/?)([a-z][a-z0-9]*)\b([^>]*)>`)
+)
+
+// cssAttrEscaper escapes a style for a double-quoted attribute.
+var cssAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "'")
+
+// voidElements never have a closing tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// unstyledElements are not rendered, so rules are never inlined into them.
+var unstyledElements = map[string]bool{
+	"head": true, "title": true, "meta": true, "link": true, "style": true, "script": true, "base": true,
+}
+
+// cssDecl is a single property declaration with the position of its rule in the cascade.
+type cssDecl struct {
+	property, value string
+	important       bool
+	specificity     [3]int
+	order           int
+}
+
+// cssCompoundSelector matches a single element, e.g. "td.header#top".
+type cssCompoundSelector struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+// cssSelector is a chain of compound selectors joined by descendant (' ') or child ('>') combinators,
+// stored from the subject element leftwards.
+type cssSelector struct {
+	steps       []cssCompoundSelector
+	combinators []byte
+	specificity [3]int
+}
+
+// cssRule is a rule that can be inlined: one selector and its declarations.
+type cssRule struct {
+	selector cssSelector
+	decls    []cssDecl
+}
+
+// htmlElement is an open element while the document is scanned.
+type htmlElement struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+// InlineCSS moves the rules of the <style> blocks in an HTML document into the style attributes of the elements
+// they match, for mail clients such as Gmail that drop <style> blocks. Type, class, and ID selectors combined with
+// descendant and child combinators are inlined following the cascade: specificity, then source order, then existing
+// style attributes, which only !important rules override. Rules that cannot be inlined, such as @media queries and
+// :hover, stay in a <style> block; style blocks for a media type other than screen are left alone.
+func InlineCSS(document string) string {
+	var rules []cssRule
+	order := 0
+	document = cssStyleBlock.ReplaceAllStringFunc(document, func(block string) string {
+		m := cssStyleBlock.FindStringSubmatch(block)
+		if media := strings.ToLower(strings.TrimSpace(parseAttrs(m[1])["media"])); media != "" && media != "all" && media != "screen" {
+			return block
+		}
+
+		inlined, kept := parseStylesheet(m[2], &order)
+		rules = append(rules, inlined...)
+		if kept == "" {
+			return ""
+		}
+		return "<style" + m[1] + ">\n" + kept + "\n</style>"
+	})
+	if len(rules) == 0 {
+		return document
+	}
+
+	var stack []htmlElement
+	return htmlAnyTag.ReplaceAllStringFunc(document, func(tag string) string {
+		m := htmlAnyTag.FindStringSubmatch(tag)
+		if m[2] == "" {
+			return tag
+		}
+		name := strings.ToLower(m[2])
+
+		if m[1] == "/" {
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].tag == name {
+					stack = stack[:i]
+					break
+				}
+			}
+			return tag
+		}
+
+		attrs := parseAttrs(m[3])
+		el := htmlElement{tag: name, id: attrs["id"], classes: strings.Fields(attrs["class"])}
+		path := append(stack, el)
+		if !voidElements[name] && !strings.HasSuffix(strings.TrimSpace(m[3]), "/") {
+			stack = path
+		}
+		if unstyledElements[name] {
+			return tag
+		}
+
+		var decls []cssDecl
+		for _, rule := range rules {
+			if rule.selector.matches(path) {
+				decls = append(decls, rule.decls...)
+			}
+		}
+		if len(decls) == 0 {
+			return tag
+		}
+		if style, ok := attrs["style"]; ok {
+			for _, d := range parseDeclarations(html.UnescapeString(style)) {
+				// Inline declarations beat every selector and lose only to !important rules
+				d.specificity = [3]int{1 << 20}
+				d.order = order
+				decls = append(decls, d)
+			}
+		}
+
+		rest := strings.TrimRight(cssStyleAttr.ReplaceAllString(m[3], ""), " \t\r\n")
+		closing := ""
+		if strings.HasSuffix(rest, "/") {
+			rest, closing = strings.TrimRight(strings.TrimSuffix(rest, "/"), " \t\r\n"), " /"
+		}
+		// Quoted font names use single quotes, which survive the attribute without entities
+		style := cssAttrEscaper.Replace(cascade(decls))
+		return "<" + m[2] + rest + ` style="` + style + `"` + closing + ">"
+	})
+}
+
+// parseStylesheet returns the rules of css that can be inlined and the text of those that cannot.
+// order numbers the declarations across all style blocks of a document.
+func parseStylesheet(css string, order *int) ([]cssRule, string) {
+	css = cssComment.ReplaceAllString(css, "")
+
+	var rules []cssRule
+	var kept []string
+	for {
+		css = strings.TrimSpace(css)
+		if css == "" {
+			break
+		}
+
+		open := strings.IndexByte(css, '{')
+		if strings.HasPrefix(css, "@") {
+			// At-rules such as @import end at a semicolon, blocks such as @media at their matching brace
+			if semi := strings.IndexByte(css, ';'); semi >= 0 && (open < 0 || semi < open) {
+				kept = append(kept, css[:semi+1])
+				css = css[semi+1:]
+				continue
+			}
+		}
+		if open < 0 {
+			break
+		}
+
+		end := matchingBrace(css, open)
+		if end < 0 {
+			kept = append(kept, css)
+			break
+		}
+		prelude, body := strings.TrimSpace(css[:open]), css[open+1:end]
+		block := css[:end+1]
+		css = css[end+1:]
+
+		if strings.HasPrefix(prelude, "@") {
+			kept = append(kept, block)
+			continue
+		}
+
+		decls := parseDeclarations(body)
+		var unsupported []string
+		for _, text := range strings.Split(prelude, ",") {
+			sel, ok := parseSelector(text)
+			if !ok {
+				unsupported = append(unsupported, strings.TrimSpace(text))
+				continue
+			}
+			rule := cssRule{selector: sel}
+			for _, d := range decls {
+				d.specificity = sel.specificity
+				d.order = *order
+				*order++
+				rule.decls = append(rule.decls, d)
+			}
+			rules = append(rules, rule)
+		}
+		if len(unsupported) != 0 {
+			kept = append(kept, strings.Join(unsupported, ", ")+" {"+body+"}")
+		}
+	}
+
+	return rules, strings.Join(kept, "\n")
+}
+
+// matchingBrace returns the index of the brace closing the one at open, or -1.
+func matchingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseDeclarations returns the declarations of a rule body or style attribute in order.
+func parseDeclarations(s string) []cssDecl {
+	var decls []cssDecl
+	for _, text := range strings.Split(s, ";") {
+		prop, value, ok := strings.Cut(text, ":")
+		prop, value = strings.ToLower(strings.TrimSpace(prop)), strings.TrimSpace(value)
+		if !ok || prop == "" || value == "" {
+			continue
+		}
+		d := cssDecl{property: prop, value: value}
+		if i := strings.LastIndex(value, "!"); i >= 0 && strings.EqualFold(strings.TrimSpace(value[i+1:]), "important") {
+			d.value, d.important = strings.TrimSpace(value[:i]), true
+		}
+		decls = append(decls, d)
+	}
+	return decls
+}
+
+// parseSelector parses a selector made of type, class, and ID selectors and descendant or child combinators.
+func parseSelector(text string) (cssSelector, bool) {
+	tokens := strings.Fields(strings.ReplaceAll(text, ">", " > "))
+	if len(tokens) == 0 {
+		return cssSelector{}, false
+	}
+
+	var sel cssSelector
+	combinator := byte(' ')
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if tokens[i] == ">" {
+			if i == len(tokens)-1 || combinator == '>' {
+				return cssSelector{}, false
+			}
+			combinator = '>'
+			continue
+		}
+		m := cssCompound.FindStringSubmatch(tokens[i])
+		if m == nil || (m[1] == "" && m[2] == "") {
+			return cssSelector{}, false
+		}
+
+		step := cssCompoundSelector{tag: strings.ToLower(m[1])}
+		if step.tag != "" && step.tag != "*" {
+			sel.specificity[2]++
+		}
+		for _, part := range cssSimple.FindAllString(m[2], -1) {
+			if part[0] == '#' {
+				if step.id != "" && step.id != part[1:] {
+					return cssSelector{}, false
+				}
+				step.id = part[1:]
+				sel.specificity[0]++
+			} else {
+				step.classes = append(step.classes, part[1:])
+				sel.specificity[1]++
+			}
+		}
+
+		if len(sel.steps) != 0 {
+			sel.combinators = append(sel.combinators, combinator)
+		}
+		sel.steps = append(sel.steps, step)
+		combinator = ' '
+	}
+	if combinator == '>' {
+		return cssSelector{}, false
+	}
+	return sel, true
+}
+
+// matches reports whether the selector matches the last element of path, whose ancestors precede it.
+func (s cssSelector) matches(path []htmlElement) bool {
+	return s.matchAt(0, path, len(path)-1)
+}
+
+func (s cssSelector) matchAt(step int, path []htmlElement, i int) bool {
+	if !s.steps[step].matches(path[i]) {
+		return false
+	}
+	if step == len(s.steps)-1 {
+		return true
+	}
+	if s.combinators[step] == '>' {
+		return i > 0 && s.matchAt(step+1, path, i-1)
+	}
+	for j := i - 1; j >= 0; j-- {
+		if s.matchAt(step+1, path, j) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c cssCompoundSelector) matches(el htmlElement) bool {
+	if c.tag != "" && c.tag != "*" && c.tag != el.tag {
+		return false
+	}
+	if c.id != "" && c.id != el.id {
+		return false
+	}
+	for _, class := range c.classes {
+		found := false
+		for _, have := range el.classes {
+			if have == class {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// cascade resolves the declarations applying to one element into the text of a style attribute.
+// Each property appears once, at the position of the declaration that wins, so shorthands and their
+// longhands keep their relative order.
+func cascade(decls []cssDecl) string {
+	sort.SliceStable(decls, func(i, j int) bool {
+		a, b := decls[i], decls[j]
+		if a.important != b.important {
+			return !a.important
+		}
+		for k := range a.specificity {
+			if a.specificity[k] != b.specificity[k] {
+				return a.specificity[k] < b.specificity[k]
+			}
+		}
+		return a.order < b.order
+	})
+
+	var props []string
+	values := map[string]string{}
+	for _, d := range decls {
+		if _, ok := values[d.property]; ok {
+			for i, p := range props {
+				if p == d.property {
+					props = append(props[:i], props[i+1:]...)
+					break
+				}
+			}
+		}
+		props = append(props, d.property)
+		value := d.value
+		if d.important {
+			value += " !important"
+		}
+		values[d.property] = value
+	}
+
+	parts := make([]string, len(props))
+	for i, p := range props {
+		parts[i] = p + ": " + values[p]
+	}
+	return strings.Join(parts, "; ")
+}
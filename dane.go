@@ -0,0 +1,140 @@
+package smtp
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+)
+
+// TLSARecord is a TLSA resource record (RFC 6698).
+type TLSARecord struct {
+	// Usage is 2 (DANE-TA) or 3 (DANE-EE); PKIX usages 0 and 1 are not used for SMTP (RFC 7672).
+	Usage uint8
+	// Selector is 0 for the full certificate or 1 for the SubjectPublicKeyInfo.
+	Selector uint8
+	// MatchingType is 0 for the exact data, 1 for SHA-256, or 2 for SHA-512.
+	MatchingType uint8
+	Data         []byte
+}
+
+// TLSAResolver looks up the TLSA records of a service name such as "_25._tcp.mx.example.com".
+// Implementations must only return records whose DNSSEC validation succeeded, and an empty result
+// when the records are missing or insecure, so DANE is only enforced for signed zones.
+type TLSAResolver interface {
+	LookupTLSA(ctx context.Context, name string) ([]TLSARecord, error)
+}
+
+// DANEError is returned when the certificate presented by the server matches none of its TLSA records.
+type DANEError struct {
+	Host string
+	Err  error
+}
+
+// Error returns a description of the validation failure.
+func (e *DANEError) Error() string {
+	return fmt.Sprintf("dane error, certificate of %s does not match its tlsa records; %s", e.Host, e.Err.Error())
+}
+
+// Unwrap returns the underlying error.
+func (e *DANEError) Unwrap() error {
+	return e.Err
+}
+
+// verifyDANE returns a VerifyConnection callback that validates the peer against the TLSA records of host.
+// Without records the connection is accepted as before.
+func verifyDANE(ctx context.Context, resolver TLSAResolver, host, port string) (func(tls.ConnectionState) error, error) {
+	name := "_" + port + "._tcp." + host
+	records, err := resolver.LookupTLSA(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("dane error, failed to look up %s; %w", name, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	return func(state tls.ConnectionState) error {
+		if err := matchTLSA(records, state.PeerCertificates, host); err != nil {
+			return &DANEError{Host: host, Err: err}
+		}
+		return nil
+	}, nil
+}
+
+// matchTLSA reports whether the chain of host satisfies at least one usable record.
+// DANE-EE records match the leaf certificate; DANE-TA records match any certificate in the chain, which must then
+// chain up to it with a leaf issued for host (RFC 7672 section 3.2.2). Name checks are skipped for DANE-EE as
+// RFC 7672 section 3.1.1 requires.
+func matchTLSA(records []TLSARecord, chain []*x509.Certificate, host string) error {
+	if len(chain) == 0 {
+		return fmt.Errorf("no certificate presented")
+	}
+
+	usable := false
+	for _, r := range records {
+		switch r.Usage {
+		case 3:
+			usable = true
+			if tlsaMatches(r, chain[0]) {
+				return nil
+			}
+		case 2:
+			usable = true
+			for i, cert := range chain {
+				if !tlsaMatches(r, cert) {
+					continue
+				}
+				if i == 0 {
+					if chain[0].VerifyHostname(host) == nil {
+						return nil
+					}
+					continue
+				}
+				roots := x509.NewCertPool()
+				roots.AddCert(cert)
+				intermediates := x509.NewCertPool()
+				for _, c := range chain[1:i] {
+					intermediates.AddCert(c)
+				}
+				opts := x509.VerifyOptions{DNSName: host, Roots: roots, Intermediates: intermediates}
+				if _, err := chain[0].Verify(opts); err == nil {
+					return nil
+				}
+			}
+		}
+	}
+
+	if !usable {
+		return nil
+	}
+	return fmt.Errorf("no matching record")
+}
+
+// tlsaMatches reports whether the certificate matches the record's selector, matching type, and data.
+func tlsaMatches(r TLSARecord, cert *x509.Certificate) bool {
+	var data []byte
+	switch r.Selector {
+	case 0:
+		data = cert.Raw
+	case 1:
+		data = cert.RawSubjectPublicKeyInfo
+	default:
+		return false
+	}
+
+	switch r.MatchingType {
+	case 0:
+	case 1:
+		sum := sha256.Sum256(data)
+		data = sum[:]
+	case 2:
+		sum := sha512.Sum512(data)
+		data = sum[:]
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare(data, r.Data) == 1
+}
@@ -0,0 +1,130 @@
+package smtp
+
+import (
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// defaults holds the client level values merged into every email.
+type defaults struct {
+	fromName   string
+	headers    map[string]string
+	categories []string
+	// listUnsubscribeBase is followed by the recipient, listUnsubscribe names none
+	listUnsubscribeBase string
+	listUnsubscribe     string
+	profile             *Profile
+}
+
+// applyDefaults merges the client defaults into the email; values set on the email take precedence.
+func (c *SMTP) applyDefaults(email Email) Email {
+	d := c.defaults
+
+	if email.FromName == "" {
+		email.FromName = d.fromName
+	}
+	if email.Profile == nil {
+		email.Profile = d.profile
+	}
+
+	if len(d.headers) != 0 || d.listUnsubscribeBase != "" || d.listUnsubscribe != "" {
+		headers := make(map[string]string, len(d.headers)+len(email.Headers)+2)
+		for name, value := range d.headers {
+			headers[CanonicalHeaderName(name)] = value
+		}
+		recipients := email.recipients()
+		link := d.listUnsubscribe
+		if d.listUnsubscribeBase != "" && len(recipients) == 1 {
+			link = d.listUnsubscribeBase + url.QueryEscape(recipients[0])
+		}
+		if link != "" {
+			setUnsubscribe(headers, link)
+		}
+		own := false
+		for name, value := range email.Headers {
+			name = CanonicalHeaderName(name)
+			headers[name] = value
+			own = own || name == "List-Unsubscribe"
+		}
+		email.Headers = headers
+		email.unsubscribeEach = c.verp && d.listUnsubscribeBase != "" && len(recipients) > 1 && !own
+	}
+
+	if len(d.categories) != 0 {
+		seen := map[string]bool{}
+		var categories []string
+		for _, category := range append(append([]string(nil), d.categories...), email.Categories...) {
+			if !seen[category] {
+				seen[category] = true
+				categories = append(categories, category)
+			}
+		}
+		email.Categories = categories
+	}
+
+	if c.tracking.enabled() && !email.NoTracking && email.TrackingID == "" && email.HTMLBody != "" {
+		if email.TrackingID = c.generateID(); email.TrackingID == "" {
+			email.TrackingID = newTrackingID()
+		}
+	}
+
+	return email
+}
+
+// setUnsubscribe sets the List-Unsubscribe header to link, with one-click unsubscribe for an https link.
+func setUnsubscribe(headers map[string]string, link string) {
+	headers["List-Unsubscribe"] = "<" + link + ">"
+	delete(headers, "List-Unsubscribe-Post")
+	if strings.HasPrefix(link, "https:") {
+		headers["List-Unsubscribe-Post"] = oneClickUnsubscribe
+	}
+}
+
+// unsubscribeFor returns a copy of the message whose List-Unsubscribe link names the single recipient of a VERP
+// envelope, see Email.unsubscribeEach.
+func (c *SMTP) unsubscribeFor(msg *Message, recipient string) *Message {
+	link := c.defaults.listUnsubscribeBase + url.QueryEscape(recipient)
+
+	out := *msg
+	out.Header = make([]HeaderField, 0, len(msg.Header)+1)
+	for _, field := range msg.Header {
+		if !strings.EqualFold(field.Name, "List-Unsubscribe-Post") {
+			out.Header = append(out.Header, field)
+		}
+	}
+	out.Set("List-Unsubscribe", "<"+link+">")
+	if strings.HasPrefix(link, "https:") {
+		out.Set("List-Unsubscribe-Post", oneClickUnsubscribe)
+	}
+	return &out
+}
+
+// fromHeader returns the From header value for the email.
+func (c *SMTP) fromHeader(email Email) string {
+	if email.FromName == "" {
+		return c.senderAddress
+	}
+	return (&mail.Address{Name: email.FromName, Address: c.senderAddress}).String()
+}
+
+// envelopeSender returns the MAIL FROM address for the email: its EnvelopeFrom, the client's envelope sender,
+// or the sender address of the From header. With VERP, the single recipient of the email is encoded into it.
+func (c *SMTP) envelopeSender(email Email) string {
+	from := c.senderAddress
+	switch {
+	case email.EnvelopeFrom != "":
+		from = email.EnvelopeFrom
+	case c.envelopeFrom != "":
+		from = c.envelopeFrom
+	}
+	if recipients := email.recipients(); c.verp && len(recipients) == 1 {
+		return VERPAddress(from, recipients[0])
+	}
+	return from
+}
+
+// categoriesHeader returns the X-Categories header value for the email.
+func categoriesHeader(categories []string) string {
+	return strings.Join(categories, ", ")
+}
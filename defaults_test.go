@@ -0,0 +1,88 @@
+package smtp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dexterdmonkey/go-smtp"
+	"github.com/dexterdmonkey/go-smtp/smtptest"
+)
+
+func TestListUnsubscribeBase(t *testing.T) {
+	const base = "https://example.com/unsubscribe?email="
+
+	tests := []struct {
+		name string
+		opts []smtp.Option
+		to   []string
+		// want maps every envelope recipient to the List-Unsubscribe header of its copy
+		want map[string]string
+	}{
+		{
+			name: "single recipient",
+			to:   []string{"ada@example.com"},
+			want: map[string]string{"ada@example.com": "<" + base + "ada%40example.com>"},
+		},
+		{
+			name: "several recipients",
+			to:   []string{"ada@example.com", "bob@example.com"},
+			want: map[string]string{"ada@example.com": "", "bob@example.com": ""},
+		},
+		{
+			name: "several recipients with a shared link",
+			opts: []smtp.Option{smtp.WithListUnsubscribe("https://example.com/preferences")},
+			to:   []string{"ada@example.com", "bob@example.com"},
+			want: map[string]string{
+				"ada@example.com": "<https://example.com/preferences>",
+				"bob@example.com": "<https://example.com/preferences>",
+			},
+		},
+		{
+			name: "several recipients with VERP",
+			opts: []smtp.Option{smtp.WithVERP()},
+			to:   []string{"ada@example.com", "bob@example.com"},
+			want: map[string]string{
+				"ada@example.com": "<" + base + "ada%40example.com>",
+				"bob@example.com": "<" + base + "bob%40example.com>",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := smtptest.NewServer()
+			t.Cleanup(srv.Close)
+
+			opts := append([]smtp.Option{smtp.WithListUnsubscribeBase(base)}, tt.opts...)
+			mail, err := smtp.New("app@example.com", "secret", srv.Host(), srv.Port(), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { mail.Close() })
+
+			if err = mail.SendMail(smtp.Email{To: tt.to, Subject: "News", Body: "Hi"}); err != nil {
+				t.Fatal(err)
+			}
+
+			messages, err := srv.Wait(1, 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]string{}
+			for _, msg := range messages {
+				email, err := smtp.ParseMessage(msg.Data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, rcpt := range msg.To {
+					got[rcpt] = email.Headers["List-Unsubscribe"]
+				}
+			}
+			for rcpt, want := range tt.want {
+				if got[rcpt] != want {
+					t.Errorf("%s received List-Unsubscribe %q, want %q", rcpt, got[rcpt], want)
+				}
+			}
+		})
+	}
+}
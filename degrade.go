@@ -0,0 +1,98 @@
+package smtp
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// DegradeStep removes optional content from an email that exceeds the server's SIZE limit.
+type DegradeStep int
+
+// Degradation steps.
+const (
+	// DropInlineImages removes inline image attachments.
+	DropInlineImages DegradeStep = iota + 1
+	// DropHTML removes the HTML body together with its inline attachments, leaving the text body.
+	// When there is no text body, one is derived from the HTML.
+	DropHTML
+	// DropTrackingPixel removes the open tracking pixel of WithTracking and the 1x1 images of the HTML body.
+	// Tracked links are kept.
+	DropTrackingPixel
+)
+
+// String returns the name of the step.
+func (s DegradeStep) String() string {
+	switch s {
+	case DropInlineImages:
+		return "drop inline images"
+	case DropHTML:
+		return "drop html"
+	case DropTrackingPixel:
+		return "drop tracking pixel"
+	default:
+		return "unknown"
+	}
+}
+
+// apply returns the email without the content the step removes and whether anything was removed.
+func (s DegradeStep) apply(email Email) (Email, bool) {
+	switch s {
+	case DropInlineImages:
+		var kept []Attachment
+		for _, a := range email.Attachments {
+			if !a.Inline || !strings.HasPrefix(a.contentType(), "image/") {
+				kept = append(kept, a)
+			}
+		}
+		changed := len(kept) != len(email.Attachments)
+		email.Attachments = kept
+		return email, changed
+	case DropTrackingPixel:
+		if email.HTMLBody == "" {
+			return email, false
+		}
+		var removed bool
+		email.HTMLBody, removed = removePixels(email.HTMLBody)
+		tracked := !email.noPixel && !email.NoTracking && email.TrackingID != ""
+		email.noPixel = true
+		return email, removed || tracked
+	case DropHTML:
+		if email.HTMLBody == "" {
+			return email, false
+		}
+		if email.Body == "" {
+			email.Body = htmlToText(email.HTMLBody)
+		}
+		email.HTMLBody = ""
+
+		var kept []Attachment
+		for _, a := range email.Attachments {
+			if !a.Inline {
+				kept = append(kept, a)
+			}
+		}
+		email.Attachments = kept
+		return email, true
+	}
+	return email, false
+}
+
+var (
+	htmlBreak    = regexp.MustCompile(`(?i)<(br|/p|/div|/h[1-6]|/li|/tr)[^>]*>`)
+	htmlComment  = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlHidden   = regexp.MustCompile(`(?is)<(style|script|head)[^>]*>.*?</(style|script|head)>`)
+	htmlTag      = regexp.MustCompile(`<[^>]*>`)
+	htmlBlankRun = regexp.MustCompile(`\n[ \t]*(\n[ \t]*)+`)
+)
+
+// htmlToText derives a plain text body from HTML by dropping markup and keeping line breaks.
+func htmlToText(s string) string {
+	s = htmlComment.ReplaceAllString(s, "")
+	s = htmlHidden.ReplaceAllString(s, "")
+	s = htmlBreak.ReplaceAllString(s, "\n")
+	s = htmlTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = htmlBlankRun.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
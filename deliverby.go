@@ -0,0 +1,35 @@
+package smtp
+
+import (
+	"log/slog"
+	"math"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// deliverByParams returns the BY parameter of the MAIL FROM command (RFC 2852) that asks the server to return
+// the message unless it is delivered before deadline, counted from now. It is nil without a deadline or when the
+// server does not support DELIVERBY. A deadline below the server's minimum is raised to it rather than having the
+// message refused.
+func deliverByParams(client *smtp.Client, deadline, now time.Time, log *slog.Logger) []string {
+	if deadline.IsZero() {
+		return nil
+	}
+	ok, param := client.Extension("DELIVERBY")
+	if !ok {
+		log.Debug("smtp server does not support DELIVERBY, delivery deadline is only enforced locally")
+		return nil
+	}
+
+	seconds := int(math.Ceil(deadline.Sub(now).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	if min, err := strconv.Atoi(strings.TrimSpace(param)); err == nil && seconds < min {
+		log.Debug("smtp delivery deadline raised to the server minimum", "seconds", seconds, "minimum", min)
+		seconds = min
+	}
+	return []string{"BY=" + strconv.Itoa(seconds) + ";R"}
+}
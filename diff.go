@@ -0,0 +1,258 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// VolatileHeaders lists the header fields ignored by DiffMessages because they change on every send.
+var VolatileHeaders = []string{"Date", "Message-ID", "DKIM-Signature", "X-Originating-Service"}
+
+// Difference describes one semantic difference between two messages.
+type Difference struct {
+	// Path locates the MIME part, e.g. "" for the top level entity and "1.2" for the second child of the first part.
+	Path  string
+	Field string
+	A     string
+	B     string
+}
+
+// String returns a human readable description of the difference.
+func (d Difference) String() string {
+	path := d.Path
+	if path == "" {
+		path = "message"
+	}
+	return fmt.Sprintf("%s %s: %q != %q", path, d.Field, d.A, d.B)
+}
+
+// DiffMessages compares two serialized messages part by part, ignoring volatile headers and
+// multipart boundaries. Text parts are compared after decoding, HTML parts with normalized
+// whitespace, and attachments by name, type, and content hash.
+func DiffMessages(a, b []byte) ([]Difference, error) {
+	ea, err := parseEntity(a)
+	if err != nil {
+		return nil, fmt.Errorf("diff error, failed to parse first message; %w", err)
+	}
+	eb, err := parseEntity(b)
+	if err != nil {
+		return nil, fmt.Errorf("diff error, failed to parse second message; %w", err)
+	}
+
+	var diffs []Difference
+	diffEntities("", ea, eb, &diffs)
+	return diffs, nil
+}
+
+// entity is a decoded MIME entity.
+type entity struct {
+	header    textproto.MIMEHeader
+	mediaType string
+	params    map[string]string
+	content   []byte
+	parts     []*entity
+}
+
+// parseEntity parses a serialized message into a tree of decoded entities.
+func parseEntity(data []byte) (*entity, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(normalizeCRLF(data)))
+	if err != nil {
+		return nil, err
+	}
+	return readEntity(textproto.MIMEHeader(msg.Header), msg.Body, 0)
+}
+
+// maxEntityDepth bounds the nesting of multipart entities, so that a crafted message cannot exhaust the stack.
+const maxEntityDepth = 32
+
+// readEntity decodes the body of an entity with the given header, nested depth multiparts deep.
+func readEntity(header textproto.MIMEHeader, body io.Reader, depth int) (*entity, error) {
+	e := &entity{header: header, mediaType: "text/plain", params: map[string]string{}}
+	if ct := header.Get("Content-Type"); ct != "" {
+		mediaType, params, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return nil, err
+		}
+		e.mediaType, e.params = mediaType, params
+	}
+
+	if strings.HasPrefix(e.mediaType, "multipart/") {
+		if depth >= maxEntityDepth {
+			return nil, fmt.Errorf("multiparts nested more than %d deep", maxEntityDepth)
+		}
+		r := multipart.NewReader(body, e.params["boundary"])
+		for {
+			part, err := r.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			child, err := readEntity(part.Header, part, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			e.parts = append(e.parts, child)
+		}
+		return e, nil
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, &lineStripper{r: body})
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	e.content = content
+	return e, nil
+}
+
+// lineStripper removes CR and LF characters so base64 can be decoded across lines.
+type lineStripper struct {
+	r io.Reader
+}
+
+func (l *lineStripper) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	out := p[:0]
+	for _, ch := range p[:n] {
+		if ch != '\r' && ch != '\n' {
+			out = append(out, ch)
+		}
+	}
+	return len(out), err
+}
+
+// diffEntities appends the differences between two entities at path.
+func diffEntities(path string, a, b *entity, diffs *[]Difference) {
+	add := func(field, va, vb string) {
+		*diffs = append(*diffs, Difference{Path: path, Field: field, A: va, B: vb})
+	}
+
+	volatile := map[string]bool{"Content-Type": true}
+	for _, name := range VolatileHeaders {
+		volatile[textproto.CanonicalMIMEHeaderKey(name)] = true
+	}
+
+	names := map[string]bool{}
+	for name := range a.header {
+		names[name] = true
+	}
+	for name := range b.header {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		if !volatile[name] {
+			sorted = append(sorted, name)
+		}
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		va, vb := strings.Join(a.header[name], ", "), strings.Join(b.header[name], ", ")
+		if va != vb {
+			add("header "+name, va, vb)
+		}
+	}
+
+	if a.mediaType != b.mediaType {
+		add("content type", a.mediaType, b.mediaType)
+		return
+	}
+	for _, param := range []string{"charset", "name", "method"} {
+		if a.params[param] != b.params[param] {
+			add("content type "+param, a.params[param], b.params[param])
+		}
+	}
+
+	if strings.HasPrefix(a.mediaType, "multipart/") {
+		if len(a.parts) != len(b.parts) {
+			add("part count", fmt.Sprint(len(a.parts)), fmt.Sprint(len(b.parts)))
+		}
+		for i := 0; i < len(a.parts) && i < len(b.parts); i++ {
+			child := fmt.Sprint(i + 1)
+			if path != "" {
+				child = path + "." + child
+			}
+			diffEntities(child, a.parts[i], b.parts[i], diffs)
+		}
+		return
+	}
+
+	switch {
+	case isAttachment(a):
+		ha, hb := contentHash(a.content), contentHash(b.content)
+		if ha != hb {
+			add("attachment content", ha, hb)
+		}
+	case a.mediaType == "text/html":
+		ca, cb := normalizeHTML(string(a.content)), normalizeHTML(string(b.content))
+		if ca != cb {
+			add("html", ca, cb)
+		}
+	case strings.HasPrefix(a.mediaType, "text/"):
+		ca, cb := normalizeText(string(a.content)), normalizeText(string(b.content))
+		if ca != cb {
+			add("text", ca, cb)
+		}
+	default:
+		ha, hb := contentHash(a.content), contentHash(b.content)
+		if ha != hb {
+			add("content", ha, hb)
+		}
+	}
+}
+
+// isAttachment reports whether the entity is declared as an attachment.
+func isAttachment(e *entity) bool {
+	disposition, _, _ := mime.ParseMediaType(e.header.Get("Content-Disposition"))
+	return disposition == "attachment"
+}
+
+// contentHash returns the size and SHA-256 of content.
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return fmt.Sprintf("%d bytes sha256:%s", len(content), hex.EncodeToString(sum[:]))
+}
+
+// normalizeText unifies line endings and drops trailing whitespace.
+func normalizeText(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+var (
+	htmlSpace    = regexp.MustCompile(`\s+`)
+	htmlTagSpace = regexp.MustCompile(`>\s+<`)
+	htmlTagName  = regexp.MustCompile(`</?[A-Za-z][A-Za-z0-9]*`)
+)
+
+// normalizeHTML collapses whitespace, drops whitespace between tags, and lower-cases tag names.
+func normalizeHTML(s string) string {
+	s = htmlSpace.ReplaceAllString(strings.TrimSpace(s), " ")
+	s = htmlTagSpace.ReplaceAllString(s, "><")
+	return htmlTagName.ReplaceAllStringFunc(s, strings.ToLower)
+}
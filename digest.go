@@ -0,0 +1,175 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Digester combines the emails whose profile sets a Digest window into one message per recipient list, so that
+// a busy feed sends a single summary instead of many separate emails. Emails without a digest window are sent
+// at once.
+type Digester struct {
+	sender Interface
+
+	// Subject is the subject of a digest of several emails; a %d verb is replaced by their number.
+	// The default is "%d new messages". A digest of a single email keeps its own subject.
+	Subject string
+	// OnError is called when a digest fails to send; the emails are dropped either way.
+	OnError func(emails []Email, err error)
+
+	mu      sync.Mutex
+	pending map[string]*digest
+	closed  bool
+}
+
+type digest struct {
+	emails []Email
+	timer  *time.Timer
+}
+
+// NewDigester initializes and returns a digester that sends through sender.
+func NewDigester(sender Interface) *Digester {
+	return &Digester{
+		sender:  sender,
+		Subject: "%d new messages",
+		pending: map[string]*digest{},
+	}
+}
+
+// Add sends the email, or holds it until the digest window of its profile has passed since the first email
+// to the same recipients with the same profile.
+func (d *Digester) Add(email Email) error {
+	if email.Profile == nil || email.Profile.Digest <= 0 {
+		return d.sender.SendMail(email)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return fmt.Errorf("digest error, digester is shut down")
+	}
+
+	key := digestKey(email)
+	pending, ok := d.pending[key]
+	if !ok {
+		pending = &digest{}
+		pending.timer = time.AfterFunc(email.Profile.Digest, func() { d.send(key) })
+		d.pending[key] = pending
+	}
+	pending.emails = append(pending.emails, email)
+	return nil
+}
+
+// Flush sends every pending digest without waiting for its window to pass.
+func (d *Digester) Flush() error {
+	d.mu.Lock()
+	keys := make([]string, 0, len(d.pending))
+	for key := range d.pending {
+		keys = append(keys, key)
+	}
+	d.mu.Unlock()
+
+	var errs []error
+	for _, key := range keys {
+		if err := d.send(key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown refuses new emails and sends the pending digests until ctx is done.
+func (d *Digester) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() { done <- d.Flush() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("digest error, pending digests not sent; %w", ctx.Err())
+	}
+}
+
+// send removes the digest from the pending set and sends it.
+func (d *Digester) send(key string) error {
+	d.mu.Lock()
+	pending, ok := d.pending[key]
+	if ok {
+		pending.timer.Stop()
+		delete(d.pending, key)
+	}
+	d.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	err := d.sender.SendMail(d.combine(pending.emails))
+	if err != nil && d.OnError != nil {
+		d.OnError(pending.emails, err)
+	}
+	return err
+}
+
+// combine joins the emails into one, keeping the recipients, profile, and headers of the first.
+func (d *Digester) combine(emails []Email) Email {
+	if len(emails) == 1 {
+		return emails[0]
+	}
+
+	email := emails[0]
+	email.Subject = d.Subject
+	if strings.Contains(email.Subject, "%d") {
+		email.Subject = fmt.Sprintf(email.Subject, len(emails))
+	}
+	email.TrackingID = ""
+	email.IdempotencyKey = ""
+	email.InReplyTo, email.References = "", nil
+
+	var text, htmlBody []string
+	hasHTML := false
+	email.Attachments = nil
+	for _, item := range emails {
+		text = append(text, item.Subject+"\n\n"+item.Body)
+		if item.HTMLBody != "" {
+			hasHTML = true
+			htmlBody = append(htmlBody, "<h2>"+html.EscapeString(item.Subject)+"</h2>\n"+item.HTMLBody)
+		} else {
+			htmlBody = append(htmlBody, "<h2>"+html.EscapeString(item.Subject)+"</h2>\n<pre>"+html.EscapeString(item.Body)+"</pre>")
+		}
+		email.Attachments = append(email.Attachments, item.Attachments...)
+	}
+
+	email.Body = strings.Join(text, "\n\n---\n\n")
+	email.HTMLBody = ""
+	if hasHTML {
+		email.HTMLBody = strings.Join(htmlBody, "\n<hr>\n")
+	}
+	return email
+}
+
+// digestKey groups emails by profile and by their To, Cc, and Bcc lists, ignoring order and case.
+func digestKey(email Email) string {
+	key := []string{email.Profile.Name}
+	for _, list := range [][]string{email.To, email.Cc, email.Bcc} {
+		addrs := make([]string, len(list))
+		for i, addr := range list {
+			addrs[i] = strings.ToLower(addr)
+		}
+		sort.Strings(addrs)
+		key = append(key, strings.Join(addrs, ","))
+	}
+	return strings.Join(key, "\x00")
+}
@@ -0,0 +1,129 @@
+package smtp
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingSender struct {
+	Interface
+	mu   sync.Mutex
+	sent []Email
+}
+
+func (s *recordingSender) SendMail(email Email) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, email)
+	return nil
+}
+
+func (s *recordingSender) emails() []Email {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Email(nil), s.sent...)
+}
+
+func TestDigester(t *testing.T) {
+	sender := &recordingSender{}
+	d := NewDigester(sender)
+
+	profile := NewBulkProfile("https://example.com/unsubscribe")
+	profile.Digest = 20 * time.Millisecond
+	for _, subject := range []string{"First", "Second", "Third"} {
+		if err := d.Add(Email{To: []string{"User@example.com"}, Subject: subject, Body: subject + " body", Profile: &profile}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Add(Email{To: []string{"other@example.com"}, Subject: "Alone", Body: "Hi", Profile: &profile}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Add(Email{To: []string{"user@example.com"}, Subject: "Receipt", Body: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Emails without a digest window are not held
+	if sent := sender.emails(); len(sent) != 1 || sent[0].Subject != "Receipt" {
+		t.Fatalf("sent %d emails before the window, want only the receipt", len(sent))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	sent := sender.emails()
+	if len(sent) != 3 {
+		t.Fatalf("sent %d emails, want 3", len(sent))
+	}
+	subjects := map[string]Email{}
+	for _, email := range sent {
+		subjects[email.Subject] = email
+	}
+	digest, ok := subjects["3 new messages"]
+	if !ok {
+		t.Fatalf("no digest among %v", subjects)
+	}
+	for _, subject := range []string{"First body", "Second body", "Third body"} {
+		if !strings.Contains(digest.Body, subject) {
+			t.Fatalf("digest body misses %q:\n%s", subject, digest.Body)
+		}
+	}
+	if _, ok = subjects["Alone"]; !ok {
+		t.Fatalf("a digest of one email did not keep its subject: %v", subjects)
+	}
+}
+
+func TestDigesterShutdown(t *testing.T) {
+	sender := &recordingSender{}
+	d := NewDigester(sender)
+
+	profile := ProfileBulk
+	if err := d.Add(Email{To: []string{"user@example.com"}, Subject: "News", Body: "Hi", Profile: &profile}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if sent := sender.emails(); len(sent) != 1 {
+		t.Fatalf("sent %d emails on shutdown, want the pending digest", len(sent))
+	}
+	if err := d.Add(Email{To: []string{"user@example.com"}, Subject: "Late", Body: "Hi", Profile: &profile}); err == nil {
+		t.Fatal("add after shutdown succeeded")
+	}
+}
+
+func TestNewBulkProfileCopiesHeaders(t *testing.T) {
+	profile := NewBulkProfile("mailto:unsubscribe@example.com")
+	profile.Headers["X-Campaign"] = "july"
+
+	if _, ok := ProfileBulk.Headers["X-Campaign"]; ok {
+		t.Fatal("changing the headers of a bulk profile changed ProfileBulk")
+	}
+}
+
+func TestProfileJSONSuppression(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		skip bool
+	}{
+		{name: "v1 checked", data: `{"version":1,"profile":{"name":"bulk","check_suppression":true}}`, skip: false},
+		{name: "v1 unchecked", data: `{"version":1,"profile":{"name":"otp"}}`, skip: true},
+		{name: "v2 checked", data: `{"version":2,"profile":{"name":"bulk"}}`, skip: false},
+		{name: "v2 skipped", data: `{"version":2,"profile":{"name":"otp","skip_suppression":true}}`, skip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var email Email
+			if err := email.UnmarshalJSON([]byte(tt.data)); err != nil {
+				t.Fatal(err)
+			}
+			if email.Profile.SkipSuppression != tt.skip {
+				t.Fatalf("SkipSuppression = %t, want %t", email.Profile.SkipSuppression, tt.skip)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package smtp
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDKIMHeaders lists the header fields signed when a DKIMSigner has no explicit list.
+var DefaultDKIMHeaders = []string{"From", "To", "Cc", "Subject", "Date", "Message-ID", "Reply-To", "MIME-Version", "Content-Type", "List-Unsubscribe", "List-Unsubscribe-Post"}
+
+// DKIMSigner signs outgoing messages with a DKIM-Signature header using relaxed/relaxed canonicalization.
+type DKIMSigner struct {
+	Domain   string
+	Selector string
+	Key      crypto.Signer
+	Headers  []string
+}
+
+// NewDKIMSigner initializes and returns a DKIM signer for an RSA or Ed25519 key.
+func NewDKIMSigner(domain, selector string, key crypto.Signer) (*DKIMSigner, error) {
+	switch key.(type) {
+	case *rsa.PrivateKey, ed25519.PrivateKey:
+	default:
+		return nil, fmt.Errorf("dkim error, unsupported key type %T", key)
+	}
+
+	return &DKIMSigner{
+		Domain:   domain,
+		Selector: selector,
+		Key:      key,
+	}, nil
+}
+
+// NewDKIMSignerFromPEM initializes and returns a DKIM signer for a PEM encoded PKCS#1 or PKCS#8 private key.
+func NewDKIMSignerFromPEM(domain, selector string, keyPEM []byte) (*DKIMSigner, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("dkim error, failed to decode pem key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return NewDKIMSigner(domain, selector, key)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("dkim error, failed to parse private key; %s", err.Error())
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("dkim error, unsupported key type %T", key)
+	}
+
+	return NewDKIMSigner(domain, selector, signer)
+}
+
+// Sign computes the DKIM signature of the message and prepends the DKIM-Signature header.
+func (s *DKIMSigner) Sign(msg *Message) error {
+	return s.signAt(msg, time.Now())
+}
+
+// signAt signs the message with now as the signature timestamp, the clock of the client when it sends.
+func (s *DKIMSigner) signAt(msg *Message, now time.Time) error {
+	algorithm := "rsa-sha256"
+	if _, ok := s.Key.(ed25519.PrivateKey); ok {
+		algorithm = "ed25519-sha256"
+	}
+
+	bodyHash, err := msg.bodyHash()
+	if err != nil {
+		return fmt.Errorf("dkim error, failed to hash body; %w", err)
+	}
+
+	names := s.Headers
+	if len(names) == 0 {
+		names = DefaultDKIMHeaders
+	}
+
+	// Select header instances bottom-up as required by RFC 6376 section 5.4.2
+	used := map[int]bool{}
+	var signed []HeaderField
+	var signedNames []string
+	for _, name := range names {
+		for i := len(msg.Header) - 1; i >= 0; i-- {
+			if used[i] || !strings.EqualFold(msg.Header[i].Name, name) {
+				continue
+			}
+			used[i] = true
+			signed = append(signed, msg.Header[i])
+			signedNames = append(signedNames, strings.ToLower(name))
+			break
+		}
+	}
+
+	value := "v=1; a=" + algorithm + "; c=relaxed/relaxed" +
+		"; d=" + s.Domain +
+		"; s=" + s.Selector +
+		"; t=" + strconv.FormatInt(now.Unix(), 10) +
+		"; h=" + strings.Join(signedNames, ":") +
+		"; bh=" + base64.StdEncoding.EncodeToString(bodyHash[:]) +
+		"; b="
+
+	var data strings.Builder
+	for _, f := range signed {
+		data.WriteString(canonicalHeaderRelaxed(f.Name, f.Value) + "\r\n")
+	}
+	data.WriteString(canonicalHeaderRelaxed("DKIM-Signature", value))
+
+	hash := sha256.Sum256([]byte(data.String()))
+
+	var sig []byte
+	if algorithm == "ed25519-sha256" {
+		sig, err = s.Key.Sign(rand.Reader, hash[:], crypto.Hash(0))
+	} else {
+		sig, err = s.Key.Sign(rand.Reader, hash[:], crypto.SHA256)
+	}
+	if err != nil {
+		return fmt.Errorf("dkim error, failed to sign message; %s", err.Error())
+	}
+
+	msg.Prepend("DKIM-Signature", value+base64.StdEncoding.EncodeToString(sig))
+	return nil
+}
+
+// canonicalHeaderRelaxed applies the relaxed header canonicalization of RFC 6376 section 3.4.2.
+func canonicalHeaderRelaxed(name, value string) string {
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = strings.ReplaceAll(value, "\n", "")
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + strings.Trim(compressWSP(value), " ")
+}
+
+// compressWSP reduces every run of spaces and tabs to a single space.
+func compressWSP(s string) string {
+	var b strings.Builder
+	inWSP := false
+	for i := 0; i < len(s); i++ {
+		if s[i] == ' ' || s[i] == '\t' {
+			if !inWSP {
+				b.WriteByte(' ')
+			}
+			inWSP = true
+			continue
+		}
+		inWSP = false
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
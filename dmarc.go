@@ -0,0 +1,252 @@
+package smtp
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// senderTagHeader carries the stable sender identifier set with WithSenderTag.
+const senderTagHeader = "X-Sender-Tag"
+
+// senderTag is the stable identifier embedded in outgoing messages.
+type senderTag struct {
+	id        string
+	messageID bool
+}
+
+// apply adds the tag header and, when enabled, prefixes the local part of the Message-ID with the tag.
+func (t *senderTag) apply(msg *Message) {
+	if t == nil || t.id == "" {
+		return
+	}
+
+	msg.Set(senderTagHeader, t.id)
+	if t.messageID {
+		id := msg.Get("Message-ID")
+		if strings.HasPrefix(id, "<") {
+			msg.Set("Message-ID", "<"+t.id+"."+id[1:])
+		}
+	}
+}
+
+// cleanSenderTag keeps the characters that are valid in the dot-atom local part of a Message-ID.
+func cleanSenderTag(id string) string {
+	var b strings.Builder
+	for _, ch := range id {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9', strings.ContainsRune("-_+=", ch):
+			b.WriteRune(ch)
+		}
+	}
+	return b.String()
+}
+
+// SenderTagFromMessageID returns the sender tag embedded in a Message-ID by WithSenderTag, or an empty string,
+// e.g. to correlate DMARC failure reports that quote the original header with the sending service.
+func SenderTagFromMessageID(messageID string) string {
+	local := strings.TrimPrefix(messageID, "<")
+	if at := strings.LastIndex(local, "@"); at >= 0 {
+		local = local[:at]
+	}
+	tag, _, ok := strings.Cut(local, ".")
+	if !ok {
+		return ""
+	}
+	return tag
+}
+
+// DMARCReport is a DMARC aggregate report (RFC 7489 appendix C).
+type DMARCReport struct {
+	OrgName  string
+	Email    string
+	ReportID string
+	Begin    time.Time
+	End      time.Time
+	Policy   DMARCPolicy
+	Records  []DMARCRecord
+}
+
+// DMARCPolicy is the policy the reporter found published for the domain.
+type DMARCPolicy struct {
+	Domain string
+	// ADKIM and ASPF are the alignment modes, r for relaxed or s for strict.
+	ADKIM string
+	ASPF  string
+	// Policy and SubdomainPolicy are none, quarantine, or reject.
+	Policy          string
+	SubdomainPolicy string
+	Percent         int
+}
+
+// DMARCRecord summarizes the messages from one source with the same authentication results.
+type DMARCRecord struct {
+	SourceIP string
+	Count    int
+	// Disposition is the policy applied: none, quarantine, or reject.
+	Disposition string
+	// DKIM and SPF are the aligned DMARC results, pass or fail.
+	DKIM string
+	SPF  string
+	// HeaderFrom is the domain of the From header.
+	HeaderFrom   string
+	EnvelopeFrom string
+	DKIMResults  []DMARCAuthResult
+	SPFResults   []DMARCAuthResult
+}
+
+// DMARCAuthResult is the raw result of a DKIM signature or SPF check.
+type DMARCAuthResult struct {
+	Domain string
+	// Selector is the DKIM selector; it is empty for SPF.
+	Selector string
+	Result   string
+}
+
+// Passed reports whether the record passed DMARC through aligned DKIM or SPF.
+func (r DMARCRecord) Passed() bool {
+	return r.DKIM == "pass" || r.SPF == "pass"
+}
+
+// dmarcFeedback mirrors the XML schema of an aggregate report.
+type dmarcFeedback struct {
+	Metadata struct {
+		OrgName   string `xml:"org_name"`
+		Email     string `xml:"email"`
+		ReportID  string `xml:"report_id"`
+		DateRange struct {
+			Begin int64 `xml:"begin"`
+			End   int64 `xml:"end"`
+		} `xml:"date_range"`
+	} `xml:"report_metadata"`
+	Policy struct {
+		Domain string `xml:"domain"`
+		ADKIM  string `xml:"adkim"`
+		ASPF   string `xml:"aspf"`
+		P      string `xml:"p"`
+		SP     string `xml:"sp"`
+		Pct    int    `xml:"pct"`
+	} `xml:"policy_published"`
+	Records []struct {
+		Row struct {
+			SourceIP        string `xml:"source_ip"`
+			Count           int    `xml:"count"`
+			PolicyEvaluated struct {
+				Disposition string `xml:"disposition"`
+				DKIM        string `xml:"dkim"`
+				SPF         string `xml:"spf"`
+			} `xml:"policy_evaluated"`
+		} `xml:"row"`
+		Identifiers struct {
+			HeaderFrom   string `xml:"header_from"`
+			EnvelopeFrom string `xml:"envelope_from"`
+		} `xml:"identifiers"`
+		AuthResults struct {
+			DKIM []struct {
+				Domain   string `xml:"domain"`
+				Selector string `xml:"selector"`
+				Result   string `xml:"result"`
+			} `xml:"dkim"`
+			SPF []struct {
+				Domain string `xml:"domain"`
+				Result string `xml:"result"`
+			} `xml:"spf"`
+		} `xml:"auth_results"`
+	} `xml:"record"`
+}
+
+// ParseDMARCReport parses a DMARC aggregate report given as XML or as the gzip or zip file it is usually
+// delivered in.
+func ParseDMARCReport(data []byte) (*DMARCReport, error) {
+	data, err := unpackDMARCReport(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var feedback dmarcFeedback
+	if err = xml.Unmarshal(data, &feedback); err != nil {
+		return nil, fmt.Errorf("dmarc error, failed to decode report; %w", err)
+	}
+
+	m := feedback.Metadata
+	report := &DMARCReport{
+		OrgName:  m.OrgName,
+		Email:    m.Email,
+		ReportID: m.ReportID,
+		Begin:    time.Unix(m.DateRange.Begin, 0).UTC(),
+		End:      time.Unix(m.DateRange.End, 0).UTC(),
+		Policy: DMARCPolicy{
+			Domain:          feedback.Policy.Domain,
+			ADKIM:           feedback.Policy.ADKIM,
+			ASPF:            feedback.Policy.ASPF,
+			Policy:          feedback.Policy.P,
+			SubdomainPolicy: feedback.Policy.SP,
+			Percent:         feedback.Policy.Pct,
+		},
+	}
+
+	for _, rec := range feedback.Records {
+		record := DMARCRecord{
+			SourceIP:     strings.TrimSpace(rec.Row.SourceIP),
+			Count:        rec.Row.Count,
+			Disposition:  rec.Row.PolicyEvaluated.Disposition,
+			DKIM:         rec.Row.PolicyEvaluated.DKIM,
+			SPF:          rec.Row.PolicyEvaluated.SPF,
+			HeaderFrom:   rec.Identifiers.HeaderFrom,
+			EnvelopeFrom: rec.Identifiers.EnvelopeFrom,
+		}
+		for _, r := range rec.AuthResults.DKIM {
+			record.DKIMResults = append(record.DKIMResults, DMARCAuthResult{Domain: r.Domain, Selector: r.Selector, Result: r.Result})
+		}
+		for _, r := range rec.AuthResults.SPF {
+			record.SPFResults = append(record.SPFResults, DMARCAuthResult{Domain: r.Domain, Result: r.Result})
+		}
+		report.Records = append(report.Records, record)
+	}
+	return report, nil
+}
+
+// unpackDMARCReport returns the XML of a report, decompressing gzip and zip files.
+func unpackDMARCReport(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("dmarc error, failed to open gzip report; %w", err)
+		}
+		defer r.Close()
+
+		out, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("dmarc error, failed to read gzip report; %w", err)
+		}
+		return out, nil
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("dmarc error, failed to open zip report; %w", err)
+		}
+		for _, f := range r.File {
+			if !strings.HasSuffix(strings.ToLower(f.Name), ".xml") {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("dmarc error, failed to open %s; %w", f.Name, err)
+			}
+			out, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("dmarc error, failed to read %s; %w", f.Name, err)
+			}
+			return out, nil
+		}
+		return nil, fmt.Errorf("dmarc error, zip report has no xml file")
+	}
+	return data, nil
+}
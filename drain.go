@@ -0,0 +1,101 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"sync"
+	"time"
+)
+
+// drainPeriod is how long a server that announced its shutdown is avoided while other servers are available.
+const drainPeriod = time.Minute
+
+// DrainEvent reports a server that announced it is shutting down with a 421 reply.
+type DrainEvent struct {
+	// Relay is the name of the drained relay, empty when no relays are configured.
+	Relay string
+	// Addr is the address of the drained server.
+	Addr  string
+	Reply string
+	Time  time.Time
+}
+
+// drainState tracks the servers that announced their shutdown.
+type drainState struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+// mark avoids the server with the given key for the drain period.
+func (d *drainState) mark(key string, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.until == nil {
+		d.until = map[string]time.Time{}
+	}
+	d.until[key] = now.Add(drainPeriod)
+}
+
+// draining reports whether the server with the given key is still being avoided.
+func (d *drainState) draining(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	until, ok := d.until[key]
+	if ok && time.Now().After(until) {
+		delete(d.until, key)
+		return false
+	}
+	return ok
+}
+
+// drainKey identifies a server by its relay name or, without relays, by its address.
+func drainKey(relay, addr string) string {
+	if relay != "" {
+		return "relay:" + relay
+	}
+	return addr
+}
+
+// isDrain reports whether err is a 421 reply, which the server sends when it is closing the channel.
+func isDrain(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code == 421
+}
+
+// drained avoids the server of the session, closes its idle pooled sessions, and notifies the drain handler.
+func (c *SMTP) drained(sess *session, err error) {
+	now := time.Now()
+	c.draining.mark(drainKey(sess.relay, sess.addr), now)
+	c.pool.discard(sess.relay, sess.addr)
+
+	var protoErr *textproto.Error
+	errors.As(err, &protoErr)
+	event := DrainEvent{Relay: sess.relay, Addr: sess.addr, Reply: protoErr.Msg, Time: now}
+
+	c.log().Warn("smtp server draining", "relay", event.Relay, "address", event.Addr, "reply", event.Reply)
+	if c.onDrain != nil {
+		c.onDrain(event)
+	}
+}
+
+// deliverRouted delivers the message and, when the server announces its shutdown, transparently moves it to
+// another pooled session, failover host, or relay. It returns the relay of the last attempt.
+func (c *SMTP) deliverRouted(ctx context.Context, email Email, from string, msg *Message) (*Message, *relayState, error) {
+	reroutes := len(c.fallbacks) + len(c.relays) + 1
+
+	relay, err := c.selectRelay(email)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err := c.deliver(ctx, email, from, msg, relay)
+	for i := 0; i < reroutes && isDrain(err) && ctx.Err() == nil; i++ {
+		if relay, err = c.selectRelay(email); err != nil {
+			return nil, nil, err
+		}
+		out, err = c.deliver(ctx, email, from, msg, relay)
+	}
+	return out, relay, err
+}
@@ -0,0 +1,24 @@
+package smtp
+
+// sendDry signs and validates the assembled message as if the server supported SMTPUTF8 and 8BITMIME,
+// then archives, logs, and tees it instead of delivering it.
+func (c *SMTP) sendDry(email Email, msg *Message) error {
+	if c.dkim != nil {
+		if err := c.dkim.signAt(msg, c.now()); err != nil {
+			return err
+		}
+	}
+
+	if size := msg.EstimateSize(); c.maxSize > 0 && size > c.maxSize {
+		return &MessageSizeError{Size: size, Limit: c.maxSize, Local: true}
+	}
+	if err := ValidateMessage(msg.Bytes(), true); err != nil {
+		c.log().Error("smtp dry run failed", "message_id", msg.Get("Message-ID"), "error", err)
+		return err
+	}
+
+	c.log().Info("smtp dry run", "message_id", msg.Get("Message-ID"), "recipients", len(email.recipients()), "size", len(msg.Bytes()))
+	c.archive(email, msg, nil)
+	c.tee(msg)
+	return nil
+}
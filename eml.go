@@ -0,0 +1,83 @@
+package smtp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExportEML returns the message as an .eml file: the wire representation with CRLF line endings,
+// ending in a line break, as read by Outlook and Thunderbird.
+func (m *Message) ExportEML() []byte {
+	var b bytes.Buffer
+	m.WriteTo(&b)
+	return b.Bytes()
+}
+
+// WriteTo writes the message to w in .eml format. The parts are encoded as they are written, and streamed
+// attachments are read on demand, so the message is never assembled in memory.
+func (m *Message) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	if _, err := io.WriteString(cw, m.headerText()); err != nil {
+		return cw.n, err
+	}
+
+	if _, err := m.writeBody(cw); err != nil {
+		return cw.n, err
+	}
+
+	if cw.last != '\n' {
+		_, err := io.WriteString(cw, "\r\n")
+		return cw.n, err
+	}
+	return cw.n, nil
+}
+
+// TeeFunc opens the destination of a copy of an outgoing message.
+type TeeFunc func(msg *Message) (io.WriteCloser, error)
+
+// TeeDir returns a TeeFunc that writes every message to its own .eml file in dir, named after its Message-ID.
+func TeeDir(dir string) TeeFunc {
+	return func(msg *Message) (io.WriteCloser, error) {
+		name := strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_', r == '@':
+				return r
+			}
+			return -1
+		}, msg.Get("Message-ID"))
+		if name == "" {
+			id, err := newID()
+			if err != nil {
+				return nil, err
+			}
+			name = id
+		}
+		return os.OpenFile(filepath.Join(dir, name+".eml"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	}
+}
+
+// tee writes a copy of a message accepted by the server to the configured destination.
+func (c *SMTP) tee(msg *Message) {
+	if c.teeFunc == nil {
+		return
+	}
+
+	err := func() error {
+		w, err := c.teeFunc(msg)
+		if err != nil {
+			return fmt.Errorf("tee error, failed to open destination; %w", err)
+		}
+		if _, err = msg.WriteTo(w); err != nil {
+			w.Close()
+			return fmt.Errorf("tee error, failed to write message; %w", err)
+		}
+		return w.Close()
+	}()
+	if err != nil {
+		c.log().Error("smtp tee failed", "message_id", msg.Get("Message-ID"), "error", err)
+	}
+}
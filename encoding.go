@@ -0,0 +1,59 @@
+package smtp
+
+import "strings"
+
+// Encoding is a Content-Transfer-Encoding of a MIME part.
+type Encoding string
+
+// Encodings. EncodingAuto picks one from the content.
+const (
+	EncodingAuto            Encoding = ""
+	Encoding7Bit            Encoding = "7bit"
+	Encoding8Bit            Encoding = "8bit"
+	EncodingQuotedPrintable Encoding = "quoted-printable"
+	EncodingBase64          Encoding = "base64"
+)
+
+// chooseEncoding returns 7bit for ASCII text with lines within the RFC 5322 limit, quoted-printable for text that is
+// mostly ASCII, and base64 for text where quoted-printable would be larger, such as Cyrillic or CJK scripts.
+// Quoted-printable spends three bytes per non-ASCII byte and base64 four bytes per three, so quoted-printable
+// wins while fewer than one in six bytes are non-ASCII.
+func chooseEncoding(text string) Encoding {
+	nonASCII := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] >= 0x80 {
+			nonASCII++
+		}
+	}
+
+	switch {
+	case nonASCII == 0 && !hasLongLine(text):
+		return Encoding7Bit
+	case nonASCII*5 < len(text)-nonASCII:
+		return EncodingQuotedPrintable
+	default:
+		return EncodingBase64
+	}
+}
+
+// fitEncoding returns enc unless the text cannot be sent with it: 7bit text must be ASCII, and neither 7bit nor
+// 8bit text may have lines over the 998 characters allowed by RFC 5322, so such text gets the encoding chosen
+// for it instead, as does text with EncodingAuto.
+func fitEncoding(enc Encoding, text string) Encoding {
+	switch {
+	case enc == EncodingAuto,
+		enc == Encoding7Bit && (!isASCII(text) || hasLongLine(text)),
+		enc == Encoding8Bit && hasLongLine(text):
+		return chooseEncoding(text)
+	}
+	return enc
+}
+
+// normalizeEncoding returns enc in lower case, or EncodingAuto when it is not a known encoding.
+func normalizeEncoding(enc Encoding) Encoding {
+	switch e := Encoding(strings.ToLower(strings.TrimSpace(string(enc)))); e {
+	case Encoding7Bit, Encoding8Bit, EncodingQuotedPrintable, EncodingBase64:
+		return e
+	}
+	return EncodingAuto
+}
@@ -0,0 +1,66 @@
+package smtp
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// DialFunc opens the network connection to a relay, e.g. through a service mesh or proxy.
+// The network is "tcp" for host:port endpoints and "unix" for unix:// endpoints.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// endpoint is a single relay address.
+type endpoint struct {
+	network   string
+	host      string
+	port      string
+	localAddr string
+}
+
+// address returns the address passed to the dialer.
+func (e endpoint) address() string {
+	if e.network == "unix" {
+		return e.host
+	}
+	return net.JoinHostPort(e.host, e.port)
+}
+
+// parseEndpoint parses "host:port", "host", or "unix:///path/to/socket", using port when none is given.
+func parseEndpoint(addr, port string) endpoint {
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		return endpoint{network: "unix", host: path}
+	}
+
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return endpoint{network: "tcp", host: addr, port: port}
+	}
+	return endpoint{network: "tcp", host: host, port: p}
+}
+
+// endpoints returns the relays in the order they should be tried.
+func (c *SMTP) endpoints() []endpoint {
+	eps := make([]endpoint, 0, len(c.fallbacks)+1)
+	eps = append(eps, parseEndpoint(c.host, c.port))
+	eps = append(eps, c.fallbacks...)
+
+	// Servers that announced their shutdown are skipped while another one is available
+	var available []endpoint
+	for _, ep := range eps {
+		if !c.draining.draining(ep.address()) {
+			available = append(available, ep)
+		}
+	}
+	if len(available) > 0 {
+		eps = available
+	}
+
+	if !c.roundRobin || len(eps) == 1 {
+		return eps
+	}
+
+	start := int(atomic.AddUint32(&c.next, 1)-1) % len(eps)
+	return append(eps[start:], eps[:start]...)
+}
@@ -0,0 +1,85 @@
+package smtp
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultEventBuffer is the number of events WithEvents holds when it is given no buffer size.
+const defaultEventBuffer = 1024
+
+// EventHandler defines the methods that any receiver of send events must implement, such as a Webhook or an
+// audit log writer. Notify is called from a single background goroutine per handler.
+type EventHandler interface {
+	Notify(ctx context.Context, event WebhookEvent) error
+}
+
+// EventHandlerFunc adapts an ordinary function to the EventHandler interface.
+type EventHandlerFunc func(ctx context.Context, event WebhookEvent) error
+
+// Notify calls f(ctx, event).
+func (f EventHandlerFunc) Notify(ctx context.Context, event WebhookEvent) error {
+	return f(ctx, event)
+}
+
+// eventQueue hands the events of a client to one handler in the background, so a slow handler never delays
+// a send. Events are dropped when the buffer is full.
+type eventQueue struct {
+	handler EventHandler
+	// types limits the queued events; nil queues every event
+	types   map[string]bool
+	events  chan WebhookEvent
+	pending *sync.WaitGroup
+	start   sync.Once
+	closing sync.Once
+}
+
+// newEventQueue returns a queue holding up to buffer events for handler. pending counts the queued events
+// that were not handled yet; it is a new WaitGroup when nil.
+func newEventQueue(handler EventHandler, buffer int, pending *sync.WaitGroup, types ...string) *eventQueue {
+	if buffer <= 0 {
+		buffer = defaultEventBuffer
+	}
+	if pending == nil {
+		pending = &sync.WaitGroup{}
+	}
+
+	q := &eventQueue{handler: handler, events: make(chan WebhookEvent, buffer), pending: pending}
+	if len(types) != 0 {
+		q.types = map[string]bool{}
+		for _, t := range types {
+			q.types[t] = true
+		}
+	}
+	return q
+}
+
+// emit queues the event on every configured handler that takes its type.
+func (c *SMTP) emit(event WebhookEvent) {
+	for _, q := range c.events {
+		if q.types != nil && !q.types[event.Type] {
+			continue
+		}
+		q.start.Do(func() { go c.handleEvents(q) })
+
+		q.pending.Add(1)
+		select {
+		case q.events <- event:
+		default:
+			q.pending.Done()
+			c.log().Warn("smtp event dropped, buffer is full", "event", event.Type, "message_id", event.MessageID)
+		}
+	}
+}
+
+// handleEvents passes the events of the queue to its handler one at a time.
+func (c *SMTP) handleEvents(q *eventQueue) {
+	// The handler is canceled when Shutdown gives up
+	ctx := c.life.aborted()
+	for event := range q.events {
+		if err := q.handler.Notify(ctx, event); err != nil {
+			c.log().Error("smtp event handler failed", "event", event.Type, "message_id", event.MessageID, "error", err)
+		}
+		q.pending.Done()
+	}
+}
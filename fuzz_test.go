@@ -0,0 +1,73 @@
+package smtp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// FuzzRoundTrip checks that parsing never panics and that every parsed message survives a Marshal and
+// ParseMessage round trip with the same subject and attachments.
+func FuzzRoundTrip(f *testing.F) {
+	f.Add([]byte("From: a@example.com\r\nTo: b@example.com\r\nSubject: Hello\r\n\r\nBody\r\n"))
+	f.Add([]byte("Subject: =?utf-8?B?w6nDqMOg?=\r\nContent-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n\r\nCaf=C3=A9 =\r\nsoft break\r\n"))
+	f.Add([]byte("Subject: Alternative\r\nMIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=\"b\"\r\n\r\n" +
+		"--b\r\nContent-Type: text/plain\r\n\r\nplain\r\n--b\r\nContent-Type: text/html\r\n\r\n<p>html</p>\r\n--b--\r\n"))
+	f.Add([]byte("Subject: Attachment\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=\"m\"\r\n\r\n" +
+		"--m\r\nContent-Type: text/plain\r\n\r\nsee attached\r\n" +
+		"--m\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=\"a.pdf\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\nJVBERi0xLjQK\r\n--m--\r\n"))
+	f.Add([]byte("Subject: Folded\r\n subject line\r\nContent-Type: multipart/mixed; boundary=\"unterminated\"\r\n\r\n--unterminated\r\n"))
+	f.Add([]byte("no header separator"))
+	f.Add(nestedMultipart(100))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		email, err := ParseMessage(data)
+		if err != nil {
+			return
+		}
+
+		out, err := Marshal(email)
+		if err != nil {
+			return
+		}
+
+		again, err := ParseMessage(out)
+		if err != nil {
+			t.Fatalf("marshaled message does not parse; %v", err)
+		}
+		if again.Subject != email.Subject {
+			t.Fatalf("subject changed from %q to %q", email.Subject, again.Subject)
+		}
+		if len(again.Attachments) != len(email.Attachments) {
+			t.Fatalf("attachment count changed from %d to %d", len(email.Attachments), len(again.Attachments))
+		}
+	})
+}
+
+// nestedMultipart returns a message of multiparts nested depth deep around a single text part.
+func nestedMultipart(depth int) []byte {
+	var b strings.Builder
+	for i := 0; i < depth; i++ {
+		fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=b%d\r\n\r\n--b%d\r\n", i, i)
+	}
+	b.WriteString("Content-Type: text/plain\r\n\r\nnested\r\n")
+	for i := depth - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "--b%d--\r\n", i)
+	}
+	return []byte(b.String())
+}
+
+func TestParseMessageDepth(t *testing.T) {
+	email, err := ParseMessage(nestedMultipart(maxEntityDepth))
+	if err != nil {
+		t.Fatalf("message nested %d deep: %v", maxEntityDepth, err)
+	}
+	if email.Body != "nested" {
+		t.Fatalf("body is %q, want the nested text", email.Body)
+	}
+	if _, err = ParseMessage(nestedMultipart(maxEntityDepth + 1)); err == nil {
+		t.Fatalf("message nested %d deep parsed", maxEntityDepth+1)
+	}
+}
@@ -0,0 +1,142 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GreylistPolicy controls how sends that the server asks to come back later are deferred, see WithGreylisting.
+type GreylistPolicy struct {
+	// Delay is the wait before the email is sent again when the reply does not say how long to wait.
+	// It defaults to five minutes.
+	Delay time.Duration
+	// MaxDelay caps the wait a reply asks for, e.g. "try again in 3600 seconds". It defaults to an hour.
+	MaxDelay time.Duration
+	// MaxDeferrals is how often an email is deferred before the reply is retried like any other temporary
+	// failure. It defaults to three.
+	MaxDeferrals int
+}
+
+const (
+	defaultGreylistDelay    = 5 * time.Minute
+	defaultGreylistMaxDelay = time.Hour
+	defaultMaxDeferrals     = 3
+)
+
+// greylisting defers greylisted sends to a schedule store.
+type greylisting struct {
+	store  ScheduleStore
+	policy GreylistPolicy
+}
+
+// retryHint matches the wait a reply asks for, e.g. "please try again in 300 seconds" or "retry after 5 min".
+var retryHint = regexp.MustCompile(`(?i)\b(?:in|after|wait)\s+(\d+)\s*(s|secs?|seconds?|m|mins?|minutes?)\b`)
+
+// IsGreylisted reports whether err is a reply that asks the sender to come back later rather than retry at
+// once: 450 and 451, which greylisting servers send to unknown senders, and 421, which a server sends when it
+// is shutting down.
+func IsGreylisted(err error) bool {
+	var lmtpErr *LMTPError
+	if errors.As(err, &lmtpErr) && lmtpErr.Accepted > 0 {
+		return false
+	}
+
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) {
+		return false
+	}
+	switch protoErr.Code {
+	case 421, 450, 451:
+		return true
+	}
+	return false
+}
+
+// DeferredError describes a deferred send in the archive record. The send itself returns no error: the email
+// is queued to be sent again, see SendResult.Deferred.
+type DeferredError struct {
+	// RetryAt is when the email is sent again.
+	RetryAt time.Time
+	// Err is the reply that deferred the send.
+	Err error
+}
+
+func (e *DeferredError) Error() string {
+	return fmt.Sprintf("send error, deferred until %s; %s", e.RetryAt.Format(time.RFC3339), e.Err.Error())
+}
+
+// Temporary reports false: the deferred email is queued again instead of being retried in the same send.
+func (e *DeferredError) Temporary() bool {
+	return false
+}
+
+// retryAt returns when a send deferred by err is sent again, preferring the wait the reply asks for.
+func (g *greylisting) retryAt(err error, now time.Time) time.Time {
+	delay := g.policy.Delay
+	if delay <= 0 {
+		delay = defaultGreylistDelay
+	}
+	limit := g.policy.MaxDelay
+	if limit <= 0 {
+		limit = defaultGreylistMaxDelay
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		if m := retryHint.FindStringSubmatch(protoErr.Msg); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			unit := time.Second
+			if strings.HasPrefix(strings.ToLower(m[2]), "m") {
+				unit = time.Minute
+			}
+			if hint := time.Duration(n) * unit; hint > 0 {
+				delay = hint
+			}
+		}
+	}
+	return now.Add(min(delay, limit))
+}
+
+// schedule queues the email to be sent again after the reply err and returns the deferral. It returns nil when
+// the email was deferred too often or would be sent after its delivery deadline, or when the store fails; the
+// send then goes on with its retries.
+func (c *SMTP) schedule(email Email, err error) *DeferredError {
+	g := c.greylist
+	maxDeferrals := g.policy.MaxDeferrals
+	if maxDeferrals <= 0 {
+		maxDeferrals = defaultMaxDeferrals
+	}
+	if email.Deferrals >= maxDeferrals {
+		return nil
+	}
+
+	now := time.Now()
+	at := g.retryAt(err, now)
+	queued := email
+	if !email.deliverBy.IsZero() {
+		// The deadline follows the client clock while the retry follows the system clock
+		left, wait := email.deliverBy.Sub(c.now()), at.Sub(now)
+		if wait >= left {
+			return nil
+		}
+		queued.DeliverBy = left - wait
+	}
+	queued.Deferrals++
+	// The key was claimed by this send, which the queued email continues
+	queued.IdempotencyKey = ""
+
+	id, idErr := newID()
+	if idErr == nil {
+		idErr = g.store.Save(ScheduledEmail{ID: id, SendAt: at, Email: queued})
+	}
+	if idErr != nil {
+		c.log().Warn("smtp send not deferred, retrying instead", "error", idErr)
+		return nil
+	}
+	return &DeferredError{RetryAt: at, Err: err}
+}
@@ -0,0 +1,78 @@
+// Command smtpgrpcd runs the gRPC Mailer service in front of a relay, so that services in any language can
+// send mail through one deployment of the library.
+//
+// Usage:
+//
+//	SMTP_HOST=smtp.example.com SMTP_FROM=app@example.com SMTP_PASSWORD=secret smtpgrpcd -listen :50051
+//
+// The connection settings are read from the environment variables read by smtp.ConfigFromEnv. The service
+// stops gracefully on SIGINT or SIGTERM, cancelling scheduled messages and finishing the sends in progress.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dexterdmonkey/go-smtp"
+	smtpgrpc "github.com/dexterdmonkey/go-smtp/grpc"
+	smtpv1 "github.com/dexterdmonkey/go-smtp/grpc/proto/smtp/v1"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "smtpgrpcd:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("smtpgrpcd", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	listen := fs.String("listen", ":50051", "address to serve gRPC on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := smtp.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	mail, err := smtp.NewFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+
+	srv := smtpgrpc.NewServer(mail)
+	s := grpc.NewServer()
+	smtpv1.RegisterMailerServer(s, srv)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		s.GracefulStop()
+	}()
+
+	fmt.Fprintf(stderr, "serving on %s\n", ln.Addr())
+	if err = s.Serve(ln); err != nil {
+		return err
+	}
+
+	srv.Close()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return mail.Shutdown(shutdownCtx)
+}
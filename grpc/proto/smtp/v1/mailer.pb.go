@@ -0,0 +1,837 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: smtp/v1/mailer.proto
+
+package smtpv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type State int32
+
+const (
+	State_STATE_UNSPECIFIED State = 0
+	State_STATE_SCHEDULED   State = 1
+	State_STATE_SENDING     State = 2
+	State_STATE_DELIVERED   State = 3
+	// STATE_DEFERRED means the relay asked to come back later and the library queued the email again.
+	State_STATE_DEFERRED State = 4
+	State_STATE_FAILED   State = 5
+	// STATE_DEAD_LETTERED means every attempt failed temporarily and the message was given up on.
+	State_STATE_DEAD_LETTERED State = 6
+	State_STATE_CANCELLED     State = 7
+)
+
+// Enum value maps for State.
+var (
+	State_name = map[int32]string{
+		0: "STATE_UNSPECIFIED",
+		1: "STATE_SCHEDULED",
+		2: "STATE_SENDING",
+		3: "STATE_DELIVERED",
+		4: "STATE_DEFERRED",
+		5: "STATE_FAILED",
+		6: "STATE_DEAD_LETTERED",
+		7: "STATE_CANCELLED",
+	}
+	State_value = map[string]int32{
+		"STATE_UNSPECIFIED":   0,
+		"STATE_SCHEDULED":     1,
+		"STATE_SENDING":       2,
+		"STATE_DELIVERED":     3,
+		"STATE_DEFERRED":      4,
+		"STATE_FAILED":        5,
+		"STATE_DEAD_LETTERED": 6,
+		"STATE_CANCELLED":     7,
+	}
+)
+
+func (x State) Enum() *State {
+	p := new(State)
+	*p = x
+	return p
+}
+
+func (x State) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (State) Descriptor() protoreflect.EnumDescriptor {
+	return file_smtp_v1_mailer_proto_enumTypes[0].Descriptor()
+}
+
+func (State) Type() protoreflect.EnumType {
+	return &file_smtp_v1_mailer_proto_enumTypes[0]
+}
+
+func (x State) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use State.Descriptor instead.
+func (State) EnumDescriptor() ([]byte, []int) {
+	return file_smtp_v1_mailer_proto_rawDescGZIP(), []int{0}
+}
+
+type Attachment struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filename      string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	ContentType   string                 `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Attachment) Reset() {
+	*x = Attachment{}
+	mi := &file_smtp_v1_mailer_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Attachment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Attachment) ProtoMessage() {}
+
+func (x *Attachment) ProtoReflect() protoreflect.Message {
+	mi := &file_smtp_v1_mailer_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Attachment.ProtoReflect.Descriptor instead.
+func (*Attachment) Descriptor() ([]byte, []int) {
+	return file_smtp_v1_mailer_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Attachment) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *Attachment) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Attachment) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type Email struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	To          []string               `protobuf:"bytes,1,rep,name=to,proto3" json:"to,omitempty"`
+	Cc          []string               `protobuf:"bytes,2,rep,name=cc,proto3" json:"cc,omitempty"`
+	Bcc         []string               `protobuf:"bytes,3,rep,name=bcc,proto3" json:"bcc,omitempty"`
+	Subject     string                 `protobuf:"bytes,4,opt,name=subject,proto3" json:"subject,omitempty"`
+	Body        string                 `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
+	HtmlBody    string                 `protobuf:"bytes,6,opt,name=html_body,json=htmlBody,proto3" json:"html_body,omitempty"`
+	Headers     map[string]string      `protobuf:"bytes,7,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Attachments []*Attachment          `protobuf:"bytes,8,rep,name=attachments,proto3" json:"attachments,omitempty"`
+	FromName    string                 `protobuf:"bytes,9,opt,name=from_name,json=fromName,proto3" json:"from_name,omitempty"`
+	Categories  []string               `protobuf:"bytes,10,rep,name=categories,proto3" json:"categories,omitempty"`
+	// profile names a predefined send profile, "otp" or "bulk".
+	Profile string `protobuf:"bytes,11,opt,name=profile,proto3" json:"profile,omitempty"`
+	// list_unsubscribe is the List-Unsubscribe URI of the bulk profile.
+	ListUnsubscribe string `protobuf:"bytes,12,opt,name=list_unsubscribe,json=listUnsubscribe,proto3" json:"list_unsubscribe,omitempty"`
+	// idempotency_key skips the send when a message with the same key was already sent.
+	IdempotencyKey string `protobuf:"bytes,13,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	Locale         string `protobuf:"bytes,14,opt,name=locale,proto3" json:"locale,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Email) Reset() {
+	*x = Email{}
+	mi := &file_smtp_v1_mailer_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Email) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Email) ProtoMessage() {}
+
+func (x *Email) ProtoReflect() protoreflect.Message {
+	mi := &file_smtp_v1_mailer_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Email.ProtoReflect.Descriptor instead.
+func (*Email) Descriptor() ([]byte, []int) {
+	return file_smtp_v1_mailer_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Email) GetTo() []string {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *Email) GetCc() []string {
+	if x != nil {
+		return x.Cc
+	}
+	return nil
+}
+
+func (x *Email) GetBcc() []string {
+	if x != nil {
+		return x.Bcc
+	}
+	return nil
+}
+
+func (x *Email) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *Email) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *Email) GetHtmlBody() string {
+	if x != nil {
+		return x.HtmlBody
+	}
+	return ""
+}
+
+func (x *Email) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *Email) GetAttachments() []*Attachment {
+	if x != nil {
+		return x.Attachments
+	}
+	return nil
+}
+
+func (x *Email) GetFromName() string {
+	if x != nil {
+		return x.FromName
+	}
+	return ""
+}
+
+func (x *Email) GetCategories() []string {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+func (x *Email) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+func (x *Email) GetListUnsubscribe() string {
+	if x != nil {
+		return x.ListUnsubscribe
+	}
+	return ""
+}
+
+func (x *Email) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+func (x *Email) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+type SendRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Email         *Email                 `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	SendAt        *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=send_at,json=sendAt,proto3" json:"send_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendRequest) Reset() {
+	*x = SendRequest{}
+	mi := &file_smtp_v1_mailer_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendRequest) ProtoMessage() {}
+
+func (x *SendRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_smtp_v1_mailer_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendRequest.ProtoReflect.Descriptor instead.
+func (*SendRequest) Descriptor() ([]byte, []int) {
+	return file_smtp_v1_mailer_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SendRequest) GetEmail() *Email {
+	if x != nil {
+		return x.Email
+	}
+	return nil
+}
+
+func (x *SendRequest) GetSendAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SendAt
+	}
+	return nil
+}
+
+type SendTemplateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Email         *Email                 `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	Template      string                 `protobuf:"bytes,2,opt,name=template,proto3" json:"template,omitempty"`
+	Params        map[string]string      `protobuf:"bytes,3,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	SendAt        *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=send_at,json=sendAt,proto3" json:"send_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendTemplateRequest) Reset() {
+	*x = SendTemplateRequest{}
+	mi := &file_smtp_v1_mailer_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendTemplateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendTemplateRequest) ProtoMessage() {}
+
+func (x *SendTemplateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_smtp_v1_mailer_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendTemplateRequest.ProtoReflect.Descriptor instead.
+func (*SendTemplateRequest) Descriptor() ([]byte, []int) {
+	return file_smtp_v1_mailer_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SendTemplateRequest) GetEmail() *Email {
+	if x != nil {
+		return x.Email
+	}
+	return nil
+}
+
+func (x *SendTemplateRequest) GetTemplate() string {
+	if x != nil {
+		return x.Template
+	}
+	return ""
+}
+
+func (x *SendTemplateRequest) GetParams() map[string]string {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+func (x *SendTemplateRequest) GetSendAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SendAt
+	}
+	return nil
+}
+
+type SendResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id identifies the message in Status and Cancel calls.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// message_id is the Message-ID header of a sent message; it is empty for a scheduled one.
+	MessageId     string `protobuf:"bytes,2,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	State         State  `protobuf:"varint,3,opt,name=state,proto3,enum=smtp.v1.State" json:"state,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendResponse) Reset() {
+	*x = SendResponse{}
+	mi := &file_smtp_v1_mailer_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendResponse) ProtoMessage() {}
+
+func (x *SendResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_smtp_v1_mailer_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendResponse.ProtoReflect.Descriptor instead.
+func (*SendResponse) Descriptor() ([]byte, []int) {
+	return file_smtp_v1_mailer_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SendResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *SendResponse) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *SendResponse) GetState() State {
+	if x != nil {
+		return x.State
+	}
+	return State_STATE_UNSPECIFIED
+}
+
+type StatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusRequest) Reset() {
+	*x = StatusRequest{}
+	mi := &file_smtp_v1_mailer_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusRequest) ProtoMessage() {}
+
+func (x *StatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_smtp_v1_mailer_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return file_smtp_v1_mailer_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *StatusRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type StatusResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	State         State                  `protobuf:"varint,2,opt,name=state,proto3,enum=smtp.v1.State" json:"state,omitempty"`
+	MessageId     string                 `protobuf:"bytes,3,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusResponse) Reset() {
+	*x = StatusResponse{}
+	mi := &file_smtp_v1_mailer_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusResponse) ProtoMessage() {}
+
+func (x *StatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_smtp_v1_mailer_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
+func (*StatusResponse) Descriptor() ([]byte, []int) {
+	return file_smtp_v1_mailer_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StatusResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetState() State {
+	if x != nil {
+		return x.State
+	}
+	return State_STATE_UNSPECIFIED
+}
+
+func (x *StatusResponse) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type CancelRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelRequest) Reset() {
+	*x = CancelRequest{}
+	mi := &file_smtp_v1_mailer_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelRequest) ProtoMessage() {}
+
+func (x *CancelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_smtp_v1_mailer_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelRequest.ProtoReflect.Descriptor instead.
+func (*CancelRequest) Descriptor() ([]byte, []int) {
+	return file_smtp_v1_mailer_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CancelRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type CancelResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelResponse) Reset() {
+	*x = CancelResponse{}
+	mi := &file_smtp_v1_mailer_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelResponse) ProtoMessage() {}
+
+func (x *CancelResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_smtp_v1_mailer_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelResponse.ProtoReflect.Descriptor instead.
+func (*CancelResponse) Descriptor() ([]byte, []int) {
+	return file_smtp_v1_mailer_proto_rawDescGZIP(), []int{8}
+}
+
+var File_smtp_v1_mailer_proto protoreflect.FileDescriptor
+
+const file_smtp_v1_mailer_proto_rawDesc = "" +
+	"\n" +
+	"\x14smtp/v1/mailer.proto\x12\asmtp.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"_\n" +
+	"\n" +
+	"Attachment\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12!\n" +
+	"\fcontent_type\x18\x02 \x01(\tR\vcontentType\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\"\xf1\x03\n" +
+	"\x05Email\x12\x0e\n" +
+	"\x02to\x18\x01 \x03(\tR\x02to\x12\x0e\n" +
+	"\x02cc\x18\x02 \x03(\tR\x02cc\x12\x10\n" +
+	"\x03bcc\x18\x03 \x03(\tR\x03bcc\x12\x18\n" +
+	"\asubject\x18\x04 \x01(\tR\asubject\x12\x12\n" +
+	"\x04body\x18\x05 \x01(\tR\x04body\x12\x1b\n" +
+	"\thtml_body\x18\x06 \x01(\tR\bhtmlBody\x125\n" +
+	"\aheaders\x18\a \x03(\v2\x1b.smtp.v1.Email.HeadersEntryR\aheaders\x125\n" +
+	"\vattachments\x18\b \x03(\v2\x13.smtp.v1.AttachmentR\vattachments\x12\x1b\n" +
+	"\tfrom_name\x18\t \x01(\tR\bfromName\x12\x1e\n" +
+	"\n" +
+	"categories\x18\n" +
+	" \x03(\tR\n" +
+	"categories\x12\x18\n" +
+	"\aprofile\x18\v \x01(\tR\aprofile\x12)\n" +
+	"\x10list_unsubscribe\x18\f \x01(\tR\x0flistUnsubscribe\x12'\n" +
+	"\x0fidempotency_key\x18\r \x01(\tR\x0eidempotencyKey\x12\x16\n" +
+	"\x06locale\x18\x0e \x01(\tR\x06locale\x1a:\n" +
+	"\fHeadersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"h\n" +
+	"\vSendRequest\x12$\n" +
+	"\x05email\x18\x01 \x01(\v2\x0e.smtp.v1.EmailR\x05email\x123\n" +
+	"\asend_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x06sendAt\"\x89\x02\n" +
+	"\x13SendTemplateRequest\x12$\n" +
+	"\x05email\x18\x01 \x01(\v2\x0e.smtp.v1.EmailR\x05email\x12\x1a\n" +
+	"\btemplate\x18\x02 \x01(\tR\btemplate\x12@\n" +
+	"\x06params\x18\x03 \x03(\v2(.smtp.v1.SendTemplateRequest.ParamsEntryR\x06params\x123\n" +
+	"\asend_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x06sendAt\x1a9\n" +
+	"\vParamsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"c\n" +
+	"\fSendResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x02 \x01(\tR\tmessageId\x12$\n" +
+	"\x05state\x18\x03 \x01(\x0e2\x0e.smtp.v1.StateR\x05state\"\x1f\n" +
+	"\rStatusRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xb6\x01\n" +
+	"\x0eStatusResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12$\n" +
+	"\x05state\x18\x02 \x01(\x0e2\x0e.smtp.v1.StateR\x05state\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x03 \x01(\tR\tmessageId\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x129\n" +
+	"\n" +
+	"updated_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"\x1f\n" +
+	"\rCancelRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x10\n" +
+	"\x0eCancelResponse*\xaf\x01\n" +
+	"\x05State\x12\x15\n" +
+	"\x11STATE_UNSPECIFIED\x10\x00\x12\x13\n" +
+	"\x0fSTATE_SCHEDULED\x10\x01\x12\x11\n" +
+	"\rSTATE_SENDING\x10\x02\x12\x13\n" +
+	"\x0fSTATE_DELIVERED\x10\x03\x12\x12\n" +
+	"\x0eSTATE_DEFERRED\x10\x04\x12\x10\n" +
+	"\fSTATE_FAILED\x10\x05\x12\x17\n" +
+	"\x13STATE_DEAD_LETTERED\x10\x06\x12\x13\n" +
+	"\x0fSTATE_CANCELLED\x10\a2\xf8\x01\n" +
+	"\x06Mailer\x123\n" +
+	"\x04Send\x12\x14.smtp.v1.SendRequest\x1a\x15.smtp.v1.SendResponse\x12C\n" +
+	"\fSendTemplate\x12\x1c.smtp.v1.SendTemplateRequest\x1a\x15.smtp.v1.SendResponse\x129\n" +
+	"\x06Status\x12\x16.smtp.v1.StatusRequest\x1a\x17.smtp.v1.StatusResponse\x129\n" +
+	"\x06Cancel\x12\x16.smtp.v1.CancelRequest\x1a\x17.smtp.v1.CancelResponseB<Z:github.com/dexterdmonkey/go-smtp/grpc/proto/smtp/v1;smtpv1b\x06proto3"
+
+var (
+	file_smtp_v1_mailer_proto_rawDescOnce sync.Once
+	file_smtp_v1_mailer_proto_rawDescData []byte
+)
+
+func file_smtp_v1_mailer_proto_rawDescGZIP() []byte {
+	file_smtp_v1_mailer_proto_rawDescOnce.Do(func() {
+		file_smtp_v1_mailer_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_smtp_v1_mailer_proto_rawDesc), len(file_smtp_v1_mailer_proto_rawDesc)))
+	})
+	return file_smtp_v1_mailer_proto_rawDescData
+}
+
+var file_smtp_v1_mailer_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_smtp_v1_mailer_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_smtp_v1_mailer_proto_goTypes = []any{
+	(State)(0),                    // 0: smtp.v1.State
+	(*Attachment)(nil),            // 1: smtp.v1.Attachment
+	(*Email)(nil),                 // 2: smtp.v1.Email
+	(*SendRequest)(nil),           // 3: smtp.v1.SendRequest
+	(*SendTemplateRequest)(nil),   // 4: smtp.v1.SendTemplateRequest
+	(*SendResponse)(nil),          // 5: smtp.v1.SendResponse
+	(*StatusRequest)(nil),         // 6: smtp.v1.StatusRequest
+	(*StatusResponse)(nil),        // 7: smtp.v1.StatusResponse
+	(*CancelRequest)(nil),         // 8: smtp.v1.CancelRequest
+	(*CancelResponse)(nil),        // 9: smtp.v1.CancelResponse
+	nil,                           // 10: smtp.v1.Email.HeadersEntry
+	nil,                           // 11: smtp.v1.SendTemplateRequest.ParamsEntry
+	(*timestamppb.Timestamp)(nil), // 12: google.protobuf.Timestamp
+}
+var file_smtp_v1_mailer_proto_depIdxs = []int32{
+	10, // 0: smtp.v1.Email.headers:type_name -> smtp.v1.Email.HeadersEntry
+	1,  // 1: smtp.v1.Email.attachments:type_name -> smtp.v1.Attachment
+	2,  // 2: smtp.v1.SendRequest.email:type_name -> smtp.v1.Email
+	12, // 3: smtp.v1.SendRequest.send_at:type_name -> google.protobuf.Timestamp
+	2,  // 4: smtp.v1.SendTemplateRequest.email:type_name -> smtp.v1.Email
+	11, // 5: smtp.v1.SendTemplateRequest.params:type_name -> smtp.v1.SendTemplateRequest.ParamsEntry
+	12, // 6: smtp.v1.SendTemplateRequest.send_at:type_name -> google.protobuf.Timestamp
+	0,  // 7: smtp.v1.SendResponse.state:type_name -> smtp.v1.State
+	0,  // 8: smtp.v1.StatusResponse.state:type_name -> smtp.v1.State
+	12, // 9: smtp.v1.StatusResponse.updated_at:type_name -> google.protobuf.Timestamp
+	3,  // 10: smtp.v1.Mailer.Send:input_type -> smtp.v1.SendRequest
+	4,  // 11: smtp.v1.Mailer.SendTemplate:input_type -> smtp.v1.SendTemplateRequest
+	6,  // 12: smtp.v1.Mailer.Status:input_type -> smtp.v1.StatusRequest
+	8,  // 13: smtp.v1.Mailer.Cancel:input_type -> smtp.v1.CancelRequest
+	5,  // 14: smtp.v1.Mailer.Send:output_type -> smtp.v1.SendResponse
+	5,  // 15: smtp.v1.Mailer.SendTemplate:output_type -> smtp.v1.SendResponse
+	7,  // 16: smtp.v1.Mailer.Status:output_type -> smtp.v1.StatusResponse
+	9,  // 17: smtp.v1.Mailer.Cancel:output_type -> smtp.v1.CancelResponse
+	14, // [14:18] is the sub-list for method output_type
+	10, // [10:14] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_smtp_v1_mailer_proto_init() }
+func file_smtp_v1_mailer_proto_init() {
+	if File_smtp_v1_mailer_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_smtp_v1_mailer_proto_rawDesc), len(file_smtp_v1_mailer_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_smtp_v1_mailer_proto_goTypes,
+		DependencyIndexes: file_smtp_v1_mailer_proto_depIdxs,
+		EnumInfos:         file_smtp_v1_mailer_proto_enumTypes,
+		MessageInfos:      file_smtp_v1_mailer_proto_msgTypes,
+	}.Build()
+	File_smtp_v1_mailer_proto = out.File
+	file_smtp_v1_mailer_proto_goTypes = nil
+	file_smtp_v1_mailer_proto_depIdxs = nil
+}
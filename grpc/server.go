@@ -0,0 +1,304 @@
+// Package smtpgrpc serves the send pipeline of an smtp client as the gRPC Mailer service defined in
+// proto/smtp/v1/mailer.proto, so the library can run as a standalone mail service shared by teams in other
+// languages. It lives in its own module so that the library itself does not depend on gRPC.
+//
+//	mail, err := smtp.New("app@example.com", password, "smtp.example.com", 587)
+//	...
+//	srv := smtpgrpc.NewServer(mail)
+//	defer srv.Close()
+//
+//	s := grpc.NewServer()
+//	smtpv1.RegisterMailerServer(s, srv)
+//	err = s.Serve(listener)
+package smtpgrpc
+
+//go:generate buf generate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dexterdmonkey/go-smtp"
+	smtpv1 "github.com/dexterdmonkey/go-smtp/grpc/proto/smtp/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultRetention is how long the state of a finished message is kept for Status.
+const defaultRetention = 24 * time.Hour
+
+// Sender defines the methods of *smtp.SMTP that the server sends through.
+type Sender interface {
+	SendMailResult(ctx context.Context, email smtp.Email) (smtp.SendResult, error)
+	RenderTemplate(email *smtp.Email, name string, parameters map[string]interface{}) error
+}
+
+// Server implements smtpv1.MailerServer. Scheduled messages and message states are held in memory, so they
+// are lost on restart; use the Scheduler of the library with a persistent store when schedules must survive one.
+type Server struct {
+	smtpv1.UnimplementedMailerServer
+
+	sender Sender
+
+	// Retention is how long Status reports a message after it was delivered, failed, or cancelled.
+	// The default is 24 hours.
+	Retention time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	messages map[string]*message
+	closed   bool
+}
+
+// message is the state of a message sent or scheduled through the server.
+type message struct {
+	state     smtpv1.State
+	messageID string
+	err       string
+	updated   time.Time
+	timer     *time.Timer
+}
+
+// NewServer initializes and returns a server that sends through sender, usually an *smtp.SMTP.
+func NewServer(sender Sender) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		sender:    sender,
+		Retention: defaultRetention,
+		ctx:       ctx,
+		cancel:    cancel,
+		messages:  map[string]*message{},
+	}
+}
+
+// Send sends the email, or schedules it when send_at is in the future.
+func (s *Server) Send(ctx context.Context, req *smtpv1.SendRequest) (*smtpv1.SendResponse, error) {
+	email, err := toEmail(req.GetEmail())
+	if err != nil {
+		return nil, err
+	}
+	return s.send(ctx, email, req.GetSendAt())
+}
+
+// SendTemplate renders the named template into the email and sends it like Send.
+func (s *Server) SendTemplate(ctx context.Context, req *smtpv1.SendTemplateRequest) (*smtpv1.SendResponse, error) {
+	email, err := toEmail(req.GetEmail())
+	if err != nil {
+		return nil, err
+	}
+
+	params := make(map[string]interface{}, len(req.GetParams()))
+	for key, value := range req.GetParams() {
+		params[key] = value
+	}
+	if err = s.sender.RenderTemplate(&email, req.GetTemplate(), params); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return s.send(ctx, email, req.GetSendAt())
+}
+
+// Status returns the state of a message sent or scheduled by this server.
+func (s *Server) Status(ctx context.Context, req *smtpv1.StatusRequest) (*smtpv1.StatusResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[req.GetId()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown message %s", req.GetId())
+	}
+	return &smtpv1.StatusResponse{
+		Id:        req.GetId(),
+		State:     msg.state,
+		MessageId: msg.messageID,
+		Error:     msg.err,
+		UpdatedAt: timestamppb.New(msg.updated),
+	}, nil
+}
+
+// Cancel removes a scheduled message before it is sent.
+func (s *Server) Cancel(ctx context.Context, req *smtpv1.CancelRequest) (*smtpv1.CancelResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[req.GetId()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown message %s", req.GetId())
+	}
+	if msg.state != smtpv1.State_STATE_SCHEDULED || !msg.timer.Stop() {
+		return nil, status.Errorf(codes.FailedPrecondition, "message %s is %s, not scheduled", req.GetId(), msg.state)
+	}
+
+	msg.state, msg.updated = smtpv1.State_STATE_CANCELLED, time.Now()
+	s.wg.Done()
+	return &smtpv1.CancelResponse{}, nil
+}
+
+// Close cancels the scheduled messages, refuses new ones, and waits for the sends in progress to finish.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	now := time.Now()
+	for _, msg := range s.messages {
+		if msg.state == smtpv1.State_STATE_SCHEDULED && msg.timer.Stop() {
+			msg.state, msg.updated = smtpv1.State_STATE_CANCELLED, now
+			s.wg.Done()
+		}
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	s.cancel()
+}
+
+// send records the email and sends it at once, or at sendAt when that is in the future.
+func (s *Server) send(ctx context.Context, email smtp.Email, sendAt *timestamppb.Timestamp) (*smtpv1.SendResponse, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to generate id; %s", err.Error())
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, status.Error(codes.Unavailable, "server is shut down")
+	}
+	s.prune(time.Now())
+
+	msg := &message{state: smtpv1.State_STATE_SENDING, updated: time.Now()}
+	s.messages[id] = msg
+	s.wg.Add(1)
+	if sendAt != nil {
+		if delay := time.Until(sendAt.AsTime()); delay > 0 {
+			msg.state = smtpv1.State_STATE_SCHEDULED
+			msg.timer = time.AfterFunc(delay, func() {
+				s.mu.Lock()
+				msg.state, msg.updated = smtpv1.State_STATE_SENDING, time.Now()
+				s.mu.Unlock()
+
+				s.deliver(s.ctx, msg, email)
+			})
+			s.mu.Unlock()
+			return &smtpv1.SendResponse{Id: id, State: smtpv1.State_STATE_SCHEDULED}, nil
+		}
+	}
+	s.mu.Unlock()
+
+	if err = s.deliver(ctx, msg, email); err != nil {
+		return nil, statusError(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &smtpv1.SendResponse{Id: id, MessageId: msg.messageID, State: msg.state}, nil
+}
+
+// deliver sends the email and records the outcome in msg.
+func (s *Server) deliver(ctx context.Context, msg *message, email smtp.Email) error {
+	defer s.wg.Done()
+
+	result, err := s.sender.SendMailResult(ctx, email)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg.messageID, msg.updated = result.MessageID, time.Now()
+	switch {
+	case err != nil && smtp.IsTemporary(err):
+		msg.state, msg.err = smtpv1.State_STATE_DEAD_LETTERED, err.Error()
+	case err != nil:
+		msg.state, msg.err = smtpv1.State_STATE_FAILED, err.Error()
+	case len(result.Deferred) != 0:
+		msg.state = smtpv1.State_STATE_DEFERRED
+	default:
+		msg.state = smtpv1.State_STATE_DELIVERED
+	}
+	return err
+}
+
+// prune forgets the messages that finished longer than the retention ago.
+func (s *Server) prune(now time.Time) {
+	retention := s.Retention
+	if retention <= 0 {
+		retention = defaultRetention
+	}
+
+	for id, msg := range s.messages {
+		switch msg.state {
+		case smtpv1.State_STATE_SCHEDULED, smtpv1.State_STATE_SENDING:
+			continue
+		}
+		if now.Sub(msg.updated) > retention {
+			delete(s.messages, id)
+		}
+	}
+}
+
+// toEmail converts the request email, resolving its profile by name.
+func toEmail(e *smtpv1.Email) (smtp.Email, error) {
+	if e == nil || len(e.GetTo())+len(e.GetCc())+len(e.GetBcc()) == 0 {
+		return smtp.Email{}, status.Error(codes.InvalidArgument, "email has no recipients")
+	}
+
+	email := smtp.Email{
+		To:             e.GetTo(),
+		Cc:             e.GetCc(),
+		Bcc:            e.GetBcc(),
+		Subject:        e.GetSubject(),
+		Body:           e.GetBody(),
+		HTMLBody:       e.GetHtmlBody(),
+		Headers:        e.GetHeaders(),
+		FromName:       e.GetFromName(),
+		Categories:     e.GetCategories(),
+		IdempotencyKey: e.GetIdempotencyKey(),
+		Locale:         e.GetLocale(),
+	}
+	for _, a := range e.GetAttachments() {
+		email.Attachments = append(email.Attachments, smtp.Attachment{Filename: a.GetFilename(), ContentType: a.GetContentType(), Data: a.GetData()})
+	}
+
+	switch e.GetProfile() {
+	case "":
+	case smtp.ProfileOTP.Name:
+		profile := smtp.ProfileOTP
+		email.Profile = &profile
+	case smtp.ProfileBulk.Name:
+		profile := smtp.NewBulkProfile(e.GetListUnsubscribe())
+		email.Profile = &profile
+	default:
+		return smtp.Email{}, status.Errorf(codes.InvalidArgument, "unknown profile %s", e.GetProfile())
+	}
+	return email, nil
+}
+
+// statusError maps a send error to the gRPC status that tells the caller whether to try again.
+func statusError(err error) error {
+	var validation *smtp.ValidationError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.As(err, &validation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, smtp.ErrClientClosed), smtp.IsTemporary(err):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
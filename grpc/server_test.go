@@ -0,0 +1,206 @@
+package smtpgrpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dexterdmonkey/go-smtp"
+	smtpgrpc "github.com/dexterdmonkey/go-smtp/grpc"
+	smtpv1 "github.com/dexterdmonkey/go-smtp/grpc/proto/smtp/v1"
+	"github.com/dexterdmonkey/go-smtp/smtptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newMailer serves a Mailer backed by a client of relay over an in-memory connection.
+func newMailer(t *testing.T, host string, port int) smtpv1.MailerClient {
+	t.Helper()
+
+	mail, err := smtp.New("app@example.com", "secret", host, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mail.Close() })
+	mail.RegisterTemplate("welcome", smtp.Template{Subject: "Welcome {{name}}", Body: "Hi {{name}}"})
+
+	srv := smtpgrpc.NewServer(mail)
+	t.Cleanup(srv.Close)
+
+	ln := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	smtpv1.RegisterMailerServer(s, srv)
+	go s.Serve(ln)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return smtpv1.NewMailerClient(conn)
+}
+
+func TestSend(t *testing.T) {
+	relay := smtptest.NewServer()
+	t.Cleanup(relay.Close)
+	client := newMailer(t, relay.Host(), relay.Port())
+	ctx := context.Background()
+
+	resp, err := client.Send(ctx, &smtpv1.SendRequest{Email: &smtpv1.Email{
+		To:      []string{"user@example.com"},
+		Subject: "Invoice",
+		Body:    "Attached",
+		Profile: "bulk",
+
+		ListUnsubscribe: "https://example.com/unsubscribe",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetState() != smtpv1.State_STATE_DELIVERED || resp.GetMessageId() == "" {
+		t.Fatalf("send returned %v, want a delivered message with a Message-ID", resp)
+	}
+
+	messages, err := relay.Wait(1, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email, err := smtp.ParseMessage(messages[0].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.Subject != "Invoice" || email.Headers["Precedence"] != "bulk" {
+		t.Fatalf("relay received subject %q with Precedence %q", email.Subject, email.Headers["Precedence"])
+	}
+
+	got, err := client.Status(ctx, &smtpv1.StatusRequest{Id: resp.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetState() != smtpv1.State_STATE_DELIVERED || got.GetMessageId() != resp.GetMessageId() {
+		t.Fatalf("status is %v, want delivered as %s", got, resp.GetMessageId())
+	}
+}
+
+func TestSendTemplate(t *testing.T) {
+	relay := smtptest.NewServer()
+	t.Cleanup(relay.Close)
+	client := newMailer(t, relay.Host(), relay.Port())
+	ctx := context.Background()
+
+	_, err := client.SendTemplate(ctx, &smtpv1.SendTemplateRequest{
+		Email:    &smtpv1.Email{To: []string{"user@example.com"}},
+		Template: "welcome",
+		Params:   map[string]string{"name": "Ada"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := relay.Wait(1, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email, _ := smtp.ParseMessage(messages[0].Data); email.Subject != "Welcome Ada" {
+		t.Fatalf("relay received subject %q, want the rendered template", email.Subject)
+	}
+
+	_, err = client.SendTemplate(ctx, &smtpv1.SendTemplateRequest{
+		Email:    &smtpv1.Email{To: []string{"user@example.com"}},
+		Template: "missing",
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unknown template returned %v, want InvalidArgument", err)
+	}
+}
+
+func TestScheduleAndCancel(t *testing.T) {
+	relay := smtptest.NewServer()
+	t.Cleanup(relay.Close)
+	client := newMailer(t, relay.Host(), relay.Port())
+	ctx := context.Background()
+	email := &smtpv1.Email{To: []string{"user@example.com"}, Subject: "Later", Body: "Hi"}
+
+	later, err := client.Send(ctx, &smtpv1.SendRequest{Email: email, SendAt: timestamppb.New(time.Now().Add(time.Hour))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if later.GetState() != smtpv1.State_STATE_SCHEDULED {
+		t.Fatalf("send in an hour is %s, want scheduled", later.GetState())
+	}
+	if _, err = client.Cancel(ctx, &smtpv1.CancelRequest{Id: later.GetId()}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.Status(ctx, &smtpv1.StatusRequest{Id: later.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetState() != smtpv1.State_STATE_CANCELLED {
+		t.Fatalf("cancelled message is %s", got.GetState())
+	}
+
+	soon, err := client.Send(ctx, &smtpv1.SendRequest{Email: email, SendAt: timestamppb.New(time.Now().Add(50 * time.Millisecond))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = relay.Wait(1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err = client.Status(ctx, &smtpv1.StatusRequest{Id: soon.GetId()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.GetState() == smtpv1.State_STATE_DELIVERED {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("scheduled message is %s, want delivered", got.GetState())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err = client.Cancel(ctx, &smtpv1.CancelRequest{Id: soon.GetId()}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("cancel of a sent message returned %v, want FailedPrecondition", err)
+	}
+	if _, err = client.Cancel(ctx, &smtpv1.CancelRequest{Id: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("cancel of an unknown message returned %v, want NotFound", err)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+
+	client := newMailer(t, "127.0.0.1", addr.Port)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		email *smtpv1.Email
+		code  codes.Code
+	}{
+		{name: "no recipients", email: &smtpv1.Email{Subject: "Hi"}, code: codes.InvalidArgument},
+		{name: "unknown profile", email: &smtpv1.Email{To: []string{"user@example.com"}, Profile: "weekly"}, code: codes.InvalidArgument},
+		{name: "relay down", email: &smtpv1.Email{To: []string{"user@example.com"}, Subject: "Hi", Body: "Hi"}, code: codes.Unavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.Send(ctx, &smtpv1.SendRequest{Email: tt.email}); status.Code(err) != tt.code {
+				t.Fatalf("send returned %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
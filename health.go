@@ -0,0 +1,209 @@
+package smtp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Healthchecker reports whether the relay of a client is reachable and accepts its credentials, e.g. for the
+// readiness probe of a service that sends mail. Each check dials the relay, says EHLO, authenticates, and quits
+// like Ping; its result is cached so that frequent probes do not open a session each.
+//
+//	health := smtp.NewHealthchecker(mail)
+//	health.Start()
+//	defer health.Stop()
+//	http.Handle("/healthz", health)
+type Healthchecker struct {
+	client   *SMTP
+	interval time.Duration
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+	stop    chan struct{}
+	done    chan struct{}
+	// checking serializes the checks so that concurrent probes of a stale result dial once
+	checking sync.Mutex
+}
+
+// minHealthInterval is the shortest interval of a healthchecker, so that the background loop never spins.
+const minHealthInterval = time.Second
+
+// NewHealthchecker initializes and returns a healthchecker for client. By default results are cached for
+// thirty seconds and a check gives up after ten seconds.
+func NewHealthchecker(client *SMTP) *Healthchecker {
+	return &Healthchecker{
+		client:   client,
+		interval: 30 * time.Second,
+		timeout:  10 * time.Second,
+	}
+}
+
+// SetInterval changes how long a result is cached and how often the background loop checks the relay. Intervals
+// shorter than a second are raised to a second.
+func (h *Healthchecker) SetInterval(interval time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.interval = max(interval, minHealthInterval)
+}
+
+// SetTimeout changes how long a check may take before the relay counts as unreachable.
+func (h *Healthchecker) SetTimeout(timeout time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.timeout = timeout
+}
+
+// Healthy returns nil when the last check succeeded, or its error. A result older than the interval is
+// refreshed first, so Healthy also works without Start, at the cost of a check on the probe that finds it stale.
+func (h *Healthchecker) Healthy() error {
+	if ok, err := h.cached(); ok {
+		return err
+	}
+
+	h.checking.Lock()
+	defer h.checking.Unlock()
+
+	// Another probe may have refreshed the result while this one waited
+	if ok, err := h.cached(); ok {
+		return err
+	}
+	return h.Check(context.Background())
+}
+
+// Check checks the relay now, caches the result, and returns it.
+func (h *Healthchecker) Check(ctx context.Context) error {
+	err := h.check(ctx)
+	h.store(err)
+	return err
+}
+
+// store caches the result of a check.
+func (h *Healthchecker) store(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checked, h.err = time.Now(), err
+}
+
+// check runs one probe within the timeout. A client that is shut down is unhealthy without dialing.
+func (h *Healthchecker) check(ctx context.Context) error {
+	h.mu.Lock()
+	timeout := h.timeout
+	h.mu.Unlock()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	l := &h.client.life
+	l.mu.Lock()
+	closed := l.closed
+	l.mu.Unlock()
+	if closed {
+		return ErrClientClosed
+	}
+
+	if err := h.client.Ping(ctx); err != nil {
+		return fmt.Errorf("health error, relay %s is unavailable; %w", h.client.host, err)
+	}
+	return nil
+}
+
+// cached reports whether the last result is still fresh and returns it.
+func (h *Healthchecker) cached() (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.checked.IsZero() || time.Since(h.checked) >= h.interval {
+		return false, nil
+	}
+	return true, h.err
+}
+
+// ServeHTTP implements http.Handler: it answers 200 OK when the relay is healthy and 503 Service Unavailable
+// with the error otherwise.
+func (h *Healthchecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := h.Healthy(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// Start checks the relay once and then every interval in the background, so that Healthy always answers
+// from the cache.
+func (h *Healthchecker) Start() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.stop != nil {
+		return
+	}
+	h.stop = make(chan struct{})
+	h.done = make(chan struct{})
+
+	go h.run(h.interval, h.stop, h.done)
+}
+
+// Stop halts the background loop and waits for an in-progress check to finish.
+func (h *Healthchecker) Stop() {
+	h.mu.Lock()
+	stop, done := h.stop, h.done
+	h.stop, h.done = nil, nil
+	h.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+func (h *Healthchecker) run(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+
+	// Refresh a little before the cached result expires, so probes never find it stale
+	ticker := time.NewTicker(interval * 9 / 10)
+	defer ticker.Stop()
+
+	for {
+		h.refresh(stop)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh runs a check that is canceled when the loop stops; the result of a canceled check is not cached.
+func (h *Healthchecker) refresh(stop chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	h.checking.Lock()
+	defer h.checking.Unlock()
+	if err := h.check(ctx); ctx.Err() == nil {
+		h.store(err)
+	}
+}
@@ -0,0 +1,115 @@
+package smtp
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultIdempotencyTTL is how long a key is remembered when WithIdempotency is given no TTL.
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// IdempotencyStore defines the methods that any store of idempotency keys must implement. Clients that share
+// a store, e.g. one backed by Redis, skip each other's duplicates.
+type IdempotencyStore interface {
+	// Claim records key for ttl and reports whether it was not recorded yet.
+	Claim(key string, ttl time.Duration) (bool, error)
+	// Release forgets key, so that a retry after a failed send is not skipped as a duplicate.
+	Release(key string) error
+}
+
+// IdempotencyMarker is implemented by idempotency stores that record which recipients a partially failed send
+// reached. Such a key is kept rather than released, so a retry does not send to those recipients twice.
+type IdempotencyMarker interface {
+	// MarkPartial records that the send of key reached only the recipients.
+	MarkPartial(key string, recipients []string) error
+}
+
+// MemoryIdempotencyStore keeps idempotency keys in memory; keys are lost on restart.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	keys    map[string]time.Time
+	partial map[string][]string
+	claims  int
+}
+
+// NewMemoryIdempotencyStore initializes and returns an empty in-memory idempotency store.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{keys: map[string]time.Time{}, partial: map[string][]string{}}
+}
+
+// Claim records key for ttl and reports whether it was not recorded yet or had expired.
+func (s *MemoryIdempotencyStore) Claim(key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	// Sweep expired keys now and then so the map does not grow without bound
+	if s.claims++; s.claims%1000 == 0 {
+		for k, expires := range s.keys {
+			if !now.Before(expires) {
+				delete(s.keys, k)
+				delete(s.partial, k)
+			}
+		}
+	}
+
+	if expires, ok := s.keys[key]; ok && now.Before(expires) {
+		return false, nil
+	}
+	s.keys[key] = now.Add(ttl)
+	delete(s.partial, key)
+	return true, nil
+}
+
+// Release forgets key.
+func (s *MemoryIdempotencyStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, key)
+	delete(s.partial, key)
+	return nil
+}
+
+// MarkPartial records the recipients that the send of key reached before it failed.
+func (s *MemoryIdempotencyStore) MarkPartial(key string, recipients []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.partial[key] = append([]string(nil), recipients...)
+	return nil
+}
+
+// Partial returns the recipients recorded by MarkPartial for key, or nil when its send was not partial.
+func (s *MemoryIdempotencyStore) Partial(key string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.partial[key]...)
+}
+
+// reached returns the recipients that a failed send reached: those recorded in its result by a routed or
+// VERP send, or those an LMTP server accepted.
+func reached(email Email, result *SendResult, err error) []string {
+	if len(result.Recipients) != 0 {
+		return result.Recipients
+	}
+
+	var lmtpErr *LMTPError
+	if !errors.As(err, &lmtpErr) || lmtpErr.Accepted == 0 {
+		return nil
+	}
+	rejected := map[string]bool{}
+	for _, r := range lmtpErr.Errors {
+		rejected[strings.ToLower(r.Recipient)] = true
+	}
+	var accepted []string
+	for _, addr := range email.recipients() {
+		if !rejected[strings.ToLower(addr)] {
+			accepted = append(accepted, addr)
+		}
+	}
+	return accepted
+}
@@ -0,0 +1,328 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrSMTPUTF8Required is wrapped by the error returned when an address with a non-ASCII local part, such as
+// müller@bücher.de, is sent through a server that does not support SMTPUTF8. Only the domain of an address
+// can be converted to ASCII.
+var ErrSMTPUTF8Required = errors.New("address error, non-ASCII local part requires SMTPUTF8")
+
+// addressHeaders lists the header fields whose values are address lists.
+var addressHeaders = map[string]bool{
+	"From":     true,
+	"To":       true,
+	"Cc":       true,
+	"Bcc":      true,
+	"Reply-To": true,
+	"Sender":   true,
+}
+
+// isASCII reports whether s contains only 7-bit characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// needsSMTPUTF8 reports whether the envelope or the header of the message contain non-ASCII text.
+func needsSMTPUTF8(from string, recipients []string, msg *Message) bool {
+	if !isASCII(from) {
+		return true
+	}
+	for _, addr := range recipients {
+		if !isASCII(addr) {
+			return true
+		}
+	}
+	for _, f := range msg.Header {
+		if !isASCII(f.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// downgradeAddress converts the domain of an address to punycode.
+// It fails when the local part is not ASCII, since such an address cannot be represented without SMTPUTF8.
+func downgradeAddress(addr string) (string, error) {
+	if isASCII(addr) {
+		return addr, nil
+	}
+
+	at := strings.LastIndex(addr, "@")
+	if at < 0 || !isASCII(addr[:at]) {
+		return "", fmt.Errorf("address error, %s cannot be converted to ASCII and the server does not support SMTPUTF8; %w", addr, ErrSMTPUTF8Required)
+	}
+
+	domain, err := punycodeDomain(addr[at+1:])
+	if err != nil {
+		return "", fmt.Errorf("address error, failed to encode domain of %s; %w", addr, err)
+	}
+	return addr[:at+1] + domain, nil
+}
+
+// addressProblem describes why an internationalized address cannot be used, or returns an empty string.
+// Non-ASCII local parts (RFC 6531) must not contain spaces or control or formatting characters, and non-ASCII
+// domain labels may only hold letters, digits, combining marks, and inner hyphens and must fit the DNS limits
+// once converted to punycode.
+func addressProblem(addr string) string {
+	at := strings.LastIndex(addr, "@")
+	if at < 0 || isASCII(addr) {
+		return ""
+	}
+	local, domain := addr[:at], addr[at+1:]
+
+	if !utf8.ValidString(addr) {
+		return "is not valid UTF-8"
+	}
+	if !isASCII(local) {
+		if len(local) > 64 {
+			return "has a local part longer than 64 bytes"
+		}
+		for _, r := range local {
+			if unicode.IsControl(r) || unicode.IsSpace(r) || unicode.Is(unicode.Cf, r) {
+				return fmt.Sprintf("has the character %U in its local part", r)
+			}
+		}
+	}
+
+	if isASCII(domain) {
+		return ""
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if isASCII(label) {
+			continue
+		}
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Sprintf("has the domain label %q starting or ending with a hyphen", label)
+		}
+		for _, r := range label {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r) && r != '-' {
+				return fmt.Sprintf("has the character %U in its domain", r)
+			}
+		}
+	}
+	ace, err := punycodeDomain(domain)
+	if err != nil {
+		return "has a domain that cannot be converted to punycode"
+	}
+	if len(ace) > 253 {
+		return "has a domain longer than 253 bytes in punycode"
+	}
+	for _, label := range strings.Split(ace, ".") {
+		if len(label) > 63 {
+			return fmt.Sprintf("has the domain label %q longer than 63 bytes in punycode", label)
+		}
+	}
+	return ""
+}
+
+// downgradeAddressList converts a comma-separated list of "Name <addr>" or bare addresses
+// to ASCII, using encoded-words for display names and punycode for domains.
+func downgradeAddressList(list string) (string, error) {
+	parts := strings.Split(list, ",")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+
+		name, addr := "", part
+		if lt := strings.LastIndex(part, "<"); lt >= 0 && strings.HasSuffix(part, ">") {
+			name = strings.TrimSpace(part[:lt])
+			addr = part[lt+1 : len(part)-1]
+		}
+
+		ascii, err := downgradeAddress(addr)
+		if err != nil {
+			return "", err
+		}
+
+		if name == "" && addr == part {
+			parts[i] = ascii
+			continue
+		}
+		if name != "" && !isASCII(name) {
+			name = encodeWords(strings.Trim(name, `"`))
+		}
+		parts[i] = strings.TrimSpace(name + " <" + ascii + ">")
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// downgrade returns a copy of the message that can be sent without SMTPUTF8 and, when allow8bit is false, without 8BITMIME.
+func (m *Message) downgrade(allowUTF8, allow8bit bool) (*Message, error) {
+	out := &Message{Header: make([]HeaderField, len(m.Header)), Body: m.Body, segments: m.segments}
+	copy(out.Header, m.Header)
+
+	if !allowUTF8 {
+		for i, f := range out.Header {
+			if isASCII(f.Value) {
+				continue
+			}
+
+			if addressHeaders[f.Name] {
+				value, err := downgradeAddressList(f.Value)
+				if err != nil {
+					return nil, err
+				}
+				out.Header[i].Value = value
+				continue
+			}
+			out.Header[i].Value = encodeWords(f.Value)
+		}
+	}
+
+	if !allow8bit && !out.bodyASCII() {
+		if out.streamed() {
+			var b strings.Builder
+			if _, err := out.writeBody(&b); err != nil {
+				return nil, err
+			}
+			out.Body, out.segments = b.String(), nil
+		}
+		out.Body = encodeQuotedPrintable(out.Body)
+		out.Set("Content-Transfer-Encoding", "quoted-printable")
+	}
+
+	return out, nil
+}
+
+// encodeWords encodes text as RFC 2047 encoded words in whichever encoding is shorter: Q keeps mostly Latin text
+// such as German or French readable, while B is shorter for scripts such as Cyrillic, Arabic, or Japanese whose
+// characters all need escaping in Q.
+func encodeWords(text string) string {
+	q, b := mime.QEncoding.Encode("utf-8", text), mime.BEncoding.Encode("utf-8", text)
+	if len(b) < len(q) {
+		return b
+	}
+	return q
+}
+
+// punycodeDomain converts every non-ASCII label of a domain to its ACE form.
+func punycodeDomain(domain string) (string, error) {
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+
+		encoded, err := punycode(strings.ToLower(label))
+		if err != nil {
+			return "", err
+		}
+		labels[i] = "xn--" + encoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// Punycode parameters from RFC 3492 section 5.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// punycode encodes a label as described in RFC 3492 section 6.3.
+func punycode(label string) (string, error) {
+	runes := []rune(label)
+
+	var out []byte
+	for _, r := range runes {
+		if r < 0x80 {
+			out = append(out, byte(r))
+		}
+	}
+	basic := len(out)
+	handled := basic
+	if basic > 0 {
+		out = append(out, '-')
+	}
+
+	n := rune(punyInitialN)
+	delta := 0
+	bias := punyInitialBias
+
+	for handled < len(runes) {
+		m := rune(0x7fffffff)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+
+		if int(m-n) > (0x7fffffff-delta)/(handled+1) {
+			return "", fmt.Errorf("punycode error, overflow encoding %q", label)
+		}
+		delta += int(m-n) * (handled + 1)
+		n = m
+
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := k - bias
+				if t < punyTMin {
+					t = punyTMin
+				} else if t > punyTMax {
+					t = punyTMax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+
+			bias = punyAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+
+		delta++
+		n++
+	}
+
+	return string(out), nil
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punyAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
@@ -0,0 +1,359 @@
+package smtp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EmailJSONVersion is the version of the JSON format written by Email.MarshalJSON. Older versions are read
+// back, so emails queued or scheduled by an older release of the library can still be sent after an upgrade.
+const EmailJSONVersion = 2
+
+// emailJSON is the JSON format of an Email. Fields are only ever added to a version; renaming or changing the
+// meaning of one requires a new version. Version 2 replaced check_suppression of a profile with
+// skip_suppression, so that a profile is checked unless it opts out.
+type emailJSON struct {
+	Version        int               `json:"version"`
+	To             []string          `json:"to,omitempty"`
+	Cc             []string          `json:"cc,omitempty"`
+	Bcc            []string          `json:"bcc,omitempty"`
+	Subject        string            `json:"subject,omitempty"`
+	Body           string            `json:"body,omitempty"`
+	HTMLBody       string            `json:"html_body,omitempty"`
+	InlineCSS      bool              `json:"inline_css,omitempty"`
+	BodyEncoding   Encoding          `json:"body_encoding,omitempty"`
+	HTMLEncoding   Encoding          `json:"html_encoding,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	DSN            *dsnJSON          `json:"dsn,omitempty"`
+	Attachments    []attachmentJSON  `json:"attachments,omitempty"`
+	Calendar       *calendarJSON     `json:"calendar,omitempty"`
+	MaxAttempts    int               `json:"max_attempts,omitempty"`
+	Profile        *profileJSON      `json:"profile,omitempty"`
+	Locale         string            `json:"locale,omitempty"`
+	Direction      string            `json:"direction,omitempty"`
+	FromName       string            `json:"from_name,omitempty"`
+	EnvelopeFrom   string            `json:"envelope_from,omitempty"`
+	Categories     []string          `json:"categories,omitempty"`
+	Priority       Priority          `json:"priority,omitempty"`
+	InReplyTo      string            `json:"in_reply_to,omitempty"`
+	References     []string          `json:"references,omitempty"`
+	TrackingID     string            `json:"tracking_id,omitempty"`
+	NoTracking     bool              `json:"no_tracking,omitempty"`
+	DeliverBy      Duration          `json:"deliver_by,omitempty"`
+	IdempotencyKey string            `json:"idempotency_key,omitempty"`
+	Deferrals      int               `json:"deferrals,omitempty"`
+}
+
+type attachmentJSON struct {
+	Filename    string   `json:"filename"`
+	ContentType string   `json:"content_type,omitempty"`
+	Data        []byte   `json:"data,omitempty"`
+	Ref         string   `json:"ref,omitempty"`
+	Size        int64    `json:"size,omitempty"`
+	Encoding    Encoding `json:"encoding,omitempty"`
+	Inline      bool     `json:"inline,omitempty"`
+	ContentID   string   `json:"content_id,omitempty"`
+}
+
+type dsnJSON struct {
+	Notify          []string            `json:"notify,omitempty"`
+	RecipientNotify map[string][]string `json:"recipient_notify,omitempty"`
+	Return          string              `json:"return,omitempty"`
+	EnvelopeID      string              `json:"envelope_id,omitempty"`
+}
+
+type calendarJSON struct {
+	Method string `json:"method,omitempty"`
+	Data   []byte `json:"data"`
+}
+
+type profileJSON struct {
+	Name            string            `json:"name,omitempty"`
+	MaxAttempts     int               `json:"max_attempts,omitempty"`
+	Deadline        Duration          `json:"deadline,omitempty"`
+	Priority        int               `json:"priority,omitempty"`
+	SkipArchiveBody bool              `json:"skip_archive_body,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	ListUnsubscribe string            `json:"list_unsubscribe,omitempty"`
+	DomainInterval  Duration          `json:"domain_interval,omitempty"`
+	SkipSuppression bool              `json:"skip_suppression,omitempty"`
+	DeliverBy       Duration          `json:"deliver_by,omitempty"`
+	Digest          Duration          `json:"digest,omitempty"`
+
+	// CheckSuppression is only read from version 1
+	CheckSuppression bool `json:"check_suppression,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler with a versioned format, e.g.
+//
+//	{"version":2,"to":["user@example.com"],"subject":"Invoice","body":"...",
+//	 "attachments":[{"filename":"invoice.pdf","data":"JVBERi0..."},
+//	                {"filename":"report.csv","ref":"file:///var/reports/report.csv","size":52311}]}
+//
+// Attachment content is written as base64, or as the Ref of a streamed attachment, so that a large file is not
+// copied into the queue. Streamed attachments without a Ref, such as those returned by AttachReader, cannot be
+// serialized and fail the marshaling.
+func (e Email) MarshalJSON() ([]byte, error) {
+	v := emailJSON{
+		Version:        EmailJSONVersion,
+		To:             e.To,
+		Cc:             e.Cc,
+		Bcc:            e.Bcc,
+		Subject:        e.Subject,
+		Body:           e.Body,
+		HTMLBody:       e.HTMLBody,
+		InlineCSS:      e.InlineCSS,
+		BodyEncoding:   e.BodyEncoding,
+		HTMLEncoding:   e.HTMLEncoding,
+		Headers:        e.Headers,
+		MaxAttempts:    e.MaxAttempts,
+		Locale:         e.Locale,
+		Direction:      e.Direction,
+		FromName:       e.FromName,
+		EnvelopeFrom:   e.EnvelopeFrom,
+		Categories:     e.Categories,
+		Priority:       e.Priority,
+		InReplyTo:      e.InReplyTo,
+		References:     e.References,
+		TrackingID:     e.TrackingID,
+		NoTracking:     e.NoTracking,
+		DeliverBy:      Duration(e.DeliverBy),
+		IdempotencyKey: e.IdempotencyKey,
+		Deferrals:      e.Deferrals,
+	}
+
+	for _, a := range e.Attachments {
+		if a.Open != nil && a.Ref == "" {
+			return nil, fmt.Errorf("json error, attachment %s is streamed and has no ref to serialize", a.Filename)
+		}
+		ja := attachmentJSON{
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			Ref:         a.Ref,
+			Size:        a.Size,
+			Encoding:    a.Encoding,
+			Inline:      a.Inline,
+			ContentID:   a.ContentID,
+		}
+		if a.Ref == "" {
+			ja.Data = a.Data
+		}
+		v.Attachments = append(v.Attachments, ja)
+	}
+	if e.DSN != nil {
+		v.DSN = &dsnJSON{Notify: e.DSN.Notify, RecipientNotify: e.DSN.RecipientNotify, Return: e.DSN.Return, EnvelopeID: e.DSN.EnvelopeID}
+	}
+	if e.Calendar != nil {
+		v.Calendar = &calendarJSON{Method: e.Calendar.Method, Data: e.Calendar.Data}
+	}
+	if p := e.Profile; p != nil {
+		v.Profile = &profileJSON{
+			Name:            p.Name,
+			MaxAttempts:     p.MaxAttempts,
+			Deadline:        Duration(p.Deadline),
+			Priority:        p.Priority,
+			SkipArchiveBody: p.SkipArchiveBody,
+			Headers:         p.Headers,
+			ListUnsubscribe: p.ListUnsubscribe,
+			DomainInterval:  Duration(p.DomainInterval),
+			SkipSuppression: p.SkipSuppression,
+			DeliverBy:       Duration(p.DeliverBy),
+			Digest:          Duration(p.Digest),
+		}
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It reads every version up to EmailJSONVersion, as well as emails
+// written before the format was versioned, and fails for versions of a newer release.
+//
+// Decoding never opens a Ref, so an email from a queue or an API cannot attach arbitrary local files. Every
+// reference, file:// URLs included, is resolved at send time by the AttachmentResolver of the client, see
+// WithAttachmentResolver and FileResolver.
+func (e *Email) UnmarshalJSON(data []byte) error {
+	var header struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return fmt.Errorf("json error, failed to decode email; %w", err)
+	}
+
+	if header.Version == nil {
+		// Before versioning, emails were encoded with the Go field names
+		type unversioned Email
+		var legacy unversioned
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return fmt.Errorf("json error, failed to decode email; %w", err)
+		}
+		*e = Email(legacy)
+		return nil
+	}
+	if *header.Version < 1 || *header.Version > EmailJSONVersion {
+		return fmt.Errorf("json error, unsupported email version %d; this release reads up to version %d", *header.Version, EmailJSONVersion)
+	}
+
+	var v emailJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("json error, failed to decode email; %w", err)
+	}
+
+	*e = Email{
+		To:             v.To,
+		Cc:             v.Cc,
+		Bcc:            v.Bcc,
+		Subject:        v.Subject,
+		Body:           v.Body,
+		HTMLBody:       v.HTMLBody,
+		InlineCSS:      v.InlineCSS,
+		BodyEncoding:   v.BodyEncoding,
+		HTMLEncoding:   v.HTMLEncoding,
+		Headers:        v.Headers,
+		MaxAttempts:    v.MaxAttempts,
+		Locale:         v.Locale,
+		Direction:      v.Direction,
+		FromName:       v.FromName,
+		EnvelopeFrom:   v.EnvelopeFrom,
+		Categories:     v.Categories,
+		Priority:       v.Priority,
+		InReplyTo:      v.InReplyTo,
+		References:     v.References,
+		TrackingID:     v.TrackingID,
+		NoTracking:     v.NoTracking,
+		DeliverBy:      time.Duration(v.DeliverBy),
+		IdempotencyKey: v.IdempotencyKey,
+		Deferrals:      v.Deferrals,
+	}
+
+	for _, ja := range v.Attachments {
+		a := Attachment{
+			Filename:    ja.Filename,
+			ContentType: ja.ContentType,
+			Data:        ja.Data,
+			Ref:         ja.Ref,
+			Size:        ja.Size,
+			Encoding:    ja.Encoding,
+			Inline:      ja.Inline,
+			ContentID:   ja.ContentID,
+		}
+		e.Attachments = append(e.Attachments, a)
+	}
+	if v.DSN != nil {
+		e.DSN = &DSN{Notify: v.DSN.Notify, RecipientNotify: v.DSN.RecipientNotify, Return: v.DSN.Return, EnvelopeID: v.DSN.EnvelopeID}
+	}
+	if v.Calendar != nil {
+		e.Calendar = &Calendar{Method: v.Calendar.Method, Data: v.Calendar.Data}
+	}
+	if p := v.Profile; p != nil {
+		e.Profile = &Profile{
+			Name:            p.Name,
+			MaxAttempts:     p.MaxAttempts,
+			Deadline:        time.Duration(p.Deadline),
+			Priority:        p.Priority,
+			SkipArchiveBody: p.SkipArchiveBody,
+			Headers:         p.Headers,
+			ListUnsubscribe: p.ListUnsubscribe,
+			DomainInterval:  time.Duration(p.DomainInterval),
+			SkipSuppression: p.SkipSuppression,
+			DeliverBy:       time.Duration(p.DeliverBy),
+			Digest:          time.Duration(p.Digest),
+		}
+		if *header.Version == 1 {
+			e.Profile.SkipSuppression = !p.CheckSuppression
+		}
+	}
+	return nil
+}
+
+// fileRef returns the file:// URL of an absolute path.
+func fileRef(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// filePath returns the path of a file:// URL.
+func filePath(ref string) (string, bool) {
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+// AttachmentResolver defines the methods that any store of attachment content, such as object storage, must
+// implement to stream attachments that were decoded from JSON with a Ref.
+type AttachmentResolver interface {
+	Open(ctx context.Context, ref string) (io.ReadCloser, error)
+}
+
+// AttachmentResolverFunc adapts an ordinary function to the AttachmentResolver interface.
+type AttachmentResolverFunc func(ctx context.Context, ref string) (io.ReadCloser, error)
+
+// Open calls f(ctx, ref).
+func (f AttachmentResolverFunc) Open(ctx context.Context, ref string) (io.ReadCloser, error) {
+	return f(ctx, ref)
+}
+
+// FileResolver returns an AttachmentResolver that opens file:// references, such as those set by AttachFile,
+// as long as the file is inside dir. Symbolic links are followed before the check, so a link cannot reach
+// a file outside dir either. Other references fail.
+func FileResolver(dir string) AttachmentResolver {
+	return AttachmentResolverFunc(func(ctx context.Context, ref string) (io.ReadCloser, error) {
+		path, ok := filePath(ref)
+		if !ok {
+			return nil, fmt.Errorf("attachment error, %s is not a file reference", ref)
+		}
+
+		root, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return nil, fmt.Errorf("attachment error, failed to resolve %s; %w", dir, err)
+		}
+		if root, err = filepath.Abs(root); err != nil {
+			return nil, fmt.Errorf("attachment error, failed to resolve %s; %w", dir, err)
+		}
+		if path, err = filepath.EvalSymlinks(path); err != nil {
+			return nil, fmt.Errorf("attachment error, failed to resolve %s; %w", ref, err)
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+			return nil, fmt.Errorf("attachment error, %s is outside %s", ref, dir)
+		}
+		return os.Open(path)
+	})
+}
+
+// resolveAttachments streams the attachments that only carry a Ref through the client's AttachmentResolver.
+func (c *SMTP) resolveAttachments(ctx context.Context, email Email) (Email, error) {
+	var attachments []Attachment
+	for i, a := range email.Attachments {
+		if a.Ref == "" || a.Open != nil || a.Data != nil {
+			continue
+		}
+		if c.attachments == nil {
+			return email, fmt.Errorf("attachment error, no attachment resolver configured for %s", a.Ref)
+		}
+		if attachments == nil {
+			attachments = append([]Attachment(nil), email.Attachments...)
+		}
+
+		ref, resolver := a.Ref, c.attachments
+		attachments[i].Open = func() (io.ReadCloser, error) {
+			rc, err := resolver.Open(ctx, ref)
+			if err != nil {
+				return nil, fmt.Errorf("attachment error, failed to open %s; %w", ref, err)
+			}
+			return rc, nil
+		}
+	}
+	if attachments != nil {
+		email.Attachments = attachments
+	}
+	return email, nil
+}
@@ -0,0 +1,203 @@
+package smtp
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// minContrast is the WCAG 2.1 AA minimum contrast ratio for normal text.
+const minContrast = 4.5
+
+var (
+	lintTag   = regexp.MustCompile(`(?is)<([a-z][a-z0-9]*)\b([^>]*)>`)
+	lintAttr  = regexp.MustCompile(`(?is)([a-z][a-z0-9:-]*)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s>]+))?`)
+	lintTable = regexp.MustCompile(`(?is)<(/?)(table|th)\b([^>]*)>`)
+)
+
+// LintEmail checks the HTML body of an email for accessibility problems before it is sent:
+// images without alt text, a missing lang attribute, text colors with too little contrast against their
+// background, and layout tables that are not marked as presentational. The findings are advisory;
+// Line refers to the line within HTMLBody.
+func LintEmail(email Email) []Violation {
+	if email.HTMLBody == "" {
+		return nil
+	}
+	return LintHTML(email.HTMLBody)
+}
+
+// LintHTML runs the accessibility checks of LintEmail on an HTML document.
+func LintHTML(html string) []Violation {
+	var violations []Violation
+	add := func(offset int, rule, format string, args ...interface{}) {
+		line := strings.Count(html[:offset], "\n") + 1
+		violations = append(violations, Violation{Line: line, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	hasLang := false
+	for _, m := range lintTag.FindAllStringSubmatchIndex(html, -1) {
+		name := strings.ToLower(html[m[2]:m[3]])
+		attrs := parseAttrs(html[m[4]:m[5]])
+
+		switch name {
+		case "html":
+			if strings.TrimSpace(attrs["lang"]) != "" {
+				hasLang = true
+			}
+		case "img":
+			if _, ok := attrs["alt"]; !ok {
+				add(m[0], "a11y-alt", "image %q has no alt attribute; use alt=\"\" for decorative images", attrs["src"])
+			}
+		}
+
+		style := parseStyle(attrs["style"])
+		fg, bg := style["color"], style["background-color"]
+		if bg == "" {
+			bg = style["background"]
+		}
+		if bg == "" {
+			bg = attrs["bgcolor"]
+		}
+		if fg == "" || bg == "" {
+			continue
+		}
+		fc, ok1 := parseColor(fg)
+		bc, ok2 := parseColor(bg)
+		if !ok1 || !ok2 {
+			continue
+		}
+		if ratio := contrastRatio(fc, bc); ratio < minContrast {
+			add(m[0], "a11y-contrast", "<%s> text %s on %s has a contrast ratio of %.1f:1, at least %.1f:1 is recommended", name, fg, bg, ratio, minContrast)
+		}
+	}
+	if !hasLang {
+		add(0, "a11y-lang", "the html element has no lang attribute, so screen readers cannot pick the language")
+	}
+
+	// Header cells belong to the innermost open table, so nested layout tables are checked on their own
+	type table struct {
+		offset       int
+		presentation bool
+		header       bool
+	}
+	var tables, open []*table
+	for _, m := range lintTable.FindAllStringSubmatchIndex(html, -1) {
+		closing, name := m[3] > m[2], strings.ToLower(html[m[4]:m[5]])
+		switch {
+		case name == "th" && !closing && len(open) > 0:
+			open[len(open)-1].header = true
+		case name == "table" && !closing:
+			role := strings.ToLower(parseAttrs(html[m[6]:m[7]])["role"])
+			t := &table{offset: m[0], presentation: role == "presentation" || role == "none"}
+			tables, open = append(tables, t), append(open, t)
+		case name == "table" && len(open) > 0:
+			open = open[:len(open)-1]
+		}
+	}
+	for _, t := range tables {
+		if !t.presentation && !t.header {
+			add(t.offset, "a11y-table", "table has no header cells; add role=\"presentation\" if it is used for layout")
+		}
+	}
+
+	return violations
+}
+
+// parseAttrs returns the attributes of a tag by lower-case name, without quotes.
+func parseAttrs(s string) map[string]string {
+	attrs := map[string]string{}
+	for _, m := range lintAttr.FindAllStringSubmatch(s, -1) {
+		attrs[strings.ToLower(m[1])] = strings.Trim(m[2], `"'`)
+	}
+	return attrs
+}
+
+// parseStyle returns the declarations of an inline style by lower-case property.
+func parseStyle(s string) map[string]string {
+	style := map[string]string{}
+	for _, decl := range strings.Split(s, ";") {
+		prop, value, ok := strings.Cut(decl, ":")
+		if ok {
+			style[strings.ToLower(strings.TrimSpace(prop))] = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important"))
+		}
+	}
+	return style
+}
+
+// namedColors covers the color keywords most often found in email templates.
+var namedColors = map[string][3]float64{
+	"white": {255, 255, 255}, "black": {0, 0, 0}, "red": {255, 0, 0}, "green": {0, 128, 0},
+	"blue": {0, 0, 255}, "yellow": {255, 255, 0}, "gray": {128, 128, 128}, "grey": {128, 128, 128},
+	"silver": {192, 192, 192}, "orange": {255, 165, 0}, "navy": {0, 0, 128}, "lightgray": {211, 211, 211},
+	"lightgrey": {211, 211, 211},
+}
+
+// parseColor parses a hex, rgb(), or named CSS color. It reports false for anything else,
+// including the color part of a background shorthand with images.
+func parseColor(s string) ([3]float64, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c, true
+	}
+
+	if strings.HasPrefix(s, "#") {
+		hex := s[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return [3]float64{}, false
+		}
+		var c [3]float64
+		for i := range c {
+			v, err := strconv.ParseUint(hex[2*i:2*i+2], 16, 8)
+			if err != nil {
+				return [3]float64{}, false
+			}
+			c[i] = float64(v)
+		}
+		return c, true
+	}
+
+	if strings.HasPrefix(s, "rgb(") && strings.HasSuffix(s, ")") {
+		parts := strings.Split(s[4:len(s)-1], ",")
+		if len(parts) != 3 {
+			return [3]float64{}, false
+		}
+		var c [3]float64
+		for i, p := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+				return [3]float64{}, false
+			}
+			c[i] = v
+		}
+		return c, true
+	}
+	return [3]float64{}, false
+}
+
+// contrastRatio returns the WCAG contrast ratio between two sRGB colors.
+func contrastRatio(a, b [3]float64) float64 {
+	la, lb := luminance(a), luminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// luminance returns the relative luminance of an sRGB color.
+func luminance(c [3]float64) float64 {
+	var l [3]float64
+	for i, v := range c {
+		v /= 255
+		if v <= 0.03928 {
+			l[i] = v / 12.92
+		} else {
+			l[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+	return 0.2126*l[0] + 0.7152*l[1] + 0.0722*l[2]
+}
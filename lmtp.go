@@ -0,0 +1,95 @@
+package smtp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+)
+
+// RecipientError is the reply of the server for a single recipient.
+type RecipientError struct {
+	Recipient string
+	Err       error
+}
+
+// Error returns the recipient and its reply.
+func (e RecipientError) Error() string {
+	return e.Recipient + ": " + e.Err.Error()
+}
+
+// LMTPError is returned when an LMTP server rejects the message for some of the recipients after DATA.
+type LMTPError struct {
+	// Accepted is the number of recipients the message was delivered to.
+	Accepted int
+	Errors   []RecipientError
+}
+
+// Error returns a description of every rejected recipient.
+func (e *LMTPError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, r := range e.Errors {
+		msgs[i] = r.Error()
+	}
+	return fmt.Sprintf("send error, %d of %d recipients rejected; %s", len(e.Errors), len(e.Errors)+e.Accepted, strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the reply of every rejected recipient.
+func (e *LMTPError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, r := range e.Errors {
+		errs[i] = r.Err
+	}
+	return errs
+}
+
+// lmtpConn turns the EHLO and HELO greetings written by net/smtp into LHLO (RFC 2033).
+type lmtpConn struct {
+	net.Conn
+}
+
+func (c *lmtpConn) Write(p []byte) (int, error) {
+	if bytes.HasPrefix(p, []byte("EHLO ")) || bytes.HasPrefix(p, []byte("HELO ")) {
+		p = append([]byte("LHLO "), p[5:]...)
+	}
+	return c.Conn.Write(p)
+}
+
+// lmtpData transfers the message with DATA and reads one reply per accepted recipient.
+func lmtpData(client *smtp.Client, msg *Message, maxSize int64, recipients []string, log *slog.Logger) error {
+	if _, _, err := command(client, 354, "DATA"); err != nil {
+		return fmt.Errorf("send error, failed to create data; %w", err)
+	}
+
+	w := client.Text.DotWriter()
+	if _, err := msg.WriteTo(limitSize(w, maxSize)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	lmtpErr := &LMTPError{}
+	for _, addr := range recipients {
+		_, _, err := client.Text.ReadResponse(250)
+		var protoErr *textproto.Error
+		if err != nil && !errors.As(err, &protoErr) {
+			return err
+		}
+		logRecipient(log, addr, err)
+		if err != nil {
+			lmtpErr.Errors = append(lmtpErr.Errors, RecipientError{Recipient: addr, Err: err})
+			continue
+		}
+		lmtpErr.Accepted++
+	}
+
+	if len(lmtpErr.Errors) != 0 {
+		return lmtpErr
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package smtp
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Locale describes how dates, numbers, and currency amounts are formatted for a region.
+type Locale struct {
+	// DateFormat and TimeFormat are time layouts such as "02.01.2006" and "15:04".
+	DateFormat string
+	TimeFormat string
+	// Decimal separates the fraction and Group separates thousands.
+	Decimal string
+	Group   string
+	// CurrencyFormat places the currency symbol (¤) relative to the amount (#), e.g. "¤#" or "# ¤".
+	CurrencyFormat string
+	// RTL marks languages written right to left.
+	RTL bool
+}
+
+// locales holds the known locales by lower-case tag; a bare language selects its most common region.
+var locales = struct {
+	mu sync.RWMutex
+	m  map[string]Locale
+}{m: map[string]Locale{
+	"en-us": {DateFormat: "01/02/2006", TimeFormat: "3:04 PM", Decimal: ".", Group: ",", CurrencyFormat: "¤#"},
+	"en-gb": {DateFormat: "02/01/2006", TimeFormat: "15:04", Decimal: ".", Group: ",", CurrencyFormat: "¤#"},
+	"en-in": {DateFormat: "02/01/2006", TimeFormat: "3:04 PM", Decimal: ".", Group: ",", CurrencyFormat: "¤#"},
+	"de-de": {DateFormat: "02.01.2006", TimeFormat: "15:04", Decimal: ",", Group: ".", CurrencyFormat: "# ¤"},
+	"de-ch": {DateFormat: "02.01.2006", TimeFormat: "15:04", Decimal: ".", Group: "’", CurrencyFormat: "¤ #"},
+	"fr-fr": {DateFormat: "02/01/2006", TimeFormat: "15:04", Decimal: ",", Group: " ", CurrencyFormat: "# ¤"},
+	"es-es": {DateFormat: "02/01/2006", TimeFormat: "15:04", Decimal: ",", Group: ".", CurrencyFormat: "# ¤"},
+	"it-it": {DateFormat: "02/01/2006", TimeFormat: "15:04", Decimal: ",", Group: ".", CurrencyFormat: "# ¤"},
+	"nl-nl": {DateFormat: "02-01-2006", TimeFormat: "15:04", Decimal: ",", Group: ".", CurrencyFormat: "¤ #"},
+	"pt-br": {DateFormat: "02/01/2006", TimeFormat: "15:04", Decimal: ",", Group: ".", CurrencyFormat: "¤ #"},
+	"id-id": {DateFormat: "02/01/2006", TimeFormat: "15.04", Decimal: ",", Group: ".", CurrencyFormat: "¤#"},
+	"ja-jp": {DateFormat: "2006/01/02", TimeFormat: "15:04", Decimal: ".", Group: ",", CurrencyFormat: "¤#"},
+	"zh-cn": {DateFormat: "2006/01/02", TimeFormat: "15:04", Decimal: ".", Group: ",", CurrencyFormat: "¤#"},
+	"ar-sa": {DateFormat: "02/01/2006", TimeFormat: "15:04", Decimal: ".", Group: ",", CurrencyFormat: "# ¤", RTL: true},
+	"ar-eg": {DateFormat: "02/01/2006", TimeFormat: "15:04", Decimal: ".", Group: ",", CurrencyFormat: "# ¤", RTL: true},
+	"he-il": {DateFormat: "02.01.2006", TimeFormat: "15:04", Decimal: ".", Group: ",", CurrencyFormat: "# ¤", RTL: true},
+	"fa-ir": {DateFormat: "2006/01/02", TimeFormat: "15:04", Decimal: ".", Group: ",", CurrencyFormat: "# ¤", RTL: true},
+	"ur-pk": {DateFormat: "02/01/2006", TimeFormat: "15:04", Decimal: ".", Group: ",", CurrencyFormat: "¤#", RTL: true},
+}}
+
+func init() {
+	for lang, tag := range map[string]string{
+		"en": "en-us", "de": "de-de", "fr": "fr-fr", "es": "es-es", "it": "it-it",
+		"nl": "nl-nl", "pt": "pt-br", "id": "id-id", "ja": "ja-jp", "zh": "zh-cn",
+		"ar": "ar-sa", "he": "he-il", "iw": "he-il", "fa": "fa-ir", "ur": "ur-pk",
+	} {
+		locales.m[lang] = locales.m[tag]
+	}
+}
+
+// RegisterLocale adds or replaces the locale with the given tag, e.g. "sv-SE".
+func RegisterLocale(tag string, l Locale) {
+	locales.mu.Lock()
+	defer locales.mu.Unlock()
+
+	locales.m[normalizeLocale(tag)] = l
+}
+
+// LookupLocale returns the locale for a tag such as "de-DE", falling back to its language and then to en-US.
+func LookupLocale(tag string) Locale {
+	locales.mu.RLock()
+	defer locales.mu.RUnlock()
+
+	tag = normalizeLocale(tag)
+	if l, ok := locales.m[tag]; ok {
+		return l
+	}
+	if i := strings.Index(tag, "-"); i > 0 {
+		if l, ok := locales.m[tag[:i]]; ok {
+			return l
+		}
+	}
+	return locales.m["en-us"]
+}
+
+// normalizeLocale lower-cases a tag and accepts "_" as the separator, as in "pt_BR".
+func normalizeLocale(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+}
+
+// currencySymbols maps ISO 4217 codes to their symbols. Other codes are printed as is.
+var currencySymbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "CNY": "¥", "INR": "₹",
+	"BRL": "R$", "IDR": "Rp", "CHF": "CHF", "KRW": "₩", "ILS": "₪",
+}
+
+// zeroDecimalCurrencies lists the currencies that have no minor unit.
+var zeroDecimalCurrencies = map[string]bool{"JPY": true, "KRW": true}
+
+// FormatDate formats t as a date in the locale.
+func (l Locale) FormatDate(t time.Time) string {
+	return t.Format(l.DateFormat)
+}
+
+// FormatTime formats t as a time of day in the locale.
+func (l Locale) FormatTime(t time.Time) string {
+	return t.Format(l.TimeFormat)
+}
+
+// FormatDateTime formats t as a date followed by a time of day in the locale.
+func (l Locale) FormatDateTime(t time.Time) string {
+	return t.Format(l.DateFormat + " " + l.TimeFormat)
+}
+
+// FormatNumber formats n with the locale's separators and the given number of decimals.
+// A negative precision uses as many decimals as necessary.
+func (l Locale) FormatNumber(n float64, precision int) string {
+	s := strconv.FormatFloat(math.Abs(n), 'f', precision, 64)
+
+	whole, frac, _ := strings.Cut(s, ".")
+	var b strings.Builder
+	if n < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, ch := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(l.Group)
+		}
+		b.WriteRune(ch)
+	}
+	if frac != "" {
+		b.WriteString(l.Decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// FormatCurrency formats an amount in the currency with the given ISO 4217 code, e.g. "EUR".
+func (l Locale) FormatCurrency(amount float64, currency string) string {
+	currency = strings.ToUpper(currency)
+	precision := 2
+	if zeroDecimalCurrencies[currency] {
+		precision = 0
+	}
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		symbol = currency + " "
+	}
+
+	format := l.CurrencyFormat
+	if format == "" {
+		format = "¤#"
+	}
+	number := l.FormatNumber(amount, precision)
+	if strings.HasPrefix(number, "-") {
+		return "-" + strings.TrimSpace(strings.Replace(strings.Replace(format, "#", number[1:], 1), "¤", symbol, 1))
+	}
+	return strings.TrimSpace(strings.Replace(strings.Replace(format, "#", number, 1), "¤", symbol, 1))
+}
+
+// templateFuncs lists the formatting functions available in template placeholders.
+var templateFuncs = map[string]bool{"date": true, "time": true, "datetime": true, "number": true, "currency": true, "bidi": true}
+
+// templateBuiltins lists the placeholders that need no parameter: {{dir}} is rtl or ltr, and {{start}} and
+// {{end}} are the sides text starts and ends on, for direction-aware layout such as align="{{start}}".
+var templateBuiltins = map[string]bool{"dir": true, "start": true, "end": true}
+
+// builtinValue returns the value of a builtin placeholder for the text direction.
+func builtinValue(name string, rtl bool) string {
+	if name == "dir" {
+		if rtl {
+			return DirectionRTL
+		}
+		return DirectionLTR
+	}
+
+	// Text starts on the right in right-to-left layouts
+	if (name == "start") == rtl {
+		return "right"
+	}
+	return "left"
+}
+
+// templateCall splits a placeholder such as "currency total EUR" into the function, the parameter key, and the
+// remaining arguments. It reports false for a plain {{key}} placeholder.
+func templateCall(expr string) (fn, key string, args []string, ok bool) {
+	fields := strings.Fields(expr)
+	if len(fields) < 2 || !templateFuncs[fields[0]] {
+		return "", "", nil, false
+	}
+	return fields[0], fields[1], fields[2:], true
+}
+
+// renderLocalized replaces the placeholders of text with the parameters, formatting the ones that call a
+// function such as {{date key}} in the locale. Builtins such as {{dir}} follow rtl unless a parameter of the same
+// name is given. Placeholders without a matching parameter are left as is.
+func renderLocalized(text string, parameters map[string]interface{}, locale Locale, rtl bool) string {
+	return placeholder.ReplaceAllStringFunc(text, func(m string) string {
+		expr := m[2 : len(m)-2]
+
+		fn, key, args, ok := templateCall(expr)
+		if !ok {
+			if value, ok := parameters[expr]; ok {
+				return fmt.Sprintf("%v", value)
+			}
+			if templateBuiltins[expr] {
+				return builtinValue(expr, rtl)
+			}
+			return m
+		}
+
+		value, found := parameters[key]
+		if !found {
+			return m
+		}
+		if fn == "bidi" {
+			return bidiIsolate(fmt.Sprintf("%v", value))
+		}
+		if s, ok := formatValue(fn, args, value, locale); ok {
+			return s
+		}
+		return fmt.Sprintf("%v", value)
+	})
+}
+
+// formatValue applies a template function to value. It reports false when value has the wrong type.
+func formatValue(fn string, args []string, value interface{}, locale Locale) (string, bool) {
+	if t, ok := value.(time.Time); ok {
+		switch fn {
+		case "date":
+			return locale.FormatDate(t), true
+		case "time":
+			return locale.FormatTime(t), true
+		case "datetime":
+			return locale.FormatDateTime(t), true
+		}
+		return "", false
+	}
+
+	n, ok := toFloat(value)
+	if !ok {
+		return "", false
+	}
+	switch fn {
+	case "number":
+		precision := -1
+		if len(args) > 0 {
+			if p, err := strconv.Atoi(args[0]); err == nil {
+				precision = p
+			}
+		}
+		return locale.FormatNumber(n, precision), true
+	case "currency":
+		if len(args) == 0 {
+			return "", false
+		}
+		return locale.FormatCurrency(n, args[0]), true
+	}
+	return "", false
+}
+
+// toFloat converts an integer or floating point value to float64.
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,25 @@
+package smtp
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler drops every record; it is used when no logger is configured.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// discardLogger is the logger used when none is configured.
+var discardLogger = slog.New(discardHandler{})
+
+// log returns the configured logger, or one that discards everything.
+func (c *SMTP) log() *slog.Logger {
+	if c.logger == nil {
+		return discardLogger
+	}
+	return c.logger
+}
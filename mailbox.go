@@ -0,0 +1,254 @@
+package smtp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MailboxConfig holds the connection settings of a POP3 or IMAP mailbox.
+type MailboxConfig struct {
+	// Addr is the server as "host:port".
+	Addr     string
+	Username string
+	Password string
+	// TLS connects with implicit TLS, as on ports 995 (POP3) and 993 (IMAP).
+	TLS bool
+	// Folder is the IMAP folder to poll; it defaults to INBOX.
+	Folder string
+}
+
+// dial opens the connection to the mailbox server and bounds it by ctx.
+func (m MailboxConfig) dial(ctx context.Context) (net.Conn, func() bool, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mailbox error, failed to dial %s; %w", m.Addr, err)
+	}
+	if m.TLS {
+		host, _, _ := net.SplitHostPort(m.Addr)
+		conn = tls.Client(conn, &tls.Config{ServerName: host})
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return conn, context.AfterFunc(ctx, func() { conn.Close() }), nil
+}
+
+// POP3Mailbox polls a mailbox over POP3 (RFC 1939).
+type POP3Mailbox struct {
+	MailboxConfig
+}
+
+// Poll calls handle for every message and deletes the handled ones when the session ends.
+func (m *POP3Mailbox) Poll(ctx context.Context, handle func(data []byte) error) error {
+	conn, stop, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	cmd := func(format string, args ...interface{}) (string, error) {
+		if format != "" {
+			if err := text.PrintfLine(format, args...); err != nil {
+				return "", err
+			}
+		}
+		line, err := text.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(line, "+OK") {
+			return "", fmt.Errorf("mailbox error, %s", line)
+		}
+		return line, nil
+	}
+
+	if _, err = cmd(""); err != nil {
+		return err
+	}
+	if _, err = cmd("USER %s", m.Username); err != nil {
+		return err
+	}
+	if _, err = cmd("PASS %s", m.Password); err != nil {
+		return fmt.Errorf("mailbox error, authentication failed")
+	}
+
+	if _, err = cmd("LIST"); err != nil {
+		return err
+	}
+	lines, err := text.ReadDotLines()
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		n, _, _ := strings.Cut(line, " ")
+		if _, err = cmd("RETR %s", n); err != nil {
+			return err
+		}
+		data, err := text.ReadDotBytes()
+		if err != nil {
+			return err
+		}
+
+		if handle(data) != nil {
+			continue
+		}
+		if _, err = cmd("DELE %s", n); err != nil {
+			return err
+		}
+	}
+
+	// Deletions only take effect once the session ends with QUIT
+	_, err = cmd("QUIT")
+	return err
+}
+
+// IMAPMailbox polls a folder over IMAP4rev1 (RFC 3501).
+type IMAPMailbox struct {
+	MailboxConfig
+}
+
+// imapLiteral matches a literal announcement at the end of a response line.
+var imapLiteral = regexp.MustCompile(`\{(\d+)\}$`)
+
+// imapConn is a minimal IMAP command channel.
+type imapConn struct {
+	w   io.Writer
+	r   *bufio.Reader
+	tag int
+}
+
+// command sends a tagged command and returns the untagged response lines and the literals they carried.
+func (c *imapConn) command(format string, args ...interface{}) ([]string, [][]byte, error) {
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	if _, err := fmt.Fprintf(c.w, tag+" "+format+"\r\n", args...); err != nil {
+		return nil, nil, err
+	}
+
+	var lines []string
+	var literals [][]byte
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Responses may carry {n} literals followed by the rest of the line
+		full := line
+		for {
+			m := imapLiteral.FindStringSubmatch(line)
+			if m == nil {
+				break
+			}
+			n, _ := strconv.Atoi(m[1])
+			literal := make([]byte, n)
+			if _, err = io.ReadFull(c.r, literal); err != nil {
+				return nil, nil, err
+			}
+			literals = append(literals, literal)
+
+			if line, err = c.readLine(); err != nil {
+				return nil, nil, err
+			}
+			full += line
+		}
+
+		if rest, ok := strings.CutPrefix(full, tag+" "); ok {
+			if !strings.HasPrefix(rest, "OK") {
+				return nil, nil, fmt.Errorf("mailbox error, %s", rest)
+			}
+			return lines, literals, nil
+		}
+		lines = append(lines, full)
+	}
+}
+
+func (c *imapConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// imapQuote quotes s as an IMAP string.
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// Poll calls handle for every message in the folder and expunges the handled ones.
+func (m *IMAPMailbox) Poll(ctx context.Context, handle func(data []byte) error) error {
+	conn, stop, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	defer conn.Close()
+
+	c := &imapConn{w: conn, r: bufio.NewReader(conn)}
+	greeting, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("mailbox error, %s", greeting)
+	}
+
+	if _, _, err = c.command("LOGIN %s %s", imapQuote(m.Username), imapQuote(m.Password)); err != nil {
+		return fmt.Errorf("mailbox error, authentication failed")
+	}
+
+	folder := m.Folder
+	if folder == "" {
+		folder = "INBOX"
+	}
+	if _, _, err = c.command("SELECT %s", imapQuote(folder)); err != nil {
+		return err
+	}
+
+	lines, _, err := c.command("UID SEARCH ALL")
+	if err != nil {
+		return err
+	}
+	var uids []string
+	for _, line := range lines {
+		if rest, ok := strings.CutPrefix(line, "* SEARCH"); ok {
+			uids = append(uids, strings.Fields(rest)...)
+		}
+	}
+
+	deleted := false
+	for _, uid := range uids {
+		_, literals, err := c.command("UID FETCH %s BODY.PEEK[]", uid)
+		if err != nil {
+			return err
+		}
+		if len(literals) == 0 || handle(literals[0]) != nil {
+			continue
+		}
+		if _, _, err = c.command(`UID STORE %s +FLAGS.SILENT (\Deleted)`, uid); err != nil {
+			return err
+		}
+		deleted = true
+	}
+
+	if deleted {
+		if _, _, err = c.command("EXPUNGE"); err != nil {
+			return err
+		}
+	}
+	_, _, err = c.command("LOGOUT")
+	return err
+}
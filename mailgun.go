@@ -0,0 +1,57 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+)
+
+// MailgunTransport sends messages through the Mailgun messages.mime API as raw MIME, so the assembled message,
+// its headers, and its DKIM signature reach Mailgun unchanged. Use it with WithTransport.
+type MailgunTransport struct {
+	// Domain is the sending domain configured in Mailgun, e.g. mg.example.com.
+	Domain string
+	APIKey Secret
+	// Endpoint overrides https://api.mailgun.net, e.g. https://api.eu.mailgun.net for the EU region.
+	Endpoint string
+	// Client is used to send requests; it defaults to a client with a 30 second timeout.
+	Client *http.Client
+}
+
+// Deliver sends the message to the envelope recipients.
+func (t *MailgunTransport) Deliver(ctx context.Context, from string, recipients []string, msg *Message) error {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, rcpt := range recipients {
+		if err := w.WriteField("to", rcpt); err != nil {
+			return fmt.Errorf("mailgun error, failed to encode request; %w", err)
+		}
+	}
+	part, err := w.CreateFormFile("message", "message.eml")
+	if err != nil {
+		return fmt.Errorf("mailgun error, failed to encode request; %w", err)
+	}
+	if _, err = msg.WriteTo(part); err != nil {
+		return fmt.Errorf("mailgun error, failed to write message; %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("mailgun error, failed to encode request; %w", err)
+	}
+
+	endpoint := t.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.mailgun.net"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v3/"+url.PathEscape(t.Domain)+"/messages.mime", &body)
+	if err != nil {
+		return fmt.Errorf("mailgun error, failed to create request; %w", err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.SetBasicAuth("api", t.APIKey.Reveal())
+
+	_, err = doAPI(t.Client, req, "mailgun")
+	return err
+}
@@ -0,0 +1,337 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"net/textproto"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// headerNameExceptions lists header names whose conventional spelling differs from the MIME canonical form.
+var headerNameExceptions = map[string]string{
+	"Message-Id":     "Message-ID",
+	"Mime-Version":   "MIME-Version",
+	"Dkim-Signature": "DKIM-Signature",
+}
+
+// CanonicalHeaderName returns the conventional spelling of a header name, e.g. "x-mailer" becomes "X-Mailer".
+func CanonicalHeaderName(name string) string {
+	name = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+	if exception, ok := headerNameExceptions[name]; ok {
+		return exception
+	}
+	return name
+}
+
+// HeaderField represents a single header line of an assembled message.
+type HeaderField struct {
+	Name  string
+	Value string
+}
+
+// Message represents an assembled message ready to be written to the DATA stream.
+type Message struct {
+	Header []HeaderField
+	// Body is the body of a message without MIME parts.
+	Body string
+
+	// segments replace Body for MIME messages and raw messages; their content is encoded while the message
+	// is written
+	segments []segment
+	// reply is the server's reply to the transferred message, e.g. "250 2.0.0 Ok: queued as 4F3A".
+	reply string
+}
+
+// Get returns the value of the last header field with the given name, or an empty string.
+func (m *Message) Get(name string) string {
+	for i := len(m.Header) - 1; i >= 0; i-- {
+		if strings.EqualFold(m.Header[i].Name, name) {
+			return m.Header[i].Value
+		}
+	}
+	return ""
+}
+
+// Set replaces the value of the first header field with the given name, or appends a new field.
+func (m *Message) Set(name, value string) {
+	name = CanonicalHeaderName(name)
+	value = sanitizeHeaderValue(value)
+	for i := range m.Header {
+		if strings.EqualFold(m.Header[i].Name, name) {
+			m.Header[i].Value = value
+			return
+		}
+	}
+	m.Header = append(m.Header, HeaderField{Name: name, Value: value})
+}
+
+// Sort reorders the header fields so the named fields come first in the given order.
+// The relative order of fields not listed is preserved.
+func (m *Message) Sort(order []string) {
+	if len(order) == 0 {
+		return
+	}
+
+	rank := map[string]int{}
+	for i, name := range order {
+		rank[strings.ToLower(name)] = i
+	}
+
+	sort.SliceStable(m.Header, func(i, j int) bool {
+		ri, iok := rank[strings.ToLower(m.Header[i].Name)]
+		rj, jok := rank[strings.ToLower(m.Header[j].Name)]
+		if iok && jok {
+			return ri < rj
+		}
+		return iok && !jok
+	})
+}
+
+// Prepend adds a header field before all existing fields.
+func (m *Message) Prepend(name, value string) {
+	m.Header = append([]HeaderField{{Name: name, Value: sanitizeHeaderValue(value)}}, m.Header...)
+}
+
+// sanitizeHeaderValue replaces line breaks so a value can never inject additional header fields.
+func sanitizeHeaderValue(value string) string {
+	if !strings.ContainsAny(value, "\r\n") {
+		return value
+	}
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(value)
+}
+
+// Bytes returns the wire representation of the message.
+// Streamed attachments are read into memory; use WriteTo to avoid that and to see read errors.
+func (m *Message) Bytes() []byte {
+	var b bytes.Buffer
+	b.WriteString(m.headerText())
+	m.writeBody(&b)
+	return b.Bytes()
+}
+
+// headerText returns the header block of the message including the empty line that ends it.
+func (m *Message) headerText() string {
+	var b strings.Builder
+	for _, f := range m.Header {
+		writeHeaderField(&b, f.Name, f.Value)
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// foldLength is the line length that header fields are folded at, as RFC 5322 section 2.1.1 recommends.
+const foldLength = 78
+
+// writeHeaderField writes a header field folded at whitespace into lines of at most 78 characters where its value
+// allows it (RFC 5322 section 2.2.3). Folding only inserts CRLF before existing whitespace, so unfolding restores
+// the value, and DKIM signatures over it stay valid. Values that are folded already, such as those of raw
+// messages, are written as they are.
+func writeHeaderField(b *strings.Builder, name, value string) {
+	line := name + ": " + value
+	if len(line) <= foldLength || strings.Contains(value, "\r\n") {
+		b.WriteString(line + "\r\n")
+		return
+	}
+
+	// The first line keeps at least the name and the start of the value, later lines at least one word
+	start := len(name) + 2
+	for len(line) > foldLength {
+		i := strings.LastIndexAny(line[:foldLength+1], " \t")
+		if i <= start {
+			// A word longer than the line is kept whole; it is only broken by the next whitespace
+			next := strings.IndexAny(line[foldLength:], " \t")
+			if next < 0 {
+				break
+			}
+			i = foldLength + next
+		}
+		b.WriteString(line[:i] + "\r\n")
+		line = line[i:]
+		start = len(line) - len(strings.TrimLeft(line, " \t"))
+	}
+	b.WriteString(line + "\r\n")
+}
+
+// buildMessage assembles the headers and body of the email.
+func (c *SMTP) buildMessage(email Email) *Message {
+	msg := &Message{}
+	msg.Set("Date", c.now().Format(time.RFC1123Z))
+	msg.Set("Message-ID", newMessageID(c.senderAddress, c.generateID()))
+	msg.Set("From", c.fromHeader(email))
+	if email.InlineCSS && email.HTMLBody != "" {
+		email.HTMLBody = InlineCSS(email.HTMLBody)
+	}
+	if c.tracking.enabled() && !email.NoTracking && email.TrackingID != "" && email.HTMLBody != "" {
+		email.HTMLBody = c.tracking.apply(email.HTMLBody, email.TrackingID, !email.noPixel)
+	}
+	subject := email.Subject
+	if email.rtl() {
+		subject = bidiSubject(subject)
+		if email.HTMLBody != "" {
+			email.HTMLBody = directHTML(email.HTMLBody, DirectionRTL, email.Locale)
+		}
+	}
+	msg.Set("Subject", subject)
+	msg.Set("To", strings.Join(email.To, ", "))
+
+	if len(email.Cc) != 0 {
+		msg.Set("Cc", strings.Join(email.Cc, ", "))
+	}
+
+	if len(email.Bcc) != 0 {
+		msg.Set("Bcc", strings.Join(email.Bcc, ", "))
+	}
+
+	if len(email.Categories) != 0 {
+		msg.Set("X-Categories", categoriesHeader(email.Categories))
+	}
+
+	if id := msgID(email.InReplyTo); id != "" {
+		msg.Set("In-Reply-To", id)
+	}
+	var refs []string
+	for _, ref := range email.References {
+		if ref = msgID(ref); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) != 0 {
+		msg.Set("References", strings.Join(refs, " "))
+	}
+
+	if xPriority, importance := email.Priority.headers(); xPriority != "" {
+		msg.Set("X-Priority", xPriority)
+		msg.Set("Importance", importance)
+	}
+
+	var body string
+	if len(email.Attachments) != 0 || email.HTMLBody != "" || email.Calendar != nil {
+		root := buildBody(email, c.boundary)
+		msg.Set("MIME-Version", "1.0")
+		for _, f := range root.header {
+			msg.Set(f.Name, f.Value)
+		}
+		msg.segments = root.segments
+	} else if enc := normalizeEncoding(email.BodyEncoding); enc != EncodingAuto || chooseEncoding(email.Body) != Encoding7Bit {
+		part := textPart("text/plain", email.Body, enc)
+		msg.Set("MIME-Version", "1.0")
+		for _, f := range part.header {
+			msg.Set(f.Name, f.Value)
+		}
+		msg.segments = part.segments
+	} else {
+		body = email.Body + "\r\n"
+	}
+
+	// Custom headers are emitted sorted by name so the output is deterministic
+	names := make([]string, 0, len(email.Headers))
+	for name := range email.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		msg.Set(name, email.Headers[name])
+	}
+
+	if email.Profile != nil {
+		email.Profile.apply(msg)
+	}
+
+	c.senderTag.apply(msg)
+	msg.Sort(c.headerOrder)
+
+	if c.traceService != "" {
+		msg.Prepend("X-Originating-Service", traceValue(c.traceService))
+	}
+
+	msg.Body = body
+	return msg
+}
+
+// traceValue returns the X-Originating-Service value for the given service name.
+func traceValue(service string) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return "service=" + service + "; host=" + host + "; library=go-smtp/" + Version
+}
+
+// newMessageID returns a Message-ID in the domain of the sender address, with a random unique part when id
+// is empty.
+func newMessageID(sender, id string) string {
+	domain := domainOf(sender)
+	if domain == "" {
+		domain = "localhost"
+	}
+
+	if id == "" {
+		var err error
+		if id, err = newID(); err != nil {
+			id = strconv.FormatInt(time.Now().UnixNano(), 36)
+		}
+	}
+	return "<" + id + "@" + domain + ">"
+}
+
+// now returns the time of the clock set by WithClock, or the current time.
+func (c *SMTP) now() time.Time {
+	if c.clock != nil {
+		return c.clock()
+	}
+	return time.Now()
+}
+
+// generateID returns the next ID of the generator set by WithIDGenerator, or an empty string without one.
+func (c *SMTP) generateID() string {
+	if c.ids != nil {
+		return c.ids()
+	}
+	return ""
+}
+
+// boundary returns a multipart boundary, random unless an ID generator is set.
+func (c *SMTP) boundary() string {
+	if id := c.generateID(); id != "" {
+		return "=_" + id
+	}
+	return newBoundary()
+}
+
+// Assemble returns the message a send of the email would deliver, without delivering it: recipient identifiers
+// and attachment references are resolved, client defaults are applied, and the MIME structure is built.
+// Suppressed recipients are not removed. DKIM signing and the downgrades that depend on the server happen
+// at delivery, so they are missing too. It is the first half of a send; a Transport is the second.
+func (c *SMTP) Assemble(ctx context.Context, email Email) (*Message, error) {
+	if err := email.ValidateWith(c.validation); err != nil {
+		return nil, err
+	}
+	email, err := c.resolve(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return c.buildMessage(email), nil
+}
+
+// resolve resolves the recipient identifiers and attachment references of the email and applies the client
+// defaults.
+func (c *SMTP) resolve(ctx context.Context, email Email) (Email, error) {
+	email, err := c.resolveRecipients(ctx, email)
+	if err != nil {
+		return email, err
+	}
+	if email, err = c.resolveAttachments(ctx, email); err != nil {
+		return email, err
+	}
+	return c.applyDefaults(email), nil
+}
+
+// Render returns the serialized message for the email without connecting to the server.
+// Client defaults are applied; DKIM signing and capability-dependent downgrading are not.
+func (c *SMTP) Render(email Email) []byte {
+	return c.buildMessage(c.applyDefaults(email)).Bytes()
+}
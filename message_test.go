@@ -0,0 +1,209 @@
+package smtp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteHeaderField(t *testing.T) {
+	long := strings.Repeat("x", 120)
+
+	tests := []struct {
+		name  string
+		field string
+		value string
+		want  string
+	}{
+		{
+			name:  "short",
+			field: "Subject",
+			value: "Hello",
+			want:  "Subject: Hello\r\n",
+		},
+		{
+			name:  "exactly 78",
+			field: "Subject",
+			value: strings.Repeat("a", 78-len("Subject: ")),
+			want:  "Subject: " + strings.Repeat("a", 78-len("Subject: ")) + "\r\n",
+		},
+		{
+			name:  "folded at 78",
+			field: "To",
+			value: "alice@example.com, bob@example.com, carol@example.com, dave@example.com, erin@example.com",
+			want:  "To: alice@example.com, bob@example.com, carol@example.com, dave@example.com,\r\n erin@example.com\r\n",
+		},
+		{
+			name:  "folded at a tab",
+			field: "Subject",
+			value: strings.Repeat("word ", 14) + "end\tlast",
+			want:  "Subject: " + strings.Repeat("word ", 13) + "word\r\n end\tlast\r\n",
+		},
+		{
+			name:  "unbreakable token kept whole",
+			field: "Message-ID",
+			value: "<" + long + "@example.com>",
+			want:  "Message-ID: <" + long + "@example.com>\r\n",
+		},
+		{
+			name:  "unbreakable token broken at the next space",
+			field: "References",
+			value: "<" + long + "@example.com> <short@example.com>",
+			want:  "References: <" + long + "@example.com>\r\n <short@example.com>\r\n",
+		},
+		{
+			name:  "already folded",
+			field: "Received",
+			value: "from mx.example.com\r\n\tby relay.example.com; " + long,
+			want:  "Received: from mx.example.com\r\n\tby relay.example.com; " + long + "\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			writeHeaderField(&b, tt.field, tt.value)
+			if got := b.String(); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+
+			// Folding only inserts CRLF before whitespace, so unfolding restores the field
+			unfolded := strings.ReplaceAll(strings.TrimSuffix(b.String(), "\r\n"), "\r\n", "")
+			if want := tt.field + ": " + strings.ReplaceAll(tt.value, "\r\n", ""); unfolded != want {
+				t.Fatalf("unfolded to %q, want %q", unfolded, want)
+			}
+		})
+	}
+}
+
+func TestWriteHeaderFieldLineLength(t *testing.T) {
+	words := strings.Repeat("lorem ipsum dolor sit amet ", 40)
+
+	var b strings.Builder
+	writeHeaderField(&b, "Subject", words)
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+		if len(line) > foldLength {
+			t.Fatalf("line of %d characters exceeds %d: %q", len(line), foldLength, line)
+		}
+	}
+}
+
+func TestFitEncoding(t *testing.T) {
+	atLimit := strings.Repeat("a", maxLineLength)
+	overLimit := strings.Repeat("a", maxLineLength+1)
+
+	tests := []struct {
+		name string
+		enc  Encoding
+		text string
+		want Encoding
+	}{
+		{name: "auto ascii", enc: EncodingAuto, text: "hello\r\n", want: Encoding7Bit},
+		{name: "auto line at 998", enc: EncodingAuto, text: atLimit + "\r\nnext", want: Encoding7Bit},
+		{name: "auto line over 998", enc: EncodingAuto, text: atLimit + "\r\n" + overLimit, want: EncodingQuotedPrintable},
+		{name: "auto bare LF lines", enc: EncodingAuto, text: atLimit + "\n" + atLimit, want: Encoding7Bit},
+		{name: "7bit line over 998", enc: Encoding7Bit, text: overLimit, want: EncodingQuotedPrintable},
+		{name: "7bit non-ascii", enc: Encoding7Bit, text: "café au lait", want: EncodingQuotedPrintable},
+		{name: "8bit line at 998", enc: Encoding8Bit, text: atLimit, want: Encoding8Bit},
+		{name: "8bit line over 998", enc: Encoding8Bit, text: "é" + overLimit, want: EncodingQuotedPrintable},
+		{name: "8bit cyrillic over 998", enc: Encoding8Bit, text: strings.Repeat("я", 500), want: EncodingBase64},
+		{name: "quoted-printable kept", enc: EncodingQuotedPrintable, text: overLimit, want: EncodingQuotedPrintable},
+		{name: "base64 kept", enc: EncodingBase64, text: "hello", want: EncodingBase64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fitEncoding(tt.enc, tt.text); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCRLFNormalization(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "crlf kept", in: "a\r\nb\r\n", want: "a\r\nb\r\n"},
+		{name: "bare lf", in: "a\nb\n", want: "a\r\nb\r\n"},
+		{name: "bare cr", in: "a\rb\r", want: "a\r\nb\r\n"},
+		{name: "cr before crlf", in: "a\r\r\nb", want: "a\r\n\r\nb"},
+		{name: "lf cr", in: "a\n\rb", want: "a\r\n\r\nb"},
+		{name: "blank lines", in: "\n\n\r\n", want: "\r\n\r\n\r\n"},
+		{name: "no line ending", in: "abc", want: "abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(normalizeCRLF([]byte(tt.in))); got != tt.want {
+				t.Fatalf("normalizeCRLF: got %q, want %q", got, tt.want)
+			}
+
+			// The streaming writer must agree however the input is split between writes
+			for split := 0; split <= len(tt.in); split++ {
+				var b bytes.Buffer
+				cw := &crlfWriter{w: &b}
+				cw.Write([]byte(tt.in[:split]))
+				cw.Write([]byte(tt.in[split:]))
+				if err := cw.flush(); err != nil {
+					t.Fatal(err)
+				}
+				if got := b.String(); got != tt.want {
+					t.Fatalf("crlfWriter split at %d: got %q, want %q", split, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMarshalLineLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		// enc is the Content-Transfer-Encoding the body must be sent with
+		enc Encoding
+	}{
+		{name: "short lines", body: "line one\nline two\rline three\r\n", enc: Encoding7Bit},
+		{name: "line at 998", body: strings.Repeat("a", maxLineLength) + "\n", enc: Encoding7Bit},
+		{name: "line over 998", body: strings.Repeat("a", 5000) + "\n", enc: EncodingQuotedPrintable},
+		{name: "unbreakable non-ascii line", body: strings.Repeat("ж", 2000), enc: EncodingBase64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(Email{
+				To:      []string{"user@example.com"},
+				Subject: "Line limits " + strings.Repeat("and folding ", 20),
+				Body:    tt.body,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 7bit is the default and need not be declared
+			declared := bytes.Contains(data, []byte("Content-Transfer-Encoding: "+string(tt.enc)+"\r\n"))
+			if tt.enc == Encoding7Bit && !declared && bytes.Contains(data, []byte("Content-Transfer-Encoding: ")) ||
+				tt.enc != Encoding7Bit && !declared {
+				t.Fatalf("body not sent as %s:\n%s", tt.enc, data)
+			}
+			for i, line := range bytes.Split(data, []byte("\r\n")) {
+				if bytes.ContainsAny(line, "\r\n") {
+					t.Fatalf("line %d has a bare CR or LF: %q", i+1, line)
+				}
+				if len(line) > maxLineLength {
+					t.Fatalf("line %d has %d octets, more than %d", i+1, len(line), maxLineLength)
+				}
+			}
+
+			email, err := ParseMessage(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := string(normalizeCRLF([]byte(tt.body))); strings.TrimRight(email.Body, "\r\n") != strings.TrimRight(want, "\r\n") {
+				t.Fatalf("body did not survive the round trip")
+			}
+		})
+	}
+}
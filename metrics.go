@@ -0,0 +1,116 @@
+package smtp
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metricsWindow is the period covered by the latency and error rate of a Metrics snapshot.
+const metricsWindow = time.Minute
+
+// Metrics is a snapshot of the sending activity of a client.
+type Metrics struct {
+	// Queued is the number of sends waiting for their turn: throttled, waiting for a connection, or backing off between attempts.
+	Queued int
+	// InFlight is the number of sends currently in an SMTP transaction.
+	InFlight int
+
+	// Sent and Failed count the sends that finished within the window.
+	Sent   int
+	Failed int
+	// AverageLatency is the mean duration of the sends that finished within the window, retries included.
+	AverageLatency time.Duration
+	// ErrorRate is the share of the sends within the window that failed, between 0 and 1.
+	ErrorRate float64
+	// Window is the period covered by Sent, Failed, AverageLatency, and ErrorRate.
+	Window time.Duration
+}
+
+// metricsBucket holds the sends that finished within one second.
+type metricsBucket struct {
+	second  int64
+	sent    int
+	failed  int
+	latency time.Duration
+}
+
+// metrics tracks the sends of a client in one-second buckets covering metricsWindow.
+type metrics struct {
+	// pending counts the sends in progress and inFlight those of them holding a connection
+	pending  int64
+	inFlight int64
+
+	mu      sync.Mutex
+	buckets [int(metricsWindow / time.Second)]metricsBucket
+}
+
+// begin marks the start of a send.
+func (m *metrics) begin() {
+	atomic.AddInt64(&m.pending, 1)
+}
+
+// transaction marks a send as in flight while it runs an SMTP transaction; the returned function ends it.
+func (m *metrics) transaction() func() {
+	atomic.AddInt64(&m.inFlight, 1)
+	return func() { atomic.AddInt64(&m.inFlight, -1) }
+}
+
+// end records the outcome of a send.
+func (m *metrics) end(latency time.Duration, err error) {
+	atomic.AddInt64(&m.pending, -1)
+
+	second := time.Now().Unix()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := &m.buckets[second%int64(len(m.buckets))]
+	if b.second != second {
+		*b = metricsBucket{second: second}
+	}
+	if err != nil {
+		b.failed++
+	} else {
+		b.sent++
+	}
+	b.latency += latency
+}
+
+// snapshot returns the current metrics.
+func (m *metrics) snapshot() Metrics {
+	inFlight := atomic.LoadInt64(&m.inFlight)
+	pending := atomic.LoadInt64(&m.pending)
+
+	out := Metrics{
+		Queued:   int(max(pending-inFlight, 0)),
+		InFlight: int(inFlight),
+		Window:   metricsWindow,
+	}
+
+	oldest := time.Now().Unix() - int64(len(m.buckets)) + 1
+
+	m.mu.Lock()
+	var latency time.Duration
+	for _, b := range m.buckets {
+		if b.second < oldest {
+			continue
+		}
+		out.Sent += b.sent
+		out.Failed += b.failed
+		latency += b.latency
+	}
+	m.mu.Unlock()
+
+	if total := out.Sent + out.Failed; total > 0 {
+		out.AverageLatency = latency / time.Duration(total)
+		out.ErrorRate = float64(out.Failed) / float64(total)
+	}
+	return out
+}
+
+// Metrics returns a snapshot of the client's queue depth, in-flight sends, and the latency and error rate
+// of the sends that finished in the last minute. It is cheap enough to call from health endpoints and autoscalers.
+func (c *SMTP) Metrics() Metrics {
+	return c.metrics.snapshot()
+}
@@ -0,0 +1,19 @@
+package smtp
+
+import "context"
+
+// SendFunc sends a single email.
+type SendFunc func(ctx context.Context, email Email) error
+
+// Middleware wraps sending with cross-cutting behavior such as audit logging, PII scrubbing, or
+// recipient allowlisting. It may inspect or change the email before passing it to next, or return
+// without calling next to drop it.
+type Middleware func(next SendFunc) SendFunc
+
+// chain wraps send with the middlewares, the first one being the outermost.
+func chain(send SendFunc, middlewares []Middleware) SendFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		send = middlewares[i](send)
+	}
+	return send
+}
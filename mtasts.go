@@ -0,0 +1,325 @@
+package smtp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MTA-STS policy modes (RFC 8461).
+const (
+	MTASTSEnforce = "enforce"
+	MTASTSTesting = "testing"
+	MTASTSNone    = "none"
+)
+
+// MTASTSPolicy is the MTA-STS policy of a recipient domain.
+type MTASTSPolicy struct {
+	Domain string
+	ID     string
+	Mode   string
+	MX     []string
+	MaxAge time.Duration
+}
+
+// MatchMX reports whether host matches one of the policy's MX patterns.
+// A pattern of the form "*.example.com" matches exactly one additional leftmost label.
+func (p *MTASTSPolicy) MatchMX(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range p.MX {
+		pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if label, rest, found := strings.Cut(host, "."); found && label != "" && rest == suffix {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// MTASTSError is returned when a connection violates the MTA-STS policy of a recipient domain.
+type MTASTSError struct {
+	Domain string
+	MX     string
+	Reason string
+}
+
+// Error returns a description of the policy failure.
+func (e *MTASTSError) Error() string {
+	return fmt.Sprintf("mta-sts error, %s for %s via %s", e.Reason, e.Domain, e.MX)
+}
+
+// MTASTSFetcher discovers and caches MTA-STS policies.
+type MTASTSFetcher struct {
+	// LookupTXT resolves TXT records; it defaults to net.DefaultResolver.LookupTXT.
+	LookupTXT func(ctx context.Context, name string) ([]string, error)
+	// Client fetches policy files; it defaults to a client with a 10 second timeout that does not follow redirects.
+	Client *http.Client
+	// OnFailure is called for every policy failure, including those of domains in testing mode which do not block delivery.
+	OnFailure func(err *MTASTSError)
+
+	mu       sync.Mutex
+	policies map[string]cachedPolicy
+}
+
+// cachedPolicy is a policy together with the time it expires.
+type cachedPolicy struct {
+	policy  *MTASTSPolicy
+	expires time.Time
+}
+
+// Policy returns the policy of the domain, or nil when the domain does not publish one.
+// A cached policy is reused until it expires or the id in the _mta-sts TXT record changes,
+// and keeps being used while the record or policy file cannot be fetched.
+func (f *MTASTSFetcher) Policy(ctx context.Context, domain string) (*MTASTSPolicy, error) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	f.mu.Lock()
+	cached, ok := f.policies[domain]
+	f.mu.Unlock()
+	if ok && time.Now().After(cached.expires) {
+		ok = false
+	}
+
+	id, err := f.lookupID(ctx, domain)
+	if err != nil {
+		if ok {
+			return cached.policy, nil
+		}
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if id == "" {
+		if ok {
+			return cached.policy, nil
+		}
+		return nil, nil
+	}
+	if ok && cached.policy.ID == id {
+		return cached.policy, nil
+	}
+
+	policy, err := f.fetch(ctx, domain)
+	if err != nil {
+		if ok {
+			return cached.policy, nil
+		}
+		return nil, err
+	}
+	policy.ID = id
+
+	f.mu.Lock()
+	if f.policies == nil {
+		f.policies = map[string]cachedPolicy{}
+	}
+	f.policies[domain] = cachedPolicy{policy: policy, expires: time.Now().Add(policy.MaxAge)}
+	f.mu.Unlock()
+
+	return policy, nil
+}
+
+// lookupID returns the policy id published in the _mta-sts TXT record, or "" when there is no valid record.
+func (f *MTASTSFetcher) lookupID(ctx context.Context, domain string) (string, error) {
+	lookup := f.LookupTXT
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupTXT
+	}
+
+	records, err := lookup(ctx, "_mta-sts."+domain)
+	if err != nil {
+		return "", fmt.Errorf("mta-sts error, failed to look up policy record of %s; %w", domain, err)
+	}
+
+	var id string
+	for _, record := range records {
+		if !strings.HasPrefix(record, "v=STSv1") {
+			continue
+		}
+		// Multiple records mean no policy is published
+		if id != "" {
+			return "", nil
+		}
+		for _, field := range strings.Split(record, ";") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(field), "id="); ok {
+				id = value
+			}
+		}
+	}
+	return id, nil
+}
+
+// fetch downloads and parses the policy file of the domain.
+func (f *MTASTSFetcher) fetch(ctx context.Context, domain string) (*MTASTSPolicy, error) {
+	client := f.Client
+	if client == nil {
+		client = &http.Client{
+			Timeout: 10 * time.Second,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+
+	url := "https://mta-sts." + domain + "/.well-known/mta-sts.txt"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("mta-sts error, failed to create request; %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("mta-sts error, failed to fetch policy of %s; %w", domain, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("mta-sts error, fetching policy of %s returned %s", domain, resp.Status)
+	}
+
+	// Policy files are limited to 64 KiB
+	policy, err := parseMTASTSPolicy(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, fmt.Errorf("mta-sts error, invalid policy of %s; %w", domain, err)
+	}
+	policy.Domain = domain
+	return policy, nil
+}
+
+// parseMTASTSPolicy parses the key: value lines of a policy file.
+func parseMTASTSPolicy(r io.Reader) (*MTASTSPolicy, error) {
+	policy := &MTASTSPolicy{}
+	version := ""
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "version":
+			version = value
+		case "mode":
+			policy.Mode = value
+		case "mx":
+			policy.MX = append(policy.MX, value)
+		case "max_age":
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || seconds < 0 {
+				return nil, fmt.Errorf("invalid max_age %q", value)
+			}
+			policy.MaxAge = time.Duration(seconds) * time.Second
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if version != "STSv1" {
+		return nil, fmt.Errorf("unsupported version %q", version)
+	}
+	switch policy.Mode {
+	case MTASTSEnforce, MTASTSTesting:
+		if len(policy.MX) == 0 {
+			return nil, fmt.Errorf("no mx patterns")
+		}
+	case MTASTSNone:
+	default:
+		return nil, fmt.Errorf("unknown mode %q", policy.Mode)
+	}
+	return policy, nil
+}
+
+// Check verifies a connection to mxHost against the policy of the domain. state is nil when the connection is not encrypted.
+// Failures of domains in testing mode are reported to OnFailure but do not return an error.
+func (f *MTASTSFetcher) Check(ctx context.Context, domain, mxHost string, state *tls.ConnectionState) error {
+	policy, err := f.Policy(ctx, domain)
+	if err != nil || policy == nil || policy.Mode == MTASTSNone {
+		return err
+	}
+
+	var reason string
+	switch {
+	case !policy.MatchMX(mxHost):
+		reason = "mx host does not match the policy"
+	case state == nil:
+		reason = "tls is required"
+	default:
+		if err = verifyPeer(state, mxHost); err != nil {
+			reason = "certificate is not valid: " + err.Error()
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+
+	stsErr := &MTASTSError{Domain: domain, MX: mxHost, Reason: reason}
+	if f.OnFailure != nil {
+		f.OnFailure(stsErr)
+	}
+	if policy.Mode == MTASTSTesting {
+		return nil
+	}
+	return stsErr
+}
+
+// verifyPeer validates the certificate chain presented on the connection for host against the system roots.
+func verifyPeer(state *tls.ConnectionState, host string) error {
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("no certificate presented")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+// checkMTASTS verifies the session against the MTA-STS policy of every recipient domain.
+func (c *SMTP) checkMTASTS(ctx context.Context, sess *session, recipients []string) error {
+	if c.mtasts == nil {
+		return nil
+	}
+
+	var state *tls.ConnectionState
+	if s, ok := sess.client.TLSConnectionState(); ok {
+		state = &s
+	}
+
+	checked := map[string]bool{}
+	for _, addr := range recipients {
+		domain := domainOf(addr)
+		if domain == "" || checked[domain] {
+			continue
+		}
+		checked[domain] = true
+
+		if err := c.mtasts.Check(ctx, domain, sess.host, state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
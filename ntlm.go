@@ -0,0 +1,244 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net/smtp"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// NTLM negotiate flags, see MS-NLMP section 2.2.2.5.
+const (
+	ntlmNegotiateUnicode        = 0x00000001
+	ntlmRequestTarget           = 0x00000004
+	ntlmNegotiateNTLM           = 0x00000200
+	ntlmNegotiateAlwaysSign     = 0x00008000
+	ntlmNegotiateExtendedSecure = 0x00080000
+	ntlmNegotiateTargetInfo     = 0x00800000
+	ntlmNegotiate128            = 0x20000000
+	ntlmNegotiate56             = 0x80000000
+
+	ntlmFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM | ntlmNegotiateAlwaysSign |
+		ntlmNegotiateExtendedSecure | ntlmNegotiateTargetInfo | ntlmNegotiate128 | ntlmNegotiate56
+)
+
+// ntlmSignature starts every NTLM message.
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmAvTimestamp is the AV pair of the target info that holds the server time.
+const ntlmAvTimestamp = 7
+
+type ntlmAuth struct {
+	domain, username, password string
+}
+
+// NTLMAuth returns an smtp.Auth that implements the NTLM mechanism with NTLMv2 responses, as accepted by
+// Microsoft Exchange. The username is either "DOMAIN\user" or a user principal name such as "ana@corp.example".
+// NTLM never sends the password, but the server is not authenticated either, so use it over TLS.
+func NTLMAuth(username, password string) smtp.Auth {
+	a := &ntlmAuth{username: username, password: password}
+	if i := strings.IndexByte(username, '\\'); i >= 0 {
+		a.domain, a.username = username[:i], username[i+1:]
+	}
+	return a
+}
+
+// Start sends the negotiate message.
+func (a *ntlmAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	advertised := false
+	for _, mechanism := range server.Auth {
+		advertised = advertised || strings.EqualFold(mechanism, "NTLM")
+	}
+	if !advertised {
+		return "", nil, errors.New("auth error, server does not support NTLM")
+	}
+
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmFlags)
+	return "NTLM", msg, nil
+}
+
+// Next answers the challenge of the server with the authenticate message.
+func (a *ntlmAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	challenge, targetInfo, flags, err := parseNTLMChallenge(fromServer)
+	if err != nil {
+		return nil, err
+	}
+
+	// The server time keeps the response valid when the clocks differ; the LMv2 response is then left empty
+	timestamp, lm := ntlmTimestamp(targetInfo), false
+	if timestamp == nil {
+		timestamp, lm = make([]byte, 8), true
+		binary.LittleEndian.PutUint64(timestamp, ntlmFiletime(time.Now()))
+	}
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, fmt.Errorf("auth error, failed to generate NTLM client challenge; %w", err)
+	}
+
+	key := ntlmv2Key(a.domain, a.username, a.password)
+	nt := ntlmv2Response(key, challenge, clientChallenge, timestamp, targetInfo)
+	lmResponse := make([]byte, 24)
+	if lm {
+		lmResponse = append(ntlmHMAC(key, challenge, clientChallenge), clientChallenge...)
+	}
+
+	return ntlmAuthenticate(flags&ntlmFlags, lmResponse, nt, utf16le(a.domain), utf16le(a.username)), nil
+}
+
+// parseNTLMChallenge returns the server challenge, the target info, and the flags of a challenge message.
+func parseNTLMChallenge(msg []byte) (challenge, targetInfo []byte, flags uint32, err error) {
+	if len(msg) < 32 || !bytes.Equal(msg[:8], ntlmSignature) || binary.LittleEndian.Uint32(msg[8:]) != 2 {
+		return nil, nil, 0, errors.New("auth error, invalid NTLM challenge")
+	}
+	flags = binary.LittleEndian.Uint32(msg[20:])
+	if flags&ntlmNegotiateUnicode == 0 {
+		return nil, nil, 0, errors.New("auth error, NTLM server does not support unicode")
+	}
+	challenge = msg[24:32]
+
+	if len(msg) >= 48 {
+		size, offset := int(binary.LittleEndian.Uint16(msg[40:])), int(binary.LittleEndian.Uint32(msg[44:]))
+		if offset+size > len(msg) {
+			return nil, nil, 0, errors.New("auth error, invalid NTLM target info")
+		}
+		targetInfo = msg[offset : offset+size]
+	}
+	return challenge, targetInfo, flags, nil
+}
+
+// ntlmTimestamp returns the server time from the target info, or nil when it has none.
+func ntlmTimestamp(targetInfo []byte) []byte {
+	for len(targetInfo) >= 4 {
+		id, size := binary.LittleEndian.Uint16(targetInfo), int(binary.LittleEndian.Uint16(targetInfo[2:]))
+		if len(targetInfo) < 4+size || id == 0 {
+			return nil
+		}
+		if id == ntlmAvTimestamp && size == 8 {
+			return targetInfo[4:12]
+		}
+		targetInfo = targetInfo[4+size:]
+	}
+	return nil
+}
+
+// ntlmv2Key returns the NTOWFv2 key of the user, see MS-NLMP section 3.3.2.
+func ntlmv2Key(domain, username, password string) []byte {
+	hash := md4(utf16le(password))
+	return ntlmHMAC(hash, utf16le(strings.ToUpper(username)+domain))
+}
+
+// ntlmv2Response returns the NTLMv2 response: the proof of the key followed by the client blob it covers.
+func ntlmv2Response(key, challenge, clientChallenge, timestamp, targetInfo []byte) []byte {
+	blob := []byte{1, 1, 0, 0, 0, 0, 0, 0}
+	blob = append(blob, timestamp...)
+	blob = append(blob, clientChallenge...)
+	blob = append(blob, 0, 0, 0, 0)
+	blob = append(blob, targetInfo...)
+	blob = append(blob, 0, 0, 0, 0)
+	return append(ntlmHMAC(key, challenge, blob), blob...)
+}
+
+// ntlmAuthenticate builds the authenticate message without a workstation and session key.
+func ntlmAuthenticate(flags uint32, lm, nt, domain, username []byte) []byte {
+	const header = 64
+	msg := make([]byte, header, header+len(lm)+len(nt)+len(domain)+len(username))
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+
+	// Each field is a length, an allocated length, and an offset into the payload
+	field := func(at int, value []byte) {
+		binary.LittleEndian.PutUint16(msg[at:], uint16(len(value)))
+		binary.LittleEndian.PutUint16(msg[at+2:], uint16(len(value)))
+		binary.LittleEndian.PutUint32(msg[at+4:], uint32(len(msg)))
+		msg = append(msg, value...)
+	}
+	field(12, lm)
+	field(20, nt)
+	field(28, domain)
+	field(36, username)
+	field(44, nil)
+	field(52, nil)
+	binary.LittleEndian.PutUint32(msg[60:], flags)
+	return msg
+}
+
+// ntlmHMAC returns the HMAC-MD5 of the concatenated data.
+func ntlmHMAC(key []byte, data ...[]byte) []byte {
+	h := hmac.New(md5.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// ntlmFiletime returns t as a Windows FILETIME, the number of 100ns intervals since 1601.
+func ntlmFiletime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100) + 116444736000000000
+}
+
+// utf16le encodes s as UTF-16 in little-endian byte order.
+func utf16le(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+// md4 returns the MD4 digest of data (RFC 1320), which NTLM uses to hash the password.
+func md4(data []byte) []byte {
+	// Pad to a multiple of 64 bytes, ending with the length in bits
+	msg := append(append([]byte(nil), data...), 0x80)
+	for len(msg)%64 != 56 {
+		msg = append(msg, 0)
+	}
+	msg = binary.LittleEndian.AppendUint64(msg, uint64(len(data))*8)
+
+	s := [4]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476}
+	rounds := [3]struct {
+		f     func(x, y, z uint32) uint32
+		k     uint32
+		order [16]int
+		shift [4]int
+	}{
+		{func(x, y, z uint32) uint32 { return x&y | ^x&z }, 0, [16]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, [4]int{3, 7, 11, 19}},
+		{func(x, y, z uint32) uint32 { return x&y | x&z | y&z }, 0x5a827999, [16]int{0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15}, [4]int{3, 5, 9, 13}},
+		{func(x, y, z uint32) uint32 { return x ^ y ^ z }, 0x6ed9eba1, [16]int{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15}, [4]int{3, 9, 11, 15}},
+	}
+
+	var x [16]uint32
+	for block := msg; len(block) > 0; block = block[64:] {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(block[4*i:])
+		}
+		a, b, c, d := s[0], s[1], s[2], s[3]
+		for _, r := range rounds {
+			for i, k := range r.order {
+				a, b, c, d = d, bits.RotateLeft32(a+r.f(b, c, d)+x[k]+r.k, r.shift[i%4]), b, c
+			}
+		}
+		s[0], s[1], s[2], s[3] = s[0]+a, s[1]+b, s[2]+c, s[3]+d
+	}
+
+	sum := make([]byte, 0, 16)
+	for _, v := range s {
+		sum = binary.LittleEndian.AppendUint32(sum, v)
+	}
+	return sum
+}
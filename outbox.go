@@ -0,0 +1,286 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// OutboxMessage is an email waiting in an outbox.
+type OutboxMessage struct {
+	ID    string
+	Email Email
+	// Attempts counts the claims of the message, including the current one.
+	Attempts int
+	// LastError is the error of the previous failed attempt, if any.
+	LastError string
+	CreatedAt time.Time
+}
+
+// OutboxStore defines the methods that any transactional outbox must implement. Emails are saved next to the
+// business data they belong to, ideally in the same database transaction, and delivered later by an
+// OutboxDispatcher, so an email is sent if and only if the transaction commits.
+type OutboxStore interface {
+	// Save stores the email as pending and returns its ID.
+	Save(ctx context.Context, email Email) (string, error)
+	// Claim returns up to limit pending messages that are due and reserves them for lease, so that other
+	// dispatchers skip them. A message whose lease expires without being marked, e.g. because the dispatcher
+	// crashed, is claimed again.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]OutboxMessage, error)
+	// MarkSent records that the message was delivered; it is never claimed again.
+	MarkSent(ctx context.Context, id string) error
+	// MarkFailed records the failed attempt. The message is claimed again at retryAt, or never when retryAt
+	// is zero.
+	MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error
+}
+
+// OutboxDispatcher delivers the messages of an OutboxStore through a sender. Delivery is at least once:
+// a message is marked sent after the sender returns, so a crash in between sends it again once its lease
+// expires. Several dispatchers may share a store.
+type OutboxDispatcher struct {
+	sender   Interface
+	store    OutboxStore
+	interval time.Duration
+	batch    int
+	lease    time.Duration
+	retry    RetryPolicy
+
+	// OnError is called when a message fails to send or the store fails. msg is the zero value for store errors.
+	OnError func(msg OutboxMessage, err error)
+
+	mu     sync.Mutex
+	stop   chan struct{}
+	done   chan struct{}
+	cancel context.CancelFunc
+	closed bool
+	// released are the IDs of messages given back to the store unsent since Shutdown was called
+	released []string
+}
+
+// errOutboxReleased is recorded for messages that were claimed but given back unsent.
+var errOutboxReleased = errors.New("outbox error, released unsent by a stopping dispatcher")
+
+// NewOutboxDispatcher initializes and returns a dispatcher that delivers the messages of store through sender.
+// By default it claims up to 50 messages every second for five minutes each, and retries temporary failures
+// up to five times, one minute after the first failure and doubling up to an hour.
+func NewOutboxDispatcher(sender Interface, store OutboxStore) *OutboxDispatcher {
+	return &OutboxDispatcher{
+		sender:   sender,
+		store:    store,
+		interval: time.Second,
+		batch:    50,
+		lease:    5 * time.Minute,
+		retry:    RetryPolicy{MaxAttempts: 5, Backoff: time.Minute, MaxBackoff: time.Hour},
+	}
+}
+
+// SetInterval changes how often the dispatcher polls the store.
+func (d *OutboxDispatcher) SetInterval(interval time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.interval = interval
+}
+
+// SetBatch changes how many messages are claimed at once and how long they are reserved. The lease must
+// exceed the time the sender needs for the whole batch, or other dispatchers send the same messages.
+func (d *OutboxDispatcher) SetBatch(size int, lease time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.batch, d.lease = size, lease
+}
+
+// SetRetryPolicy changes how often and when messages that failed temporarily are claimed again.
+// Permanent failures are never retried.
+func (d *OutboxDispatcher) SetRetryPolicy(policy RetryPolicy) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.retry = policy
+}
+
+// Start begins polling the store in the background.
+func (d *OutboxDispatcher) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stop != nil {
+		return
+	}
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+
+	go d.run(ctx, d.interval, d.stop, d.done)
+}
+
+// Stop halts the background loop and waits for an in-progress dispatch to finish.
+func (d *OutboxDispatcher) Stop() {
+	d.mu.Lock()
+	stop, done, cancel := d.stop, d.done, d.cancel
+	d.stop, d.done, d.cancel = nil, nil, nil
+	d.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+	cancel()
+}
+
+// Shutdown stops the background loop and waits for the message being sent until ctx is done. The rest of the
+// claimed batch is not sent but given back to the store, due at once for another dispatcher; once ctx is done,
+// the message being sent is canceled and given back too. Their IDs are reported in a *ShutdownError.
+func (d *OutboxDispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	stop, done, cancel := d.stop, d.done, d.cancel
+	d.stop, d.done, d.cancel = nil, nil, nil
+	d.mu.Unlock()
+
+	report := &ShutdownError{}
+	if stop != nil {
+		close(stop)
+		select {
+		case <-done:
+		case <-ctx.Done():
+			report.Err = ctx.Err()
+			cancel()
+			<-done
+		}
+		cancel()
+	}
+
+	d.mu.Lock()
+	report.Outbox = d.released
+	d.mu.Unlock()
+	if report.empty() {
+		return nil
+	}
+	return report
+}
+
+// Dispatch claims one batch of due messages and sends them. It returns the number of messages claimed and
+// the errors of the store; send failures are recorded in the store and reported to OnError.
+func (d *OutboxDispatcher) Dispatch(ctx context.Context) (int, error) {
+	d.mu.Lock()
+	batch, lease, retry := d.batch, d.lease, d.retry
+	d.mu.Unlock()
+
+	msgs, err := d.store.Claim(ctx, batch, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	// The outcome of a send is recorded even when ctx ends right after it
+	record := context.WithoutCancel(ctx)
+
+	var errs []error
+	for i, msg := range msgs {
+		if d.isClosed() {
+			errs = append(errs, d.release(ctx, msgs[i:])...)
+			break
+		}
+
+		err = d.send(ctx, msg.Email)
+		if err != nil && ctx.Err() != nil {
+			// Interrupted, e.g. by Shutdown, so the message goes back to the store as it was
+			errs = append(errs, d.release(ctx, msgs[i:])...)
+			break
+		}
+		if err == nil {
+			errs = append(errs, d.store.MarkSent(record, msg.ID))
+			continue
+		}
+		if d.OnError != nil {
+			d.OnError(msg, err)
+		}
+
+		var retryAt time.Time
+		if IsTemporary(err) && msg.Attempts < retry.attempts(0) {
+			retryAt = time.Now().Add(retry.delay(msg.Attempts))
+		}
+		errs = append(errs, d.store.MarkFailed(record, msg.ID, err, retryAt))
+	}
+	return len(msgs), errors.Join(errs...)
+}
+
+// release gives the claimed messages back to the store unsent, due at once.
+func (d *OutboxDispatcher) release(ctx context.Context, msgs []OutboxMessage) []error {
+	ctx = context.WithoutCancel(ctx)
+
+	var errs []error
+	for _, msg := range msgs {
+		if err := d.store.MarkFailed(ctx, msg.ID, errOutboxReleased, time.Now()); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		d.mu.Lock()
+		if d.closed {
+			d.released = append(d.released, msg.ID)
+		}
+		d.mu.Unlock()
+	}
+	return errs
+}
+
+func (d *OutboxDispatcher) isClosed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.closed
+}
+
+// send sends the email with ctx when the sender supports it.
+func (d *OutboxDispatcher) send(ctx context.Context, email Email) error {
+	if sender, ok := d.sender.(interface {
+		SendMailContext(ctx context.Context, email Email) error
+	}); ok {
+		return sender.SendMailContext(ctx, email)
+	}
+	return d.sender.SendMail(email)
+}
+
+// dispatchFull dispatches one batch and reports whether it was full and the loop is not stopping.
+func (d *OutboxDispatcher) dispatchFull(ctx context.Context, stop chan struct{}) bool {
+	n, err := d.Dispatch(ctx)
+	if err != nil {
+		if d.OnError != nil {
+			d.OnError(OutboxMessage{}, err)
+		}
+		return false
+	}
+
+	d.mu.Lock()
+	full := n > 0 && n >= d.batch
+	d.mu.Unlock()
+
+	select {
+	case <-stop:
+		return false
+	default:
+		return full
+	}
+}
+
+func (d *OutboxDispatcher) run(ctx context.Context, interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// Keep going while full batches are waiting instead of one batch per tick
+			for d.dispatchFull(ctx, stop) {
+			}
+		}
+	}
+}
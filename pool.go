@@ -0,0 +1,289 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// PoolConfig controls how authenticated sessions are kept open between sends.
+type PoolConfig struct {
+	// MaxIdle is the maximum number of idle sessions kept open. Zero disables pooling.
+	MaxIdle int
+	// IdleTimeout closes sessions that have been idle for longer than this duration when greater than zero.
+	IdleTimeout time.Duration
+	// KeepAlive is the interval at which idle sessions are probed with NOOP when greater than zero.
+	KeepAlive time.Duration
+}
+
+// session is an SMTP connection together with its underlying network connection.
+type session struct {
+	client *smtp.Client
+	conn   net.Conn
+	// used is when the session was last returned to the pool, zero for a new session.
+	used time.Time
+	// deadlines enforces the configured command and data timeouts, nil without them.
+	deadlines *deadlineConn
+	// relay is the name of the relay the session is connected to, empty when no relays are configured.
+	relay string
+	// host is the host name of the endpoint the session is connected to.
+	host string
+	// addr is the address of the endpoint the session is connected to.
+	addr string
+	// transcript records the dialogue for the send that uses the session, nil without WithTranscript.
+	transcript *transcriber
+	// startTLS is set when the server offered STARTTLS before the session was encrypted.
+	startTLS bool
+}
+
+// pool holds idle authenticated sessions.
+type pool struct {
+	config PoolConfig
+	// drained is called when an idle session receives a 421 shutdown notice.
+	drained func(sess *session, err error)
+
+	mu   sync.Mutex
+	idle []*session
+	stop chan struct{}
+	done chan struct{}
+	// closed is set by Close; sessions returned or probed afterwards are quit instead of pooled
+	closed bool
+}
+
+// acquire returns an idle session to the relay, or to the configured hosts when relay is nil, when pooled is true,
+// or dials a new one.
+func (c *SMTP) acquire(ctx context.Context, relay *relayState, pooled bool) (*session, error) {
+	name, eps := "", c.endpoints()
+	if relay != nil {
+		name, eps = relay.Name, []endpoint{relay.endpoint}
+	}
+
+	if pooled {
+		// A batch keeps its session for the next email, unless that goes to another client or relay
+		if sess := batchFrom(ctx).take(c, name); sess != nil {
+			deadline, _ := ctx.Deadline()
+			sess.conn.SetDeadline(deadline)
+			sess.transcript.attach(recorderFrom(ctx), "reusing session to "+sess.addr)
+			return sess, nil
+		}
+		if sess := c.pool.get(name); sess != nil {
+			deadline, _ := ctx.Deadline()
+			sess.conn.SetDeadline(deadline)
+			sess.transcript.attach(recorderFrom(ctx), "reusing session to "+sess.addr)
+			return sess, nil
+		}
+	}
+
+	sess, err := c.dial(ctx, eps)
+	if err != nil {
+		return nil, err
+	}
+	sess.relay = name
+	return sess, nil
+}
+
+// staleSessionError reports that a pooled session turned out to be closed by the server before the transaction started.
+type staleSessionError struct {
+	err   error
+	relay string
+	addr  string
+}
+
+func (e *staleSessionError) Error() string {
+	return "client error, pooled session closed by server; " + e.err.Error()
+}
+
+// isStale reports whether err shows that the server closed the connection, e.g. after its idle timeout.
+func isStale(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code == 421
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// release keeps a healthy session for the next email of the batch, returns it to the pool, or closes it.
+func (c *SMTP) release(ctx context.Context, sess *session, reuse bool) {
+	// The reset or quit still belongs to the transcript of the send, the keepalive probes of an idle session do not
+	defer sess.transcript.attach(nil, "")
+
+	b := batchFrom(ctx)
+	if !reuse || (b == nil && c.pool.config.MaxIdle <= 0) {
+		sess.client.Close()
+		return
+	}
+
+	sess.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := sess.client.Reset(); err != nil {
+		sess.client.Close()
+		return
+	}
+	sess.conn.SetDeadline(time.Time{})
+	sess.used = time.Now()
+
+	if b != nil {
+		b.keep(c, sess)
+		return
+	}
+	c.park(sess)
+}
+
+// park returns a reset session to the pool, or quits it when pooling is disabled or the pool is full.
+func (c *SMTP) park(sess *session) {
+	if c.pool.config.MaxIdle <= 0 || !c.pool.put(sess) {
+		sess.client.Quit()
+	}
+}
+
+// Ping verifies connectivity and credentials by dialing, authenticating, and quitting without sending mail.
+func (c *SMTP) Ping(ctx context.Context) error {
+	sess, err := c.dial(ctx, c.endpoints())
+	if err != nil {
+		return err
+	}
+	return sess.client.Quit()
+}
+
+// Close stops the keepalive loop, waits for a keepalive pass in progress, and closes every idle pooled session.
+// Sessions are no longer pooled afterwards.
+func (c *SMTP) Close() error {
+	c.pool.mu.Lock()
+	c.pool.closed = true
+	stop, done := c.pool.stop, c.pool.done
+	c.pool.stop, c.pool.done = nil, nil
+	c.pool.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	c.pool.mu.Lock()
+	idle := c.pool.idle
+	c.pool.idle = nil
+	c.pool.mu.Unlock()
+
+	for _, sess := range idle {
+		sess.client.Quit()
+	}
+	return nil
+}
+
+// get removes and returns the most recently used idle session to the named relay, if any.
+func (p *pool) get(relay string) *session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := len(p.idle) - 1; i >= 0; i-- {
+		sess := p.idle[i]
+		if sess.relay != relay {
+			continue
+		}
+		p.idle = append(p.idle[:i], p.idle[i+1:]...)
+
+		if p.config.IdleTimeout > 0 && time.Since(sess.used) > p.config.IdleTimeout {
+			sess.client.Close()
+			continue
+		}
+		return sess
+	}
+	return nil
+}
+
+// discard closes the idle sessions to the named relay and address.
+func (p *pool) discard(relay, addr string) {
+	p.mu.Lock()
+	var kept, closed []*session
+	for _, sess := range p.idle {
+		if sess.relay == relay && sess.addr == addr {
+			closed = append(closed, sess)
+			continue
+		}
+		kept = append(kept, sess)
+	}
+	p.idle = kept
+	p.mu.Unlock()
+
+	for _, sess := range closed {
+		sess.client.Close()
+	}
+}
+
+// put adds a session to the pool; it reports false when the pool is full or closed.
+func (p *pool) put(sess *session) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed || len(p.idle) >= p.config.MaxIdle {
+		return false
+	}
+	p.idle = append(p.idle, sess)
+
+	if p.config.KeepAlive > 0 && p.stop == nil {
+		p.stop = make(chan struct{})
+		p.done = make(chan struct{})
+		go p.keepAlive(p.config.KeepAlive, p.stop, p.done)
+	}
+	return true
+}
+
+// keepAlive probes idle sessions with NOOP and drops the ones that are dead or expired.
+func (p *pool) keepAlive(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		idle := p.idle
+		p.idle = nil
+		p.mu.Unlock()
+
+		var alive []*session
+		for _, sess := range idle {
+			if p.config.IdleTimeout > 0 && time.Since(sess.used) > p.config.IdleTimeout {
+				sess.client.Quit()
+				continue
+			}
+
+			sess.conn.SetDeadline(time.Now().Add(10 * time.Second))
+			if err := sess.client.Noop(); err != nil {
+				sess.client.Close()
+				if isDrain(err) && p.drained != nil {
+					p.drained(sess, err)
+				}
+				continue
+			}
+			sess.conn.SetDeadline(time.Time{})
+			alive = append(alive, sess)
+		}
+
+		p.mu.Lock()
+		closed := p.closed
+		if !closed {
+			p.idle = append(alive, p.idle...)
+		}
+		p.mu.Unlock()
+
+		// Close ran during the pass and no longer sees the probed sessions
+		if closed {
+			for _, sess := range alive {
+				sess.client.Quit()
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package smtp
+
+import (
+	"bufio"
+	"net"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowNoopServer answers an SMTP client on conn, holding each NOOP reply until release is closed. It reports
+// the start of a NOOP on probing and a QUIT on quit.
+func slowNoopServer(conn net.Conn, probing chan<- struct{}, release <-chan struct{}, quit chan<- struct{}) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	conn.Write([]byte("220 mx.example.com ready\r\n"))
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(cmd, "EHLO"):
+			conn.Write([]byte("250 mx.example.com\r\n"))
+		case cmd == "NOOP":
+			probing <- struct{}{}
+			<-release
+			conn.Write([]byte("250 2.0.0 Ok\r\n"))
+		case cmd == "QUIT":
+			conn.Write([]byte("221 2.0.0 Bye\r\n"))
+			close(quit)
+			return
+		default:
+			conn.Write([]byte("250 2.0.0 Ok\r\n"))
+		}
+	}
+}
+
+func TestCloseDuringKeepAlive(t *testing.T) {
+	c, err := New("app@example.com", "", "localhost", 25, WithPool(PoolConfig{MaxIdle: 1, KeepAlive: 5 * time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientConn, serverConn := net.Pipe()
+	probing, release, quit := make(chan struct{}, 1), make(chan struct{}), make(chan struct{})
+	go slowNoopServer(serverConn, probing, release, quit)
+
+	client, err := smtp.NewClient(clientConn, "mx.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.pool.put(&session{client: client, conn: clientConn, used: time.Now()}) {
+		t.Fatal("pool refused the session")
+	}
+
+	// Close while the keepalive pass is waiting for the NOOP reply of the session it took out of the pool
+	select {
+	case <-probing:
+	case <-time.After(5 * time.Second):
+		t.Fatal("keepalive never probed the session")
+	}
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the keepalive pass ended")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return after the keepalive pass")
+	}
+	select {
+	case <-quit:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the probed session was not quit")
+	}
+
+	c.pool.mu.Lock()
+	idle, running := len(c.pool.idle), c.pool.stop != nil
+	c.pool.mu.Unlock()
+	if idle != 0 || running {
+		t.Fatalf("pool holds %d sessions after Close, keepalive running: %t", idle, running)
+	}
+	if c.pool.put(&session{}) {
+		t.Fatal("pool took a session after Close")
+	}
+}
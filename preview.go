@@ -0,0 +1,114 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// MessagePreview is an assembled message broken down for review, e.g. by the people who approve a campaign.
+type MessagePreview struct {
+	// Header holds the header fields in the order they are sent.
+	Header []HeaderField
+	// Subject is the decoded subject.
+	Subject string
+	// Text and HTML are the decoded plain text and HTML bodies with LF line endings, empty when the message has
+	// no such body. HTML is the body as sent, after CSS inlining, link tracking, and direction markup.
+	Text string
+	HTML string
+	// Attachments lists the attachments and inline images without their content.
+	Attachments []PreviewAttachment
+	// Raw is the message as a send would deliver it, see Assemble.
+	Raw []byte
+}
+
+// PreviewAttachment describes an attachment of a previewed message.
+type PreviewAttachment struct {
+	Filename    string
+	ContentType string
+	// Size is the decoded size of the content in bytes.
+	Size      int64
+	Inline    bool
+	ContentID string
+}
+
+// Preview renders the named template with the parameters and assembles the message a send would deliver, without
+// sending it. The message goes through the same steps as a send: the parameters are checked against the schema
+// of the template, client defaults and tracking are applied, and the MIME structure is built. Like Assemble, it
+// stops short of DKIM signing and the downgrades that depend on the server.
+func (c *SMTP) Preview(name string, parameters map[string]interface{}) (MessagePreview, error) {
+	return c.PreviewEmail(context.Background(), Email{}, name, parameters)
+}
+
+// PreviewEmail previews the named template rendered into email like Preview, e.g. with the recipients, Locale,
+// HTML body, and attachments of a campaign. The email is not validated, so a preview needs no recipients.
+func (c *SMTP) PreviewEmail(ctx context.Context, email Email, name string, parameters map[string]interface{}) (MessagePreview, error) {
+	if err := c.RenderTemplate(&email, name, parameters); err != nil {
+		return MessagePreview{}, err
+	}
+	email, err := c.resolve(ctx, email)
+	if err != nil {
+		return MessagePreview{}, err
+	}
+	msg := c.buildMessage(email)
+
+	var b bytes.Buffer
+	if _, err = msg.WriteTo(&b); err != nil {
+		return MessagePreview{}, fmt.Errorf("preview error, failed to write message; %w", err)
+	}
+
+	root, err := parseEntity(b.Bytes())
+	if err != nil {
+		return MessagePreview{}, fmt.Errorf("preview error, failed to parse message; %w", err)
+	}
+	if len(root.parts) == 0 {
+		// Drop the line break that ends the message, as the multipart reader drops the one before each boundary
+		root.content = bytes.TrimSuffix(root.content, crlf)
+	}
+
+	preview := MessagePreview{Header: append([]HeaderField(nil), msg.Header...), Raw: b.Bytes()}
+	preview.Subject = msg.Get("Subject")
+	if subject, err := new(mime.WordDecoder).DecodeHeader(preview.Subject); err == nil {
+		preview.Subject = subject
+	}
+	preview.add(root)
+	return preview, nil
+}
+
+// add fills in the bodies and attachments from the entity and its parts. The first text and HTML bodies win,
+// as in a mail client.
+func (p *MessagePreview) add(e *entity) {
+	if len(e.parts) > 0 {
+		for _, part := range e.parts {
+			p.add(part)
+		}
+		return
+	}
+
+	disposition, params, _ := mime.ParseMediaType(e.header.Get("Content-Disposition"))
+	contentID := strings.Trim(e.header.Get("Content-ID"), "<>")
+	if disposition == "attachment" || disposition == "inline" && (params["filename"] != "" || contentID != "") {
+		filename := params["filename"]
+		if filename == "" {
+			filename = e.params["name"]
+		}
+		p.Attachments = append(p.Attachments, PreviewAttachment{
+			Filename:    filename,
+			ContentType: e.mediaType,
+			Size:        int64(len(e.content)),
+			Inline:      disposition == "inline",
+			ContentID:   contentID,
+		})
+		return
+	}
+
+	text := strings.ReplaceAll(string(e.content), "\r\n", "\n")
+	switch {
+	case e.mediaType == "text/plain" && p.Text == "":
+		p.Text = text
+	case e.mediaType == "text/html" && p.HTML == "":
+		p.HTML = text
+	}
+}
@@ -0,0 +1,45 @@
+package smtp
+
+import (
+	"net/smtp"
+	"strconv"
+)
+
+// Priority marks how urgent an email is, see Email.Priority.
+type Priority int
+
+// Priorities. The zero value adds nothing to the message.
+const (
+	PriorityNormal Priority = iota
+	PriorityHigh
+	PriorityLow
+)
+
+// headers returns the X-Priority and Importance values that mail clients use to flag the message.
+func (p Priority) headers() (xPriority, importance string) {
+	switch p {
+	case PriorityHigh:
+		return "1 (Highest)", "high"
+	case PriorityLow:
+		return "5 (Lowest)", "low"
+	}
+	return "", ""
+}
+
+// mailParams returns the MT-PRIORITY parameter of the MAIL FROM command (RFC 6710) when the server supports it.
+// The levels follow the MIXER policy, where 4 is urgent and -4 non-urgent.
+func (p Priority) mailParams(client *smtp.Client) []string {
+	level := 0
+	switch p {
+	case PriorityHigh:
+		level = 4
+	case PriorityLow:
+		level = -4
+	default:
+		return nil
+	}
+	if ok, _ := client.Extension("MT-PRIORITY"); !ok {
+		return nil
+	}
+	return []string{"MT-PRIORITY=" + strconv.Itoa(level)}
+}
@@ -0,0 +1,156 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// probeHeader carries the token that identifies a probe message in the seed mailbox.
+const probeHeader = "X-Delivery-Probe"
+
+// errNotProbe keeps messages other than the probe in the seed mailbox.
+var errNotProbe = errors.New("probe error, not the probe message")
+
+// ProbeResult describes a probe message as it arrived in the seed mailbox.
+type ProbeResult struct {
+	Token   string
+	Sent    time.Time
+	Arrived time.Time
+	// Latency is the time between sending and finding the message; it is bounded below by the poll interval.
+	Latency time.Duration
+	// SPF, DKIM, and DMARC hold the results reported in the Authentication-Results header, e.g. "pass".
+	SPF   string
+	DKIM  string
+	DMARC string
+	// Header is the header of the received message.
+	Header mail.Header
+}
+
+// Authenticated reports whether SPF and DKIM both passed.
+func (r ProbeResult) Authenticated() bool {
+	return r.SPF == "pass" && r.DKIM == "pass"
+}
+
+// ContextSender sends an email, giving up when ctx is done. *SMTP implements it.
+type ContextSender interface {
+	SendMailContext(ctx context.Context, email Email) error
+}
+
+// Probe sends a canary message to a seed mailbox and waits for it to arrive, measuring
+// real deliverability rather than relay acceptance.
+type Probe struct {
+	sender  ContextSender
+	mailbox Mailbox
+	seed    string
+
+	// Timeout bounds how long Run waits for the message; it defaults to five minutes.
+	Timeout time.Duration
+	// PollInterval is the delay between mailbox checks; it defaults to ten seconds.
+	PollInterval time.Duration
+}
+
+// NewProbe initializes and returns a probe that sends through sender to the seed address and checks mailbox for it.
+func NewProbe(sender ContextSender, mailbox Mailbox, seed string) *Probe {
+	return &Probe{
+		sender:       sender,
+		mailbox:      mailbox,
+		seed:         seed,
+		Timeout:      5 * time.Minute,
+		PollInterval: 10 * time.Second,
+	}
+}
+
+// Run sends one probe and polls the seed mailbox until it arrives, the timeout passes, or ctx is done.
+// The probe message is deleted from the mailbox once found; other messages are left alone.
+func (p *Probe) Run(ctx context.Context) (ProbeResult, error) {
+	token, err := newID()
+	if err != nil {
+		return ProbeResult{}, fmt.Errorf("probe error, failed to generate token; %w", err)
+	}
+
+	result := ProbeResult{Token: token, Sent: time.Now()}
+	err = p.sender.SendMailContext(ctx, Email{
+		To:      []string{p.seed},
+		Subject: "Delivery probe " + token,
+		Body:    "This message verifies mail delivery and can be deleted.\r\n",
+		Headers: map[string]string{probeHeader: token},
+	})
+	if err != nil {
+		return result, fmt.Errorf("probe error, failed to send probe; %w", err)
+	}
+
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	interval := p.PollInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var header mail.Header
+		err = p.mailbox.Poll(ctx, func(data []byte) error {
+			msg, err := mail.ReadMessage(bytes.NewReader(data))
+			if err != nil || msg.Header.Get(probeHeader) != token {
+				return errNotProbe
+			}
+			header = msg.Header
+			return nil
+		})
+		if header != nil {
+			result.Arrived = time.Now()
+			result.Latency = result.Arrived.Sub(result.Sent)
+			result.Header = header
+			result.SPF, result.DKIM, result.DMARC = authenticationResults(header["Authentication-Results"])
+			return result, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return result, fmt.Errorf("probe error, message did not arrive within %s; %w", timeout, err)
+			}
+			return result, fmt.Errorf("probe error, message did not arrive within %s", timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// authResult matches a method=result pair of an Authentication-Results header.
+var authResult = regexp.MustCompile(`(?i)\b(spf|dkim|dmarc)\s*=\s*([a-z]+)`)
+
+// authenticationResults returns the first SPF, DKIM, and DMARC results found in the Authentication-Results headers (RFC 8601).
+func authenticationResults(values []string) (spf, dkim, dmarc string) {
+	for _, value := range values {
+		for _, m := range authResult.FindAllStringSubmatch(value, -1) {
+			result := strings.ToLower(m[2])
+			switch strings.ToLower(m[1]) {
+			case "spf":
+				if spf == "" {
+					spf = result
+				}
+			case "dkim":
+				if dkim == "" {
+					dkim = result
+				}
+			case "dmarc":
+				if dmarc == "" {
+					dmarc = result
+				}
+			}
+		}
+	}
+	return spf, dkim, dmarc
+}
@@ -0,0 +1,108 @@
+package smtp
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Profile groups send settings for a class of messages.
+type Profile struct {
+	Name string
+	// MaxAttempts overrides the client's retry policy attempt ceiling when greater than zero.
+	MaxAttempts int
+	// Deadline bounds the total time spent on all attempts when greater than zero.
+	Deadline time.Duration
+	// Priority orders due emails in the Scheduler; higher values are sent first.
+	Priority int
+	// SkipArchiveBody records only metadata, never the message content, in the archive.
+	SkipArchiveBody bool
+	// Headers are added to the message unless the email already sets them.
+	Headers map[string]string
+	// ListUnsubscribe is a mailto: or https: URI emitted as the List-Unsubscribe header.
+	ListUnsubscribe string
+	// DomainInterval is the minimum time between two sends to the same recipient domain.
+	DomainInterval time.Duration
+	// SkipSuppression sends to recipients reported by the client's SuppressionList instead of skipping them,
+	// e.g. for one-time passwords the user asked for.
+	SkipSuppression bool
+	// DeliverBy is used for emails that do not set Email.DeliverBy.
+	DeliverBy time.Duration
+	// Digest is how long a Digester collects the emails with this profile to the same recipients before it
+	// sends them as one message. Zero sends every email on its own.
+	Digest time.Duration
+}
+
+// ProfileOTP is a fast path for one-time-password emails: two attempts within ten seconds,
+// highest priority, and no archived body.
+var ProfileOTP = Profile{
+	Name:            "otp",
+	MaxAttempts:     2,
+	Deadline:        10 * time.Second,
+	Priority:        100,
+	SkipArchiveBody: true,
+	SkipSuppression: true,
+}
+
+// ProfileBulk is the base profile for newsletters and other marketing sends. Emails sent through a Digester
+// are combined into one message per recipient every hour.
+// Use NewBulkProfile to set the unsubscribe address.
+var ProfileBulk = Profile{
+	Name:     "bulk",
+	Priority: -10,
+	Headers: map[string]string{
+		"Precedence":     "bulk",
+		"Auto-Submitted": "auto-generated",
+	},
+	DomainInterval: time.Second,
+	Digest:         time.Hour,
+}
+
+// NewBulkProfile returns a copy of ProfileBulk with the given List-Unsubscribe URI.
+func NewBulkProfile(listUnsubscribe string) Profile {
+	profile := ProfileBulk
+	profile.Headers = cloneHeaders(ProfileBulk.Headers)
+	profile.ListUnsubscribe = listUnsubscribe
+	return profile
+}
+
+// cloneHeaders returns a copy of headers, so that changing a profile never changes the one it was copied from.
+func cloneHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	clone := make(map[string]string, len(headers))
+	for name, value := range headers {
+		clone[name] = value
+	}
+	return clone
+}
+
+// apply adds the profile headers that the message does not set yet.
+func (p *Profile) apply(msg *Message) {
+	names := make([]string, 0, len(p.Headers))
+	for name := range p.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if msg.Get(name) == "" {
+			msg.Set(name, p.Headers[name])
+		}
+	}
+
+	if p.ListUnsubscribe != "" && msg.Get("List-Unsubscribe") == "" {
+		msg.Set("List-Unsubscribe", "<"+p.ListUnsubscribe+">")
+		if strings.HasPrefix(p.ListUnsubscribe, "https:") {
+			msg.Set("List-Unsubscribe-Post", oneClickUnsubscribe)
+		}
+	}
+}
+
+// priority returns the scheduling priority of the email's profile.
+func (e Email) priority() int {
+	if e.Profile == nil {
+		return 0
+	}
+	return e.Profile.Priority
+}
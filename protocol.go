@@ -0,0 +1,344 @@
+package smtp
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// DSN holds delivery status notification parameters (RFC 3461).
+// They are only sent when the server advertises the DSN extension.
+type DSN struct {
+	// Notify lists the default NOTIFY conditions for every recipient: NEVER on its own, or any of SUCCESS,
+	// FAILURE, and DELAY.
+	Notify []string
+	// RecipientNotify overrides Notify for individual recipients.
+	RecipientNotify map[string][]string
+	// Return requests the FULL message or only the HDRS in the notification.
+	Return string
+	// EnvelopeID is returned in notifications to correlate them with the original message.
+	EnvelopeID string
+}
+
+// command sends a raw command and reads the reply, expecting the given status code.
+func command(client *smtp.Client, expectCode int, format string, args ...interface{}) (int, string, error) {
+	id, err := client.Text.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+
+	client.Text.StartResponse(id)
+	defer client.Text.EndResponse(id)
+
+	return client.Text.ReadResponse(expectCode)
+}
+
+// validateLine checks that a command argument does not contain line breaks.
+func validateLine(line string) error {
+	if strings.ContainsAny(line, "\r\n") {
+		return fmt.Errorf("smtp: a line must not contain CR or LF")
+	}
+	return nil
+}
+
+// mailFrom issues the MAIL FROM command with the given extension parameters.
+func mailFrom(client *smtp.Client, from string, params []string) error {
+	if err := validateLine(from); err != nil {
+		return err
+	}
+
+	_, _, err := command(client, 250, "%s", commandLine("MAIL FROM:<"+from+">", params))
+	return err
+}
+
+// rcptTo issues the RCPT TO command with the given extension parameters.
+func rcptTo(client *smtp.Client, to string, params []string) error {
+	if err := validateLine(to); err != nil {
+		return err
+	}
+
+	_, _, err := command(client, 25, "%s", commandLine("RCPT TO:<"+to+">", params))
+	return err
+}
+
+// sendEnvelope issues MAIL FROM and one RCPT TO per recipient, logging the reply to every recipient.
+// When the server advertises PIPELINING, all commands are written at once and the replies read afterwards.
+func sendEnvelope(client *smtp.Client, from string, params []string, recipients []string, rcptParams [][]string, log *slog.Logger) error {
+	if ok, _ := client.Extension("PIPELINING"); !ok {
+		if err := mailFrom(client, from, params); err != nil {
+			return fmt.Errorf("client error, failed to create mail; %w", err)
+		}
+
+		// Send mail to recipients
+		for i, addr := range recipients {
+			err := rcptTo(client, addr, rcptParams[i])
+			logRecipient(log, addr, err)
+			if err != nil {
+				return fmt.Errorf("send error, failed to add recipients; %w", err)
+			}
+		}
+		return nil
+	}
+
+	if err := validateLine(from); err != nil {
+		return err
+	}
+	for _, addr := range recipients {
+		if err := validateLine(addr); err != nil {
+			return err
+		}
+	}
+
+	ids := make([]uint, 0, len(recipients)+1)
+	id, err := client.Text.Cmd("%s", commandLine("MAIL FROM:<"+from+">", params))
+	if err != nil {
+		return fmt.Errorf("client error, failed to create mail; %w", err)
+	}
+	ids = append(ids, id)
+
+	for i, addr := range recipients {
+		id, err = client.Text.Cmd("%s", commandLine("RCPT TO:<"+addr+">", rcptParams[i]))
+		if err != nil {
+			return fmt.Errorf("send error, failed to add recipients; %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Every reply must be consumed to keep the session in sync, even after a failure
+	var firstErr error
+	for i, id := range ids {
+		client.Text.StartResponse(id)
+		_, _, err = client.Text.ReadResponse(25)
+		client.Text.EndResponse(id)
+
+		if i > 0 {
+			logRecipient(log, recipients[i-1], err)
+		}
+		if err == nil || firstErr != nil {
+			continue
+		}
+		if i == 0 {
+			firstErr = fmt.Errorf("client error, failed to create mail; %w", err)
+		} else {
+			firstErr = fmt.Errorf("send error, failed to add recipients; %w", err)
+		}
+	}
+
+	return firstErr
+}
+
+// logRecipient logs the server's reply to a recipient.
+func logRecipient(log *slog.Logger, addr string, err error) {
+	if err != nil {
+		log.Warn("smtp recipient rejected", "recipient", addr, "error", err)
+		return
+	}
+	log.Debug("smtp recipient accepted", "recipient", addr)
+}
+
+// commandLine appends the extension parameters to a command.
+func commandLine(cmd string, params []string) string {
+	if len(params) == 0 {
+		return cmd
+	}
+	return cmd + " " + strings.Join(params, " ")
+}
+
+// defaultChunkSize is the BDAT chunk size used when none is configured.
+const defaultChunkSize = 1 << 20
+
+// bdat transfers the message with BDAT commands (RFC 3030) in chunks of the given size.
+// Unlike DATA, the content is sent verbatim, so r must already use CRLF line endings. It returns the
+// server's reply to the last chunk, e.g. "250 2.0.0 Ok: queued as 4F3A".
+func bdat(client *smtp.Client, r io.Reader, chunkSize int) (string, error) {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	// One chunk is read ahead so the last one can be marked
+	next := make([]byte, chunkSize)
+	n, err := io.ReadFull(r, next)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	next = next[:n]
+
+	for {
+		chunk := next
+		last := len(chunk) < chunkSize
+		if !last {
+			next = make([]byte, chunkSize)
+			n, err = io.ReadFull(r, next)
+			if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+				return "", err
+			}
+			next = next[:n]
+			last = n == 0
+		}
+
+		cmd := fmt.Sprintf("BDAT %d", len(chunk))
+		if last {
+			cmd += " LAST"
+		}
+
+		id := client.Text.Next()
+		client.Text.StartRequest(id)
+		_, err := client.Text.W.WriteString(cmd + "\r\n")
+		if err == nil {
+			_, err = client.Text.W.Write(chunk)
+		}
+		if err == nil {
+			err = client.Text.W.Flush()
+		}
+		client.Text.EndRequest(id)
+		if err != nil {
+			return "", err
+		}
+
+		client.Text.StartResponse(id)
+		code, msg, err := client.Text.ReadResponse(250)
+		client.Text.EndResponse(id)
+		if err != nil {
+			return "", err
+		}
+
+		if last {
+			return strconv.Itoa(code) + " " + msg, nil
+		}
+	}
+}
+
+// startData issues DATA and returns the writer for the message content, which dot-stuffs and terminates it.
+func startData(client *smtp.Client) (io.WriteCloser, error) {
+	if _, _, err := command(client, 354, "DATA"); err != nil {
+		return nil, err
+	}
+	return client.Text.DotWriter(), nil
+}
+
+// endData terminates the message content and returns the server's reply, e.g. "250 2.0.0 Ok: queued as 4F3A".
+func endData(client *smtp.Client, w io.WriteCloser) (string, error) {
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	code, msg, err := client.Text.ReadResponse(250)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(code) + " " + msg, nil
+}
+
+// normalizeCRLF converts bare CR and LF characters to CRLF line endings.
+func normalizeCRLF(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		switch {
+		case data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n':
+			out = append(out, '\r', '\n')
+			i++
+		case data[i] == '\r' || data[i] == '\n':
+			out = append(out, '\r', '\n')
+		default:
+			out = append(out, data[i])
+		}
+	}
+	return out
+}
+
+// notifyProblem describes what is wrong with a list of NOTIFY conditions, or returns "" when it is valid.
+// NEVER must stand alone.
+func notifyProblem(notify []string) string {
+	seen := map[string]bool{}
+	for _, n := range notify {
+		condition := strings.ToUpper(n)
+		switch condition {
+		case "NEVER", "SUCCESS", "FAILURE", "DELAY":
+		default:
+			return fmt.Sprintf("has unknown condition %q", n)
+		}
+		if seen[condition] {
+			return "lists " + condition + " twice"
+		}
+		seen[condition] = true
+	}
+	if seen["NEVER"] && len(notify) > 1 {
+		return "combines NEVER with other conditions"
+	}
+	return ""
+}
+
+// mailParams returns the DSN parameters of the MAIL FROM command.
+func (d *DSN) mailParams(client *smtp.Client) []string {
+	if d == nil {
+		return nil
+	}
+	if ok, _ := client.Extension("DSN"); !ok {
+		return nil
+	}
+
+	var params []string
+	if d.Return != "" {
+		params = append(params, "RET="+strings.ToUpper(d.Return))
+	}
+	if d.EnvelopeID != "" {
+		params = append(params, "ENVID="+xtext(d.EnvelopeID))
+	}
+	return params
+}
+
+// rcptParams returns the DSN parameters of the RCPT TO command for the recipient.
+func (d *DSN) rcptParams(client *smtp.Client, addr string) []string {
+	if d == nil {
+		return nil
+	}
+	if ok, _ := client.Extension("DSN"); !ok {
+		return nil
+	}
+
+	notify := d.Notify
+	if n, ok := d.RecipientNotify[addr]; ok {
+		notify = n
+	}
+
+	var params []string
+	if len(notify) != 0 {
+		params = append(params, "NOTIFY="+strings.ToUpper(strings.Join(notify, ",")))
+	}
+	if isASCII(addr) {
+		params = append(params, "ORCPT=rfc822;"+xtext(addr))
+	} else {
+		params = append(params, "ORCPT=utf-8;"+utf8AddrXtext(addr))
+	}
+	return params
+}
+
+// utf8AddrXtext encodes an internationalized address as utf-8-addr-xtext (RFC 6533 section 3), which is valid
+// with and without SMTPUTF8.
+func utf8AddrXtext(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < '!' || r > '~' || r == '+' || r == '=' || r == '\\' {
+			fmt.Fprintf(&b, "\\x{%02X}", r)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// xtext encodes s as defined in RFC 3461 section 4.
+func xtext(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch < '!' || ch > '~' || ch == '+' || ch == '=' {
+			fmt.Fprintf(&b, "+%02X", ch)
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
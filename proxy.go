@@ -0,0 +1,238 @@
+package smtp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ProxyDialer returns a dialer that tunnels connections through the proxy at proxyURL.
+// Supported schemes are socks5 and socks5h (RFC 1928, with username and password authentication per RFC 1929)
+// and http or https (HTTP CONNECT, with basic authentication). Credentials are taken from the URL's user info.
+func ProxyDialer(proxyURL *url.URL) (DialFunc, error) {
+	var username, password string
+	if proxyURL.User != nil {
+		username = proxyURL.User.Username()
+		password, _ = proxyURL.User.Password()
+	}
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		addr := hostPortDefault(proxyURL, "1080")
+		return func(ctx context.Context, network, target string) (net.Conn, error) {
+			conn, err := dialProxy(ctx, network, addr, false)
+			if err != nil {
+				return nil, err
+			}
+			return handshake(ctx, conn, func() (net.Conn, error) {
+				return conn, socks5Connect(conn, target, username, password)
+			})
+		}, nil
+	case "http", "https":
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		addr := hostPortDefault(proxyURL, port)
+		return func(ctx context.Context, network, target string) (net.Conn, error) {
+			conn, err := dialProxy(ctx, network, addr, proxyURL.Scheme == "https")
+			if err != nil {
+				return nil, err
+			}
+			return handshake(ctx, conn, func() (net.Conn, error) {
+				return httpConnect(conn, target, username, password)
+			})
+		}, nil
+	}
+	return nil, fmt.Errorf("proxy error, unsupported scheme %q", proxyURL.Scheme)
+}
+
+// hostPortDefault returns the host and port of the proxy, adding the default port when none is given.
+func hostPortDefault(u *url.URL, port string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// dialProxy opens the connection to the proxy itself.
+func dialProxy(ctx context.Context, network, addr string, useTLS bool) (net.Conn, error) {
+	if network != "tcp" {
+		return nil, fmt.Errorf("proxy error, cannot tunnel %s connections", network)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("proxy error, failed to dial %s; %w", addr, err)
+	}
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		conn = tls.Client(conn, &tls.Config{ServerName: host})
+	}
+	return conn, nil
+}
+
+// handshake runs the proxy negotiation within the deadline of ctx and returns the tunnelled connection.
+func handshake(ctx context.Context, conn net.Conn, fn func() (net.Conn, error)) (net.Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	tunnel, err := fn()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return tunnel, nil
+}
+
+// socks5Connect negotiates a CONNECT to target over a SOCKS5 proxy connection.
+func socks5Connect(conn net.Conn, target, username, password string) error {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return fmt.Errorf("proxy error, invalid target %s; %w", target, err)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("proxy error, invalid port in %s", target)
+	}
+
+	methods := []byte{0x00}
+	if username != "" {
+		methods = []byte{0x00, 0x02}
+	}
+	if _, err = conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return fmt.Errorf("proxy error, failed to greet socks5 proxy; %w", err)
+	}
+
+	reply := make([]byte, 2)
+	if _, err = io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("proxy error, failed to read socks5 greeting; %w", err)
+	}
+	if reply[0] != 0x05 {
+		return fmt.Errorf("proxy error, unexpected socks version %d", reply[0])
+	}
+
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if len(username) > 255 || len(password) > 255 {
+			return fmt.Errorf("proxy error, socks5 credentials are too long")
+		}
+		req := []byte{0x01, byte(len(username))}
+		req = append(req, username...)
+		req = append(req, byte(len(password)))
+		req = append(req, password...)
+		if _, err = conn.Write(req); err != nil {
+			return fmt.Errorf("proxy error, failed to authenticate with socks5 proxy; %w", err)
+		}
+		if _, err = io.ReadFull(conn, reply); err != nil {
+			return fmt.Errorf("proxy error, failed to authenticate with socks5 proxy; %w", err)
+		}
+		if reply[1] != 0x00 {
+			return fmt.Errorf("proxy error, socks5 proxy rejected the credentials")
+		}
+	default:
+		return fmt.Errorf("proxy error, socks5 proxy accepts none of the offered authentication methods")
+	}
+
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("proxy error, host name %s is too long", host)
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, 0x01)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, 0x04)
+		req = append(req, ip.To16()...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err = conn.Write(req); err != nil {
+		return fmt.Errorf("proxy error, failed to send socks5 connect; %w", err)
+	}
+
+	head := make([]byte, 4)
+	if _, err = io.ReadFull(conn, head); err != nil {
+		return fmt.Errorf("proxy error, failed to read socks5 reply; %w", err)
+	}
+	if head[1] != 0x00 {
+		return fmt.Errorf("proxy error, socks5 proxy failed to connect to %s with code %d", target, head[1])
+	}
+
+	// Skip the bound address and port
+	var skip int
+	switch head[3] {
+	case 0x01:
+		skip = net.IPv4len + 2
+	case 0x04:
+		skip = net.IPv6len + 2
+	case 0x03:
+		n := make([]byte, 1)
+		if _, err = io.ReadFull(conn, n); err != nil {
+			return fmt.Errorf("proxy error, failed to read socks5 reply; %w", err)
+		}
+		skip = int(n[0]) + 2
+	default:
+		return fmt.Errorf("proxy error, unknown socks5 address type %d", head[3])
+	}
+	if _, err = io.ReadFull(conn, make([]byte, skip)); err != nil {
+		return fmt.Errorf("proxy error, failed to read socks5 reply; %w", err)
+	}
+	return nil
+}
+
+// httpConnect opens a tunnel to target with an HTTP CONNECT request.
+func httpConnect(conn net.Conn, target, username, password string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: http.Header{},
+	}
+	if username != "" {
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("proxy error, failed to send connect request; %w", err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, fmt.Errorf("proxy error, failed to read connect response; %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy error, proxy refused to connect to %s: %s", target, resp.Status)
+	}
+
+	// The server greeting may already sit in the reader's buffer
+	return &bufferedConn{Conn: conn, r: r}, nil
+}
+
+// bufferedConn reads through a buffered reader that may hold data already received on the connection.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,142 @@
+package smtp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SendRaw delivers a message that was assembled elsewhere, e.g. by another MIME library, to the given envelope
+// sender and recipients. The message is sent as is, apart from DKIM signing and SMTPUTF8 or 8BITMIME downgrading
+// when configured or required; no headers are added. Pooling, retries, relays, and logging apply as for SendMail,
+// while middlewares, defaults, and templates do not.
+//
+// When r is an io.ReadSeeker, such as an *os.File, only the header is held in memory and the body is streamed
+// from r, which is read again for DKIM signing and for each attempt. Other readers are read into memory first.
+func (c *SMTP) SendRaw(ctx context.Context, from string, to []string, r io.Reader) error {
+	if len(to) == 0 {
+		return fmt.Errorf("send error, raw message has no recipients")
+	}
+	ctx, end, err := c.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	var msg *Message
+	if rs, ok := r.(io.ReadSeeker); ok {
+		var err error
+		if msg, err = streamRawMessage(rs); err != nil {
+			return err
+		}
+	} else {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("send error, failed to read raw message; %w", err)
+		}
+		if msg, err = parseRawMessage(data); err != nil {
+			return err
+		}
+	}
+
+	email := Email{To: to, Subject: unfold(msg.Get("Subject"))}
+	if c.dryRun {
+		return c.sendDry(email, msg)
+	}
+	return c.transmit(ctx, email, from, msg, 0, false)
+}
+
+// parseRawMessage splits a serialized message into its header fields and body.
+func parseRawMessage(data []byte) (*Message, error) {
+	data = normalizeCRLF(data)
+
+	end := bytes.Index(data, []byte("\r\n\r\n"))
+	if end < 0 {
+		return nil, fmt.Errorf("send error, raw message has no empty line after the header")
+	}
+
+	msg := &Message{Body: string(data[end+4:])}
+	if err := msg.parseRawHeader(strings.Split(string(data[:end]), "\r\n")); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// streamRawMessage reads the header of a serialized message from r and leaves the body in r to be streamed.
+// The body is read once here to measure it.
+func streamRawMessage(r io.ReadSeeker) (*Message, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("send error, failed to read raw message; %w", err)
+	}
+
+	br := bufio.NewReader(r)
+	var lines []string
+	offset := start
+	for {
+		line, err := br.ReadString('\n')
+		offset += int64(len(line))
+		if err == io.EOF {
+			return nil, fmt.Errorf("send error, raw message has no empty line after the header")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("send error, failed to read raw message; %w", err)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	msg := &Message{}
+	if err = msg.parseRawHeader(lines); err != nil {
+		return nil, err
+	}
+
+	body := segment{raw: true, open: func() (io.ReadCloser, error) {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(r), nil
+	}}
+
+	stats := &rawStats{ascii: true}
+	if err = body.encode(stats); err != nil {
+		return nil, err
+	}
+	body.size, body.ascii = stats.size, stats.ascii
+
+	msg.segments = []segment{body}
+	return msg, nil
+}
+
+// parseRawHeader appends the header fields of a serialized message given as lines without line endings.
+// Folded values are kept as they are so existing signatures stay valid.
+func (m *Message) parseRawHeader(lines []string) error {
+	for _, line := range lines {
+		if line != "" && (line[0] == ' ' || line[0] == '\t') {
+			if len(m.Header) == 0 {
+				return fmt.Errorf("send error, raw message starts with a continuation line")
+			}
+			m.Header[len(m.Header)-1].Value += "\r\n" + line
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || !validFieldName(name) {
+			return fmt.Errorf("send error, invalid header field %q in raw message", line)
+		}
+		m.Header = append(m.Header, HeaderField{Name: name, Value: strings.TrimPrefix(value, " ")})
+	}
+	return nil
+}
+
+// unfold joins the lines of a folded header value.
+func unfold(value string) string {
+	return strings.NewReplacer("\r\n ", " ", "\r\n\t", " ").Replace(value)
+}
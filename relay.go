@@ -0,0 +1,206 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"sync"
+)
+
+// Relay is a sending identity: a relay endpoint, optionally bound to a local egress address.
+type Relay struct {
+	// Name identifies the relay to a RelaySelector; it must be set and unique.
+	Name string
+	// Addr is the relay as "host:port" or "unix:///path".
+	Addr string
+	// LocalAddr is the local IP outgoing connections are bound to. It is ignored when a custom dialer is configured.
+	LocalAddr string
+}
+
+// RelayStats holds the recent delivery statistics of a relay. The rates are moving averages between 0 and 1.
+type RelayStats struct {
+	Sent         int64
+	DeferralRate float64
+	BounceRate   float64
+}
+
+// RelayStatus is a relay together with its statistics.
+type RelayStatus struct {
+	Relay
+	Stats RelayStats
+}
+
+// RelaySelector picks the relay for an email by name. An empty name selects the least failing relay; a name that
+// is not configured fails the send.
+type RelaySelector func(email Email, relays []RelayStatus) string
+
+// noRelay is returned by IsolateProfiles when the email may use none of the relays.
+const noRelay = "\x00"
+
+// reputationWeight is the weight of the latest result in the moving rates of a relay.
+const reputationWeight = 0.05
+
+// relayState is a configured relay and its statistics.
+type relayState struct {
+	Relay
+	endpoint endpoint
+
+	mu    sync.Mutex
+	stats RelayStats
+}
+
+// record updates the statistics with the outcome of a delivery attempt.
+// Errors other than SMTP replies, such as network failures, do not reflect the relay's reputation and are ignored.
+func (r *relayState) record(err error) {
+	if r == nil {
+		return
+	}
+
+	var deferred, bounced float64
+	if err != nil {
+		var protoErr *textproto.Error
+		if !errors.As(err, &protoErr) {
+			return
+		}
+		switch {
+		case protoErr.Code >= 400 && protoErr.Code < 500:
+			deferred = 1
+		case protoErr.Code >= 500:
+			bounced = 1
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stats.Sent++
+	r.stats.DeferralRate += reputationWeight * (deferred - r.stats.DeferralRate)
+	r.stats.BounceRate += reputationWeight * (bounced - r.stats.BounceRate)
+}
+
+// status returns the relay with a snapshot of its statistics.
+func (r *relayState) status() RelayStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return RelayStatus{Relay: r.Relay, Stats: r.stats}
+}
+
+// RelayStats returns every configured relay with its recent statistics.
+func (c *SMTP) RelayStats() []RelayStatus {
+	out := make([]RelayStatus, len(c.relays))
+	for i, r := range c.relays {
+		out[i] = r.status()
+	}
+	return out
+}
+
+// validateRelays checks that every relay has a name of its own, so that selectors and the pool can tell them apart.
+func (c *SMTP) validateRelays() error {
+	names := map[string]bool{}
+	for _, r := range c.relays {
+		if r.Name == "" {
+			return fmt.Errorf("relay error, relay %s has no name", r.Addr)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("relay error, relay name %q is used twice", r.Name)
+		}
+		names[r.Name] = true
+	}
+	return nil
+}
+
+// selectRelay returns the relay for the email, or nil when no relays are configured. It fails when the selector
+// picks a relay that is not configured.
+func (c *SMTP) selectRelay(email Email) (*relayState, error) {
+	if len(c.relays) == 0 {
+		return nil, nil
+	}
+
+	// Relays that announced their shutdown are skipped while another one is available
+	var relays []*relayState
+	for _, r := range c.relays {
+		if !c.draining.draining(drainKey(r.Name, "")) && !c.draining.draining(r.endpoint.address()) {
+			relays = append(relays, r)
+		}
+	}
+	if len(relays) == 0 {
+		relays = c.relays
+	}
+
+	statuses := make([]RelayStatus, len(relays))
+	for i, r := range relays {
+		statuses[i] = r.status()
+	}
+	name := ""
+	if c.relaySelector != nil {
+		name = c.relaySelector(email, statuses)
+	}
+	if name == "" {
+		name = leastFailing(statuses)
+	}
+
+	// A draining relay is still used when the selector has no other choice
+	for _, list := range [][]*relayState{relays, c.relays} {
+		for _, r := range list {
+			if r.Name == name {
+				return r, nil
+			}
+		}
+	}
+	if name == noRelay {
+		return nil, fmt.Errorf("relay error, no relay is allowed for the email")
+	}
+	return nil, fmt.Errorf("relay error, relay %q is not configured", name)
+}
+
+// leastFailing returns the name of the relay with the lowest combined deferral and bounce rate.
+// Ties go to the relay listed first.
+func leastFailing(relays []RelayStatus) string {
+	best := -1
+	for i, r := range relays {
+		if best < 0 || r.Stats.DeferralRate+r.Stats.BounceRate < relays[best].Stats.DeferralRate+relays[best].Stats.BounceRate {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return relays[best].Name
+}
+
+// IsolateProfiles restricts emails to the relays listed for their profile name and picks the least failing of them,
+// e.g. to keep bulk traffic away from the relays used for transactional mail. Emails without a profile use the
+// "" entry; profiles without an entry may use every relay. When every listed relay is draining, the first one is
+// used anyway, and an email whose list names no configured relay fails rather than using another one.
+func IsolateProfiles(pools map[string][]string) RelaySelector {
+	return func(email Email, relays []RelayStatus) string {
+		name := ""
+		if email.Profile != nil {
+			name = email.Profile.Name
+		}
+
+		pool, ok := pools[name]
+		if !ok {
+			return leastFailing(relays)
+		}
+
+		allowed := map[string]bool{}
+		for _, n := range pool {
+			allowed[n] = true
+		}
+		var candidates []RelayStatus
+		for _, r := range relays {
+			if allowed[r.Name] {
+				candidates = append(candidates, r)
+			}
+		}
+		if len(candidates) == 0 {
+			if len(pool) == 0 {
+				return noRelay
+			}
+			return pool[0]
+		}
+		return leastFailing(candidates)
+	}
+}
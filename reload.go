@@ -0,0 +1,240 @@
+package smtp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RuntimeConfig holds the settings that can be changed while the client is in use.
+type RuntimeConfig struct {
+	Retry RetryPolicy
+	// DomainInterval spaces out sends to the same recipient domain for every email when greater than zero.
+	// A longer interval set by the email's profile takes precedence.
+	DomainInterval time.Duration
+	TLSMode        TLSMode
+	// TemplateDir is a directory of *.tmpl files registered as templates named after the file.
+	// A file may start with a "Subject: ..." line followed by a blank line; the rest is the body.
+	TemplateDir string
+
+	// unset lists the fields a FileConfigSource file leaves out; ApplyConfig keeps their current values.
+	unset configField
+}
+
+// configField is a set of RuntimeConfig fields.
+type configField uint8
+
+const (
+	configRetry configField = 1 << iota
+	configDomainInterval
+	configTLSMode
+	configTemplateDir
+)
+
+// ConfigSource returns the current runtime configuration, e.g. from a file or a configuration service.
+type ConfigSource func(ctx context.Context) (RuntimeConfig, error)
+
+// fileConfig is the JSON representation of RuntimeConfig read by FileConfigSource.
+// A nil field was left out of the file.
+type fileConfig struct {
+	Retry *struct {
+		MaxAttempts int    `json:"max_attempts"`
+		Backoff     string `json:"backoff"`
+		MaxBackoff  string `json:"max_backoff"`
+	} `json:"retry"`
+	DomainInterval *string `json:"domain_interval"`
+	TLSMode        *string `json:"tls_mode"`
+	TemplateDir    *string `json:"template_dir"`
+}
+
+// FileConfigSource reads the runtime configuration from a JSON file such as:
+//
+//	{
+//		"retry": {"max_attempts": 3, "backoff": "1s", "max_backoff": "30s"},
+//		"domain_interval": "500ms",
+//		"tls_mode": "starttls",
+//		"template_dir": "/etc/mail/templates"
+//	}
+//
+// Fields left out of the file keep the client's current value, so a file without "tls_mode"
+// does not override WithTLSMode.
+func FileConfigSource(path string) ConfigSource {
+	return func(ctx context.Context) (RuntimeConfig, error) {
+		var cfg RuntimeConfig
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("config error, failed to read %s; %w", path, err)
+		}
+
+		var fc fileConfig
+		if err = json.Unmarshal(data, &fc); err != nil {
+			return cfg, fmt.Errorf("config error, failed to decode %s; %w", path, err)
+		}
+
+		if fc.Retry != nil {
+			cfg.Retry.MaxAttempts = fc.Retry.MaxAttempts
+			if cfg.Retry.Backoff, err = parseDuration("retry.backoff", fc.Retry.Backoff); err != nil {
+				return cfg, err
+			}
+			if cfg.Retry.MaxBackoff, err = parseDuration("retry.max_backoff", fc.Retry.MaxBackoff); err != nil {
+				return cfg, err
+			}
+		} else {
+			cfg.unset |= configRetry
+		}
+		if fc.DomainInterval != nil {
+			if cfg.DomainInterval, err = parseDuration("domain_interval", *fc.DomainInterval); err != nil {
+				return cfg, err
+			}
+		} else {
+			cfg.unset |= configDomainInterval
+		}
+		if fc.TLSMode != nil {
+			if cfg.TLSMode, err = ParseTLSMode(*fc.TLSMode); err != nil {
+				return cfg, err
+			}
+		} else {
+			cfg.unset |= configTLSMode
+		}
+		if fc.TemplateDir != nil {
+			cfg.TemplateDir = *fc.TemplateDir
+		} else {
+			cfg.unset |= configTemplateDir
+		}
+		return cfg, nil
+	}
+}
+
+// parseDuration parses an optional duration field.
+func parseDuration(field, s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("config error, invalid %s %q; %w", field, s, err)
+	}
+	return d, nil
+}
+
+// ApplyConfig replaces the runtime configuration; fields left out of a FileConfigSource file are kept. Sends already in progress and pooled sessions are not interrupted;
+// the new settings apply from the next attempt. Nothing is changed when the template directory cannot be loaded.
+func (c *SMTP) ApplyConfig(cfg RuntimeConfig) error {
+	var templates map[string]Template
+	if cfg.unset&configTemplateDir == 0 && cfg.TemplateDir != "" {
+		var err error
+		if templates, err = loadTemplateDir(cfg.TemplateDir); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	if cfg.unset&configRetry == 0 {
+		c.retry = cfg.Retry
+	}
+	if cfg.unset&configDomainInterval == 0 {
+		c.domainInterval = cfg.DomainInterval
+	}
+	if cfg.unset&configTLSMode == 0 {
+		c.tlsMode = cfg.TLSMode
+	}
+	if cfg.unset&configTemplateDir == 0 {
+		c.templateDir = cfg.TemplateDir
+	}
+	c.mu.Unlock()
+
+	for name, tmpl := range templates {
+		c.RegisterTemplate(name, tmpl)
+	}
+	return nil
+}
+
+// WatchConfig loads the configuration from source, then polls it every interval in the background and
+// applies changes until ctx is done. Only the initial load reports errors; later failures keep the last good configuration.
+func (c *SMTP) WatchConfig(ctx context.Context, source ConfigSource, interval time.Duration) error {
+	cfg, err := source(ctx)
+	if err != nil {
+		return err
+	}
+	if err = c.ApplyConfig(cfg); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last := cfg
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			next, err := source(ctx)
+			if err != nil {
+				c.log().Error("smtp config reload failed", "error", err)
+				continue
+			}
+			// Template files may change without the directory changing, so they are always reloaded
+			if next == last && next.TemplateDir == "" {
+				continue
+			}
+			if err = c.ApplyConfig(next); err != nil {
+				c.log().Error("smtp config apply failed", "error", err)
+				continue
+			}
+			last = next
+		}
+	}()
+
+	return nil
+}
+
+// current returns a snapshot of the runtime configuration.
+func (c *SMTP) current() RuntimeConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return RuntimeConfig{
+		Retry:          c.retry,
+		DomainInterval: c.domainInterval,
+		TLSMode:        c.tlsMode,
+		TemplateDir:    c.templateDir,
+	}
+}
+
+// loadTemplateDir reads every *.tmpl file in dir.
+func loadTemplateDir(dir string) (map[string]Template, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("template error, failed to list %s; %w", dir, err)
+	}
+
+	templates := make(map[string]Template, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("template error, failed to read %s; %w", path, err)
+		}
+
+		var tmpl Template
+		body := strings.ReplaceAll(string(data), "\r\n", "\n")
+		if rest, ok := strings.CutPrefix(body, "Subject:"); ok {
+			subject, after, _ := strings.Cut(rest, "\n")
+			tmpl.Subject = strings.TrimSpace(subject)
+			body = strings.TrimPrefix(after, "\n")
+		}
+		tmpl.Body = body
+
+		templates[strings.TrimSuffix(filepath.Base(path), ".tmpl")] = tmpl
+	}
+	return templates, nil
+}
@@ -0,0 +1,83 @@
+package smtp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Recipient is the contact information a RecipientResolver returns for an identifier.
+type Recipient struct {
+	Address string
+	Name    string
+	// Locale is the preferred language of the recipient, e.g. "en-US".
+	Locale string
+	// Preferences holds application defined settings such as notification channels.
+	Preferences map[string]string
+}
+
+// RecipientResolver defines the methods that any address book or CRM lookup must implement.
+type RecipientResolver interface {
+	Resolve(ctx context.Context, id string) (Recipient, error)
+}
+
+// RecipientResolverFunc adapts an ordinary function to the RecipientResolver interface.
+type RecipientResolverFunc func(ctx context.Context, id string) (Recipient, error)
+
+// Resolve calls f(ctx, id).
+func (f RecipientResolverFunc) Resolve(ctx context.Context, id string) (Recipient, error) {
+	return f(ctx, id)
+}
+
+// isIdentifier reports whether a recipient entry is an abstract identifier such as "user:42" rather than an address.
+func isIdentifier(s string) bool {
+	return !strings.Contains(s, "@") && strings.Contains(s, ":")
+}
+
+// ResolveRecipient looks up a single identifier with the configured resolver.
+func (c *SMTP) ResolveRecipient(ctx context.Context, id string) (Recipient, error) {
+	if c.resolver == nil {
+		return Recipient{}, fmt.Errorf("resolve error, no recipient resolver configured for %s", id)
+	}
+
+	r, err := c.resolver.Resolve(ctx, id)
+	if err != nil {
+		return Recipient{}, fmt.Errorf("resolve error, failed to resolve %s; %w", id, err)
+	}
+	if r.Address == "" {
+		return Recipient{}, fmt.Errorf("resolve error, %s has no address", id)
+	}
+	return r, nil
+}
+
+// resolveRecipients replaces the identifiers in To, Cc, and Bcc with the addresses they resolve to.
+func (c *SMTP) resolveRecipients(ctx context.Context, email Email) (Email, error) {
+	resolve := func(entries []string) ([]string, error) {
+		out := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			if !isIdentifier(entry) {
+				out = append(out, entry)
+				continue
+			}
+
+			r, err := c.ResolveRecipient(ctx, entry)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, r.Address)
+		}
+		return out, nil
+	}
+
+	var err error
+	if email.To, err = resolve(email.To); err != nil {
+		return email, err
+	}
+	if email.Cc, err = resolve(email.Cc); err != nil {
+		return email, err
+	}
+	if email.Bcc, err = resolve(email.Bcc); err != nil {
+		return email, err
+	}
+	return email, nil
+}
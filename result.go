@@ -0,0 +1,52 @@
+package smtp
+
+import (
+	"context"
+	"time"
+)
+
+// SendResult reports which recipients a send reached.
+type SendResult struct {
+	// MessageID is the Message-ID of the sent message, or empty when nothing was sent.
+	MessageID string
+	// Recipients are the envelope recipients the message was accepted for.
+	Recipients []string
+	// Suppressed are the recipients skipped because they are on the suppression list.
+	Suppressed []string
+	// TrackingID keys the open and click events of the message when tracking is enabled, see WithTracking.
+	TrackingID string
+	// Duplicate reports that nothing was sent because the idempotency key of the email was already sent,
+	// see Email.IdempotencyKey.
+	Duplicate bool
+	// Deferred are the recipients whose server asked to come back later, e.g. by greylisting. The email was
+	// queued to be sent to them again at RetryAt instead of failing, see WithGreylisting.
+	Deferred []string
+	RetryAt  time.Time
+}
+
+// resultKey is the context key of the SendResult filled in by a send.
+type resultKey struct{}
+
+// SendMailResult sends the email like SendMailContext and reports the recipients it was sent to and those
+// that were skipped. Suppressed recipients are not an error: when every recipient is suppressed, nothing is
+// sent and the error is nil.
+func (c *SMTP) SendMailResult(ctx context.Context, email Email) (SendResult, error) {
+	result := &SendResult{}
+	err := c.SendMailContext(context.WithValue(ctx, resultKey{}, result), email)
+	return *result, err
+}
+
+// resultFrom returns the SendResult to fill in for the send, or a discarded one when the caller did not ask for it.
+func resultFrom(ctx context.Context) *SendResult {
+	if result, ok := ctx.Value(resultKey{}).(*SendResult); ok {
+		return result
+	}
+	return &SendResult{}
+}
+
+// sent records the delivered message.
+func (r *SendResult) sent(email Email, msg *Message) {
+	r.MessageID = msg.Get("Message-ID")
+	r.Recipients = email.recipients()
+	r.TrackingID = email.TrackingID
+}
@@ -0,0 +1,136 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"net/textproto"
+	"time"
+)
+
+// RetryPolicy controls how temporary send failures are retried.
+// The zero value sends every email exactly once.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// Backoff is the delay before the first retry; it doubles after every attempt.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between attempts when greater than zero.
+	MaxBackoff time.Duration
+}
+
+// defaultBackoff is used when a retry is needed but the policy has no backoff configured.
+const defaultBackoff = time.Second
+
+// IsTemporary reports whether err is worth retrying. Only failures that may go away on their own are
+// temporary: network errors, 4xx replies, connections closed by the server, and errors with a Temporary
+// method, such as a *SendmailError, which decide for themselves. Everything else is permanent, including 5xx
+// replies, cancellation, ErrCircuitOpen, and local errors such as invalid addresses, validation, or policy
+// failures, which fail the same way on every attempt.
+func IsTemporary(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// Retrying a partially delivered LMTP transaction would deliver twice to the accepted recipients
+	var lmtpErr *LMTPError
+	if errors.As(err, &lmtpErr) && lmtpErr.Accepted > 0 {
+		return false
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 400 && protoErr.Code < 500
+	}
+
+	var stale *staleSessionError
+	if errors.As(err, &stale) {
+		return true
+	}
+
+	// A file that cannot be opened fails again; its syscall error would otherwise pass for a network error
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return false
+	}
+
+	// Network errors are retried whatever their deprecated Temporary method says
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	// Transport errors such as SendmailError tell themselves
+	var tempErr interface{ Temporary() bool }
+	if errors.As(err, &tempErr) {
+		return tempErr.Temporary()
+	}
+
+	return false
+}
+
+// attempts returns the attempt ceiling, letting a per-message override take precedence.
+func (p RetryPolicy) attempts(override int) int {
+	if override > 0 {
+		return override
+	}
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return 1
+}
+
+// delay returns the wait before the given retry, starting at 1 for the first retry.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.Backoff
+	if d <= 0 {
+		d = defaultBackoff
+	}
+
+	for i := 1; i < retry; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// do calls fn until it succeeds, fails permanently, runs out of attempts, or would pass the deadline of ctx.
+// onRetry, when not nil, is called before waiting for the next attempt.
+func (p RetryPolicy) do(ctx context.Context, override int, fn func() error, onRetry func(attempt int, err error)) error {
+	attempts := p.attempts(override)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= attempts || !IsTemporary(err) {
+			return err
+		}
+
+		wait := p.delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return err
+		}
+		if onRetry != nil {
+			onRetry(attempt, err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestIsTemporary(t *testing.T) {
+	_, missing := os.Open("/nonexistent/attachment.pdf")
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+
+		// Failures that may go away on their own
+		{name: "net op error", err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, want: true},
+		{name: "dns error", err: &net.DNSError{Err: "timeout", Name: "mx.example.com", IsTimeout: true}, want: true},
+		{name: "wrapped net error", err: fmt.Errorf("client error, failed to dial; %w", &net.OpError{Op: "dial", Err: syscall.ECONNRESET}), want: true},
+		{name: "4xx reply", err: &textproto.Error{Code: 451, Msg: "4.3.0 try again later"}, want: true},
+		{name: "421 reply", err: &textproto.Error{Code: 421, Msg: "4.4.2 closing"}, want: true},
+		{name: "eof", err: io.EOF, want: true},
+		{name: "unexpected eof", err: fmt.Errorf("send error; %w", io.ErrUnexpectedEOF), want: true},
+		{name: "stale session", err: &staleSessionError{err: errors.New("connection reset")}, want: true},
+		{name: "temporary method", err: &SendmailError{ExitCode: exTempFail, Err: errors.New("exit status 75")}, want: true},
+		{name: "api rate limit", err: &APIError{Provider: "ses", StatusCode: 429}, want: true},
+
+		// Failures that repeat on every attempt
+		{name: "5xx reply", err: &textproto.Error{Code: 550, Msg: "5.1.1 user unknown"}, want: false},
+		{name: "temporary method false", err: &SendmailError{ExitCode: 1, Err: errors.New("exit status 1")}, want: false},
+		{name: "api bad request", err: &APIError{Provider: "ses", StatusCode: 400}, want: false},
+		{name: "lmtp partial delivery", err: &LMTPError{Accepted: 1, Errors: []RecipientError{{Recipient: "b@example.com", Err: &textproto.Error{Code: 452}}}}, want: false},
+		{name: "message size", err: &MessageSizeError{Size: 2 << 20, Limit: 1 << 20}, want: false},
+		{name: "smtputf8 required", err: ErrSMTPUTF8Required, want: false},
+		{name: "deferred", err: &DeferredError{Err: &textproto.Error{Code: 450, Msg: "greylisted"}}, want: false},
+		{name: "circuit open", err: ErrCircuitOpen, want: false},
+		{name: "canceled", err: context.Canceled, want: false},
+		{name: "deadline exceeded", err: fmt.Errorf("send error; %w", context.DeadlineExceeded), want: false},
+		{name: "compliance", err: &ComplianceError{Violations: []Violation{{Rule: "header"}}}, want: false},
+		{name: "validation", err: &ValidationError{Violations: []Violation{{Rule: "to"}}}, want: false},
+		{name: "dane", err: &DANEError{Host: "mx.example.com", Err: errors.New("no match")}, want: false},
+		{name: "mta-sts", err: &MTASTSError{Domain: "example.com", MX: "mx.other.com", Reason: "mx not in policy"}, want: false},
+		{name: "missing file", err: fmt.Errorf("attachment error; %w", missing), want: false},
+		{name: "unknown", err: errors.New("something local"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTemporary(tt.err); got != tt.want {
+				t.Fatalf("IsTemporary(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
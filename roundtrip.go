@@ -0,0 +1,212 @@
+package smtp
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// structuralHeaders are derived from Email fields and therefore not copied into Email.Headers by ParseMessage.
+var structuralHeaders = map[string]bool{
+	"To":                        true,
+	"Cc":                        true,
+	"Bcc":                       true,
+	"Subject":                   true,
+	"Mime-Version":              true,
+	"Content-Type":              true,
+	"Content-Transfer-Encoding": true,
+	"Content-Disposition":       true,
+}
+
+// ParseMessage parses a serialized RFC 5322 message into an Email.
+// The first text/plain and text/html parts become the bodies, the first text/calendar part the Calendar,
+// and every other leaf part an attachment.
+// Other header fields, including From, are kept in Email.Headers.
+// Malformed input yields an error.
+func ParseMessage(data []byte) (email Email, err error) {
+	root, err := parseEntity(data)
+	if err != nil {
+		return Email{}, fmt.Errorf("parse error, malformed message; %w", err)
+	}
+
+	decoder := new(mime.WordDecoder)
+	decode := func(value string) string {
+		decoded, err := decoder.DecodeHeader(value)
+		if err != nil {
+			return value
+		}
+		return decoded
+	}
+
+	email.Subject = decode(root.header.Get("Subject"))
+	email.To = parseAddressList(root.header.Get("To"))
+	email.Cc = parseAddressList(root.header.Get("Cc"))
+	email.Bcc = parseAddressList(root.header.Get("Bcc"))
+
+	for name, values := range root.header {
+		canonical := textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+		if structuralHeaders[canonical] || !validFieldName(name) || len(values) == 0 {
+			continue
+		}
+		if email.Headers == nil {
+			email.Headers = map[string]string{}
+		}
+		email.Headers[CanonicalHeaderName(name)] = sanitizeHeaderValue(values[0])
+	}
+
+	bodyFound, htmlFound, calendarFound := false, false, false
+	var walk func(e *entity)
+	walk = func(e *entity) {
+		if strings.HasPrefix(e.mediaType, "multipart/") {
+			for _, part := range e.parts {
+				walk(part)
+			}
+			return
+		}
+
+		if !bodyFound && e.mediaType == "text/plain" && isBodyPart(e) {
+			bodyFound = true
+			email.Body = strings.TrimSuffix(string(e.content), "\r\n")
+			return
+		}
+		if !htmlFound && e.mediaType == "text/html" && isBodyPart(e) {
+			htmlFound = true
+			email.HTMLBody = strings.TrimSuffix(string(e.content), "\r\n")
+			return
+		}
+		if !calendarFound && e.mediaType == "text/calendar" && isBodyPart(e) {
+			calendarFound = true
+			email.Calendar = &Calendar{Method: e.params["method"], Data: e.content}
+			return
+		}
+
+		filename := e.params["name"]
+		if _, params, err := mime.ParseMediaType(e.header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+			filename = params["filename"]
+		}
+		if filename == "" {
+			filename = fmt.Sprintf("part-%d", len(email.Attachments)+1)
+		}
+		disposition, _, _ := mime.ParseMediaType(e.header.Get("Content-Disposition"))
+		email.Attachments = append(email.Attachments, Attachment{
+			Filename:    filename,
+			ContentType: e.mediaType,
+			Data:        e.content,
+			Inline:      disposition == "inline",
+			ContentID:   strings.Trim(e.header.Get("Content-ID"), "<> "),
+		})
+	}
+	walk(root)
+
+	// The invite.ics copy of the calendar is added again when the email is built
+	if email.Calendar != nil {
+		for i, a := range email.Attachments {
+			if a.ContentType == "application/ics" && bytes.Equal(normalizeCRLF(a.Data), normalizeCRLF(email.Calendar.Data)) {
+				email.Attachments = append(email.Attachments[:i], email.Attachments[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return email, nil
+}
+
+// isBodyPart reports whether the entity is a message body rather than an attachment or inline file.
+func isBodyPart(e *entity) bool {
+	if isAttachment(e) || e.header.Get("Content-ID") != "" {
+		return false
+	}
+	_, params, _ := mime.ParseMediaType(e.header.Get("Content-Disposition"))
+	return params["filename"] == ""
+}
+
+// Marshal serializes an email without a client. Header fields such as From are taken from Email.Headers.
+// The output of Marshal is always accepted by ParseMessage.
+func Marshal(email Email) ([]byte, error) {
+	for name := range email.Headers {
+		if !validFieldName(name) {
+			return nil, fmt.Errorf("marshal error, invalid header name %q", name)
+		}
+	}
+	for _, addr := range email.recipients() {
+		if err := validateLine(addr); err != nil {
+			return nil, fmt.Errorf("marshal error, invalid address %q", addr)
+		}
+	}
+
+	email.Subject = encodeSubject(email.Subject)
+
+	var c SMTP
+	return c.buildMessage(email).Bytes(), nil
+}
+
+// encodeSubject forces an encoded-word when the subject would not survive header parsing verbatim,
+// e.g. because of surrounding whitespace, control characters, or text that looks like an encoded-word.
+func encodeSubject(subject string) string {
+	if subject == strings.TrimSpace(subject) && !strings.Contains(subject, "=?") && isPrintableASCII(subject) {
+		return subject
+	}
+
+	var words []string
+	var word strings.Builder
+	for i := 0; i < len(subject); i++ {
+		b := subject[i]
+
+		// Start a new word only at a rune boundary so multi-byte characters stay together
+		if word.Len() > 40 && (b < 0x80 || b >= 0xC0) {
+			words = append(words, "=?utf-8?q?"+word.String()+"?=")
+			word.Reset()
+		}
+
+		switch {
+		case b == ' ':
+			word.WriteByte('_')
+		case b >= '0' && b <= '9', b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z':
+			word.WriteByte(b)
+		default:
+			fmt.Fprintf(&word, "=%02X", b)
+		}
+	}
+	if word.Len() > 0 {
+		words = append(words, "=?utf-8?q?"+word.String()+"?=")
+	}
+	return strings.Join(words, " ")
+}
+
+// isPrintableASCII reports whether s only contains printable ASCII characters and spaces.
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < ' ' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseAddressList returns the bare addresses of a header value, falling back to splitting on commas.
+func parseAddressList(value string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	list, err := mail.ParseAddressList(value)
+	if err == nil {
+		addrs := make([]string, len(list))
+		for i, addr := range list {
+			addrs[i] = addr.Address
+		}
+		return addrs
+	}
+
+	var addrs []string
+	for _, addr := range strings.Split(value, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, sanitizeHeaderValue(addr))
+		}
+	}
+	return addrs
+}
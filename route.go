@@ -0,0 +1,154 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Route delivers the mail for some recipient domains through another client, e.g. a partner's smarthost
+// with its own credentials and TLS settings.
+type Route struct {
+	// Domains are recipient domains such as "partner.com", or patterns such as "*.partner.com" that match
+	// every subdomain. Matching is case-insensitive.
+	Domains []string
+	// Client delivers the recipients of the route with its own connection settings, pool, retries, and DKIM signer.
+	Client *SMTP
+}
+
+// matches reports whether the route covers the domain of addr.
+func (r Route) matches(addr string) bool {
+	at := strings.LastIndex(addr, "@")
+	domain := strings.ToLower(strings.TrimSuffix(addr[at+1:], ">"))
+	for _, pattern := range r.Domains {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(domain, "."+suffix) {
+				return true
+			}
+		} else if domain == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// routeGroup is the part of an email's envelope delivered by one client.
+type routeGroup struct {
+	client *SMTP
+	email  Email
+}
+
+// route splits the recipients of the email by the first matching route; the rest stay with c.
+func (c *SMTP) route(email Email) []routeGroup {
+	groups := make([]routeGroup, len(c.routes)+1)
+	groups[len(c.routes)].client = c
+	for i, r := range c.routes {
+		groups[i].client = r.Client
+	}
+
+	split := func(addrs []string, field func(*Email) *[]string) {
+		for _, addr := range addrs {
+			i := len(c.routes)
+			for j, r := range c.routes {
+				if r.Client != nil && r.matches(addr) {
+					i = j
+					break
+				}
+			}
+			list := field(&groups[i].email)
+			*list = append(*list, addr)
+		}
+	}
+	split(email.To, func(e *Email) *[]string { return &e.To })
+	split(email.Cc, func(e *Email) *[]string { return &e.Cc })
+	split(email.Bcc, func(e *Email) *[]string { return &e.Bcc })
+
+	out := groups[:0]
+	for _, g := range groups {
+		if len(g.email.recipients()) == 0 {
+			continue
+		}
+		to, cc, bcc := g.email.To, g.email.Cc, g.email.Bcc
+		g.email = email
+		g.email.To, g.email.Cc, g.email.Bcc = to, cc, bcc
+		out = append(out, g)
+	}
+	return out
+}
+
+// transmitRouted transmits the message through the client of every route that has recipients, each with its
+// own retries, and records the recipients that were reached. The headers keep every recipient; only the
+// envelope is split, so split sends are never deferred: the queued email would lose the other recipients.
+func (c *SMTP) transmitRouted(ctx context.Context, email Email, msg *Message, attempts int, result *SendResult) error {
+	if len(c.routes) == 0 && !c.verp {
+		err := c.transmit(ctx, email, c.envelopeSender(email), msg, attempts, true)
+		var deferred *DeferredError
+		if errors.As(err, &deferred) {
+			result.Deferred, result.RetryAt = email.recipients(), deferred.RetryAt
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		result.sent(email, msg)
+		return nil
+	}
+
+	groups := []routeGroup{{client: c, email: email}}
+	if len(c.routes) != 0 {
+		groups = c.route(email)
+	}
+	if c.verp && batchFrom(ctx) == nil {
+		// The transactions of one recipient each share a session per client
+		b := &batch{}
+		defer b.close()
+		ctx = context.WithValue(ctx, batchKey{}, b)
+	}
+
+	var errs []error
+	for _, g := range groups {
+		errs = append(errs, c.transmitGroup(ctx, g, email, msg, attempts, result)...)
+	}
+	return errors.Join(errs...)
+}
+
+// transmitGroup transmits the envelopes of one route group. The send is registered with the route client too,
+// so that shutting that client down waits for the group, and a route client that is already shut down fails
+// the group with ErrClientClosed.
+func (c *SMTP) transmitGroup(ctx context.Context, g routeGroup, email Email, msg *Message, attempts int, result *SendResult) []error {
+	if g.client != c {
+		var end func()
+		var err error
+		if ctx, end, err = g.client.life.begin(ctx); err != nil {
+			return []error{fmt.Errorf("route error, failed to send to %s via %s; %w", strings.Join(g.email.recipients(), ", "), g.client.host, err)}
+		}
+		defer end()
+	}
+
+	envelopes := []Email{g.email}
+	if c.verp {
+		envelopes = g.email.perRecipient()
+	}
+
+	var errs []error
+	for _, e := range envelopes {
+		out := msg
+		if c.verp && email.unsubscribeEach {
+			out = c.unsubscribeFor(msg, e.recipients()[0])
+		}
+		if err := g.client.transmit(ctx, e, c.envelopeSender(e), out, attempts, false); err != nil {
+			if g.client == c {
+				errs = append(errs, fmt.Errorf("send error, failed to send to %s; %w", strings.Join(e.recipients(), ", "), err))
+			} else {
+				errs = append(errs, fmt.Errorf("route error, failed to send to %s via %s; %w", strings.Join(e.recipients(), ", "), g.client.host, err))
+			}
+			continue
+		}
+		result.MessageID = msg.Get("Message-ID")
+		result.Recipients = append(result.Recipients, e.recipients()...)
+		result.TrackingID = email.TrackingID
+	}
+	return errs
+}
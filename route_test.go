@@ -0,0 +1,52 @@
+package smtp_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dexterdmonkey/go-smtp"
+	"github.com/dexterdmonkey/go-smtp/smtptest"
+)
+
+func TestRouteClientShutDown(t *testing.T) {
+	srv, partner := smtptest.NewServer(), smtptest.NewServer()
+	t.Cleanup(srv.Close)
+	t.Cleanup(partner.Close)
+
+	// The route client emits events, whose queues are closed by its shutdown
+	events := smtp.EventHandlerFunc(func(ctx context.Context, event smtp.WebhookEvent) error { return nil })
+	route, err := smtp.New("app@example.com", "secret", partner.Host(), partner.Port(), smtp.WithEvents(events, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mail, err := smtp.New("app@example.com", "secret", srv.Host(), srv.Port(),
+		smtp.WithRoutes(smtp.Route{Domains: []string{"partner.com"}, Client: route}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mail.Close() })
+
+	if err = route.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := mail.SendMailResult(context.Background(), smtp.Email{
+		To:      []string{"user@example.com", "user@partner.com"},
+		Subject: "Hello",
+		Body:    "Hi",
+	})
+	if !errors.Is(err, smtp.ErrClientClosed) {
+		t.Fatalf("send through a shut down route returned %v, want ErrClientClosed", err)
+	}
+	if len(result.Recipients) != 1 || result.Recipients[0] != "user@example.com" {
+		t.Fatalf("sent to %v, want only the recipient outside the route", result.Recipients)
+	}
+	if _, err = srv.Wait(1, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if messages := partner.Messages(); len(messages) != 0 {
+		t.Fatalf("shut down route client sent %d messages", len(messages))
+	}
+}
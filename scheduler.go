@@ -0,0 +1,362 @@
+package smtp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ScheduledEmail represents an email waiting to be sent at a specific time.
+type ScheduledEmail struct {
+	ID     string
+	SendAt time.Time
+	Email  Email
+}
+
+// ScheduleStore defines the methods that any schedule persistence backend must implement.
+type ScheduleStore interface {
+	Save(item ScheduledEmail) error
+	Delete(id string) error
+	List() ([]ScheduledEmail, error)
+}
+
+// MemoryScheduleStore keeps scheduled emails in memory; schedules are lost on restart.
+type MemoryScheduleStore struct {
+	mu    sync.Mutex
+	items map[string]ScheduledEmail
+}
+
+// NewMemoryScheduleStore initializes and returns an empty in-memory schedule store.
+func NewMemoryScheduleStore() *MemoryScheduleStore {
+	return &MemoryScheduleStore{items: map[string]ScheduledEmail{}}
+}
+
+// Save stores or replaces the scheduled email.
+func (s *MemoryScheduleStore) Save(item ScheduledEmail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[item.ID] = item
+	return nil
+}
+
+// Delete removes the scheduled email with the given ID.
+func (s *MemoryScheduleStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[id]; !ok {
+		return fmt.Errorf("schedule error, unknown id %s", id)
+	}
+	delete(s.items, id)
+	return nil
+}
+
+// List returns all scheduled emails ordered by send time.
+func (s *MemoryScheduleStore) List() ([]ScheduledEmail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := make([]ScheduledEmail, 0, len(s.items))
+	for _, item := range s.items {
+		items = append(items, item)
+	}
+	sortSchedule(items)
+	return items, nil
+}
+
+// FileScheduleStore persists scheduled emails as JSON in a single file.
+type FileScheduleStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileScheduleStore initializes and returns a schedule store backed by the file at path.
+func NewFileScheduleStore(path string) *FileScheduleStore {
+	return &FileScheduleStore{path: path}
+}
+
+// Save stores or replaces the scheduled email.
+func (s *FileScheduleStore) Save(item ScheduledEmail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items, err := s.read()
+	if err != nil {
+		return err
+	}
+	items[item.ID] = item
+	return s.write(items)
+}
+
+// Delete removes the scheduled email with the given ID.
+func (s *FileScheduleStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := items[id]; !ok {
+		return fmt.Errorf("schedule error, unknown id %s", id)
+	}
+	delete(items, id)
+	return s.write(items)
+}
+
+// List returns all scheduled emails ordered by send time.
+func (s *FileScheduleStore) List() ([]ScheduledEmail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]ScheduledEmail, 0, len(items))
+	for _, item := range items {
+		list = append(list, item)
+	}
+	sortSchedule(list)
+	return list, nil
+}
+
+func (s *FileScheduleStore) read() (map[string]ScheduledEmail, error) {
+	items := map[string]ScheduledEmail{}
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return items, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("schedule error, failed to read store; %s", err.Error())
+	}
+	if len(data) == 0 {
+		return items, nil
+	}
+
+	if err = json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("schedule error, failed to decode store; %s", err.Error())
+	}
+	return items, nil
+}
+
+func (s *FileScheduleStore) write(items map[string]ScheduledEmail) error {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return fmt.Errorf("schedule error, failed to encode store; %s", err.Error())
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated store
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("schedule error, failed to write store; %s", err.Error())
+	}
+	if err = os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("schedule error, failed to write store; %s", err.Error())
+	}
+	return nil
+}
+
+// Scheduler dispatches emails through a sender once their send time is due.
+type Scheduler struct {
+	sender   Interface
+	store    ScheduleStore
+	interval time.Duration
+
+	// OnError is called when a due email fails to send. The email is removed from the store either way.
+	OnError func(item ScheduledEmail, err error)
+
+	mu     sync.Mutex
+	stop   chan struct{}
+	done   chan struct{}
+	closed bool
+}
+
+// NewScheduler initializes and returns a scheduler that sends due emails through sender.
+func NewScheduler(sender Interface, store ScheduleStore) *Scheduler {
+	if store == nil {
+		store = NewMemoryScheduleStore()
+	}
+
+	return &Scheduler{
+		sender:   sender,
+		store:    store,
+		interval: time.Second,
+	}
+}
+
+// SetInterval changes how often the scheduler checks for due emails.
+func (s *Scheduler) SetInterval(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.interval = interval
+}
+
+// Schedule stores the email to be sent at sendAt and returns its schedule ID.
+// Use a time in the recipient's location (e.g. time.Date(..., 9, 0, 0, 0, loc)) to send at their local time.
+func (s *Scheduler) Schedule(email Email, sendAt time.Time) (string, error) {
+	if s.isClosed() {
+		return "", fmt.Errorf("schedule error, scheduler is shut down")
+	}
+
+	id, err := newID()
+	if err != nil {
+		return "", fmt.Errorf("schedule error, failed to generate id; %s", err.Error())
+	}
+
+	item := ScheduledEmail{ID: id, SendAt: sendAt, Email: email}
+	if err = s.store.Save(item); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Cancel removes a scheduled email before it is sent.
+func (s *Scheduler) Cancel(id string) error {
+	return s.store.Delete(id)
+}
+
+// Start begins checking for due emails in the background.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go s.run(s.interval, s.stop, s.done)
+}
+
+// Stop halts the background loop and waits for an in-progress dispatch to finish.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// Dispatch sends every email whose send time is at or before now.
+func (s *Scheduler) Dispatch(now time.Time) error {
+	items, err := s.store.List()
+	if err != nil {
+		return err
+	}
+
+	var due []ScheduledEmail
+	for _, item := range items {
+		if item.SendAt.After(now) {
+			break
+		}
+		due = append(due, item)
+	}
+
+	// Higher priority emails go out first when several are due at once
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].Email.priority() > due[j].Email.priority()
+	})
+
+	for _, item := range due {
+		// Emails not sent yet stay in the store once the scheduler shuts down
+		if s.isClosed() {
+			break
+		}
+		// Remove before sending so a crash never delivers the same email twice
+		if err = s.store.Delete(item.ID); err != nil {
+			continue
+		}
+
+		if err = s.sender.SendMail(item.Email); err != nil && s.OnError != nil {
+			s.OnError(item, err)
+		}
+	}
+
+	return nil
+}
+
+// Shutdown stops the background loop like Stop, refuses new schedules, and waits for an in-progress dispatch
+// until ctx is done; emails that are due but not sent yet stay in the store. A MemoryScheduleStore does not
+// survive the process, so the emails left in it are reported in a *ShutdownError for the caller to persist.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+
+	report := &ShutdownError{}
+	if stop != nil {
+		close(stop)
+		select {
+		case <-done:
+		case <-ctx.Done():
+			report.Err = ctx.Err()
+		}
+	}
+
+	if store, ok := s.store.(*MemoryScheduleStore); ok {
+		report.Scheduled, _ = store.List()
+	}
+	if report.empty() {
+		return nil
+	}
+	return report
+}
+
+func (s *Scheduler) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+func (s *Scheduler) run(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if err := s.Dispatch(now); err != nil && s.OnError != nil {
+				s.OnError(ScheduledEmail{}, err)
+			}
+		}
+	}
+}
+
+func sortSchedule(items []ScheduledEmail) {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].SendAt.Before(items[j].SendAt)
+	})
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,81 @@
+package smtp
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// redacted replaces secrets in every printed, logged, or marshaled form.
+const redacted = "[REDACTED]"
+
+// Secret holds a password so that it cannot leak by accident: printing it with any fmt verb, logging it with slog,
+// or marshaling it to JSON yields "[REDACTED]". Reveal returns the value.
+type Secret struct {
+	value string
+}
+
+// NewSecret wraps a password.
+func NewSecret(value string) Secret {
+	return Secret{value: value}
+}
+
+// Reveal returns the secret in plain text. It is the only way to read the value; keep it out of logs and errors.
+func (s Secret) Reveal() string {
+	return s.value
+}
+
+// IsZero reports whether the secret is empty.
+func (s Secret) IsZero() bool {
+	return s.value == ""
+}
+
+// String returns "[REDACTED]".
+func (s Secret) String() string {
+	return redacted
+}
+
+// GoString returns "[REDACTED]" for the %#v verb.
+func (s Secret) GoString() string {
+	return redacted
+}
+
+// Format prints "[REDACTED]" for every verb, including those such as %d that would otherwise print the fields.
+func (s Secret) Format(f fmt.State, verb rune) {
+	f.Write([]byte(redacted))
+}
+
+// LogValue implements slog.LogValuer.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
+
+// MarshalText implements encoding.TextMarshaler and returns "[REDACTED]", so marshaling never writes the secret.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler so that a Secret reads from a JSON or YAML string.
+func (s *Secret) UnmarshalText(text []byte) error {
+	s.value = string(text)
+	return nil
+}
+
+// String describes the client without its password.
+func (c *SMTP) String() string {
+	return fmt.Sprintf("smtp.SMTP{sender: %s, host: %s, port: %s, password: %s}", c.senderAddress, c.host, c.port, c.password)
+}
+
+// GoString describes the client like String for the %#v verb.
+func (c *SMTP) GoString() string {
+	return c.String()
+}
+
+// LogValue implements slog.LogValuer and logs the client without its password.
+func (c *SMTP) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("sender", c.senderAddress),
+		slog.String("host", c.host),
+		slog.String("port", c.port),
+		slog.Any("password", c.password),
+	)
+}
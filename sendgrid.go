@@ -0,0 +1,173 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+)
+
+// sendgridReservedHeaders are set by SendGrid from the request and may not be passed as custom headers.
+// The names are lower case.
+var sendgridReservedHeaders = map[string]bool{
+	"from": true, "to": true, "cc": true, "bcc": true, "subject": true, "reply-to": true, "date": true,
+	"content-type": true, "content-transfer-encoding": true, "mime-version": true, "dkim-signature": true,
+	"received": true, "x-sg-id": true, "x-sg-eid": true,
+}
+
+// SendGridTransport sends messages through the SendGrid v3 Mail Send API. SendGrid does not accept raw MIME,
+// so the assembled message is taken apart again: its bodies, attachments, and custom headers are sent as
+// fields, and SendGrid builds and signs the final message. Use it with WithTransport.
+type SendGridTransport struct {
+	APIKey Secret
+	// Endpoint overrides https://api.sendgrid.com, e.g. https://api.eu.sendgrid.com for EU regional subusers.
+	Endpoint string
+	// Client is used to send requests; it defaults to a client with a 30 second timeout.
+	Client *http.Client
+}
+
+type sendgridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendgridPersonalization struct {
+	To  []sendgridAddress `json:"to"`
+	Cc  []sendgridAddress `json:"cc,omitempty"`
+	Bcc []sendgridAddress `json:"bcc,omitempty"`
+}
+
+type sendgridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendgridAttachment struct {
+	Content     string `json:"content"`
+	Type        string `json:"type,omitempty"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition,omitempty"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+type sendgridRequest struct {
+	Personalizations []sendgridPersonalization `json:"personalizations"`
+	From             sendgridAddress           `json:"from"`
+	ReplyTo          *sendgridAddress          `json:"reply_to,omitempty"`
+	Subject          string                    `json:"subject"`
+	Content          []sendgridContent         `json:"content,omitempty"`
+	Attachments      []sendgridAttachment      `json:"attachments,omitempty"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+	Categories       []string                  `json:"categories,omitempty"`
+}
+
+// Deliver sends the message to the envelope recipients, keeping each in the To or Cc header it appears in
+// and sending the others as Bcc. SendGrid requires a To recipient, so when none of the envelope recipients is
+// in the To header, the first one is shown there.
+func (t *SendGridTransport) Deliver(ctx context.Context, from string, recipients []string, msg *Message) error {
+	email, err := ParseMessage(msg.Bytes())
+	if err != nil {
+		return fmt.Errorf("sendgrid error, failed to read message; %w", err)
+	}
+
+	body := sendgridRequest{Subject: email.Subject, Headers: map[string]string{}}
+	body.From = sendgridAddress{Email: from}
+	if addr, err := mail.ParseAddress(email.Headers["From"]); err == nil {
+		body.From = sendgridAddress{Email: addr.Address, Name: addr.Name}
+	}
+	if addr, err := mail.ParseAddress(email.Headers["Reply-To"]); err == nil {
+		body.ReplyTo = &sendgridAddress{Email: addr.Address, Name: addr.Name}
+	}
+
+	var p sendgridPersonalization
+	for _, rcpt := range recipients {
+		switch {
+		case containsAddress(email.To, rcpt):
+			p.To = append(p.To, sendgridAddress{Email: rcpt})
+		case containsAddress(email.Cc, rcpt):
+			p.Cc = append(p.Cc, sendgridAddress{Email: rcpt})
+		default:
+			p.Bcc = append(p.Bcc, sendgridAddress{Email: rcpt})
+		}
+	}
+	if len(p.To) == 0 {
+		if len(p.Cc) != 0 {
+			p.To, p.Cc = p.Cc[:1], p.Cc[1:]
+		} else if len(p.Bcc) != 0 {
+			p.To, p.Bcc = p.Bcc[:1], p.Bcc[1:]
+		}
+	}
+	body.Personalizations = []sendgridPersonalization{p}
+
+	// text/plain must come before text/html
+	if email.Body != "" || email.HTMLBody == "" {
+		body.Content = append(body.Content, sendgridContent{Type: "text/plain", Value: email.Body})
+	}
+	if email.HTMLBody != "" {
+		body.Content = append(body.Content, sendgridContent{Type: "text/html", Value: email.HTMLBody})
+	}
+	if email.Calendar != nil {
+		email.Attachments = append(email.Attachments, email.Calendar.attachment())
+	}
+	for _, a := range email.Attachments {
+		disposition := "attachment"
+		if a.Inline {
+			disposition = "inline"
+		}
+		body.Attachments = append(body.Attachments, sendgridAttachment{
+			Content:     base64.StdEncoding.EncodeToString(a.Data),
+			Type:        a.contentType(),
+			Filename:    a.Filename,
+			Disposition: disposition,
+			ContentID:   a.ContentID,
+		})
+	}
+
+	for name, value := range email.Headers {
+		if sendgridReservedHeaders[strings.ToLower(name)] {
+			continue
+		}
+		if name == "X-Categories" {
+			for _, category := range strings.Split(value, ",") {
+				if category = strings.TrimSpace(category); category != "" {
+					body.Categories = append(body.Categories, category)
+				}
+			}
+			continue
+		}
+		body.Headers[name] = value
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("sendgrid error, failed to encode request; %w", err)
+	}
+
+	endpoint := t.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.sendgrid.com"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v3/mail/send", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("sendgrid error, failed to create request; %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.APIKey.Reveal())
+
+	_, err = doAPI(t.Client, req, "sendgrid")
+	return err
+}
+
+// containsAddress reports whether list holds addr, ignoring case.
+func containsAddress(list []string, addr string) bool {
+	for _, a := range list {
+		if strings.EqualFold(a, addr) {
+			return true
+		}
+	}
+	return false
+}
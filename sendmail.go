@@ -0,0 +1,134 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// exTempFail is the sysexits.h status of sendmail for a temporary failure.
+const exTempFail = 75
+
+// SendmailTransport pipes every message to a local sendmail-compatible binary such as Postfix's or Exim's
+// sendmail, or msmtp, for hosts without outbound SMTP. Use it with WithTransport.
+type SendmailTransport struct {
+	// Path is the binary, /usr/sbin/sendmail by default.
+	Path string
+	// Args precede the envelope; the default is -i, so a line with a single dot does not end the message.
+	// The envelope sender is always passed with -f. The recipients are passed after "--", unless Args contain
+	// -t, in which case sendmail reads them from the To, Cc, and Bcc headers and the envelope of the send,
+	// e.g. of VERP or routes, is not used.
+	Args []string
+}
+
+// SendmailError reports a sendmail command that failed.
+type SendmailError struct {
+	// ExitCode is the exit status of the command, or -1 when it could not be started.
+	ExitCode int
+	// Stderr is what the command printed to its standard error.
+	Stderr string
+	Err    error
+}
+
+// Error returns the error output of the command and how it failed.
+func (e *SendmailError) Error() string {
+	if e.Stderr != "" {
+		return fmt.Sprintf("sendmail error, command failed: %s; %v", e.Stderr, e.Err)
+	}
+	return fmt.Sprintf("sendmail error, command failed; %v", e.Err)
+}
+
+func (e *SendmailError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether sendmail exited with EX_TEMPFAIL, e.g. because its queue is unavailable.
+func (e *SendmailError) Temporary() bool {
+	return e.ExitCode == exTempFail
+}
+
+// Deliver runs the sendmail command with the message on its standard input, with LF line endings as local
+// mail programs expect.
+func (t *SendmailTransport) Deliver(ctx context.Context, from string, recipients []string, msg *Message) error {
+	path := t.Path
+	if path == "" {
+		path = "/usr/sbin/sendmail"
+	}
+	args := t.Args
+	if args == nil {
+		args = []string{"-i"}
+	}
+
+	args = append(append([]string(nil), args...), "-f", from)
+	readsHeaders := false
+	for _, arg := range t.Args {
+		if arg == "-t" {
+			readsHeaders = true
+		}
+	}
+	if !readsHeaders {
+		args = append(append(args, "--"), recipients...)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return &SendmailError{ExitCode: -1, Err: err}
+	}
+	if err = cmd.Start(); err != nil {
+		return &SendmailError{ExitCode: -1, Err: err}
+	}
+
+	_, writeErr := msg.WriteTo(&lfWriter{w: stdin})
+	stdin.Close()
+	err = cmd.Wait()
+
+	if err != nil {
+		code := -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		}
+		return &SendmailError{ExitCode: code, Stderr: strings.TrimSpace(stderr.String()), Err: err}
+	}
+	if writeErr != nil {
+		return fmt.Errorf("sendmail error, failed to write message; %w", writeErr)
+	}
+	return nil
+}
+
+// lfWriter converts CRLF line endings to LF.
+type lfWriter struct {
+	w io.Writer
+	// cr is set when the last write ended with a CR whose LF may follow
+	cr bool
+}
+
+func (l *lfWriter) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p)+1)
+	if l.cr && (len(p) == 0 || p[0] != '\n') {
+		out = append(out, '\r')
+	}
+	l.cr = false
+
+	for i := 0; i < len(p); i++ {
+		switch {
+		case p[i] != '\r':
+			out = append(out, p[i])
+		case i == len(p)-1:
+			l.cr = true
+		case p[i+1] != '\n':
+			out = append(out, '\r')
+		}
+	}
+	if _, err := l.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
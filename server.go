@@ -0,0 +1,757 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close or Shutdown.
+var ErrServerClosed = errors.New("server error, server closed")
+
+// Backend creates a Session for every connection a Server accepts.
+type Backend interface {
+	// NewSession is called when a client connects, before the greeting. An error rejects the connection:
+	// a *ReplyError is sent as the greeting, any other error as 421.
+	NewSession(conn *ServerConn) (Session, error)
+}
+
+// BackendFunc adapts a function to the Backend interface.
+type BackendFunc func(conn *ServerConn) (Session, error)
+
+// NewSession calls f(conn).
+func (f BackendFunc) NewSession(conn *ServerConn) (Session, error) {
+	return f(conn)
+}
+
+// Session handles the mail transactions of a single inbound connection. A method returning a *ReplyError
+// sends that reply to the client; any other error is reported as a temporary failure.
+type Session interface {
+	// Mail starts a transaction with the envelope sender, which is empty for bounces.
+	Mail(from string, opts MailOptions) error
+	// Rcpt adds an envelope recipient.
+	Rcpt(to string) error
+	// Data receives the message with dot-stuffing removed and LF line endings. It is accepted when Data
+	// returns nil; content left unread is discarded.
+	Data(r io.Reader) error
+	// Reset ends the current transaction: after DATA, on RSET, or on a new EHLO.
+	Reset()
+	// Logout is called once when the connection ends.
+	Logout() error
+}
+
+// AuthSession is a Session that supports AUTH PLAIN and LOGIN. The server only advertises AUTH when the
+// session implements it.
+type AuthSession interface {
+	Session
+	// Auth verifies the credentials; an error rejects them with 535 unless it is a *ReplyError.
+	Auth(username, password string) error
+}
+
+// MailOptions are the ESMTP parameters of MAIL FROM.
+type MailOptions struct {
+	// Size is the declared message size, or 0 when the client did not declare one.
+	Size int64
+	// Body is 7BIT or 8BITMIME when declared.
+	Body string
+	// UTF8 reports whether the client requested SMTPUTF8.
+	UTF8 bool
+}
+
+// ServerConn describes an inbound connection. Its fields are updated as the client sends HELO or EHLO,
+// STARTTLS, and AUTH.
+type ServerConn struct {
+	RemoteAddr net.Addr
+	// Hostname is the name the client gave in HELO or EHLO.
+	Hostname string
+	// TLS is the state of the connection once TLS is active, or nil.
+	TLS *tls.ConnectionState
+	// Username is the user the client authenticated as, or empty.
+	Username string
+}
+
+// ReplyError is an SMTP reply returned by a Session to reject a command.
+type ReplyError struct {
+	Code int
+	// EnhancedCode is the RFC 3463 status code, e.g. "5.1.1". It may be empty.
+	EnhancedCode string
+	Message      string
+}
+
+func (e *ReplyError) Error() string {
+	return fmt.Sprintf("server error, %d %s", e.Code, e.text())
+}
+
+// text returns the reply text with the enhanced status code.
+func (e *ReplyError) text() string {
+	if e.EnhancedCode == "" {
+		return e.Message
+	}
+	return e.EnhancedCode + " " + e.Message
+}
+
+// Server accepts inbound SMTP connections and hands the transactions to the sessions of its Backend.
+// It supports PIPELINING, 8BITMIME, SMTPUTF8, SIZE, STARTTLS, and AUTH PLAIN and LOGIN. Command lines longer than
+// 1000 octets are answered with 500 and discarded.
+type Server struct {
+	// Addr is the address ListenAndServe listens on, ":25" when empty.
+	Addr string
+	// Domain is the host name announced in the greeting and EHLO reply; the default is the system host name.
+	Domain  string
+	Backend Backend
+
+	// TLSConfig enables STARTTLS, and is required by ListenAndServeTLS.
+	TLSConfig *tls.Config
+	// AllowInsecureAuth offers AUTH before STARTTLS. Credentials are then sent in the clear.
+	AllowInsecureAuth bool
+	// AuthRequired rejects MAIL FROM until the client authenticated.
+	AuthRequired bool
+
+	// MaxMessageBytes is advertised with SIZE and enforced on DATA when greater than zero.
+	MaxMessageBytes int64
+	// MaxRecipients limits the recipients of a transaction when greater than zero.
+	MaxRecipients int
+	// ReadTimeout and WriteTimeout bound every command and reply when greater than zero.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	Logger *slog.Logger
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*serverConn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// NewServer initializes and returns a server for the backend with a 25 MB message limit, at most 100 recipients
+// per message, and five minute timeouts.
+func NewServer(backend Backend) *Server {
+	return &Server{
+		Backend:         backend,
+		MaxMessageBytes: 25 << 20,
+		MaxRecipients:   100,
+		ReadTimeout:     5 * time.Minute,
+		WriteTimeout:    5 * time.Minute,
+	}
+}
+
+// ListenAndServe listens on Addr and serves connections until the server is closed.
+func (s *Server) ListenAndServe() error {
+	addr := s.Addr
+	if addr == "" {
+		addr = ":25"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("server error, failed to listen; %w", err)
+	}
+	return s.Serve(l)
+}
+
+// ListenAndServeTLS listens on Addr with implicit TLS, as used by SMTPS on port 465.
+func (s *Server) ListenAndServeTLS() error {
+	if s.TLSConfig == nil {
+		return fmt.Errorf("server error, TLSConfig is required for implicit TLS")
+	}
+	addr := s.Addr
+	if addr == "" {
+		addr = ":465"
+	}
+	l, err := tls.Listen("tcp", addr, s.TLSConfig)
+	if err != nil {
+		return fmt.Errorf("server error, failed to listen; %w", err)
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until the server is closed and always returns a non-nil error.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.trackListener(l, true) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.trackListener(l, false)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return fmt.Errorf("server error, failed to accept; %w", err)
+		}
+
+		c := &serverConn{server: s, raw: conn, conn: conn, text: textproto.NewConn(conn), state: &ServerConn{RemoteAddr: conn.RemoteAddr()}}
+		if !s.trackConn(c, true) {
+			conn.Close()
+			return ErrServerClosed
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.trackConn(c, false)
+			c.serve()
+		}()
+	}
+}
+
+// Close stops the listeners and closes every open connection immediately.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.raw.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// Shutdown stops the listeners and waits for open connections to end, closing them once ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Close()
+		return ctx.Err()
+	}
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+// trackListener adds or removes a listener; it reports false when the server is already closed.
+func (s *Server) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !add {
+		delete(s.listeners, l)
+		return true
+	}
+	if s.closed {
+		return false
+	}
+	if s.listeners == nil {
+		s.listeners = map[net.Listener]struct{}{}
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+// trackConn adds or removes an open connection; it reports false when the server is already closed.
+func (s *Server) trackConn(c *serverConn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !add {
+		delete(s.conns, c)
+		return true
+	}
+	if s.closed {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = map[*serverConn]struct{}{}
+	}
+	s.conns[c] = struct{}{}
+	return true
+}
+
+func (s *Server) domain() string {
+	if s.Domain != "" {
+		return s.Domain
+	}
+	if name, err := os.Hostname(); err == nil {
+		return name
+	}
+	return "localhost"
+}
+
+func (s *Server) log() *slog.Logger {
+	if s.Logger == nil {
+		return discardLogger
+	}
+	return s.Logger
+}
+
+// serverConn is the state of a single inbound connection.
+type serverConn struct {
+	server *Server
+	// raw is the accepted connection, which conn wraps once STARTTLS succeeds
+	raw     net.Conn
+	conn    net.Conn
+	text    *textproto.Conn
+	state   *ServerConn
+	session Session
+	tls     bool
+
+	from       string
+	recipients int
+	mail       bool
+}
+
+// serve runs the session until the client quits or the connection fails.
+func (c *serverConn) serve() {
+	defer c.text.Close()
+
+	// Connections accepted by a TLS listener complete the handshake before the greeting
+	if tlsConn, ok := c.conn.(*tls.Conn); ok {
+		if c.server.ReadTimeout > 0 {
+			tlsConn.SetDeadline(time.Now().Add(c.server.ReadTimeout))
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			c.server.log().Debug("smtp server tls handshake failed", "remote", c.state.RemoteAddr, "error", err)
+			return
+		}
+		state := tlsConn.ConnectionState()
+		c.tls, c.state.TLS = true, &state
+	}
+
+	session, err := c.server.Backend.NewSession(c.state)
+	if err != nil {
+		c.replyError(err, 421, "4.3.2", "service not available")
+		return
+	}
+	c.session = session
+	defer func() {
+		if err := session.Logout(); err != nil {
+			c.server.log().Warn("smtp server logout failed", "remote", c.state.RemoteAddr, "error", err)
+		}
+	}()
+
+	c.reply(220, c.server.domain()+" ESMTP ready")
+	for {
+		if c.server.ReadTimeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(c.server.ReadTimeout))
+		}
+		line, err := c.readLine()
+		if errors.Is(err, errLineTooLong) {
+			c.reply(500, "5.5.2 line too long")
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		if c.command(strings.ToUpper(verb), strings.TrimSpace(arg)) {
+			return
+		}
+	}
+}
+
+func (c *serverConn) reply(code int, lines ...string) {
+	if c.server.WriteTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.server.WriteTimeout))
+	}
+	for i, line := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		c.text.PrintfLine("%d%s%s", code, sep, line)
+	}
+}
+
+// replyError sends the reply of a session error, or the given fallback for errors that are not a *ReplyError.
+func (c *serverConn) replyError(err error, code int, enhanced, message string) {
+	var reply *ReplyError
+	if !errors.As(err, &reply) {
+		reply = &ReplyError{Code: code, EnhancedCode: enhanced, Message: message}
+	}
+	c.reply(reply.Code, reply.text())
+}
+
+// canAuth reports whether AUTH is offered on the connection.
+func (c *serverConn) canAuth() bool {
+	_, ok := c.session.(AuthSession)
+	return ok && (c.tls || c.server.AllowInsecureAuth)
+}
+
+// command handles a single command and reports whether the connection is over.
+func (c *serverConn) command(verb, arg string) bool {
+	switch verb {
+	case "EHLO", "HELO":
+		if arg == "" {
+			c.reply(501, "5.5.4 syntax: "+verb+" hostname")
+			return false
+		}
+		c.state.Hostname = arg
+		c.reset()
+		if verb == "HELO" {
+			c.reply(250, c.server.domain())
+			return false
+		}
+
+		lines := []string{c.server.domain(), "PIPELINING", "8BITMIME", "SMTPUTF8", "ENHANCEDSTATUSCODES"}
+		if c.server.TLSConfig != nil && !c.tls {
+			lines = append(lines, "STARTTLS")
+		}
+		if c.canAuth() {
+			lines = append(lines, "AUTH PLAIN LOGIN")
+		}
+		if c.server.MaxMessageBytes > 0 {
+			lines = append(lines, "SIZE "+strconv.FormatInt(c.server.MaxMessageBytes, 10))
+		}
+		c.reply(250, lines...)
+	case "STARTTLS":
+		if c.server.TLSConfig == nil || c.tls {
+			c.reply(502, "5.5.1 STARTTLS not available")
+			return false
+		}
+		c.reply(220, "2.0.0 ready to start TLS")
+		tlsConn := tls.Server(c.conn, c.server.TLSConfig)
+		if c.server.ReadTimeout > 0 {
+			tlsConn.SetDeadline(time.Now().Add(c.server.ReadTimeout))
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			c.server.log().Debug("smtp server tls handshake failed", "remote", c.state.RemoteAddr, "error", err)
+			return true
+		}
+		state := tlsConn.ConnectionState()
+		c.conn, c.text, c.tls = tlsConn, textproto.NewConn(tlsConn), true
+		c.state.TLS, c.state.Hostname = &state, ""
+		c.reset()
+	case "AUTH":
+		c.auth(arg)
+	case "MAIL":
+		c.mailFrom(arg)
+	case "RCPT":
+		c.rcptTo(arg)
+	case "DATA":
+		return c.data()
+	case "RSET":
+		c.reset()
+		c.reply(250, "2.0.0 ok")
+	case "NOOP":
+		c.reply(250, "2.0.0 ok")
+	case "VRFY":
+		c.reply(252, "2.5.0 cannot verify")
+	case "QUIT":
+		c.reply(221, "2.0.0 bye")
+		return true
+	default:
+		c.reply(502, "5.5.2 command not recognized")
+	}
+	return false
+}
+
+func (c *serverConn) mailFrom(arg string) {
+	switch {
+	case c.state.Hostname == "":
+		c.reply(503, "5.5.1 send HELO or EHLO first")
+		return
+	case c.mail:
+		c.reply(503, "5.5.1 nested MAIL command")
+		return
+	case c.server.AuthRequired && c.state.Username == "":
+		c.reply(530, "5.7.0 authentication required")
+		return
+	}
+
+	from, ok := serverPath(arg, "FROM:")
+	if !ok {
+		c.reply(501, "5.5.4 syntax: MAIL FROM:<address>")
+		return
+	}
+
+	var opts MailOptions
+	if size := serverParam(arg, "SIZE"); size != "" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || n < 0 {
+			c.reply(501, "5.5.4 invalid SIZE parameter")
+			return
+		}
+		if max := c.server.MaxMessageBytes; max > 0 && n > max {
+			c.reply(552, "5.3.4 message size exceeds fixed limit")
+			return
+		}
+		opts.Size = n
+	}
+	opts.Body = strings.ToUpper(serverParam(arg, "BODY"))
+	for _, field := range strings.Fields(arg) {
+		if strings.EqualFold(field, "SMTPUTF8") {
+			opts.UTF8 = true
+		}
+	}
+
+	if err := c.session.Mail(from, opts); err != nil {
+		c.replyError(err, 451, "4.3.0", "sender not accepted")
+		return
+	}
+	c.from, c.mail = from, true
+	c.reply(250, "2.1.0 ok")
+}
+
+func (c *serverConn) rcptTo(arg string) {
+	if !c.mail {
+		c.reply(503, "5.5.1 need MAIL first")
+		return
+	}
+	if max := c.server.MaxRecipients; max > 0 && c.recipients >= max {
+		c.reply(452, "4.5.3 too many recipients")
+		return
+	}
+
+	to, ok := serverPath(arg, "TO:")
+	if !ok || to == "" {
+		c.reply(501, "5.5.4 syntax: RCPT TO:<address>")
+		return
+	}
+	if err := c.session.Rcpt(to); err != nil {
+		c.replyError(err, 451, "4.3.0", "recipient not accepted")
+		return
+	}
+	c.recipients++
+	c.reply(250, "2.1.5 ok")
+}
+
+// data receives the message and reports whether the connection is over.
+func (c *serverConn) data() bool {
+	if c.recipients == 0 {
+		c.reply(503, "5.5.1 need RCPT first")
+		return false
+	}
+	c.reply(354, "end data with <CR><LF>.<CR><LF>")
+
+	// The read deadline covers the whole message rather than a single line
+	if c.server.ReadTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.server.ReadTimeout))
+	}
+	r := &dataReader{r: c.text.DotReader(), max: c.server.MaxMessageBytes}
+	err := c.session.Data(r)
+
+	// The rest of the message must be read before the next command
+	if _, drainErr := io.Copy(io.Discard, r); drainErr != nil && !errors.Is(drainErr, errMessageTooLarge) {
+		return true
+	}
+	c.reset()
+
+	switch {
+	case r.exceeded:
+		c.reply(552, "5.3.4 message size exceeds fixed limit")
+	case err != nil:
+		c.replyError(err, 451, "4.3.0", "message not accepted")
+	default:
+		c.reply(250, "2.0.0 queued")
+	}
+	return false
+}
+
+// auth handles AUTH PLAIN and AUTH LOGIN, with or without an initial response.
+func (c *serverConn) auth(arg string) {
+	session, ok := c.session.(AuthSession)
+	switch {
+	case !ok || !c.canAuth():
+		c.reply(502, "5.5.1 AUTH not available")
+		return
+	case c.state.Username != "":
+		c.reply(503, "5.5.1 already authenticated")
+		return
+	case c.mail:
+		c.reply(503, "5.5.1 AUTH not allowed during a transaction")
+		return
+	}
+
+	mechanism, initial, _ := strings.Cut(arg, " ")
+	var username, password string
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		if initial == "" {
+			if initial, ok = c.challenge(""); !ok {
+				return
+			}
+		}
+		decoded, err := base64.StdEncoding.DecodeString(initial)
+		parts := strings.Split(string(decoded), "\x00")
+		if err != nil || len(parts) != 3 {
+			c.reply(501, "5.5.2 invalid PLAIN response")
+			return
+		}
+		username, password = parts[1], parts[2]
+	case "LOGIN":
+		user := initial
+		if user == "" {
+			if user, ok = c.challenge("Username:"); !ok {
+				return
+			}
+		}
+		pass, ok := c.challenge("Password:")
+		if !ok {
+			return
+		}
+		u, err1 := base64.StdEncoding.DecodeString(user)
+		p, err2 := base64.StdEncoding.DecodeString(pass)
+		if err := errors.Join(err1, err2); err != nil {
+			c.reply(501, "5.5.2 invalid LOGIN response")
+			return
+		}
+		username, password = string(u), string(p)
+	default:
+		c.reply(504, "5.5.4 unrecognized authentication type")
+		return
+	}
+
+	if err := session.Auth(username, password); err != nil {
+		c.replyError(err, 535, "5.7.8", "authentication credentials invalid")
+		return
+	}
+	c.state.Username = username
+	c.reply(235, "2.7.0 authentication successful")
+}
+
+// challenge sends a 334 prompt and returns the client's response; it reports false when the client cancels.
+func (c *serverConn) challenge(prompt string) (string, bool) {
+	c.reply(334, base64.StdEncoding.EncodeToString([]byte(prompt)))
+	line, err := c.readLine()
+	if errors.Is(err, errLineTooLong) {
+		c.reply(500, "5.5.2 line too long")
+		return "", false
+	}
+	if err != nil || line == "*" {
+		c.reply(501, "5.0.0 authentication cancelled")
+		return "", false
+	}
+	return line, true
+}
+
+// maxCommandLength is the longest command line accepted, including CRLF (RFC 5321 section 4.5.3.1.4).
+const maxCommandLength = 1000
+
+// errLineTooLong is returned by readLine for a line longer than maxCommandLength.
+var errLineTooLong = errors.New("server error, line too long")
+
+// readLine reads a command line without its line break. A line longer than maxCommandLength is discarded without
+// buffering it, and reported with errLineTooLong.
+func (c *serverConn) readLine() (string, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, more, err := c.text.R.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		if !tooLong && len(line)+len(chunk)+2 > maxCommandLength {
+			tooLong, line = true, nil
+		}
+		if !tooLong {
+			line = append(line, chunk...)
+		}
+		if !more {
+			break
+		}
+	}
+	if tooLong {
+		return "", errLineTooLong
+	}
+	return string(line), nil
+}
+
+// reset aborts the current transaction.
+func (c *serverConn) reset() {
+	if c.mail {
+		c.session.Reset()
+	}
+	c.from, c.recipients, c.mail = "", 0, false
+}
+
+// errMessageTooLarge is returned by a dataReader once the message exceeds the size limit.
+var errMessageTooLarge = errors.New("server error, message size exceeds fixed limit")
+
+// dataReader reads the DATA stream and fails once more than max bytes were read.
+type dataReader struct {
+	r        io.Reader
+	max      int64
+	n        int64
+	exceeded bool
+}
+
+func (d *dataReader) Read(p []byte) (int, error) {
+	if d.exceeded {
+		// Keep consuming the stream so the connection stays in sync, without handing out more data
+		for {
+			if _, err := d.r.Read(p); err != nil {
+				if err == io.EOF {
+					return 0, errMessageTooLarge
+				}
+				return 0, err
+			}
+		}
+	}
+
+	n, err := d.r.Read(p)
+	d.n += int64(n)
+	if d.max > 0 && d.n > d.max {
+		d.exceeded = true
+		return 0, errMessageTooLarge
+	}
+	return n, err
+}
+
+// serverPath extracts the address of a MAIL FROM or RCPT TO argument.
+func serverPath(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	arg = strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(arg, "<") {
+		return "", false
+	}
+	end := strings.Index(arg, ">")
+	if end < 0 {
+		return "", false
+	}
+	return arg[1:end], true
+}
+
+// serverParam returns the value of an ESMTP parameter such as SIZE=1024.
+func serverParam(arg, name string) string {
+	for _, field := range strings.Fields(arg) {
+		key, value, ok := strings.Cut(field, "=")
+		if ok && strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// sesPath is the SES v2 SendEmail operation.
+const sesPath = "/v2/email/outbound-emails"
+
+// SESTransport sends messages through the Amazon SES v2 API as raw MIME, so the assembled message, its headers,
+// and its DKIM signature reach SES unchanged. Use it with WithTransport.
+type SESTransport struct {
+	// Region is the AWS region of the SES account, e.g. eu-west-1.
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey Secret
+	// SessionToken is set for temporary credentials, e.g. of an assumed role.
+	SessionToken Secret
+	// ConfigurationSet names the SES configuration set, e.g. for event publishing; optional.
+	ConfigurationSet string
+	// Endpoint overrides https://email.<region>.amazonaws.com, e.g. for a VPC endpoint.
+	Endpoint string
+	// Client is used to send requests; it defaults to a client with a 30 second timeout.
+	Client *http.Client
+}
+
+// sesRequest is the body of the SendEmail operation.
+type sesRequest struct {
+	Destination struct {
+		ToAddresses []string `json:"ToAddresses"`
+	} `json:"Destination"`
+	Content struct {
+		Raw struct {
+			// Data is base64 encoded by encoding/json, as the API expects
+			Data []byte `json:"Data"`
+		} `json:"Raw"`
+	} `json:"Content"`
+	FeedbackForwardingEmailAddress string `json:"FeedbackForwardingEmailAddress,omitempty"`
+	ConfigurationSetName           string `json:"ConfigurationSetName,omitempty"`
+}
+
+// Deliver sends the message to the envelope recipients. Bounces and complaints are forwarded to the envelope
+// sender, which must belong to an identity verified in SES.
+func (t *SESTransport) Deliver(ctx context.Context, from string, recipients []string, msg *Message) error {
+	var body sesRequest
+	body.Destination.ToAddresses = recipients
+	body.Content.Raw.Data = msg.Bytes()
+	body.FeedbackForwardingEmailAddress = from
+	body.ConfigurationSetName = t.ConfigurationSet
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("ses error, failed to encode request; %w", err)
+	}
+
+	endpoint := t.Endpoint
+	if endpoint == "" {
+		endpoint = "https://email." + t.Region + ".amazonaws.com"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+sesPath, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("ses error, failed to create request; %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	t.sign(req, payload, time.Now())
+
+	_, err = doAPI(t.Client, req, "ses")
+	return err
+}
+
+// sign adds the AWS Signature Version 4 headers for the ses service to req.
+func (t *SESTransport) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	scope := date + "/" + t.Region + "/ses/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if !t.SessionToken.IsZero() {
+		req.Header.Set("X-Amz-Security-Token", t.SessionToken.Reveal())
+	}
+
+	// Canonical headers are sorted by lower-case name
+	headers := "content-type:" + req.Header.Get("Content-Type") + "\n" +
+		"host:" + req.URL.Host + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	signed := "content-type;host;x-amz-date"
+	if !t.SessionToken.IsZero() {
+		headers += "x-amz-security-token:" + t.SessionToken.Reveal() + "\n"
+		signed += ";x-amz-security-token"
+	}
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(payload)
+	// SendEmail has no query string
+	canonical := req.Method + "\n" + path + "\n\n" + headers + "\n" + signed + "\n" + hex.EncodeToString(payloadHash[:])
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+t.SecretAccessKey.Reveal()), date)
+	key = hmacSHA256(key, t.Region)
+	key = hmacSHA256(key, "ses")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+t.AccessKeyID+"/"+scope+", SignedHeaders="+signed+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,208 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrClientClosed is returned by sends on a client that was shut down.
+var ErrClientClosed = errors.New("send error, client is shut down")
+
+// ShutdownError reports the work a Shutdown left unfinished, so that it can be logged or persisted elsewhere.
+type ShutdownError struct {
+	// Err is the error of the Shutdown context when its deadline passed, or nil.
+	Err error
+	// Sends is the number of sends that were still running at the deadline and were canceled; their callers
+	// receive the cancellation error.
+	Sends int
+	// Events are the send events that no handler received.
+	Events []WebhookEvent
+	// Scheduled are the emails left in a MemoryScheduleStore, which does not survive the process.
+	Scheduled []ScheduledEmail
+	// Outbox are the IDs of claimed outbox messages that were released unsent; another dispatcher sends them.
+	Outbox []string
+}
+
+func (e *ShutdownError) Error() string {
+	var left []string
+	if e.Sends > 0 {
+		left = append(left, fmt.Sprintf("%d sends canceled", e.Sends))
+	}
+	if len(e.Events) > 0 {
+		left = append(left, fmt.Sprintf("%d events undelivered", len(e.Events)))
+	}
+	if len(e.Scheduled) > 0 {
+		left = append(left, fmt.Sprintf("%d scheduled emails in memory", len(e.Scheduled)))
+	}
+	if len(e.Outbox) > 0 {
+		left = append(left, fmt.Sprintf("%d outbox messages released", len(e.Outbox)))
+	}
+	if len(left) == 0 {
+		left = append(left, "work left unfinished")
+	}
+
+	msg := "shutdown error, " + strings.Join(left, ", ")
+	if e.Err != nil {
+		msg += "; " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// empty reports whether nothing was left unfinished.
+func (e *ShutdownError) empty() bool {
+	return e.Err == nil && e.Sends == 0 && len(e.Events) == 0 && len(e.Scheduled) == 0 && len(e.Outbox) == 0
+}
+
+// lifecycle tracks the sends in progress so that Shutdown can wait for them, and cancels them when it gives up.
+type lifecycle struct {
+	mu     sync.Mutex
+	closed bool
+	active int
+	// idle is closed once the client is shut down and the last send returned
+	idle   chan struct{}
+	abort  context.Context
+	cancel context.CancelFunc
+}
+
+// init creates the context that is canceled when Shutdown gives up. The caller holds mu.
+func (l *lifecycle) init() {
+	if l.abort == nil {
+		l.abort, l.cancel = context.WithCancel(context.Background())
+	}
+}
+
+// aborted returns the context that is canceled when Shutdown gives up.
+func (l *lifecycle) aborted() context.Context {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.init()
+	return l.abort
+}
+
+// begin registers a send and returns its context, which is also canceled when Shutdown gives up.
+// end must be called once the send returns.
+func (l *lifecycle) begin(ctx context.Context) (context.Context, func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ctx, nil, ErrClientClosed
+	}
+	l.init()
+	l.active++
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(l.abort, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		l.end()
+	}, nil
+}
+
+func (l *lifecycle) end() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active--
+	if l.closed && l.active == 0 && l.idle != nil {
+		close(l.idle)
+		l.idle = nil
+	}
+}
+
+// Shutdown stops accepting sends and waits until the sends in progress return and every queued event reached
+// its handler, then closes the pooled sessions like Close. Sends started afterwards fail with ErrClientClosed.
+//
+// Once ctx is done, the remaining sends are canceled and the remaining events are dropped. What was left
+// unfinished is reported in a *ShutdownError, e.g. for a Kubernetes preStop hook:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 25*time.Second)
+//	defer cancel()
+//	if err := client.Shutdown(ctx); err != nil {
+//		log.Println(err)
+//	}
+func (c *SMTP) Shutdown(ctx context.Context) error {
+	report := &ShutdownError{}
+
+	l := &c.life
+	l.mu.Lock()
+	l.closed = true
+	l.init()
+	idle := l.idle
+	if idle == nil {
+		idle = make(chan struct{})
+		if l.active == 0 {
+			close(idle)
+		} else {
+			l.idle = idle
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		l.mu.Lock()
+		report.Sends, report.Err = l.active, ctx.Err()
+		l.mu.Unlock()
+		// Canceled sends close their connections and return promptly
+		l.cancel()
+		<-idle
+	}
+
+	for _, q := range c.events {
+		report.Events = append(report.Events, q.shutdown(ctx, l.cancel)...)
+		if ctx.Err() != nil {
+			report.Err = ctx.Err()
+		}
+	}
+
+	c.Close()
+	if report.empty() {
+		return nil
+	}
+	return report
+}
+
+// shutdown waits for the queued events to be handled until ctx is done, then cancels the handler through
+// abort and returns the events that were never handed to it. No events may be queued anymore.
+func (q *eventQueue) shutdown(ctx context.Context, abort context.CancelFunc) []WebhookEvent {
+	drained := make(chan struct{})
+	go func() {
+		q.pending.Wait()
+		close(drained)
+	}()
+
+	var dropped []WebhookEvent
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		abort()
+		for {
+			select {
+			case event := <-q.events:
+				dropped = append(dropped, event)
+				q.pending.Done()
+				continue
+			default:
+			}
+			break
+		}
+	}
+
+	q.closing.Do(func() {
+		// Without events the background goroutine is never started
+		q.start.Do(func() {})
+		close(q.events)
+	})
+	return dropped
+}
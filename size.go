@@ -0,0 +1,131 @@
+package smtp
+
+import (
+	"fmt"
+	"io"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// MessageSizeError is returned when a message exceeds the size limit advertised by the server or the client's
+// own limit set with WithMaxMessageSize.
+type MessageSizeError struct {
+	// Size is the size of the message, or the bytes written so far when the limit was hit while streaming.
+	Size  int64
+	Limit int64
+	// Local is true when the limit is the client's MaxMessageSize rather than the server's.
+	Local bool
+}
+
+// Error returns a description of the size violation.
+func (e *MessageSizeError) Error() string {
+	if e.Local {
+		return fmt.Sprintf("size error, message of %d bytes exceeds the maximum message size of %d bytes", e.Size, e.Limit)
+	}
+	return fmt.Sprintf("size error, message of %d bytes exceeds the server limit of %d bytes", e.Size, e.Limit)
+}
+
+// sizeLimitWriter fails with a MessageSizeError before the bytes written exceed limit, so content of unknown
+// size, such as an AttachReader attachment, cannot push a message past the client's maximum.
+type sizeLimitWriter struct {
+	w     io.Writer
+	n     int64
+	limit int64
+}
+
+// limitSize wraps w in a sizeLimitWriter when limit is greater than zero.
+func limitSize(w io.Writer, limit int64) io.Writer {
+	if limit <= 0 {
+		return w
+	}
+	return &sizeLimitWriter{w: w, limit: limit}
+}
+
+func (l *sizeLimitWriter) Write(p []byte) (int, error) {
+	if l.n+int64(len(p)) > l.limit {
+		return 0, &MessageSizeError{Size: l.n + int64(len(p)), Limit: l.limit, Local: true}
+	}
+	n, err := l.w.Write(p)
+	l.n += int64(n)
+	return n, err
+}
+
+// EstimateSize returns the number of bytes the message occupies on the wire,
+// including CRLF line endings and dot-stuffing.
+// Streamed attachments are measured without holding them in memory.
+func (m *Message) EstimateSize() int64 {
+	size := wireSize(m.headerText())
+	if !m.streamed() {
+		return size + wireSize(m.Body)
+	}
+
+	for _, seg := range m.segments {
+		switch {
+		case seg.open != nil:
+			size += seg.encodedSize()
+		case seg.enc == EncodingBase64 && seg.data != nil:
+			size += base64Size(int64(len(seg.data)))
+		default:
+			// Text is encoded to measure it, without keeping the result
+			stats := &rawStats{}
+			seg.encode(stats)
+			size += stats.size
+		}
+	}
+	return size
+}
+
+// wireSize returns the length of data once line endings are converted to CRLF and lines are dot-stuffed.
+func wireSize(data string) int64 {
+	size := int64(len(data))
+	atLineStart := true
+	for i, ch := range data {
+		if atLineStart && ch == '.' {
+			size++
+		}
+		bareLF := ch == '\n' && (i == 0 || data[i-1] != '\r')
+		bareCR := ch == '\r' && (i == len(data)-1 || data[i+1] != '\n')
+		if bareLF || bareCR {
+			size++
+		}
+		atLineStart = ch == '\n' || bareCR
+	}
+	return size
+}
+
+// sizeLimit returns the SIZE limit advertised by the server, or 0 when there is none.
+func sizeLimit(client *smtp.Client) int64 {
+	ok, param := client.Extension("SIZE")
+	if !ok {
+		return 0
+	}
+
+	limit, err := strconv.ParseInt(strings.TrimSpace(param), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return limit
+}
+
+// sizeParams checks the message against maxSize, when greater than zero, and the server's SIZE limit,
+// and returns the SIZE parameter of the MAIL FROM command.
+func sizeParams(client *smtp.Client, msg *Message, maxSize int64) ([]string, error) {
+	hasSize, _ := client.Extension("SIZE")
+	if !hasSize && maxSize <= 0 {
+		return nil, nil
+	}
+
+	size := msg.EstimateSize()
+	if maxSize > 0 && size > maxSize {
+		return nil, &MessageSizeError{Size: size, Limit: maxSize, Local: true}
+	}
+	if !hasSize {
+		return nil, nil
+	}
+	if limit := sizeLimit(client); limit > 0 && size > limit {
+		return nil, &MessageSizeError{Size: size, Limit: limit}
+	}
+
+	return []string{"SIZE=" + strconv.FormatInt(size, 10)}, nil
+}
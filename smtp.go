@@ -1,17 +1,26 @@
 package smtp
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/smtp"
+	"net/textproto"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// Version is the version of the library reported in trace headers.
+const Version = "0.1.0"
+
 // Interface defines the methods that any SMTP client must implement.
 type Interface interface {
 	GetSenderAddress() string
-	GetPassword() string
 	GetHost() string
 	GetPort() int
 	ParseBody(body string, parameters map[string]interface{}) string
@@ -25,19 +34,162 @@ type Email struct {
 	Bcc     []string
 	Subject string
 	Body    string
+	Headers map[string]string
+	DSN     *DSN
+
+	// HTMLBody is sent as a text/html alternative to Body when set.
+	HTMLBody string
+	// InlineCSS moves the rules of the <style> blocks in HTMLBody into style attributes before the message is
+	// assembled, for clients such as Gmail that drop <style> blocks. See the InlineCSS function.
+	InlineCSS bool
+
+	// TrackingID keys the open and click tracking of the message when the client tracks HTML bodies, see
+	// WithTracking. A random ID is used when it is empty; SendMailResult reports it. NoTracking disables
+	// tracking for this email, e.g. for password resets.
+	TrackingID string
+	NoTracking bool
+
+	// BodyEncoding and HTMLEncoding override the Content-Transfer-Encoding of the bodies. By default 7bit is used
+	// for short-lined ASCII, quoted-printable for mostly ASCII text, and base64 otherwise, so that bodies survive
+	// relays that only pass 7-bit data.
+	BodyEncoding Encoding
+	HTMLEncoding Encoding
+
+	Attachments []Attachment
+
+	// Calendar adds an iCalendar object such as a meeting invitation, see Event.
+	Calendar *Calendar
+
+	// MaxAttempts overrides the client's retry policy attempt ceiling for this email when greater than zero.
+	MaxAttempts int
+
+	// Profile applies a predefined set of send settings such as ProfileOTP.
+	Profile *Profile
+
+	// Locale selects how templates format dates, numbers, and currency amounts, e.g. "de-DE".
+	// The default is en-US.
+	Locale string
+	// Direction is DirectionRTL or DirectionLTR. When empty, it follows Locale, e.g. right to left for "ar" or "he".
+	Direction string
+
+	// FromName is the display name of the From header.
+	FromName string
+	// EnvelopeFrom is the address sent in MAIL FROM, where bounces are returned, when it differs from the
+	// From header, e.g. bounces@example.com. It overrides WithEnvelopeFrom.
+	EnvelopeFrom string
+	// Categories are emitted in the X-Categories header for provider side reporting.
+	Categories []string
+
+	// Priority adds the X-Priority and Importance headers and, when the server supports MT-PRIORITY,
+	// the matching MAIL FROM parameter.
+	Priority Priority
+
+	// InReplyTo and References are the Message-IDs that thread a reply with the original message, see Email.Reply.
+	InReplyTo  string
+	References []string
+
+	// DeliverBy asks the relay to deliver the message within the duration or return it as undeliverable,
+	// using the DELIVERBY extension (RFC 2852) when the relay advertises it, e.g. for one-time passwords.
+	// Sending and retrying also stop once it has passed.
+	DeliverBy time.Duration
+	// deliverBy is the delivery deadline of the current send.
+	deliverBy time.Time
+	// noPixel leaves out the open tracking pixel once DropTrackingPixel has removed it.
+	noPixel bool
+	// unsubscribeEach gives every VERP envelope its own List-Unsubscribe link, see WithListUnsubscribeBase.
+	unsubscribeEach bool
+
+	// IdempotencyKey identifies the email across application retries, e.g. "password-reset:42:1699999999".
+	// When the client has an IdempotencyStore, see WithIdempotency, an email whose key was already sent within
+	// the TTL is skipped and SendMailResult reports it as a duplicate.
+	IdempotencyKey string
+
+	// Deferrals counts how often the email was already deferred and queued again, see WithGreylisting.
+	// The client sets it on the queued email.
+	Deferrals int
 }
 
 // SMTP struct represents the SMTP client with necessary credentials and configurations.
+//
+// An SMTP client is safe for concurrent use by multiple goroutines: the pool, throttles, circuit breaker,
+// relay statistics, metrics, templates, and runtime configuration are guarded internally, and each send works
+// on its own copy of the email. A session is used by one send at a time and only returns to the pool once its
+// transaction is complete. Options are applied once by New and must not be changed afterwards; use ApplyConfig
+// for settings that change at runtime. Functions passed as options, such as a CredentialsProvider, middleware,
+// or archiver, are called from every sending goroutine and must be safe for concurrent use themselves.
+// A Conn or *smtp.Client obtained from the client is not safe for concurrent use.
 type SMTP struct {
 	senderAddress string
-	password      string
+	username      string
+	password      Secret
 	host          string
 	port          string
 	auth          smtp.Auth
+	dkim          *DKIMSigner
+	headerOrder   []string
+	traceService  string
+	retry         RetryPolicy
+	archiver      Archiver
+	suppression   SuppressionList
+	throttle      domainThrottle
+	templates     templateRegistry
+	chunkSize     int
+	defaults      defaults
+	strict        bool
+	pool          pool
+	breaker       circuitBreaker
+	fallbacks     []endpoint
+	roundRobin    bool
+	next          uint32
+	resolver      RecipientResolver
+	events        []*eventQueue
+	life          lifecycle
+	lmtp          bool
+	anonymous     bool
+	mechanism     AuthMechanism
+	transcript    TranscriptFunc
+	dialer        DialFunc
+	adaptive      adaptiveThrottle
+	relays        []*relayState
+	relaySelector RelaySelector
+	mtasts        *MTASTSFetcher
+	dane          TLSAResolver
+	logger        *slog.Logger
+	degrade       []DegradeStep
+	draining      drainState
+	onDrain       func(DrainEvent)
+	middlewares   []Middleware
+	dryRun        bool
+	senderTag     *senderTag
+	teeFunc       TeeFunc
+	metrics       metrics
+	timeout       time.Duration
+	credentials   CredentialsProvider
+	timeouts      Timeouts
+	routes        []Route
+	maxSize       int64
+	validation    Validation
+	tracking      *Tracking
+	envelopeFrom  string
+	verp          bool
+	transport     Transport
+	attachments   AttachmentResolver
+	idempotency   IdempotencyStore
+	idempotentFor time.Duration
+	greylist      *greylisting
+	clock         func() time.Time
+	ids           func() string
+
+	// mu guards the settings that can be changed at runtime with ApplyConfig
+	mu             sync.RWMutex
+	domainInterval time.Duration
+	tlsMode        TLSMode
+	templateDir    string
 }
 
 // New initializes and returns a new SMTP client.
-func New(senderAddress, password, host string, port int) (*SMTP, error) {
+// The host may also be a Unix domain socket given as "unix:///path/to/socket", in which case port is ignored.
+func New(senderAddress, password, host string, port int, opts ...Option) (*SMTP, error) {
 	auth := smtp.PlainAuth("", senderAddress, password, host)
 	if auth == nil {
 		return nil, fmt.Errorf("auth error, empty auth")
@@ -45,11 +197,23 @@ func New(senderAddress, password, host string, port int) (*SMTP, error) {
 
 	c := &SMTP{
 		senderAddress: senderAddress,
-		password:      password,
+		password:      NewSecret(password),
+		username:      senderAddress,
 		host:          host,
 		port:          strconv.Itoa(port),
 		auth:          auth,
 	}
+	c.pool.drained = c.drained
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.mechanism != AuthPlain {
+		c.auth = c.newAuth(senderAddress, password, host)
+	}
+	if err := c.validateRelays(); err != nil {
+		return nil, err
+	}
 
 	return c, nil
 }
@@ -59,8 +223,9 @@ func (c *SMTP) GetSenderAddress() string {
 	return c.senderAddress
 }
 
-// GetPassword returns the password for the SMTP client.
-func (c *SMTP) GetPassword() string {
+// Password returns the password given to New, not one returned by a credentials provider.
+// Call Reveal on the result to read it.
+func (c *SMTP) Password() Secret {
 	return c.password
 }
 
@@ -75,79 +240,565 @@ func (c *SMTP) GetPort() int {
 	return port
 }
 
-// GetClient initializes and returns an SMTP client.
+// GetClient initializes and returns an authenticated SMTP client. No mail transaction is started, so the
+// session can deliver any number of messages, each with its own Mail, Rcpt, and Data; call Reset to abort a
+// transaction and Quit when done. Dial returns the same session with more protocol steps.
 func (c *SMTP) GetClient() (*smtp.Client, error) {
-	client, err := smtp.Dial(c.host + ":" + c.port)
+	sess, err := c.dial(context.Background(), c.endpoints())
 	if err != nil {
-		return nil, fmt.Errorf("client error, failed to dial; %s", err.Error())
+		return nil, err
 	}
+	return sess.client, nil
+}
 
-	if err = client.StartTLS(&tls.Config{InsecureSkipVerify: true, ServerName: c.host}); err != nil {
-		return nil, fmt.Errorf("client error, failed to start tls; %s", err.Error())
+// dial connects, starts TLS, and authenticates without starting a mail transaction.
+// The deadline of ctx, if any, bounds the whole session.
+func (c *SMTP) dial(ctx context.Context, eps []endpoint) (*session, error) {
+	sess, err := c.connect(ctx, eps)
+	if err != nil {
+		return nil, err
 	}
 
-	if err = client.Auth(c.auth); err != nil {
-		return nil, fmt.Errorf("client error, failed to apply auth; %s", err.Error())
+	// LMTP servers deliver locally and are trusted without authentication
+	if c.lmtp || c.anonymous {
+		return sess, nil
+	}
+	// Relays that trust the client by its address do not advertise AUTH, and without credentials there is
+	// nothing to authenticate with
+	if ok, _ := sess.client.Extension("AUTH"); !ok || (c.credentials == nil && c.password.Reveal() == "") {
+		c.log().Debug("smtp auth skipped", "host", sess.host, "advertised", ok)
+		return sess, nil
 	}
 
-	if err = client.Mail(c.senderAddress); err != nil {
-		return nil, fmt.Errorf("client error, failed to create mail; %s", err.Error())
+	auth, err := c.sessionAuth(ctx, sess.host)
+	if err != nil {
+		sess.client.Close()
+		return nil, err
+	}
+	if err = sess.client.Auth(auth); err != nil {
+		sess.client.Close()
+		c.log().Warn("smtp auth failed", "host", sess.host, "error", err)
+		return nil, fmt.Errorf("client error, failed to apply auth; %w", err)
 	}
+	c.log().Debug("smtp auth", "host", sess.host)
 
-	return client, nil
+	return sess, nil
+}
+
+// connect opens the connection and starts TLS, moving on to the next endpoint when one fails.
+func (c *SMTP) connect(ctx context.Context, eps []endpoint) (*session, error) {
+	var err error
+	for _, ep := range eps {
+		start := time.Now()
+
+		var sess *session
+		if sess, err = c.connectTo(ctx, ep); err == nil {
+			c.log().Debug("smtp dial", "network", ep.network, "address", ep.address(), "duration", time.Since(start))
+			return sess, nil
+		}
+		c.log().Warn("smtp dial failed", "network", ep.network, "address", ep.address(), "duration", time.Since(start), "error", err)
+		if isDrain(err) {
+			c.drained(&session{addr: ep.address()}, err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// connectTo opens a connection to a single endpoint and starts TLS.
+func (c *SMTP) connectTo(ctx context.Context, ep endpoint) (*session, error) {
+	dial := c.dialer
+	if dial == nil {
+		var dialer net.Dialer
+		if ep.localAddr != "" && ep.network == "tcp" {
+			dialer.LocalAddr = &net.TCPAddr{IP: net.ParseIP(ep.localAddr)}
+		}
+		dial = dialer.DialContext
+	}
+
+	dialCtx := ctx
+	if c.timeouts.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, c.timeouts.DialTimeout)
+		defer cancel()
+	}
+	conn, err := dial(dialCtx, ep.network, ep.address())
+	if err != nil {
+		return nil, fmt.Errorf("client error, failed to dial; %w", err)
+	}
+	// Closing the connection unblocks the greeting and handshake when ctx is canceled without a deadline
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	var deadlines *deadlineConn
+	if c.timeouts.CommandTimeout > 0 || c.timeouts.DataTimeout > 0 {
+		deadlines = &deadlineConn{Conn: conn, timeout: c.timeouts.CommandTimeout}
+		conn = deadlines
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	var transcript *transcriber
+	if rec := recorderFrom(ctx); rec != nil {
+		transcript = &transcriber{}
+		transcript.attach(rec, "connected to "+ep.address())
+	}
+
+	serverName := ep.host
+	if ep.network == "unix" {
+		serverName = ""
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: true, ServerName: serverName}
+
+	mode := c.current().TLSMode
+	if c.dane != nil && ep.network == "tcp" {
+		verify, err := verifyDANE(ctx, c.dane, ep.host, ep.port)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		// Published TLSA records make TLS mandatory (RFC 7672 section 2.2)
+		if verify != nil {
+			tlsConfig.VerifyConnection = verify
+			if mode != TLSImplicit {
+				mode = TLSStartTLS
+			}
+		}
+	}
+	if mode == TLSImplicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	if transcript != nil {
+		conn = &transcriptConn{Conn: conn, t: transcript}
+	}
+	if c.lmtp {
+		conn = &lmtpConn{Conn: conn}
+	}
+
+	client, err := smtp.NewClient(conn, ep.host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("client error, failed to dial; %w", err)
+	}
+
+	sess := &session{client: client, conn: conn, deadlines: deadlines, host: ep.host, addr: ep.address(), transcript: transcript}
+	if mode != TLSImplicit {
+		// The reply to the EHLO after STARTTLS no longer offers it
+		sess.startTLS, _ = client.Extension("STARTTLS")
+	}
+	if c.lmtp {
+		return sess, nil
+	}
+
+	useStartTLS := mode == TLSStartTLS || mode == TLSOpportunistic && sess.startTLS
+	if useStartTLS {
+		if err = client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("client error, failed to start tls; %w", err)
+		}
+		transcript.resume(client)
+	}
+
+	return sess, nil
 }
 
 // SendMail sends an email with the specified content and recipients.
+// Temporary failures are retried according to the client's retry policy.
 func (c *SMTP) SendMail(email Email) error {
-	client, err := c.GetClient()
+	return c.SendMailContext(context.Background(), email)
+}
+
+// SendMailContext sends an email like SendMail, aborting when ctx is cancelled or its deadline passes.
+// The email passes through the configured middlewares first.
+func (c *SMTP) SendMailContext(ctx context.Context, email Email) error {
+	ctx, end, err := c.life.begin(ctx)
 	if err != nil {
 		return err
 	}
-	defer client.Close()
+	defer end()
+
+	return chain(c.send, c.middlewares)(ctx, email)
+}
+
+// send resolves, assembles, and delivers the email with retries.
+func (c *SMTP) send(ctx context.Context, email Email) (err error) {
+	if err = email.ValidateWith(c.validation); err != nil {
+		return err
+	}
+
+	result := resultFrom(ctx)
+	if key := email.IdempotencyKey; key != "" && c.idempotency != nil {
+		first, claimErr := c.idempotency.Claim(key, c.idempotentFor)
+		if claimErr != nil {
+			return fmt.Errorf("idempotency error, failed to claim key %s; %w", key, claimErr)
+		}
+		if !first {
+			result.Duplicate = true
+			c.log().Info("smtp send skipped, duplicate idempotency key", "key", key)
+			return nil
+		}
+		defer func() {
+			if err == nil {
+				return
+			}
+			// Releasing the key after a partial send would have a retry deliver twice to the recipients reached
+			if recipients := reached(email, result, err); len(recipients) != 0 {
+				c.log().Warn("smtp idempotency key kept after partial send", "key", key, "reached", len(recipients))
+				if marker, ok := c.idempotency.(IdempotencyMarker); ok {
+					if markErr := marker.MarkPartial(key, recipients); markErr != nil {
+						c.log().Warn("smtp idempotency key not marked", "key", key, "error", markErr)
+					}
+				}
+				return
+			}
+			if releaseErr := c.idempotency.Release(key); releaseErr != nil {
+				c.log().Warn("smtp idempotency key not released", "key", key, "error", releaseErr)
+			}
+		}()
+	}
+
+	if email, err = c.resolve(ctx, email); err != nil {
+		return err
+	}
+
+	if email.Profile == nil || !email.Profile.SkipSuppression {
+		if email, result.Suppressed, err = c.filterSuppressed(email); err != nil {
+			return err
+		}
+		if len(email.recipients()) == 0 {
+			c.log().Info("smtp send skipped, all recipients are suppressed", "suppressed", len(result.Suppressed))
+			return nil
+		}
+	}
+
+	msg := c.buildMessage(email)
+	if len(c.events) != 0 {
+		c.emit(c.event(EventQueued, email, msg))
+	}
+	if c.dryRun {
+		if err = c.sendDry(email, msg); err == nil {
+			result.sent(email, msg)
+		}
+		return err
+	}
+
+	attempts := email.MaxAttempts
+	if email.Profile != nil {
+		if attempts == 0 {
+			attempts = email.Profile.MaxAttempts
+		}
+		if email.Profile.Deadline > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, email.Profile.Deadline)
+			defer cancel()
+		}
+		if email.DeliverBy == 0 {
+			email.DeliverBy = email.Profile.DeliverBy
+		}
+	}
+	if email.DeliverBy > 0 {
+		// Retries stop at the delivery deadline too, so servers without DELIVERBY never get a stale message
+		email.deliverBy = c.now().Add(email.DeliverBy)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, email.DeliverBy)
+		defer cancel()
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	return c.transmitRouted(ctx, email, msg, attempts, result)
+}
+
+// transmit delivers the assembled message from the envelope sender with retries, then logs, tees, archives,
+// and reports the outcome. The recipients of the email form the envelope. When deferrable is true and the
+// client defers greylisted sends, such a reply queues the email again and transmit returns a *DeferredError.
+func (c *SMTP) transmit(ctx context.Context, email Email, from string, msg *Message, attempts int, deferrable bool) error {
+	var rec *recorder
+	if c.transcript != nil {
+		rec = &recorder{}
+		ctx = context.WithValue(ctx, recorderKey{}, rec)
+	}
 
-	// Send mail to recipients
-	for _, addr := range email.To {
-		if err = client.Rcpt(addr); err != nil {
-			return fmt.Errorf("send error, failed to add recipients; %s", err.Error())
+	start := time.Now()
+	c.metrics.begin()
+	sent := msg
+	tries := 0
+	err := c.current().Retry.do(ctx, attempts, func() error {
+		tries++
+		// The slot of the recipient domains is taken once per send, not again by every retry or reconnect
+		if tries == 1 {
+			if err := c.waitDomains(ctx, email); err != nil {
+				return err
+			}
+		}
+		out, err := c.attempt(ctx, email, from, msg)
+		if out != nil {
+			sent = out
+		}
+		if deferrable && c.greylist != nil && IsGreylisted(err) {
+			if deferred := c.schedule(email, err); deferred != nil {
+				return deferred
+			}
 		}
+		return err
+	}, func(attempt int, err error) {
+		if len(c.events) == 0 {
+			return
+		}
+		event := c.event(EventRetried, email, sent)
+		event.Attempts, event.Error, event.Response = attempt, err.Error(), errorReply(err)
+		c.emit(event)
+	})
+	c.metrics.end(time.Since(start), err)
+
+	attrs := []any{"message_id", sent.Get("Message-ID"), "recipients", len(email.recipients()), "attempts", tries, "duration", time.Since(start)}
+	var deferred *DeferredError
+	if errors.As(err, &deferred) {
+		c.log().Warn("smtp send deferred", append(attrs, "retry_at", deferred.RetryAt, "error", deferred.Err)...)
+	} else if err != nil {
+		c.log().Error("smtp send failed", append(attrs, "error", err)...)
+	} else {
+		c.log().Info("smtp send", attrs...)
+		c.tee(sent)
 	}
+	if transcript := rec.transcript(); len(transcript) > 0 {
+		c.transcript(ctx, email, transcript, err)
+		if err != nil {
+			err = &TranscriptError{Err: err, Transcript: transcript}
+		}
+	}
+
+	c.archive(email, sent, err)
+	c.notify(email, sent, tries, err)
+	return err
+}
 
-	w, err := client.Data()
+// waitDomains spaces out sends to the recipient domains by the longest of the client's domain interval, the
+// adaptive throttle delay, and the profile's domain interval.
+func (c *SMTP) waitDomains(ctx context.Context, email Email) error {
+	interval := max(c.current().DomainInterval, c.adaptive.interval(email.recipients()))
+	if email.Profile != nil {
+		interval = max(interval, email.Profile.DomainInterval)
+	}
+	if interval <= 0 {
+		return nil
+	}
+	return c.throttle.wait(ctx, email.recipients(), interval)
+}
+
+// attempt delivers the assembled message once through the transport or the SMTP sessions, guarded by the
+// circuit breaker, and returns the message as it was sent.
+func (c *SMTP) attempt(ctx context.Context, email Email, from string, msg *Message) (*Message, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	var out *Message
+	var relay *relayState
+	var err error
+	if c.transport != nil {
+		out, err = c.deliverTransport(ctx, email, from, msg)
+	} else {
+		out, relay, err = c.deliverRouted(ctx, email, from, msg)
+	}
+	c.breaker.record(err)
+	relay.record(err)
+	c.adaptive.record(email.recipients(), err)
+	return out, err
+}
+
+// deliver runs a single SMTP transaction for the assembled message and returns the message as it was sent.
+// When relay is not nil, the transaction goes through that relay only.
+func (c *SMTP) deliver(ctx context.Context, email Email, from string, msg *Message, relay *relayState) (*Message, error) {
+	out, err := c.deliverSession(ctx, email, from, msg, relay, true)
+
+	var stale *staleSessionError
+	if errors.As(err, &stale) {
+		if ctx.Err() != nil {
+			return nil, stale.err
+		}
+		c.log().Debug("smtp pooled session closed by server, reconnecting", "address", stale.addr, "error", stale.err)
+		c.pool.discard(stale.relay, stale.addr)
+		return c.deliverSession(ctx, email, from, msg, relay, false)
+	}
+	return out, err
+}
+
+// deliverSession delivers the message on a pooled session when pooled is true, or on a new one.
+func (c *SMTP) deliverSession(ctx context.Context, email Email, from string, msg *Message, relay *relayState, pooled bool) (_ *Message, err error) {
+	sess, err := c.acquire(ctx, relay, pooled)
 	if err != nil {
-		return fmt.Errorf("send error, failed to create data; %s", err.Error())
+		return nil, err
 	}
+	client := sess.client
+	reused := !sess.used.IsZero()
+	defer c.metrics.transaction()()
+
+	// The session goes back to the pool only when the whole transaction succeeded
+	reuse := false
 	defer func() {
-		err = w.Close()
-		if err != nil {
-			fmt.Printf("send error, failed to close email writer; %s\n", err.Error())
+		if isDrain(err) {
+			c.drained(sess, err)
 		}
+		c.release(ctx, sess, reuse)
 	}()
 
-	ccStmt := ""
-	if len(email.Cc) != 0 {
-		ccStmt = "Cc: " + strings.Join(email.Cc, ",") + "\r\n"
+	// Closing the connection unblocks any pending read or write once ctx is done
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	if err = c.checkMTASTS(ctx, sess, email.recipients()); err != nil {
+		return nil, err
 	}
 
-	bccStmt := ""
-	if len(email.Bcc) != 0 {
-		bccStmt = "Bcc: " + strings.Join(email.Bcc, ",") + "\r\n"
+	original := email.recipients()
+	recipients := append([]string(nil), original...)
+	built := msg
+	msg, params, err := c.prepare(client, built, &from, recipients)
+	if err != nil {
+		return nil, err
 	}
 
-	message := []byte(
-		"Subject: " + email.Subject + "\r\n" +
-			"To: " + strings.Join(email.To, ",") + "\r\n" +
-			ccStmt +
-			bccStmt +
-			"\r\n" +
-			email.Body + "\r\n",
-	)
+	// Optional content is dropped step by step while the message exceeds the server's SIZE limit
+	sizes, err := sizeParams(client, msg, c.maxSize)
+	var sizeErr *MessageSizeError
+	for i := 0; errors.As(err, &sizeErr) && i < len(c.degrade); i++ {
+		slim, changed := c.degrade[i].apply(email)
+		if !changed {
+			continue
+		}
+		email = slim
 
-	_, err = w.Write(message)
+		rebuilt := c.buildMessage(email)
+		rebuilt.Set("Date", built.Get("Date"))
+		rebuilt.Set("Message-ID", built.Get("Message-ID"))
+		if msg, params, err = c.prepare(client, rebuilt, &from, recipients); err != nil {
+			return nil, err
+		}
+		c.log().Info("smtp message degraded", "step", c.degrade[i].String(), "size", sizeErr.Size, "limit", sizeErr.Limit)
+		sizes, err = sizeParams(client, msg, c.maxSize)
+	}
 	if err != nil {
-		return fmt.Errorf("send error, failed to send email from %s [%s:%s], %s", c.senderAddress, c.host, c.port, err.Error())
+		return nil, err
 	}
+	params = append(params, sizes...)
 
-	return nil
+	if c.strict {
+		hasUTF8, _ := client.Extension("SMTPUTF8")
+		if err = ValidateMessage(msg.Bytes(), hasUTF8); err != nil {
+			return nil, err
+		}
+	}
+	params = append(params, email.DSN.mailParams(client)...)
+	params = append(params, email.Priority.mailParams(client)...)
+	params = append(params, deliverByParams(client, email.deliverBy, c.now(), c.log())...)
+
+	rcptParams := make([][]string, len(recipients))
+	for i, addr := range original {
+		rcptParams[i] = email.DSN.rcptParams(client, addr)
+	}
+
+	if err = sendEnvelope(client, from, params, recipients, rcptParams, c.log()); err != nil {
+		// MAIL FROM is the first command on a pooled session, so nothing was delivered yet
+		if reused && isStale(err) {
+			return nil, &staleSessionError{err: err, relay: sess.relay, addr: sess.addr}
+		}
+		// A rejected sender or recipient leaves the session usable after RSET
+		var protoErr *textproto.Error
+		reuse = errors.As(err, &protoErr) && !isDrain(err) && ctx.Err() == nil
+		return nil, err
+	}
+
+	// The message transfer runs under the data timeout, the commands around it under the command timeout
+	sess.deadlines.phase(c.timeouts.DataTimeout)
+	defer sess.deadlines.phase(c.timeouts.CommandTimeout)
+
+	if c.lmtp {
+		if err = lmtpData(client, msg, c.maxSize, recipients, c.log()); err != nil {
+			var lmtpErr *LMTPError
+			reuse = errors.As(err, &lmtpErr) && ctx.Err() == nil
+			return nil, err
+		}
+		reuse = ctx.Err() == nil
+		return msg, nil
+	}
+
+	if ok, _ := client.Extension("CHUNKING"); ok {
+		r := msg.reader(c.maxSize)
+		msg.reply, err = bdat(client, r, c.chunkSize)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("send error, failed to send email from %s [%s:%s], %w", from, c.host, c.port, err)
+		}
+		reuse = ctx.Err() == nil
+		return msg, nil
+	}
+
+	w, err := startData(client)
+	if err != nil {
+		return nil, fmt.Errorf("send error, failed to create data; %w", err)
+	}
+
+	// On failure the writer is left open, so dropping the connection aborts the transaction
+	// instead of delivering a truncated message
+	_, err = msg.WriteTo(limitSize(w, c.maxSize))
+	if err != nil {
+		return nil, fmt.Errorf("send error, failed to send email from %s [%s:%s], %w", from, c.host, c.port, err)
+	}
+
+	// Closing the writer reads the server's verdict on the message
+	if msg.reply, err = endData(client, w); err != nil {
+		return nil, fmt.Errorf("send error, failed to close email writer; %w", err)
+	}
+
+	reuse = ctx.Err() == nil
+	return msg, nil
+}
+
+// prepare adapts the message and envelope to the server's SMTPUTF8 and 8BITMIME support,
+// signs the result, and returns the matching MAIL FROM parameters.
+// Envelope addresses in from and recipients are rewritten in place when downgraded.
+func (c *SMTP) prepare(client *smtp.Client, msg *Message, from *string, recipients []string) (*Message, []string, error) {
+	hasUTF8, _ := client.Extension("SMTPUTF8")
+	has8bit, _ := client.Extension("8BITMIME")
+
+	var params []string
+	useUTF8 := needsSMTPUTF8(*from, recipients, msg)
+	if useUTF8 && hasUTF8 {
+		params = append(params, "SMTPUTF8")
+	}
+	if !msg.bodyASCII() && has8bit {
+		params = append(params, "BODY=8BITMIME")
+	}
+
+	if useUTF8 && !hasUTF8 {
+		var err error
+		if *from, err = downgradeAddress(*from); err != nil {
+			return nil, nil, err
+		}
+		for i, addr := range recipients {
+			if recipients[i], err = downgradeAddress(addr); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	out, err := msg.downgrade(hasUTF8, has8bit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.dkim != nil {
+		if err = c.dkim.signAt(out, c.now()); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return out, params, nil
 }
 
 // ParseBody replaces placeholders in the email body with actual values from the parameters map.
@@ -159,3 +810,12 @@ func (c *SMTP) ParseBody(body string, parameters map[string]interface{}) string
 
 	return body
 }
+
+// recipients returns every envelope recipient of the email.
+func (e Email) recipients() []string {
+	addrs := make([]string, 0, len(e.To)+len(e.Cc)+len(e.Bcc))
+	addrs = append(addrs, e.To...)
+	addrs = append(addrs, e.Cc...)
+	addrs = append(addrs, e.Bcc...)
+	return addrs
+}
@@ -0,0 +1,163 @@
+package smtpmock
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dexterdmonkey/go-smtp"
+)
+
+// Matcher selects emails for assertions and programmed failures.
+type Matcher struct {
+	// Description says what the matcher expects, e.g. `recipient "ana@example.com"`, for failure messages.
+	Description string
+	Match       func(email smtp.Email) bool
+}
+
+// Recipient matches emails with the address in To, Cc, or Bcc, ignoring case.
+func Recipient(addr string) Matcher {
+	return Matcher{
+		Description: fmt.Sprintf("recipient %q", addr),
+		Match: func(email smtp.Email) bool {
+			for _, list := range [][]string{email.To, email.Cc, email.Bcc} {
+				for _, a := range list {
+					if strings.EqualFold(a, addr) {
+						return true
+					}
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Subject matches emails with exactly the subject.
+func Subject(subject string) Matcher {
+	return Matcher{
+		Description: fmt.Sprintf("subject %q", subject),
+		Match:       func(email smtp.Email) bool { return email.Subject == subject },
+	}
+}
+
+// SubjectContains matches emails whose subject contains s.
+func SubjectContains(s string) Matcher {
+	return Matcher{
+		Description: fmt.Sprintf("subject containing %q", s),
+		Match:       func(email smtp.Email) bool { return strings.Contains(email.Subject, s) },
+	}
+}
+
+// BodyContains matches emails whose plain text or HTML body contains s.
+func BodyContains(s string) Matcher {
+	return Matcher{
+		Description: fmt.Sprintf("body containing %q", s),
+		Match: func(email smtp.Email) bool {
+			return strings.Contains(email.Body, s) || strings.Contains(email.HTMLBody, s)
+		},
+	}
+}
+
+// Header matches emails with the header field set to value; the name is matched ignoring case.
+func Header(name, value string) Matcher {
+	return Matcher{
+		Description: fmt.Sprintf("header %s: %q", name, value),
+		Match: func(email smtp.Email) bool {
+			for n, v := range email.Headers {
+				if strings.EqualFold(n, name) && v == value {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Attachment matches emails with an attachment of the filename.
+func Attachment(filename string) Matcher {
+	return Matcher{
+		Description: fmt.Sprintf("attachment %q", filename),
+		Match: func(email smtp.Email) bool {
+			for _, a := range email.Attachments {
+				if a.Filename == filename {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Where matches emails for which fn returns true, described by description.
+func Where(description string, fn func(email smtp.Email) bool) Matcher {
+	return Matcher{Description: description, Match: fn}
+}
+
+// matchAll reports whether the email matches every matcher.
+func matchAll(email smtp.Email, matchers []Matcher) bool {
+	for _, matcher := range matchers {
+		if !matcher.Match(email) {
+			return false
+		}
+	}
+	return true
+}
+
+// describe joins the descriptions of the matchers, e.g. ` with recipient "ana@example.com"`.
+func describe(matchers []Matcher) string {
+	if len(matchers) == 0 {
+		return ""
+	}
+	descriptions := make([]string, len(matchers))
+	for i, matcher := range matchers {
+		descriptions[i] = matcher.Description
+	}
+	return " with " + strings.Join(descriptions, " and ")
+}
+
+// AssertSent fails the test unless an email that matches all matchers was sent, and returns the first one.
+// The failure message lists the emails that were sent.
+func (m *Mailer) AssertSent(t testing.TB, matchers ...Matcher) smtp.Email {
+	t.Helper()
+
+	found := m.Find(matchers...)
+	if len(found) == 0 {
+		t.Errorf("smtpmock: expected an email%s to be sent%s", describe(matchers), m.summary())
+		return smtp.Email{}
+	}
+	return found[0]
+}
+
+// AssertNotSent fails the test when an email that matches all matchers was sent.
+func (m *Mailer) AssertNotSent(t testing.TB, matchers ...Matcher) {
+	t.Helper()
+
+	if found := m.Find(matchers...); len(found) > 0 {
+		t.Errorf("smtpmock: expected no email%s to be sent, found %d%s", describe(matchers), len(found), m.summary())
+	}
+}
+
+// AssertCount fails the test unless exactly n emails that match all matchers were sent.
+func (m *Mailer) AssertCount(t testing.TB, n int, matchers ...Matcher) {
+	t.Helper()
+
+	if found := m.Find(matchers...); len(found) != n {
+		t.Errorf("smtpmock: expected %d emails%s to be sent, found %d%s", n, describe(matchers), len(found), m.summary())
+	}
+}
+
+// summary lists the sent emails for failure messages.
+func (m *Mailer) summary() string {
+	sent := m.Sent()
+	if len(sent) == 0 {
+		return "; nothing was sent"
+	}
+
+	var b strings.Builder
+	b.WriteString("; sent:")
+	for i, email := range sent {
+		recipients := append(append(append([]string(nil), email.To...), email.Cc...), email.Bcc...)
+		fmt.Fprintf(&b, "\n  %d. to %s, subject %q", i+1, strings.Join(recipients, ", "), email.Subject)
+	}
+	return b.String()
+}
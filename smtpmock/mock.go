@@ -0,0 +1,201 @@
+// Package smtpmock provides a recording implementation of smtp.Interface, so code that sends mail can be tested
+// without a server, a client, or a hand-written fake.
+//
+//	mailer := smtpmock.New("noreply@example.com")
+//	signup := NewSignupService(mailer)
+//
+//	signup.Register("ana@example.com")
+//	mailer.AssertSent(t, smtpmock.Recipient("ana@example.com"), smtpmock.SubjectContains("Welcome"))
+package smtpmock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dexterdmonkey/go-smtp"
+)
+
+// Mailer is an smtp.Interface that records the emails sent through it instead of delivering them. Sends can be
+// programmed to fail with FailWith and FailNext. A Mailer is safe for concurrent use.
+type Mailer struct {
+	// SenderAddress, Host, and Port are returned by the getters of smtp.Interface.
+	SenderAddress string
+	Host          string
+	Port          int
+	// SkipValidation records emails that fail validation instead of failing their send like a client.
+	SkipValidation bool
+	// Validation selects the optional checks like smtp.WithValidation does for a client.
+	Validation smtp.Validation
+
+	mu       sync.Mutex
+	sent     []smtp.Email
+	failed   []smtp.Email
+	failures []*failure
+}
+
+// failure makes the sends that match every matcher fail with err, at most remaining times when it is positive.
+type failure struct {
+	err       error
+	matchers  []Matcher
+	remaining int
+}
+
+// New returns a mailer with the given sender address.
+func New(senderAddress string) *Mailer {
+	return &Mailer{SenderAddress: senderAddress, Host: "localhost", Port: 25}
+}
+
+// GetSenderAddress returns the sender address.
+func (m *Mailer) GetSenderAddress() string {
+	return m.SenderAddress
+}
+
+// GetHost returns the host.
+func (m *Mailer) GetHost() string {
+	return m.Host
+}
+
+// GetPort returns the port.
+func (m *Mailer) GetPort() int {
+	return m.Port
+}
+
+// ParseBody replaces the {{key}} placeholders in body like the client does.
+func (m *Mailer) ParseBody(body string, parameters map[string]interface{}) string {
+	for key, value := range parameters {
+		body = strings.ReplaceAll(body, "{{"+key+"}}", fmt.Sprintf("%v", value))
+	}
+	return body
+}
+
+// SendMail records the email, or returns the error of the first programmed failure it matches. Like a client,
+// it fails emails that do not pass smtp.Email.ValidateWith with Validation unless SkipValidation is set.
+func (m *Mailer) SendMail(email smtp.Email) error {
+	return m.SendMailContext(context.Background(), email)
+}
+
+// SendMailContext sends the email like SendMail, and fails without recording it when ctx is done.
+func (m *Mailer) SendMailContext(ctx context.Context, email smtp.Email) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	email = clone(email)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.fail(email)
+	if err == nil && !m.SkipValidation {
+		err = email.ValidateWith(m.Validation)
+	}
+	if err != nil {
+		m.failed = append(m.failed, email)
+		return err
+	}
+	m.sent = append(m.sent, email)
+	return nil
+}
+
+// fail returns the error of the first failure the email matches and counts it down. The caller holds mu.
+func (m *Mailer) fail(email smtp.Email) error {
+	for i, f := range m.failures {
+		if !matchAll(email, f.matchers) {
+			continue
+		}
+		if f.remaining > 0 {
+			f.remaining--
+			if f.remaining == 0 {
+				m.failures = append(m.failures[:i:i], m.failures[i+1:]...)
+			}
+		}
+		return f.err
+	}
+	return nil
+}
+
+// FailWith makes every send that matches all matchers fail with err, e.g. a *textproto.Error with code 550 for
+// a rejected recipient. Without matchers every send fails.
+func (m *Mailer) FailWith(err error, matchers ...Matcher) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failures = append(m.failures, &failure{err: err, matchers: matchers})
+}
+
+// FailNext makes the next n sends that match all matchers fail with err, e.g. to exercise a retry.
+func (m *Mailer) FailNext(n int, err error, matchers ...Matcher) {
+	if n <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failures = append(m.failures, &failure{err: err, matchers: matchers, remaining: n})
+}
+
+// Sent returns the emails sent so far in the order they were sent.
+func (m *Mailer) Sent() []smtp.Email {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]smtp.Email(nil), m.sent...)
+}
+
+// Failed returns the emails whose send failed, in the order they were attempted.
+func (m *Mailer) Failed() []smtp.Email {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]smtp.Email(nil), m.failed...)
+}
+
+// Last returns the email sent last, and false when none was sent.
+func (m *Mailer) Last() (smtp.Email, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.sent) == 0 {
+		return smtp.Email{}, false
+	}
+	return m.sent[len(m.sent)-1], true
+}
+
+// Find returns the sent emails that match all matchers.
+func (m *Mailer) Find(matchers ...Matcher) []smtp.Email {
+	var found []smtp.Email
+	for _, email := range m.Sent() {
+		if matchAll(email, matchers) {
+			found = append(found, email)
+		}
+	}
+	return found
+}
+
+// Reset discards the recorded emails and the programmed failures.
+func (m *Mailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sent, m.failed, m.failures = nil, nil, nil
+}
+
+// clone copies the slices and maps of the email, so that the caller changing them after the send does not
+// change the recorded email.
+func clone(email smtp.Email) smtp.Email {
+	email.To = append([]string(nil), email.To...)
+	email.Cc = append([]string(nil), email.Cc...)
+	email.Bcc = append([]string(nil), email.Bcc...)
+	email.Attachments = append([]smtp.Attachment(nil), email.Attachments...)
+	email.Categories = append([]string(nil), email.Categories...)
+	if email.Headers != nil {
+		headers := make(map[string]string, len(email.Headers))
+		for name, value := range email.Headers {
+			headers[name] = value
+		}
+		email.Headers = headers
+	}
+	return email
+}
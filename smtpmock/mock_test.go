@@ -0,0 +1,171 @@
+package smtpmock_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/dexterdmonkey/go-smtp"
+	"github.com/dexterdmonkey/go-smtp/smtpmock"
+)
+
+// recordingT records the failures of the assert helpers instead of failing the test.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMailerRecords(t *testing.T) {
+	mailer := smtpmock.New("noreply@example.com")
+
+	to := []string{"ana@example.com"}
+	headers := map[string]string{"X-Campaign": "spring"}
+	err := mailer.SendMail(smtp.Email{
+		To:          to,
+		Subject:     "Welcome aboard",
+		Body:        "Hello Ana",
+		Headers:     headers,
+		Attachments: []smtp.Attachment{{Filename: "terms.pdf", Data: []byte("%PDF")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mailer.SendMail(smtp.Email{To: []string{"bob@example.com"}, Subject: "Receipt", HTMLBody: "<p>Paid</p>"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the caller's slices and maps after the send does not change the recorded email
+	to[0] = "eve@example.com"
+	headers["X-Campaign"] = "changed"
+
+	mailer.AssertCount(t, 2)
+	mailer.AssertCount(t, 1, smtpmock.Recipient("ANA@example.com"), smtpmock.Subject("Welcome aboard"))
+	mailer.AssertSent(t, smtpmock.SubjectContains("Welcome"), smtpmock.BodyContains("Ana"), smtpmock.Header("x-campaign", "spring"))
+	mailer.AssertSent(t, smtpmock.Attachment("terms.pdf"))
+	mailer.AssertSent(t, smtpmock.BodyContains("Paid"))
+	mailer.AssertNotSent(t, smtpmock.Recipient("eve@example.com"))
+	if found := mailer.Find(smtpmock.Where("no attachments", func(email smtp.Email) bool { return len(email.Attachments) == 0 })); len(found) != 1 || found[0].Subject != "Receipt" {
+		t.Fatalf("Find returned %d emails, want the receipt", len(found))
+	}
+	if last, ok := mailer.Last(); !ok || last.Subject != "Receipt" {
+		t.Fatalf("Last returned %q, %t; want the receipt", last.Subject, ok)
+	}
+
+	mailer.Reset()
+	if _, ok := mailer.Last(); ok || len(mailer.Sent()) != 0 {
+		t.Fatal("emails left after Reset")
+	}
+}
+
+func TestMailerAssertFailures(t *testing.T) {
+	mailer := smtpmock.New("noreply@example.com")
+	if err := mailer.SendMail(smtp.Email{To: []string{"ana@example.com"}, Subject: "Welcome", Body: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rt := &recordingT{TB: t}
+	mailer.AssertSent(rt, smtpmock.Recipient("bob@example.com"))
+	mailer.AssertNotSent(rt, smtpmock.Subject("Welcome"))
+	mailer.AssertCount(rt, 2)
+	if len(rt.errors) != 3 {
+		t.Fatalf("assert helpers reported %d failures, want 3", len(rt.errors))
+	}
+	if msg := rt.errors[0]; !strings.Contains(msg, `recipient "bob@example.com"`) || !strings.Contains(msg, `to ana@example.com, subject "Welcome"`) {
+		t.Fatalf("failure message does not describe the matcher and the sent emails:\n%s", msg)
+	}
+}
+
+func TestMailerFailWith(t *testing.T) {
+	mailer := smtpmock.New("noreply@example.com")
+	rejected := &textproto.Error{Code: 550, Msg: "5.1.1 mailbox unavailable"}
+	mailer.FailWith(rejected, smtpmock.Recipient("gone@example.com"))
+
+	for i := 0; i < 2; i++ {
+		if err := mailer.SendMail(smtp.Email{To: []string{"gone@example.com"}, Subject: "Hello", Body: "Hi"}); !errors.Is(err, rejected) {
+			t.Fatalf("send %d to the rejected recipient returned %v, want the programmed error", i+1, err)
+		}
+	}
+	if err := mailer.SendMail(smtp.Email{To: []string{"ana@example.com"}, Subject: "Hello", Body: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if failed := mailer.Failed(); len(failed) != 2 {
+		t.Fatalf("recorded %d failed sends, want 2", len(failed))
+	}
+	mailer.AssertCount(t, 1)
+	mailer.AssertNotSent(t, smtpmock.Recipient("gone@example.com"))
+
+	// Reset drops the programmed failures along with the recorded emails
+	mailer.Reset()
+	if err := mailer.SendMail(smtp.Email{To: []string{"gone@example.com"}, Subject: "Hello", Body: "Hi"}); err != nil {
+		t.Fatalf("send after Reset returned %v", err)
+	}
+	if len(mailer.Failed()) != 0 {
+		t.Fatal("failed sends left after Reset")
+	}
+}
+
+func TestMailerFailNext(t *testing.T) {
+	mailer := smtpmock.New("noreply@example.com")
+	busy := &textproto.Error{Code: 421, Msg: "4.3.2 try again later"}
+	mailer.FailNext(2, busy, smtpmock.SubjectContains("Receipt"))
+
+	email := smtp.Email{To: []string{"ana@example.com"}, Subject: "Receipt #42", Body: "Paid"}
+	if err := mailer.SendMail(smtp.Email{To: []string{"ana@example.com"}, Subject: "Welcome", Body: "Hi"}); err != nil {
+		t.Fatalf("send that matches no failure returned %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := mailer.SendMail(email); !errors.Is(err, busy) {
+			t.Fatalf("send %d returned %v, want the programmed error", i+1, err)
+		}
+	}
+	if err := mailer.SendMail(email); err != nil {
+		t.Fatalf("send after the programmed failures returned %v", err)
+	}
+
+	mailer.AssertCount(t, 1, smtpmock.SubjectContains("Receipt"))
+	if failed := mailer.Failed(); len(failed) != 2 {
+		t.Fatalf("recorded %d failed sends, want 2", len(failed))
+	}
+}
+
+func TestMailerValidation(t *testing.T) {
+	mailer := smtpmock.New("noreply@example.com")
+	invalid := smtp.Email{To: []string{"not an address"}, Subject: "Hello", Body: "Hi"}
+
+	var validationErr *smtp.ValidationError
+	if err := mailer.SendMail(invalid); !errors.As(err, &validationErr) {
+		t.Fatalf("send to an invalid address returned %v, want a ValidationError", err)
+	}
+	if len(mailer.Sent()) != 0 || len(mailer.Failed()) != 1 {
+		t.Fatal("invalid email was recorded as sent")
+	}
+
+	mailer.SkipValidation = true
+	if err := mailer.SendMail(invalid); err != nil {
+		t.Fatalf("send with SkipValidation returned %v", err)
+	}
+	mailer.AssertSent(t, smtpmock.Recipient("not an address"))
+}
+
+func TestMailerContext(t *testing.T) {
+	mailer := smtpmock.New("noreply@example.com")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := mailer.SendMailContext(ctx, smtp.Email{To: []string{"ana@example.com"}, Subject: "Hello", Body: "Hi"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("send with a canceled context returned %v", err)
+	}
+	if len(mailer.Sent()) != 0 || len(mailer.Failed()) != 0 {
+		t.Fatal("send with a canceled context was recorded")
+	}
+}
@@ -0,0 +1,223 @@
+package smtptest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dexterdmonkey/go-smtp"
+)
+
+// GoldenTime is the Date of the messages rendered by EML.
+var GoldenTime = time.Date(2024, time.January, 1, 9, 0, 0, 0, time.UTC)
+
+// UpdateEnv names the environment variable that makes AssertGolden write the golden files instead of comparing
+// them, e.g. SMTPTEST_UPDATE=1 go test ./...
+const UpdateEnv = "SMTPTEST_UPDATE"
+
+const (
+	// diffContext is the number of unchanged lines shown around a change.
+	diffContext = 2
+	// maxDiffLines caps a run of removed or added lines shown by AssertGolden.
+	maxDiffLines = 20
+	// maxDiffCells caps the size of the table that aligns the changed lines.
+	maxDiffCells = 4 << 20
+)
+
+// Deterministic returns options that make a client assemble the same message for the same email on every run:
+// the Date is GoldenTime, and Message-IDs, multipart boundaries, and tracking IDs count up from "id1". The
+// count runs for the life of the client, so use a new client for every message that is compared.
+func Deterministic() []smtp.Option {
+	var mu sync.Mutex
+	n := 0
+	return []smtp.Option{
+		smtp.WithClock(func() time.Time { return GoldenTime }),
+		smtp.WithIDGenerator(func() string {
+			mu.Lock()
+			defer mu.Unlock()
+
+			n++
+			return "id" + strconv.Itoa(n)
+		}),
+	}
+}
+
+// EML renders the email as a client for sender with opts would assemble it, made deterministic by the options of
+// Deterministic, and returns it with LF line endings like the messages received by Server. DKIM signing and
+// the downgrades that depend on the server are not applied, see smtp.SMTP.Assemble.
+func EML(sender string, email smtp.Email, opts ...smtp.Option) ([]byte, error) {
+	client, err := smtp.New(sender, "", "localhost", 25, append(opts, Deterministic()...)...)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := client.Assemble(context.Background(), email)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if _, err = msg.WriteTo(&b); err != nil {
+		return nil, err
+	}
+	return bytes.ReplaceAll(b.Bytes(), []byte("\r\n"), []byte("\n")), nil
+}
+
+// AssertGolden compares got with the golden file at path, e.g. "testdata/welcome.eml", and fails t when they
+// differ. The failure lists the semantic differences reported by smtp.DiffMessages and the lines that changed.
+// When the UpdateEnv environment variable is set, the golden file is written instead:
+//
+//	got, err := smtptest.EML("noreply@example.com", welcomeEmail("Ana"))
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	smtptest.AssertGolden(t, "testdata/welcome.eml", got)
+func AssertGolden(t testing.TB, path string, got []byte) {
+	t.Helper()
+
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("smtptest: failed to create golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("smtptest: failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("smtptest: failed to read golden file, run with %s=1 to create it: %v", UpdateEnv, err)
+	}
+	if bytes.Equal(got, want) {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "smtptest: message differs from %s", path)
+	if diffs, err := smtp.DiffMessages(want, got); err == nil && len(diffs) > 0 {
+		b.WriteString("\n\ndifferences (golden != got):")
+		for _, d := range diffs {
+			b.WriteString("\n  " + d.String())
+		}
+	}
+	b.WriteString("\n\n" + lineDiff(string(want), string(got)))
+	fmt.Fprintf(&b, "\n\nrun with %s=1 to update the golden file", UpdateEnv)
+	t.Error(b.String())
+}
+
+// lineDiff returns the changed lines of got against want in hunks with two lines of context, prefixed with
+// - and +. Long runs of changed lines are shortened.
+func lineDiff(want, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+
+	// The common prefix and suffix are trimmed, so the table only covers the changed region
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		start++
+	}
+	end := 0
+	for end < len(a)-start && end < len(b)-start && a[len(a)-1-end] == b[len(b)-1-end] {
+		end++
+	}
+	ma, mb := a[start:len(a)-end], b[start:len(b)-end]
+
+	type op struct {
+		kind byte
+		line string
+		n    int
+	}
+	var ops []op
+	for i := 0; i < start; i++ {
+		ops = append(ops, op{' ', a[i], i + 1})
+	}
+	if len(ma)*len(mb) > maxDiffCells {
+		// Too large to align line by line
+		for i, line := range ma {
+			ops = append(ops, op{'-', line, start + i + 1})
+		}
+		for _, line := range mb {
+			ops = append(ops, op{'+', line, 0})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of ma[i:] and mb[j:]
+		lcs := make([][]int, len(ma)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(mb)+1)
+		}
+		for i := len(ma) - 1; i >= 0; i-- {
+			for j := len(mb) - 1; j >= 0; j-- {
+				if ma[i] == mb[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(ma) || j < len(mb) {
+			switch {
+			case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+				ops = append(ops, op{' ', ma[i], start + i + 1})
+				i, j = i+1, j+1
+			case i < len(ma) && (j == len(mb) || lcs[i+1][j] >= lcs[i][j+1]):
+				ops = append(ops, op{'-', ma[i], start + i + 1})
+				i++
+			default:
+				ops = append(ops, op{'+', mb[j], 0})
+				j++
+			}
+		}
+	}
+	for i := len(a) - end; i < len(a); i++ {
+		ops = append(ops, op{' ', a[i], i + 1})
+	}
+
+	// Keep the changes and the context around them
+	keep := make([]bool, len(ops))
+	for i, o := range ops {
+		if o.kind == ' ' {
+			continue
+		}
+		for k := max(0, i-diffContext); k <= min(len(ops)-1, i+diffContext); k++ {
+			keep[k] = true
+		}
+	}
+
+	var out strings.Builder
+	run := 0
+	for i, o := range ops {
+		if !keep[i] {
+			run = 0
+			continue
+		}
+		if i == 0 || !keep[i-1] {
+			line := o.n
+			for k := i; line == 0 && k < len(ops); k++ {
+				line = ops[k].n
+			}
+			if out.Len() > 0 {
+				out.WriteString("\n")
+			}
+			fmt.Fprintf(&out, "@@ line %d @@", line)
+		}
+		if o.kind != ' ' && i > 0 && ops[i-1].kind == o.kind {
+			run++
+		} else {
+			run = 0
+		}
+		switch {
+		case run < maxDiffLines:
+			out.WriteString("\n" + string(o.kind) + o.line)
+		case run == maxDiffLines:
+			out.WriteString("\n" + string(o.kind) + " ...")
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,245 @@
+// Package smtptest provides an in-process SMTP server for tests, so code that sends mail can be
+// exercised without an external mail catcher.
+package smtptest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dexterdmonkey/go-smtp"
+)
+
+// Message is an email received by the server.
+type Message struct {
+	From string
+	To   []string
+	// Data is the message as sent after DATA, with dot-stuffing removed and line endings converted to LF.
+	Data []byte
+	// Username is the user the client authenticated as, empty without AUTH.
+	Username string
+	// TLS reports whether the message was received over a STARTTLS connection.
+	TLS bool
+}
+
+// Server is an SMTP server listening on a random local port. It advertises STARTTLS with a self-signed
+// certificate and AUTH PLAIN and LOGIN, and records every message it accepts.
+type Server struct {
+	// Addr is the address the server listens on as "127.0.0.1:port".
+	Addr string
+	// Users restricts AUTH to the given username and password pairs. Any credentials are accepted when nil.
+	Users map[string]string
+	// MaxSize is advertised with the SIZE extension when greater than zero, and larger messages are rejected.
+	MaxSize int
+
+	server *smtp.Server
+	cert   *x509.Certificate
+	done   chan struct{}
+
+	mu       sync.Mutex
+	messages []Message
+	received chan struct{}
+}
+
+// NewServer starts and returns a new server. The caller should call Close when finished.
+func NewServer() *Server {
+	s := NewUnstartedServer()
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a new server that is not yet listening, so its fields can be set before Start.
+func NewUnstartedServer() *Server {
+	return &Server{received: make(chan struct{}, 1)}
+}
+
+// Start starts the server. It panics when the server cannot listen, like httptest.
+func (s *Server) Start() {
+	if s.server != nil {
+		panic("smtptest: server already started")
+	}
+
+	cert, config, err := selfSigned()
+	if err != nil {
+		panic(fmt.Sprintf("smtptest: failed to create certificate: %v", err))
+	}
+	s.cert = cert
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("smtptest: failed to listen: %v", err))
+	}
+	s.Addr = ln.Addr().String()
+
+	s.server = &smtp.Server{
+		Domain:            "smtptest",
+		Backend:           smtp.BackendFunc(s.newSession),
+		TLSConfig:         config,
+		AllowInsecureAuth: true,
+		MaxMessageBytes:   int64(s.MaxSize),
+	}
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		s.server.Serve(ln)
+	}()
+}
+
+// Host returns the host the server listens on.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr)
+	return host
+}
+
+// Port returns the port the server listens on.
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.Addr)
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+// Certificate returns the self-signed certificate presented on STARTTLS.
+func (s *Server) Certificate() *x509.Certificate {
+	return s.cert
+}
+
+// Messages returns the messages received so far in the order they were accepted.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+// Reset discards the received messages.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = nil
+}
+
+// Wait blocks until at least n messages were received and returns them, or fails after timeout.
+func (s *Server) Wait(n int, timeout time.Duration) ([]Message, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		if messages := s.Messages(); len(messages) >= n {
+			return messages, nil
+		}
+		select {
+		case <-s.received:
+		case <-deadline.C:
+			return s.Messages(), fmt.Errorf("smtptest: received %d of %d messages within %s", len(s.Messages()), n, timeout)
+		}
+	}
+}
+
+// Close stops the server, closes open sessions, and waits for them to end.
+func (s *Server) Close() {
+	if s.server == nil {
+		return
+	}
+	s.server.Close()
+	<-s.done
+}
+
+func (s *Server) newSession(conn *smtp.ServerConn) (smtp.Session, error) {
+	return &session{server: s, conn: conn}, nil
+}
+
+func (s *Server) record(msg Message) {
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+
+	select {
+	case s.received <- struct{}{}:
+	default:
+	}
+}
+
+// session records the transactions of a single client connection.
+type session struct {
+	server *Server
+	conn   *smtp.ServerConn
+	from   string
+	to     []string
+}
+
+func (sess *session) Auth(username, password string) error {
+	if users := sess.server.Users; users != nil {
+		if expected, ok := users[username]; !ok || expected != password {
+			return &smtp.ReplyError{Code: 535, EnhancedCode: "5.7.8", Message: "authentication credentials invalid"}
+		}
+	}
+	return nil
+}
+
+func (sess *session) Mail(from string, opts smtp.MailOptions) error {
+	sess.from = from
+	return nil
+}
+
+func (sess *session) Rcpt(to string) error {
+	sess.to = append(sess.to, to)
+	return nil
+}
+
+func (sess *session) Data(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	sess.server.record(Message{From: sess.from, To: sess.to, Data: data, Username: sess.conn.Username, TLS: sess.conn.TLS != nil})
+	return nil
+}
+
+func (sess *session) Reset() {
+	sess.from, sess.to = "", nil
+}
+
+func (sess *session) Logout() error {
+	return nil
+}
+
+// selfSigned creates a certificate for localhost and 127.0.0.1.
+func selfSigned() (*x509.Certificate, *tls.Config, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "smtptest"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}}}
+	return cert, config, nil
+}
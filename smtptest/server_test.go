@@ -0,0 +1,181 @@
+package smtptest_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	netsmtp "net/smtp"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dexterdmonkey/go-smtp"
+	"github.com/dexterdmonkey/go-smtp/smtptest"
+)
+
+func newClient(t *testing.T, srv *smtptest.Server, username, password string, opts ...smtp.Option) *smtp.SMTP {
+	t.Helper()
+
+	mail, err := smtp.New(username, password, srv.Host(), srv.Port(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mail.Close() })
+	return mail
+}
+
+func TestServerReceives(t *testing.T) {
+	srv := smtptest.NewServer()
+	t.Cleanup(srv.Close)
+	mail := newClient(t, srv, "app@example.com", "secret")
+
+	err := mail.SendMail(smtp.Email{To: []string{"ada@example.com"}, Bcc: []string{"audit@example.com"}, Subject: "Hello", Body: "Hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := srv.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("received %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.From != "app@example.com" || strings.Join(msg.To, ",") != "ada@example.com,audit@example.com" {
+		t.Fatalf("envelope is %s -> %v", msg.From, msg.To)
+	}
+	if msg.Username != "app@example.com" || !msg.TLS {
+		t.Fatalf("message received as %q, TLS %t; want authenticated over STARTTLS", msg.Username, msg.TLS)
+	}
+	if !strings.Contains(string(msg.Data), "Subject: Hello\n") || strings.Contains(string(msg.Data), "\r\n") {
+		t.Fatalf("data is not the message with LF line endings:\n%s", msg.Data)
+	}
+
+	srv.Reset()
+	if messages = srv.Messages(); len(messages) != 0 {
+		t.Fatalf("%d messages left after Reset", len(messages))
+	}
+}
+
+func TestServerAuth(t *testing.T) {
+	srv := smtptest.NewUnstartedServer()
+	srv.Users = map[string]string{"app@example.com": "secret"}
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	email := smtp.Email{To: []string{"ada@example.com"}, Subject: "Hello", Body: "Hi"}
+
+	err := newClient(t, srv, "app@example.com", "wrong").SendMail(email)
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) || protoErr.Code != 535 {
+		t.Fatalf("send with a wrong password returned %v, want 535", err)
+	}
+	if err = newClient(t, srv, "app@example.com", "secret").SendMail(email); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := srv.Messages()
+	if len(messages) != 1 || messages[0].Username != "app@example.com" {
+		t.Fatalf("received %d messages, want one from the authenticated user", len(messages))
+	}
+}
+
+func TestServerStartTLS(t *testing.T) {
+	srv := smtptest.NewServer()
+	t.Cleanup(srv.Close)
+
+	// The self-signed certificate is valid for the listening address once it is trusted
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	client, err := netsmtp.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); !ok {
+		t.Fatal("server does not advertise STARTTLS")
+	}
+	if err = client.StartTLS(&tls.Config{RootCAs: roots, ServerName: srv.Host()}); err != nil {
+		t.Fatal(err)
+	}
+	state, ok := client.TLSConnectionState()
+	if !ok || len(state.PeerCertificates) == 0 || !state.PeerCertificates[0].Equal(srv.Certificate()) {
+		t.Fatal("connection does not present the server certificate")
+	}
+	if err = client.Quit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerMaxSize(t *testing.T) {
+	srv := smtptest.NewUnstartedServer()
+	srv.MaxSize = 1024
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	big := strings.Repeat("0123456789abcdef\n", 200)
+
+	// The client reads the SIZE extension and fails before sending
+	err := newClient(t, srv, "app@example.com", "secret").SendMail(smtp.Email{To: []string{"ada@example.com"}, Subject: "Big", Body: big})
+	var sizeErr *smtp.MessageSizeError
+	if !errors.As(err, &sizeErr) || sizeErr.Limit != 1024 {
+		t.Fatalf("send over SIZE returned %v, want a MessageSizeError with the advertised limit", err)
+	}
+
+	// A client that ignores SIZE is rejected after DATA
+	client, err := netsmtp.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err = client.Mail("app@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Rcpt("ada@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("Subject: Big\r\n\r\n" + big))
+	var protoErr *textproto.Error
+	if err = w.Close(); !errors.As(err, &protoErr) || protoErr.Code != 552 {
+		t.Fatalf("oversized DATA returned %v, want 552", err)
+	}
+
+	if messages := srv.Messages(); len(messages) != 0 {
+		t.Fatalf("server recorded %d oversized messages", len(messages))
+	}
+}
+
+func TestServerWait(t *testing.T) {
+	srv := smtptest.NewServer()
+	t.Cleanup(srv.Close)
+	mail := newClient(t, srv, "app@example.com", "secret")
+
+	messages, err := srv.Wait(1, 20*time.Millisecond)
+	if err == nil || len(messages) != 0 {
+		t.Fatalf("Wait without messages returned %d messages and %v, want a timeout", len(messages), err)
+	}
+
+	errs := make(chan error, 2)
+	for _, subject := range []string{"First", "Second"} {
+		go func(subject string) {
+			time.Sleep(10 * time.Millisecond)
+			errs <- mail.SendMail(smtp.Email{To: []string{"ada@example.com"}, Subject: subject, Body: "Hi"})
+		}(subject)
+	}
+
+	if messages, err = srv.Wait(2, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Wait returned %d messages, want 2", len(messages))
+	}
+	for i := 0; i < 2; i++ {
+		if err = <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
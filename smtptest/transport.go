@@ -0,0 +1,61 @@
+package smtptest
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/dexterdmonkey/go-smtp"
+)
+
+// Transport is an smtp.Transport that records messages instead of delivering them, so the assembly of a
+// client can be tested without a server:
+//
+//	transport := &smtptest.Transport{}
+//	client, err := smtp.New("noreply@example.com", "", "", 0, smtp.WithTransport(transport))
+type Transport struct {
+	// Fail returns the error of a delivery, e.g. a temporary one to exercise retries. Deliveries succeed and are
+	// recorded when it is nil or returns nil.
+	Fail func(from string, recipients []string, msg *smtp.Message) error
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Deliver records the message, unless Fail returns an error.
+func (t *Transport) Deliver(ctx context.Context, from string, recipients []string, msg *smtp.Message) error {
+	if t.Fail != nil {
+		if err := t.Fail(from, recipients, msg); err != nil {
+			return err
+		}
+	}
+
+	var b bytes.Buffer
+	if _, err := msg.WriteTo(&b); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Data has LF line endings like the messages received by Server
+	data := bytes.ReplaceAll(b.Bytes(), []byte("\r\n"), []byte("\n"))
+	t.messages = append(t.messages, Message{From: from, To: append([]string(nil), recipients...), Data: data})
+	return nil
+}
+
+// Messages returns the recorded messages in the order they were delivered.
+func (t *Transport) Messages() []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]Message(nil), t.messages...)
+}
+
+// Reset discards the recorded messages.
+func (t *Transport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messages = nil
+}
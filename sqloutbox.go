@@ -0,0 +1,207 @@
+package smtp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLDialect selects the SQL flavor of a SQLOutbox.
+type SQLDialect int
+
+// SQL dialects.
+const (
+	SQLDialectPostgres SQLDialect = iota
+	SQLDialectMySQL
+	SQLDialectSQLite
+)
+
+// Outbox message states.
+const (
+	outboxPending = "pending"
+	outboxSent    = "sent"
+	outboxFailed  = "failed"
+)
+
+// SQLOutbox is an OutboxStore in a database/sql table. Emails are stored in their JSON format, see
+// Email.MarshalJSON, and times as Unix milliseconds, so the table works the same with every driver.
+// Claims use a conditional update per row instead of row locks, so dispatchers on several hosts can share
+// the table. The library has no dependencies; import the driver in the application.
+type SQLOutbox struct {
+	db      *sql.DB
+	table   string
+	dialect SQLDialect
+}
+
+// NewSQLOutbox initializes and returns an outbox stored in table, e.g. "email_outbox". The table name is used
+// in the queries as is. Create the table with CreateTable or an equivalent migration.
+func NewSQLOutbox(db *sql.DB, table string, dialect SQLDialect) *SQLOutbox {
+	return &SQLOutbox{db: db, table: table, dialect: dialect}
+}
+
+// CreateTable creates the table of the outbox unless it exists. Large installations should add an index on
+// (status, next_attempt_at).
+func (o *SQLOutbox) CreateTable(ctx context.Context) error {
+	text := "TEXT"
+	if o.dialect == SQLDialectMySQL {
+		text = "LONGTEXT"
+	}
+
+	_, err := o.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+o.table+` (
+	id VARCHAR(32) NOT NULL PRIMARY KEY,
+	email `+text+` NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error `+text+`,
+	created_at BIGINT NOT NULL,
+	next_attempt_at BIGINT NOT NULL,
+	claimed_until BIGINT NOT NULL DEFAULT 0,
+	sent_at BIGINT NOT NULL DEFAULT 0
+)`)
+	if err != nil {
+		return fmt.Errorf("outbox error, failed to create table %s; %w", o.table, err)
+	}
+	return nil
+}
+
+// Save stores the email as pending and returns its ID.
+func (o *SQLOutbox) Save(ctx context.Context, email Email) (string, error) {
+	return o.save(ctx, o.db, email)
+}
+
+// SaveTx stores the email as pending within tx, so it is only sent when tx commits, e.g. together with the
+// order it confirms.
+func (o *SQLOutbox) SaveTx(ctx context.Context, tx *sql.Tx, email Email) (string, error) {
+	return o.save(ctx, tx, email)
+}
+
+func (o *SQLOutbox) save(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}, email Email) (string, error) {
+	data, err := json.Marshal(email)
+	if err != nil {
+		return "", fmt.Errorf("outbox error, failed to encode email; %w", err)
+	}
+	id, err := newID()
+	if err != nil {
+		return "", fmt.Errorf("outbox error, failed to generate id; %w", err)
+	}
+
+	now := time.Now().UnixMilli()
+	_, err = db.ExecContext(ctx, o.query("INSERT INTO "+o.table+" (id, email, status, created_at, next_attempt_at) VALUES (?, ?, ?, ?, ?)"),
+		id, string(data), outboxPending, now, now)
+	if err != nil {
+		return "", fmt.Errorf("outbox error, failed to save email; %w", err)
+	}
+	return id, nil
+}
+
+// Claim returns up to limit pending messages that are due, oldest first, and reserves them for lease.
+// A message that no longer decodes is marked failed and skipped.
+func (o *SQLOutbox) Claim(ctx context.Context, limit int, lease time.Duration) ([]OutboxMessage, error) {
+	now := time.Now()
+	rows, err := o.db.QueryContext(ctx, o.query("SELECT id, email, attempts, last_error, created_at FROM "+o.table+
+		" WHERE status = ? AND next_attempt_at <= ? AND claimed_until <= ? ORDER BY next_attempt_at LIMIT ?"),
+		outboxPending, now.UnixMilli(), now.UnixMilli(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("outbox error, failed to query due emails; %w", err)
+	}
+
+	type candidate struct {
+		msg  OutboxMessage
+		data string
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		var lastError sql.NullString
+		var created int64
+		if err = rows.Scan(&c.msg.ID, &c.data, &c.msg.Attempts, &lastError, &created); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("outbox error, failed to read due emails; %w", err)
+		}
+		c.msg.LastError, c.msg.CreatedAt = lastError.String, time.UnixMilli(created)
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("outbox error, failed to read due emails; %w", err)
+	}
+
+	var claimed []OutboxMessage
+	for _, c := range candidates {
+		// Another dispatcher may have claimed the row since the query; only one update succeeds
+		res, err := o.db.ExecContext(ctx, o.query("UPDATE "+o.table+" SET claimed_until = ?, attempts = attempts + 1"+
+			" WHERE id = ? AND status = ? AND claimed_until <= ?"),
+			now.Add(lease).UnixMilli(), c.msg.ID, outboxPending, now.UnixMilli())
+		if err != nil {
+			return claimed, fmt.Errorf("outbox error, failed to claim %s; %w", c.msg.ID, err)
+		}
+		if n, err := res.RowsAffected(); err != nil || n != 1 {
+			continue
+		}
+
+		if err = json.Unmarshal([]byte(c.data), &c.msg.Email); err != nil {
+			if err = o.MarkFailed(ctx, c.msg.ID, fmt.Errorf("outbox error, failed to decode email; %w", err), time.Time{}); err != nil {
+				return claimed, err
+			}
+			continue
+		}
+		c.msg.Attempts++
+		claimed = append(claimed, c.msg)
+	}
+	return claimed, nil
+}
+
+// MarkSent records that the message was delivered.
+func (o *SQLOutbox) MarkSent(ctx context.Context, id string) error {
+	_, err := o.db.ExecContext(ctx, o.query("UPDATE "+o.table+" SET status = ?, sent_at = ?, claimed_until = 0 WHERE id = ?"),
+		outboxSent, time.Now().UnixMilli(), id)
+	if err != nil {
+		return fmt.Errorf("outbox error, failed to mark %s sent; %w", id, err)
+	}
+	return nil
+}
+
+// MarkFailed records the failed attempt and schedules the next one at retryAt, or marks the message failed for
+// good when retryAt is zero.
+func (o *SQLOutbox) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	status, next := outboxPending, retryAt.UnixMilli()
+	if retryAt.IsZero() {
+		status, next = outboxFailed, 0
+	}
+	var lastError string
+	if cause != nil {
+		lastError = cause.Error()
+	}
+
+	_, err := o.db.ExecContext(ctx, o.query("UPDATE "+o.table+" SET status = ?, next_attempt_at = ?, claimed_until = 0, last_error = ? WHERE id = ?"),
+		status, next, lastError, id)
+	if err != nil {
+		return fmt.Errorf("outbox error, failed to mark %s failed; %w", id, err)
+	}
+	return nil
+}
+
+// query rewrites the ? placeholders of q for the dialect.
+func (o *SQLOutbox) query(q string) string {
+	if o.dialect != SQLDialectPostgres {
+		return q
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
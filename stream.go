@@ -0,0 +1,434 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// segment is a piece of a message body: literal text, or content that is encoded while the message is
+// written, so that the encoded message is never held in memory as a whole.
+type segment struct {
+	text string
+	// enc is the encoding of content held in text or data; literal text has none
+	enc  Encoding
+	data []byte
+	open func() (io.ReadCloser, error)
+	// size is the length of the attachment content, or 0 when unknown.
+	size    int64
+	oneShot bool
+
+	// raw content is copied as is with CRLF line endings instead of being encoded; size is then its size
+	// on the wire and ascii tells whether it is 7-bit
+	raw   bool
+	ascii bool
+}
+
+// AttachFile returns an attachment that streams the file at path instead of holding it in memory.
+// The file is opened again for every delivery attempt.
+func AttachFile(path string) (Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("attachment error, failed to stat %s; %w", path, err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("attachment error, failed to resolve %s; %w", path, err)
+	}
+
+	return Attachment{
+		Filename:    filepath.Base(path),
+		ContentType: mime.TypeByExtension(filepath.Ext(path)),
+		Open:        func() (io.ReadCloser, error) { return os.Open(path) },
+		Size:        info.Size(),
+		Ref:         fileRef(abs),
+	}, nil
+}
+
+// AttachReader returns an attachment that streams r. A reader can only be consumed once, so a send that needs
+// a second attempt, or that reads the message twice for DKIM signing, fails; prefer AttachFile or an Open
+// function that can start over. The content is left out of the SIZE parameter since it cannot be measured.
+func AttachReader(filename string, r io.Reader) Attachment {
+	var once sync.Once
+	return Attachment{
+		Filename: filename,
+		oneShot:  true,
+		Open: func() (io.ReadCloser, error) {
+			var rc io.ReadCloser
+			once.Do(func() { rc = io.NopCloser(r) })
+			if rc == nil {
+				return nil, fmt.Errorf("attachment error, reader of %s was already consumed", filename)
+			}
+			return rc, nil
+		},
+	}
+}
+
+// streamed reports whether the body of the message is written from segments rather than Body.
+func (m *Message) streamed() bool {
+	return m.segments != nil
+}
+
+// writeBody writes the body of the message to w with CRLF line endings, encoding streamed attachments on the fly.
+func (m *Message) writeBody(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	if !m.streamed() {
+		crlf := &crlfWriter{w: cw}
+		if _, err := io.WriteString(crlf, m.Body); err != nil {
+			return cw.n, err
+		}
+		return cw.n, crlf.flush()
+	}
+
+	for _, seg := range m.segments {
+		if err := seg.encode(cw); err != nil {
+			return cw.n, err
+		}
+	}
+	return cw.n, nil
+}
+
+// literal reports whether the segment is text written as is.
+func (s segment) literal() bool {
+	return s.open == nil && s.enc == ""
+}
+
+// content returns the content of the segment and whether it is text, whose line endings are converted to CRLF
+// before it is encoded.
+func (s segment) content() (io.ReadCloser, bool, error) {
+	switch {
+	case s.open != nil:
+		r, err := s.open()
+		if err != nil {
+			return nil, false, fmt.Errorf("attachment error, failed to open content; %w", err)
+		}
+		return r, s.raw, nil
+	case s.data != nil:
+		// Attachment data is binary unless it is encoded as text
+		return io.NopCloser(bytes.NewReader(s.data)), s.enc != EncodingBase64, nil
+	default:
+		// LimitReader hides WriteTo, so the text is copied in small chunks instead of being converted to a
+		// []byte as a whole
+		return io.NopCloser(io.LimitReader(strings.NewReader(s.text), int64(len(s.text)))), true, nil
+	}
+}
+
+// encode writes the segment: literal text as is, raw content with its line endings converted to CRLF,
+// and other content with its encoding, base64 wrapped at 76 characters per line.
+func (s segment) encode(w io.Writer) error {
+	if s.literal() {
+		_, err := io.WriteString(w, s.text)
+		return err
+	}
+
+	r, text, err := s.content()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if s.raw {
+		cw := &crlfWriter{w: w}
+		if _, err = io.Copy(cw, r); err != nil {
+			return fmt.Errorf("send error, failed to stream message body; %w", err)
+		}
+		return cw.flush()
+	}
+
+	switch s.enc {
+	case EncodingQuotedPrintable:
+		qw := quotedprintable.NewWriter(w)
+		if _, err = io.Copy(qw, r); err != nil {
+			return fmt.Errorf("attachment error, failed to stream content; %w", err)
+		}
+		if err = qw.Close(); err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, "\r\n")
+		return err
+	case Encoding7Bit, Encoding8Bit:
+		cw := &crlfWriter{w: w}
+		if _, err = io.Copy(cw, r); err != nil {
+			return fmt.Errorf("attachment error, failed to stream content; %w", err)
+		}
+		if err = cw.flush(); err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, "\r\n")
+		return err
+	}
+
+	lw := &lineWrapper{w: w}
+	enc := base64.NewEncoder(base64.StdEncoding, lw)
+	if text {
+		cw := &crlfWriter{w: enc}
+		if _, err = io.Copy(cw, r); err == nil {
+			err = cw.flush()
+		}
+	} else {
+		_, err = io.Copy(enc, r)
+	}
+	if err != nil {
+		return fmt.Errorf("attachment error, failed to stream content; %w", err)
+	}
+	if err = enc.Close(); err != nil {
+		return err
+	}
+	if lw.col > 0 {
+		_, err = io.WriteString(w, "\r\n")
+	}
+	return err
+}
+
+// encodedSize returns the length of the base64 encoded content including line breaks.
+// The content is read once to measure it when its size is unknown, unless it can only be read once.
+func (s segment) encodedSize() int64 {
+	n := s.size
+	if s.raw {
+		return n
+	}
+	if n <= 0 && s.oneShot {
+		return 0
+	}
+	if n <= 0 {
+		r, err := s.open()
+		if err != nil {
+			return 0
+		}
+		n, _ = io.Copy(io.Discard, r)
+		r.Close()
+	}
+	return base64Size(n)
+}
+
+// base64Size returns the length of n bytes encoded as base64 wrapped at 76 characters per line.
+func base64Size(n int64) int64 {
+	encoded := 4 * ((n + 2) / 3)
+	lines := (encoded + 75) / 76
+	return encoded + 2*lines
+}
+
+// bodyASCII reports whether the body of the message is 7-bit.
+func (m *Message) bodyASCII() bool {
+	if !m.streamed() {
+		return isASCII(m.Body)
+	}
+	for _, seg := range m.segments {
+		switch {
+		case seg.raw:
+			if !seg.ascii {
+				return false
+			}
+		case seg.literal() || seg.enc == Encoding7Bit || seg.enc == Encoding8Bit:
+			if !isASCII(seg.text) || !isASCII(string(seg.data)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// reader returns the message in .eml format as a stream, failing with a MessageSizeError once it would exceed
+// maxSize when greater than zero. The caller must close it to release the writer.
+func (m *Message) reader(maxSize int64) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := m.WriteTo(limitSize(pw, maxSize))
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// bodyHash returns the SHA-256 hash of the relaxed canonical body for DKIM.
+func (m *Message) bodyHash() ([]byte, error) {
+	h := &relaxedBodyWriter{h: sha256.New()}
+	if _, err := m.writeBody(h); err != nil {
+		return nil, err
+	}
+	h.finish()
+	return h.h.Sum(nil), nil
+}
+
+// relaxedBodyWriter applies the relaxed body canonicalization of RFC 6376 section 3.4.4 to a stream and
+// hashes the result.
+type relaxedBodyWriter struct {
+	h hash.Hash
+	// line holds the current incomplete line and blank counts the empty lines not yet written.
+	line  []byte
+	blank int
+}
+
+func (r *relaxedBodyWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			r.line = append(r.line, p...)
+			break
+		}
+		r.line = append(r.line, p[:i]...)
+		r.endLine()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// endLine canonicalizes the current line; empty lines are held back since trailing ones are dropped.
+func (r *relaxedBodyWriter) endLine() {
+	line := bytes.TrimSuffix(r.line, []byte("\r"))
+	canonical := bytes.TrimRight([]byte(compressWSP(string(line))), " ")
+	r.line = r.line[:0]
+
+	if len(canonical) == 0 {
+		r.blank++
+		return
+	}
+	for ; r.blank > 0; r.blank-- {
+		r.h.Write([]byte("\r\n"))
+	}
+	r.h.Write(canonical)
+	r.h.Write([]byte("\r\n"))
+}
+
+// finish handles a final line without a line break.
+func (r *relaxedBodyWriter) finish() {
+	if len(r.line) != 0 {
+		r.endLine()
+	}
+}
+
+// crlf is the line ending written by the writers below, held in a variable so it is not allocated for each line.
+var crlf = []byte("\r\n")
+
+// lineWrapper inserts CRLF after every 76 characters.
+type lineWrapper struct {
+	w   io.Writer
+	col int
+}
+
+func (l *lineWrapper) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(76-l.col, len(p))
+		if _, err := l.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		l.col += n
+		p = p[n:]
+
+		if l.col == 76 {
+			if _, err := l.w.Write(crlf); err != nil {
+				return written, err
+			}
+			l.col = 0
+		}
+	}
+	return written, nil
+}
+
+// crlfWriter converts bare CR and LF line endings to CRLF.
+type crlfWriter struct {
+	w  io.Writer
+	cr bool
+}
+
+// Write passes the text between line endings through as is, so that it does not copy p.
+func (c *crlfWriter) Write(p []byte) (int, error) {
+	start := 0
+	for i, ch := range p {
+		if ch != '\r' && ch != '\n' {
+			if c.cr {
+				// A bare CR ended the line before
+				if _, err := c.w.Write(crlf); err != nil {
+					return 0, err
+				}
+				c.cr = false
+			}
+			continue
+		}
+
+		if i > start {
+			if _, err := c.w.Write(p[start:i]); err != nil {
+				return 0, err
+			}
+		}
+		start = i + 1
+		if ch == '\r' {
+			if c.cr {
+				if _, err := c.w.Write(crlf); err != nil {
+					return 0, err
+				}
+			}
+			c.cr = true
+			continue
+		}
+		c.cr = false
+		if _, err := c.w.Write(crlf); err != nil {
+			return 0, err
+		}
+	}
+	if start < len(p) {
+		if _, err := c.w.Write(p[start:]); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flush ends a trailing bare CR.
+func (c *crlfWriter) flush() error {
+	if !c.cr {
+		return nil
+	}
+	c.cr = false
+	_, err := io.WriteString(c.w, "\r\n")
+	return err
+}
+
+// rawStats measures content written with CRLF line endings: its size on the wire including dot-stuffing
+// and whether it is 7-bit.
+type rawStats struct {
+	size    int64
+	ascii   bool
+	midLine bool
+}
+
+func (r *rawStats) Write(p []byte) (int, error) {
+	for _, ch := range p {
+		if !r.midLine && ch == '.' {
+			r.size++
+		}
+		if ch >= 0x80 {
+			r.ascii = false
+		}
+		r.midLine = ch != '\n'
+	}
+	r.size += int64(len(p))
+	return len(p), nil
+}
+
+// countingWriter counts the bytes written through it and remembers the last one.
+type countingWriter struct {
+	w    io.Writer
+	n    int64
+	last byte
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if n > 0 {
+		c.last = p[n-1]
+	}
+	return n, err
+}
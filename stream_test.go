@@ -0,0 +1,82 @@
+package smtp
+
+import (
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// benchmarkEmails returns emails with a text and HTML body and attachments of the given size, both held in
+// memory and streamed from a file.
+func benchmarkEmails(b *testing.B, size int) map[string]Email {
+	b.Helper()
+
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		b.Fatal(err)
+	}
+	path := filepath.Join(b.TempDir(), "attachment.bin")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		b.Fatal(err)
+	}
+	file, err := AttachFile(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	email := Email{
+		To:       []string{"user@example.com"},
+		Subject:  "Quarterly report",
+		Body:     strings.Repeat("The numbers for this quarter are attached.\n", 200),
+		HTMLBody: strings.Repeat("<p>The numbers for this quarter are attached.</p>\n", 200),
+	}
+	inMemory, streamed := email, email
+	inMemory.Attachments = []Attachment{{Filename: "report.bin", Data: data}}
+	streamed.Attachments = []Attachment{file}
+
+	return map[string]Email{"body": email, "data": inMemory, "file": streamed}
+}
+
+func BenchmarkBuildMessage(b *testing.B) {
+	c, err := New("sender@example.com", "", "localhost", 25)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	emails := benchmarkEmails(b, 10<<20)
+	for _, name := range []string{"body", "data", "file"} {
+		email := emails[name]
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				c.buildMessage(email)
+			}
+		})
+	}
+}
+
+func BenchmarkWriteTo(b *testing.B) {
+	c, err := New("sender@example.com", "", "localhost", 25)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	emails := benchmarkEmails(b, 10<<20)
+	for _, name := range []string{"body", "data", "file"} {
+		email := emails[name]
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				msg := c.buildMessage(email)
+				n, err := msg.WriteTo(io.Discard)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(n)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package smtp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SuppressionList defines the methods that any suppression backend must implement.
+type SuppressionList interface {
+	Suppressed(addr string) (bool, error)
+}
+
+// filterSuppressed removes suppressed recipients from the email and returns them.
+func (c *SMTP) filterSuppressed(email Email) (Email, []string, error) {
+	if c.suppression == nil {
+		return email, nil, nil
+	}
+
+	var skipped []string
+	filter := func(addrs []string) ([]string, error) {
+		var kept []string
+		for _, addr := range addrs {
+			suppressed, err := c.suppression.Suppressed(addr)
+			if err != nil {
+				return nil, fmt.Errorf("suppression error, failed to check %s; %w", addr, err)
+			}
+			if suppressed {
+				skipped = append(skipped, addr)
+			} else {
+				kept = append(kept, addr)
+			}
+		}
+		return kept, nil
+	}
+
+	var err error
+	if email.To, err = filter(email.To); err != nil {
+		return email, nil, err
+	}
+	if email.Cc, err = filter(email.Cc); err != nil {
+		return email, nil, err
+	}
+	if email.Bcc, err = filter(email.Bcc); err != nil {
+		return email, nil, err
+	}
+	return email, skipped, nil
+}
+
+// SuppressionStore is a suppression list that addresses can be added to, e.g. by the bounce poller.
+type SuppressionStore interface {
+	SuppressionList
+	Suppress(addr, reason string) error
+}
+
+// MemorySuppressionList keeps suppressed addresses in memory; the list is lost on restart.
+type MemorySuppressionList struct {
+	mu      sync.Mutex
+	entries map[string]string
+}
+
+// NewMemorySuppressionList initializes and returns an empty in-memory suppression list.
+func NewMemorySuppressionList() *MemorySuppressionList {
+	return &MemorySuppressionList{entries: map[string]string{}}
+}
+
+// Suppressed reports whether the address is on the list. Addresses are compared case-insensitively.
+func (l *MemorySuppressionList) Suppressed(addr string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, ok := l.entries[strings.ToLower(addr)]
+	return ok, nil
+}
+
+// Suppress adds the address to the list with the reason it was suppressed.
+func (l *MemorySuppressionList) Suppress(addr, reason string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[strings.ToLower(addr)] = reason
+	return nil
+}
+
+// Reason returns why the address was suppressed and whether it is on the list.
+func (l *MemorySuppressionList) Reason(addr string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	reason, ok := l.entries[strings.ToLower(addr)]
+	return reason, ok
+}
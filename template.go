@@ -0,0 +1,251 @@
+package smtp
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Template represents a reusable email whose subject and body contain {{key}} placeholders.
+// The functions date, time, datetime, number, and currency format a parameter in the email's locale,
+// e.g. {{date due}}, {{number count 0}}, or {{currency total EUR}}; {{bidi name}} isolates a parameter from
+// the surrounding text direction, and {{dir}}, {{start}}, and {{end}} help with direction-aware layout.
+type Template struct {
+	Subject string
+	Body    string
+	// Schema declares the parameters of the template. When set, rendering fails if a required parameter is
+	// missing, a parameter has the wrong type, or a placeholder is not declared.
+	Schema map[string]Param
+}
+
+// ParamType is the expected type of a template parameter.
+type ParamType int
+
+// Parameter types.
+const (
+	// ParamAny accepts a value of any type.
+	ParamAny ParamType = iota
+	// ParamString accepts strings.
+	ParamString
+	// ParamInt accepts signed and unsigned integers.
+	ParamInt
+	// ParamFloat accepts floating point numbers and integers.
+	ParamFloat
+	// ParamBool accepts booleans.
+	ParamBool
+	// ParamTime accepts time.Time values.
+	ParamTime
+)
+
+// String returns the name of the type.
+func (t ParamType) String() string {
+	switch t {
+	case ParamString:
+		return "string"
+	case ParamInt:
+		return "int"
+	case ParamFloat:
+		return "float"
+	case ParamBool:
+		return "bool"
+	case ParamTime:
+		return "time"
+	default:
+		return "any"
+	}
+}
+
+// accepts reports whether v is a valid value of the type.
+func (t ParamType) accepts(v interface{}) bool {
+	if _, ok := v.(time.Time); ok {
+		return t == ParamAny || t == ParamTime
+	}
+
+	kind := reflect.ValueOf(v).Kind()
+	switch t {
+	case ParamString:
+		return kind == reflect.String
+	case ParamInt:
+		return kind >= reflect.Int && kind <= reflect.Uintptr
+	case ParamFloat:
+		return kind >= reflect.Int && kind <= reflect.Float64
+	case ParamBool:
+		return kind == reflect.Bool
+	case ParamTime:
+		return false
+	default:
+		return true
+	}
+}
+
+// Param declares a template parameter.
+type Param struct {
+	Type     ParamType
+	Optional bool
+}
+
+// TemplateParamsError is returned when the parameters passed to a template do not match its schema.
+type TemplateParamsError struct {
+	Template string
+	Problems []string
+}
+
+// Error returns every problem found with the parameters.
+func (e *TemplateParamsError) Error() string {
+	return fmt.Sprintf("template error, invalid parameters for %s: %s", e.Template, strings.Join(e.Problems, "; "))
+}
+
+// placeholder matches a {{key}} placeholder.
+var placeholder = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+
+// validate checks the parameters against the schema of the template.
+func (t Template) validate(name string, parameters map[string]interface{}) error {
+	if t.Schema == nil {
+		return nil
+	}
+
+	var problems []string
+	keys := make([]string, 0, len(t.Schema))
+	for key := range t.Schema {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		param := t.Schema[key]
+		value, ok := parameters[key]
+		switch {
+		case !ok && !param.Optional:
+			problems = append(problems, fmt.Sprintf("missing %s (%s)", key, param.Type))
+		case ok && !param.Type.accepts(value):
+			problems = append(problems, fmt.Sprintf("%s must be %s, got %T", key, param.Type, value))
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, m := range placeholder.FindAllStringSubmatch(t.Subject+t.Body, -1) {
+		key := m[1]
+		if _, k, _, ok := templateCall(key); ok {
+			key = k
+		} else if _, declared := t.Schema[key]; !declared && templateBuiltins[key] {
+			continue
+		}
+		if _, ok := t.Schema[key]; !ok && !seen[key] {
+			seen[key] = true
+			problems = append(problems, fmt.Sprintf("placeholder %s is not declared", key))
+		}
+	}
+
+	if len(problems) != 0 {
+		return &TemplateParamsError{Template: name, Problems: problems}
+	}
+	return nil
+}
+
+// templateRegistry holds the templates registered on a client.
+type templateRegistry struct {
+	mu        sync.RWMutex
+	templates map[string]Template
+	// localized holds the translations of templates by name and normalized locale tag.
+	localized map[string]map[string]Template
+	// fallback lists the normalized locale tags tried after the locale of the email.
+	fallback []string
+}
+
+// RegisterTemplate adds or replaces a named template.
+func (c *SMTP) RegisterTemplate(name string, tmpl Template) {
+	c.templates.mu.Lock()
+	defer c.templates.mu.Unlock()
+
+	if c.templates.templates == nil {
+		c.templates.templates = map[string]Template{}
+	}
+	c.templates.templates[name] = tmpl
+}
+
+// RegisterLocalizedTemplate adds or replaces the translation of the named template for a locale such as "de" or
+// "pt-BR". Rendering picks the translation for the Locale of the email, see RenderTemplate; a template registered
+// with RegisterTemplate under the same name serves the locales without a translation.
+func (c *SMTP) RegisterLocalizedTemplate(name, locale string, tmpl Template) {
+	c.templates.mu.Lock()
+	defer c.templates.mu.Unlock()
+
+	if c.templates.localized == nil {
+		c.templates.localized = map[string]map[string]Template{}
+	}
+	if c.templates.localized[name] == nil {
+		c.templates.localized[name] = map[string]Template{}
+	}
+	c.templates.localized[name][normalizeLocale(locale)] = tmpl
+}
+
+// template returns the named template in the best locale for tag, and the normalized locale of the translation,
+// empty for the template registered without a locale. The locales are tried in order: tag, its language, each
+// fallback locale and its language, and finally no locale.
+func (c *SMTP) template(name, tag string) (Template, string, bool) {
+	c.templates.mu.RLock()
+	defer c.templates.mu.RUnlock()
+
+	if translations := c.templates.localized[name]; len(translations) > 0 {
+		for _, locale := range append([]string{normalizeLocale(tag)}, c.templates.fallback...) {
+			if locale == "" {
+				continue
+			}
+			if tmpl, ok := translations[locale]; ok {
+				return tmpl, locale, true
+			}
+			if lang := localeLanguage(locale); lang != locale {
+				if tmpl, ok := translations[lang]; ok {
+					return tmpl, lang, true
+				}
+			}
+		}
+	}
+	tmpl, ok := c.templates.templates[name]
+	return tmpl, "", ok
+}
+
+// localeLanguage returns the language of a normalized locale tag, e.g. "pt" for "pt-br".
+func localeLanguage(tag string) string {
+	lang, _, _ := strings.Cut(tag, "-")
+	return lang
+}
+
+// RenderTemplate fills the subject and body of the email from the named template.
+// Placeholders such as {{date key}} or {{currency key EUR}} are formatted in the email's Locale.
+// The parameters are validated against the template's schema, if any, before anything is rendered.
+//
+// With translations registered by RegisterLocalizedTemplate, the one for the email's Locale is rendered, falling
+// back to its language, then to the locales of WithTemplateFallback, and then to the template without a locale.
+// When the translation is in another language than the Locale, the Locale is changed to that of the translation,
+// so that formatting and text direction match the text.
+func (c *SMTP) RenderTemplate(email *Email, name string, parameters map[string]interface{}) error {
+	tmpl, translation, ok := c.template(name, email.Locale)
+	if !ok {
+		return fmt.Errorf("template error, unknown template %s", name)
+	}
+	if err := tmpl.validate(name, parameters); err != nil {
+		return err
+	}
+	if translation != "" && localeLanguage(translation) != localeLanguage(normalizeLocale(email.Locale)) {
+		email.Locale = translation
+	}
+
+	locale := LookupLocale(email.Locale)
+	email.Subject = renderLocalized(tmpl.Subject, parameters, locale, email.rtl())
+	email.Body = renderLocalized(tmpl.Body, parameters, locale, email.rtl())
+	return nil
+}
+
+// SendTemplate renders the named template into the email and sends it.
+// Nothing is sent when the parameters do not match the template's schema.
+func (c *SMTP) SendTemplate(email Email, name string, parameters map[string]interface{}) error {
+	if err := c.RenderTemplate(&email, name, parameters); err != nil {
+		return err
+	}
+	return c.SendMail(email)
+}
@@ -0,0 +1,69 @@
+package smtp
+
+import (
+	"strings"
+)
+
+// maxReferences caps the References header; the first message of the conversation and the most recent ones are kept.
+const maxReferences = 10
+
+// Reply makes the email a reply to the message with the given Message-ID, so mail clients show it in the same
+// conversation. references are the References of that message, if any, given as separate IDs or as the header value.
+// The IDs may be given with or without angle brackets.
+func (e *Email) Reply(messageID string, references ...string) {
+	id := msgID(messageID)
+	if id == "" {
+		return
+	}
+
+	var refs []string
+	for _, value := range references {
+		for _, ref := range strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' }) {
+			if ref = msgID(ref); ref != "" && ref != id {
+				refs = append(refs, ref)
+			}
+		}
+	}
+	refs = append(refs, id)
+	if len(refs) > maxReferences {
+		refs = append(refs[:1], refs[len(refs)-maxReferences+1:]...)
+	}
+
+	e.InReplyTo = id
+	e.References = refs
+}
+
+// ReplyToMessage makes the email a reply to original, e.g. a message parsed with ParseMessage, using its Message-ID
+// and References headers. An empty subject becomes the original subject prefixed with "Re: ".
+func (e *Email) ReplyToMessage(original Email) {
+	header := func(name string) string {
+		for k, v := range original.Headers {
+			if strings.EqualFold(k, name) {
+				return v
+			}
+		}
+		return ""
+	}
+
+	references := header("References")
+	if references == "" {
+		references = header("In-Reply-To")
+	}
+	e.Reply(header("Message-ID"), references)
+
+	if e.Subject == "" && original.Subject != "" {
+		e.Subject = original.Subject
+		if len(e.Subject) < 3 || !strings.EqualFold(e.Subject[:3], "re:") {
+			e.Subject = "Re: " + e.Subject
+		}
+	}
+}
+
+// msgID returns a Message-ID in angle brackets, or an empty string when id is empty.
+func msgID(id string) string {
+	id = strings.Trim(strings.TrimSpace(id), "<>")
+	if id == "" {
+		return ""
+	}
+	return "<" + id + ">"
+}
@@ -0,0 +1,191 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// domainThrottle spaces out sends to the same recipient domain.
+type domainThrottle struct {
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+// wait reserves the next slot of every recipient domain and blocks until it starts or ctx is done.
+// Domains whose slot has passed are forgotten, so the throttle holds only the domains sent to within the interval.
+func (t *domainThrottle) wait(ctx context.Context, recipients []string, interval time.Duration) error {
+	t.mu.Lock()
+	if t.next == nil {
+		t.next = map[string]time.Time{}
+	}
+
+	now := time.Now()
+	for domain, next := range t.next {
+		if !next.After(now) {
+			delete(t.next, domain)
+		}
+	}
+
+	start := now
+	for _, addr := range recipients {
+		if next, ok := t.next[domainOf(addr)]; ok && next.After(start) {
+			start = next
+		}
+	}
+	for _, addr := range recipients {
+		t.next[domainOf(addr)] = start.Add(interval)
+	}
+	t.mu.Unlock()
+
+	if !start.After(now) {
+		return nil
+	}
+	timer := time.NewTimer(start.Sub(now))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// domainOf returns the lower-cased domain part of an address.
+func domainOf(addr string) string {
+	at := strings.LastIndex(addr, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSuffix(addr[at+1:], ">"))
+}
+
+// AdaptiveThrottleConfig controls how sends to a recipient domain slow down when the domain defers mail.
+type AdaptiveThrottleConfig struct {
+	// Threshold is the deferral rate between 0 and 1 above which a domain is throttled. It defaults to 0.2.
+	Threshold float64
+	// MinDelay is the interval between sends once a domain is throttled. It defaults to one second.
+	MinDelay time.Duration
+	// MaxDelay caps the interval between sends. It defaults to five minutes.
+	MaxDelay time.Duration
+}
+
+// deferralWeight is the weight of the latest result in the moving deferral rate of a domain.
+const deferralWeight = 0.1
+
+// domainRate is the deferral history of a recipient domain.
+type domainRate struct {
+	rate  float64
+	delay time.Duration
+}
+
+// adaptiveThrottle tracks deferral rates per recipient domain and derives the interval between sends.
+type adaptiveThrottle struct {
+	config AdaptiveThrottleConfig
+
+	mu      sync.Mutex
+	domains map[string]*domainRate
+}
+
+// enabled reports whether adaptive throttling is configured.
+func (t *adaptiveThrottle) enabled() bool {
+	return t.config.MinDelay > 0
+}
+
+// interval returns the longest current delay among the recipient domains.
+func (t *adaptiveThrottle) interval(recipients []string) time.Duration {
+	if !t.enabled() {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var d time.Duration
+	for _, addr := range recipients {
+		if r, ok := t.domains[domainOf(addr)]; ok {
+			d = max(d, r.delay)
+		}
+	}
+	return d
+}
+
+// record updates the deferral rate of the recipient domains with the outcome of a send.
+// A deferral above the threshold doubles the delay; successes shrink it gradually until the domain is released.
+// Errors other than 4xx replies say nothing about the domain and are ignored.
+func (t *adaptiveThrottle) record(recipients []string, err error) {
+	if !t.enabled() {
+		return
+	}
+
+	deferred := false
+	if err != nil {
+		var protoErr *textproto.Error
+		if !errors.As(err, &protoErr) || protoErr.Code < 400 || protoErr.Code >= 500 {
+			return
+		}
+		deferred = true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.domains == nil {
+		t.domains = map[string]*domainRate{}
+	}
+
+	seen := map[string]bool{}
+	for _, addr := range recipients {
+		domain := domainOf(addr)
+		if seen[domain] {
+			continue
+		}
+		seen[domain] = true
+
+		r, ok := t.domains[domain]
+		if !ok {
+			if !deferred {
+				continue
+			}
+			r = &domainRate{}
+			t.domains[domain] = r
+		}
+
+		if deferred {
+			r.rate += deferralWeight * (1 - r.rate)
+			if r.rate > t.config.Threshold {
+				r.delay = min(max(r.delay*2, t.config.MinDelay), t.config.MaxDelay)
+			}
+			continue
+		}
+
+		r.rate -= deferralWeight * r.rate
+		if r.rate <= t.config.Threshold {
+			r.delay -= r.delay / 4
+			if r.delay < t.config.MinDelay {
+				r.delay = 0
+			}
+		}
+		if r.delay == 0 && r.rate < 0.01 {
+			delete(t.domains, domain)
+		}
+	}
+}
+
+// ThrottledDomains returns the current interval between sends for every domain slowed down by adaptive throttling.
+func (c *SMTP) ThrottledDomains() map[string]time.Duration {
+	c.adaptive.mu.Lock()
+	defer c.adaptive.mu.Unlock()
+
+	out := map[string]time.Duration{}
+	for domain, r := range c.adaptive.domains {
+		if r.delay > 0 {
+			out[domain] = r.delay
+		}
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDomainThrottleWait(t *testing.T) {
+	var th domainThrottle
+	ctx := context.Background()
+
+	if err := th.wait(ctx, []string{"a@example.com"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second send waits for the first slot to pass, unless its context ends first
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := th.wait(ctx, []string{"b@EXAMPLE.com"}, time.Hour); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait returned %v, want the context error", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("wait took %s after its context ended", d)
+	}
+}
+
+func TestDomainThrottlePrunes(t *testing.T) {
+	var th domainThrottle
+	ctx := context.Background()
+
+	for _, addr := range []string{"a@one.example", "b@two.example", "c@three.example"} {
+		if err := th.wait(ctx, []string{addr}, time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := th.wait(ctx, []string{"d@four.example"}, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	if len(th.next) != 1 {
+		t.Fatalf("throttle holds %d domains, want only the latest", len(th.next))
+	}
+}
+
+func TestDomainIntervalOncePerSend(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+
+	const interval = 300 * time.Millisecond
+	c, err := New("app@example.com", "", "127.0.0.1", addr.Port,
+		WithRetry(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Retries of a refused send do not wait for the slot the first attempt reserved
+	start := time.Now()
+	if err = c.SendMail(Email{To: []string{"user@example.com"}, Subject: "Hello", Body: "Hi", Profile: &Profile{DomainInterval: interval}}); err == nil {
+		t.Fatal("send to a closed port succeeded")
+	}
+	if d := time.Since(start); d >= interval {
+		t.Fatalf("send with retries took %s, want less than one interval", d)
+	}
+}
@@ -0,0 +1,71 @@
+package smtp
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Timeouts bounds the phases of an SMTP session, see WithTimeouts. A zero value disables the timeout.
+type Timeouts struct {
+	// DialTimeout bounds opening the connection, including a proxy handshake.
+	DialTimeout time.Duration
+	// CommandTimeout bounds every read and write outside of the message transfer: the greeting, TLS handshake,
+	// EHLO, AUTH, MAIL, RCPT, and their replies.
+	CommandTimeout time.Duration
+	// DataTimeout bounds every read and write of the message transfer: the DATA or BDAT command, each write
+	// of the message, and the wait for the server's reply to it, so a stalled upload fails instead of hanging.
+	DataTimeout time.Duration
+}
+
+// deadlineConn sets a fresh deadline before every read and write from the timeout of the current phase.
+// Deadlines set on the connection, e.g. from the send's context, remain the upper bound.
+type deadlineConn struct {
+	net.Conn
+
+	mu      sync.Mutex
+	timeout time.Duration
+	limit   time.Time
+}
+
+// phase switches to the timeout of the next protocol phase.
+func (c *deadlineConn) phase(timeout time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.timeout = timeout
+	c.mu.Unlock()
+}
+
+// next returns the deadline for the next read or write.
+func (c *deadlineConn) next() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.timeout <= 0 {
+		return c.limit
+	}
+	deadline := time.Now().Add(c.timeout)
+	if !c.limit.IsZero() && c.limit.Before(deadline) {
+		return c.limit
+	}
+	return deadline
+}
+
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	c.Conn.SetReadDeadline(c.next())
+	return c.Conn.Read(p)
+}
+
+func (c *deadlineConn) Write(p []byte) (int, error) {
+	c.Conn.SetWriteDeadline(c.next())
+	return c.Conn.Write(p)
+}
+
+func (c *deadlineConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.limit = t
+	c.mu.Unlock()
+	return c.Conn.SetDeadline(t)
+}
@@ -0,0 +1,62 @@
+package smtp
+
+import "fmt"
+
+// TLSMode selects how the connection to the relay is secured.
+type TLSMode int
+
+// TLS modes.
+const (
+	// TLSStartTLS upgrades the connection with STARTTLS and fails when the server does not support it.
+	TLSStartTLS TLSMode = iota
+	// TLSImplicit starts TLS before the greeting, as used by SMTPS on port 465.
+	TLSImplicit
+	// TLSOpportunistic upgrades with STARTTLS when the server advertises it and continues in plaintext otherwise.
+	TLSOpportunistic
+	// TLSNone never uses TLS. Only suitable for local relays, since credentials are sent in the clear.
+	TLSNone
+)
+
+// String returns the name of the mode as accepted by ParseTLSMode.
+func (m TLSMode) String() string {
+	switch m {
+	case TLSImplicit:
+		return "implicit"
+	case TLSOpportunistic:
+		return "opportunistic"
+	case TLSNone:
+		return "none"
+	default:
+		return "starttls"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler so that modes read and write as their names in JSON and YAML.
+func (m TLSMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using ParseTLSMode.
+func (m *TLSMode) UnmarshalText(text []byte) error {
+	mode, err := ParseTLSMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
+
+// ParseTLSMode parses "starttls", "implicit", "opportunistic", or "none".
+func ParseTLSMode(s string) (TLSMode, error) {
+	switch s {
+	case "", "starttls":
+		return TLSStartTLS, nil
+	case "implicit":
+		return TLSImplicit, nil
+	case "opportunistic":
+		return TLSOpportunistic, nil
+	case "none":
+		return TLSNone, nil
+	}
+	return TLSStartTLS, fmt.Errorf("config error, unknown tls mode %q", s)
+}
@@ -0,0 +1,105 @@
+package smtp
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	trackLink    = regexp.MustCompile(`(?is)<a\b([^>]*?)\shref\s*=\s*("[^"]*"|'[^']*')([^>]*)>`)
+	trackNoTrack = regexp.MustCompile(`(?i)\sdata-notrack\b`)
+	trackBodyEnd = regexp.MustCompile(`(?i)</body\s*>`)
+	trackImage   = regexp.MustCompile(`(?is)<img\b([^>]*)>`)
+)
+
+// Tracking configures open and click tracking of HTML bodies. In both URL templates, {id} is replaced by
+// the tracking ID of the message.
+type Tracking struct {
+	// ClickURL is the address links are rewritten to, where {url} is replaced by the query-escaped original link,
+	// e.g. "https://t.example.com/c/{id}?u={url}". Only http and https links are rewritten, and links with a
+	// data-notrack attribute are left alone. Empty disables click tracking.
+	ClickURL string
+	// OpenURL is the source of a 1x1 image appended to the HTML body, e.g. "https://t.example.com/o/{id}.gif".
+	// Empty disables open tracking.
+	OpenURL string
+}
+
+// enabled reports whether the configuration tracks anything.
+func (t *Tracking) enabled() bool {
+	return t != nil && (t.ClickURL != "" || t.OpenURL != "")
+}
+
+// apply rewrites the links of document and, with pixel, appends the tracking pixel for the message with the given ID.
+func (t *Tracking) apply(document, id string, pixel bool) string {
+	if t.ClickURL != "" {
+		document = trackLink.ReplaceAllStringFunc(document, func(tag string) string {
+			m := trackLink.FindStringSubmatch(tag)
+			if trackNoTrack.MatchString(m[1] + m[3]) {
+				return tag
+			}
+			link := html.UnescapeString(strings.Trim(m[2], `"'`))
+			if u, err := url.Parse(link); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return tag
+			}
+
+			tracked := strings.NewReplacer("{id}", url.PathEscape(id), "{url}", url.QueryEscape(link)).Replace(t.ClickURL)
+			return "<a" + m[1] + ` href="` + html.EscapeString(tracked) + `"` + m[3] + ">"
+		})
+	}
+
+	if t.OpenURL != "" && pixel {
+		src := strings.ReplaceAll(t.OpenURL, "{id}", url.PathEscape(id))
+		img := `<img src="` + html.EscapeString(src) + `" width="1" height="1" alt="" style="display:block;border:0;width:1px;height:1px">`
+		if loc := trackBodyEnd.FindAllStringIndex(document, -1); len(loc) != 0 {
+			i := loc[len(loc)-1][0]
+			document = document[:i] + img + document[i:]
+		} else {
+			document += img
+		}
+	}
+
+	return document
+}
+
+// removePixels removes the tracking pixels from document, images no larger than one pixel in each dimension like
+// the one appended by apply, and reports whether it found any.
+func removePixels(document string) (string, bool) {
+	found := false
+	document = trackImage.ReplaceAllStringFunc(document, func(tag string) string {
+		if !isTrackingPixel(trackImage.FindStringSubmatch(tag)[1]) {
+			return tag
+		}
+		found = true
+		return ""
+	})
+	return document, found
+}
+
+// isTrackingPixel reports whether the attributes of an img tag size it at most 1x1 pixels, by its width and height
+// attributes or its inline style.
+func isTrackingPixel(attributes string) bool {
+	attrs := parseAttrs(attributes)
+	style := parseStyle(attrs["style"])
+	tiny := func(name string) bool {
+		value := attrs[name]
+		if s := style[name]; s != "" {
+			value = s
+		}
+		value = strings.TrimSuffix(strings.TrimSpace(value), "px")
+		return value == "0" || value == "1"
+	}
+	return tiny("width") && tiny("height")
+}
+
+// newTrackingID returns a random ID that keys the tracking events of one message.
+func newTrackingID() string {
+	id, err := newID()
+	if err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return id
+}
@@ -0,0 +1,268 @@
+package smtp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Transcript is the SMTP dialogue of a send, one entry per line. Lines sent by the client start with "C: ",
+// replies of the server with "S: ", and notes such as a new connection with "* ". Credentials are replaced with
+// "****" and the message itself with a note of its size.
+type Transcript []string
+
+// String returns the lines of the transcript separated by line breaks.
+func (t Transcript) String() string {
+	return strings.Join(t, "\n")
+}
+
+// TranscriptFunc receives the transcript of every send of a client with WithTranscript, together with the
+// error of the send, nil when it succeeded.
+type TranscriptFunc func(ctx context.Context, email Email, transcript Transcript, err error)
+
+// TranscriptError is the error of a failed send on a client with WithTranscript. It carries the dialogue with
+// the server, e.g. to show which command a provider rejected:
+//
+//	var transcriptErr *smtp.TranscriptError
+//	if errors.As(err, &transcriptErr) {
+//		log.Printf("send failed: %v\n%s", err, transcriptErr.Transcript)
+//	}
+type TranscriptError struct {
+	Err        error
+	Transcript Transcript
+}
+
+func (e *TranscriptError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TranscriptError) Unwrap() error {
+	return e.Err
+}
+
+// recorder collects the transcript of a send across its attempts and connections.
+type recorder struct {
+	mu    sync.Mutex
+	lines Transcript
+}
+
+type recorderKey struct{}
+
+// recorderFrom returns the recorder of the send, or nil when transcripts are disabled.
+func recorderFrom(ctx context.Context) *recorder {
+	rec, _ := ctx.Value(recorderKey{}).(*recorder)
+	return rec
+}
+
+func (r *recorder) add(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lines = append(r.lines, line)
+}
+
+func (r *recorder) transcript() Transcript {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append(Transcript(nil), r.lines...)
+}
+
+// transcriber turns the bytes of a connection into transcript lines for the recorder of the send that currently
+// uses the session. It redacts AUTH responses, summarizes the message data, and stops reading the connection
+// once STARTTLS switches it to ciphertext, see resume.
+type transcriber struct {
+	mu  sync.Mutex
+	rec *recorder
+	// in and out hold incomplete lines received and sent
+	in, out []byte
+	// command is the verb of the last command sent
+	command string
+	// challenge is set while the server waits for an AUTH response
+	challenge bool
+	// encrypted is set once the server accepted STARTTLS
+	encrypted bool
+	// data is set while the message is sent after DATA, with size counting its bytes
+	data bool
+	size int
+	// skip counts the bytes of a BDAT chunk still to be sent
+	skip int
+}
+
+// attach directs the lines to rec, nil to drop them while the session is idle, and notes why when rec is set.
+func (t *transcriber) attach(rec *recorder, note string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rec = rec
+	if rec != nil && note != "" {
+		rec.add("* " + note)
+	}
+}
+
+// resume records the dialogue again after STARTTLS, above the TLS layer of the client. The EHLO that the client
+// repeats as part of StartTLS is not recorded.
+func (t *transcriber) resume(client *smtp.Client) {
+	if t == nil {
+		return
+	}
+	client.Text.Reader.R = bufio.NewReader(&transcriptReader{r: client.Text.Reader.R, t: t})
+	client.Text.Writer.W = bufio.NewWriter(&transcriptWriter{w: client.Text.Writer.W, t: t})
+}
+
+// record adds the complete lines of data sent (out) or received. Data read from the connection itself (wire)
+// is ignored once TLS has started.
+func (t *transcriber) record(data []byte, out, wire bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if wire && t.encrypted {
+		return
+	}
+
+	buf := &t.in
+	if out {
+		buf = &t.out
+	}
+	*buf = append(*buf, data...)
+
+	for {
+		if out && t.skip > 0 {
+			n := min(t.skip, len(*buf))
+			*buf, t.skip = (*buf)[n:], t.skip-n
+			if t.skip > 0 {
+				return
+			}
+		}
+
+		i := bytes.IndexByte(*buf, '\n')
+		if i < 0 {
+			return
+		}
+		line := strings.TrimRight(string((*buf)[:i]), "\r")
+		*buf = (*buf)[i+1:]
+
+		if out {
+			t.sent(line)
+		} else {
+			t.received(line)
+		}
+	}
+}
+
+// sent records a line sent by the client. The caller holds mu.
+func (t *transcriber) sent(line string) {
+	if t.data {
+		if line != "." {
+			t.size += len(line) + 2
+			return
+		}
+		t.data = false
+		t.add("* " + strconv.Itoa(t.size) + " bytes of message data")
+		t.add("C: .")
+		return
+	}
+	if t.challenge {
+		t.add("C: ****")
+		return
+	}
+
+	verb, args, _ := strings.Cut(line, " ")
+	t.command = strings.ToUpper(verb)
+	switch t.command {
+	case "AUTH":
+		// The initial response carries the credentials
+		if mechanism, _, ok := strings.Cut(args, " "); ok {
+			line = verb + " " + mechanism + " ****"
+		}
+	case "BDAT":
+		size, _, _ := strings.Cut(args, " ")
+		t.skip, _ = strconv.Atoi(size)
+	}
+	t.add("C: " + line)
+}
+
+// received records a line of a server reply. The caller holds mu.
+func (t *transcriber) received(line string) {
+	t.add("S: " + line)
+
+	// Only the last line of a reply, without a hyphen after the code, ends it
+	if len(line) > 3 && line[3] == '-' {
+		return
+	}
+	t.challenge = strings.HasPrefix(line, "334")
+	switch {
+	case t.command == "STARTTLS" && strings.HasPrefix(line, "220"):
+		t.encrypted = true
+		t.add("* TLS started")
+	case t.command == "DATA" && strings.HasPrefix(line, "354"):
+		t.data, t.size = true, 0
+	}
+}
+
+// add appends a line to the recorder, if any. The caller holds mu.
+func (t *transcriber) add(line string) {
+	if t.rec != nil {
+		t.rec.add(line)
+	}
+}
+
+// transcriptConn records the plaintext dialogue of a connection until TLS starts.
+type transcriptConn struct {
+	net.Conn
+	t *transcriber
+}
+
+func (c *transcriptConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.t.record(p[:n], false, true)
+	}
+	return n, err
+}
+
+func (c *transcriptConn) Write(p []byte) (int, error) {
+	c.t.record(p, true, true)
+	return c.Conn.Write(p)
+}
+
+// transcriptReader records the replies read by the client after STARTTLS.
+type transcriptReader struct {
+	r *bufio.Reader
+	t *transcriber
+}
+
+func (r *transcriptReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.t.record(p[:n], false, false)
+	}
+	return n, err
+}
+
+// transcriptWriter records the commands written by the client after STARTTLS. It flushes the writer below it
+// on every write, since the client only flushes the writer above.
+type transcriptWriter struct {
+	w *bufio.Writer
+	t *transcriber
+}
+
+func (w *transcriptWriter) Write(p []byte) (int, error) {
+	w.t.record(p, true, false)
+	n, err := w.w.Write(p)
+	if err == nil {
+		err = w.w.Flush()
+	}
+	return n, err
+}
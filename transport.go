@@ -0,0 +1,70 @@
+package smtp
+
+import (
+	"context"
+	"fmt"
+)
+
+// Transport delivers assembled messages in place of the client's SMTP sessions, see WithTransport.
+// Deliver is called for every attempt with the envelope sender, the envelope recipients, and the message as it
+// is to be sent, DKIM signature included. Failures that satisfy IsTemporary are retried.
+//
+// The library ships transports for SMTP and LMTP (*SMTP itself), sendmail (SendmailTransport), HTTP APIs
+// (SESTransport, SendGridTransport, MailgunTransport), and tests (smtptest.Transport).
+type Transport interface {
+	Deliver(ctx context.Context, from string, recipients []string, msg *Message) error
+}
+
+// TransportFunc adapts an ordinary function to the Transport interface.
+type TransportFunc func(ctx context.Context, from string, recipients []string, msg *Message) error
+
+// Deliver calls f(ctx, from, recipients, msg).
+func (f TransportFunc) Deliver(ctx context.Context, from string, recipients []string, msg *Message) error {
+	return f(ctx, from, recipients, msg)
+}
+
+// Deliver makes a client a Transport: it delivers an assembled message once through the client's pooled
+// sessions and relays, over LMTP with WithLMTP, or through the client's own transport. The message is signed
+// when the client has DKIM configured. Retries, logging, and events are left to the client that calls it, e.g.
+// one that assembles messages and delivers them through another:
+//
+//	relay, err := smtp.New("noreply@example.com", password, "smtp.example.com", 587)
+//	client, err := smtp.New("noreply@example.com", "", "", 0, smtp.WithTransport(relay))
+func (c *SMTP) Deliver(ctx context.Context, from string, recipients []string, msg *Message) error {
+	if len(recipients) == 0 {
+		return fmt.Errorf("send error, message has no recipients")
+	}
+	ctx, end, err := c.life.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	email := Email{To: recipients, Subject: unfold(msg.Get("Subject"))}
+	if err = c.waitDomains(ctx, email); err != nil {
+		return err
+	}
+	_, err = c.attempt(ctx, email, from, msg)
+	return err
+}
+
+// deliverTransport signs a copy of the message and hands it to the client's transport.
+func (c *SMTP) deliverTransport(ctx context.Context, email Email, from string, msg *Message) (*Message, error) {
+	out, err := msg.downgrade(true, true)
+	if err != nil {
+		return nil, err
+	}
+	if c.dkim != nil {
+		if err = c.dkim.signAt(out, c.now()); err != nil {
+			return nil, err
+		}
+	}
+	if size := out.EstimateSize(); c.maxSize > 0 && size > c.maxSize {
+		return nil, &MessageSizeError{Size: size, Limit: c.maxSize, Local: true}
+	}
+
+	if err = c.transport.Deliver(ctx, from, email.recipients(), out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,87 @@
+package smtp
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// oneClickUnsubscribe is the List-Unsubscribe-Post value of RFC 8058.
+const oneClickUnsubscribe = "List-Unsubscribe=One-Click"
+
+// SetListUnsubscribe sets the List-Unsubscribe header to the given mailto address and HTTPS URL, either of which
+// may be empty. The mailto address may carry a "mailto:" prefix and a query such as "?subject=unsubscribe".
+// With an HTTPS URL, List-Unsubscribe-Post is set as well so that Gmail and Yahoo offer one-click unsubscribe
+// (RFC 8058); the URL must then accept a POST and identify the recipient by itself.
+func (e *Email) SetListUnsubscribe(mailto, httpsURL string) error {
+	if mailto == "" && httpsURL == "" {
+		return fmt.Errorf("unsubscribe error, neither a mailto address nor an https URL given")
+	}
+
+	var uris []string
+	if httpsURL != "" {
+		uri, err := unsubscribeURL(httpsURL)
+		if err != nil {
+			return err
+		}
+		uris = append(uris, "<"+uri+">")
+	}
+	if mailto != "" {
+		uri, err := unsubscribeMailto(mailto)
+		if err != nil {
+			return err
+		}
+		uris = append(uris, "<"+uri+">")
+	}
+
+	if e.Headers == nil {
+		e.Headers = map[string]string{}
+	}
+	e.Headers["List-Unsubscribe"] = strings.Join(uris, ", ")
+	if httpsURL != "" {
+		e.Headers["List-Unsubscribe-Post"] = oneClickUnsubscribe
+	} else {
+		delete(e.Headers, "List-Unsubscribe-Post")
+	}
+	return nil
+}
+
+// unsubscribeURL validates an absolute HTTPS URL for the List-Unsubscribe header.
+func unsubscribeURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("unsubscribe error, invalid url %q; %w", raw, err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("unsubscribe error, %q is not an absolute https url", raw)
+	}
+	if u.Fragment != "" {
+		return "", fmt.Errorf("unsubscribe error, url %q must not have a fragment", raw)
+	}
+	return u.String(), nil
+}
+
+// unsubscribeMailto validates an address or mailto URI for the List-Unsubscribe header and returns the mailto URI.
+func unsubscribeMailto(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) >= 7 && strings.EqualFold(raw[:7], "mailto:") {
+		raw = raw[7:]
+	}
+
+	addr, query, _ := strings.Cut(raw, "?")
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil || parsed.Name != "" || parsed.Address != addr {
+		return "", fmt.Errorf("unsubscribe error, invalid mailto address %q", addr)
+	}
+
+	uri := "mailto:" + addr
+	if query == "" {
+		return uri, nil
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("unsubscribe error, invalid mailto query %q; %w", query, err)
+	}
+	return uri + "?" + strings.ReplaceAll(values.Encode(), "+", "%20"), nil
+}
@@ -0,0 +1,127 @@
+package smtp
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// MaxAttachmentSize is the largest attachment Validate accepts by default, the limit of most mailbox providers.
+const MaxAttachmentSize = 25 << 20
+
+// Validation selects the optional pre-flight checks of ValidateWith and of every send, see WithValidation.
+type Validation struct {
+	// RequireSubject reports an email with an empty subject.
+	RequireSubject bool
+	// MaxAttachmentSize is the largest attachment accepted in bytes: MaxAttachmentSize when zero, and no limit
+	// when negative.
+	MaxAttachmentSize int64
+}
+
+// ValidationError is returned by Validate and SendMail when an email fails the pre-flight checks.
+type ValidationError struct {
+	Violations []Violation
+}
+
+// Error returns every violation on its own line.
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		lines[i] = v.String()
+	}
+	return "validation error, email is not sendable;\n" + strings.Join(lines, "\n")
+}
+
+// Validate checks the email before any network traffic: it needs a recipient, a subject, and some content;
+// recipients must be bare addresses or resolver identifiers; header fields must be well formed; DSN parameters
+// must be known NOTIFY conditions and RET values; and attachments need a filename and must not exceed
+// MaxAttachmentSize. Streamed attachments of unknown size are not measured.
+// Every violation is reported in a *ValidationError.
+func (e Email) Validate() error {
+	return e.ValidateWith(Validation{RequireSubject: true})
+}
+
+// ValidateWith runs the checks of Validate with the subject and attachment size checks of v. SendMail calls it
+// first with the Validation of WithValidation, which does not require a subject by default.
+func (e Email) ValidateWith(v Validation) error {
+	var violations []Violation
+	add := func(rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(e.recipients()) == 0 {
+		add("recipients", "no To, Cc, or Bcc recipient")
+	}
+	for _, field := range []struct {
+		name  string
+		addrs []string
+	}{{"To", e.To}, {"Cc", e.Cc}, {"Bcc", e.Bcc}} {
+		for _, addr := range field.addrs {
+			if isIdentifier(addr) {
+				continue
+			}
+			if parsed, err := mail.ParseAddress(addr); err != nil || parsed.Address != addr {
+				add("address", "%s address %q is not a bare email address", field.name, addr)
+			} else if problem := addressProblem(addr); problem != "" {
+				add("address", "%s address %q %s", field.name, addr, problem)
+			}
+		}
+	}
+
+	if e.EnvelopeFrom != "" {
+		if parsed, err := mail.ParseAddress(e.EnvelopeFrom); err != nil || parsed.Address != e.EnvelopeFrom {
+			add("address", "envelope sender %q is not a bare email address", e.EnvelopeFrom)
+		}
+	}
+
+	if v.RequireSubject && strings.TrimSpace(e.Subject) == "" {
+		add("subject", "subject is empty")
+	}
+	if e.Body == "" && e.HTMLBody == "" && len(e.Attachments) == 0 && e.Calendar == nil {
+		add("body", "no body, HTML body, attachment, or calendar")
+	}
+
+	if e.DSN != nil {
+		if problem := notifyProblem(e.DSN.Notify); problem != "" {
+			add("dsn", "NOTIFY %s", problem)
+		}
+		for addr, notify := range e.DSN.RecipientNotify {
+			if problem := notifyProblem(notify); problem != "" {
+				add("dsn", "NOTIFY of %q %s", addr, problem)
+			}
+		}
+		if ret := strings.ToUpper(e.DSN.Return); ret != "" && ret != "FULL" && ret != "HDRS" {
+			add("dsn", "RET %q is neither FULL nor HDRS", e.DSN.Return)
+		}
+	}
+
+	for name, value := range e.Headers {
+		if !validFieldName(name) {
+			add("header", "invalid header name %q", name)
+		} else if strings.ContainsAny(value, "\r\n") {
+			add("header", "header %s contains a line break", name)
+		}
+	}
+
+	limit := v.MaxAttachmentSize
+	if limit == 0 {
+		limit = MaxAttachmentSize
+	}
+	for i, a := range e.Attachments {
+		if strings.TrimSpace(a.Filename) == "" {
+			add("attachment", "attachment %d has no filename", i+1)
+		}
+		size := int64(len(a.Data))
+		if a.Open != nil {
+			size = a.Size
+		}
+		if limit > 0 && size > limit {
+			add("attachment", "attachment %q is %d bytes, more than the %d byte limit", a.Filename, size, limit)
+		}
+	}
+
+	if len(violations) != 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package smtp
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// VERPAddress returns the variable envelope return path that encodes recipient into sender, e.g.
+// bounces+user=example.com@ourdomain.com for bounces@ourdomain.com and user@example.com. The mail server
+// of ourdomain.com must deliver the address to the sender's mailbox, as Postfix and most others do for
+// the "+" extension.
+func VERPAddress(sender, recipient string) string {
+	at := strings.LastIndex(sender, "@")
+	rat := strings.LastIndex(recipient, "@")
+	if at < 0 || rat < 0 {
+		return sender
+	}
+	return sender[:at] + "+" + recipient[:rat] + "=" + recipient[rat+1:] + sender[at:]
+}
+
+// DecodeVERP returns the recipient encoded in a VERP address such as the address a bounce was returned to,
+// e.g. user@example.com for bounces+user=example.com@ourdomain.com. Angle brackets and display names are
+// allowed. ok is false when addr is not a VERP address.
+func DecodeVERP(addr string) (recipient string, ok bool) {
+	addr = strings.TrimSpace(addr)
+	if parsed, err := mail.ParseAddress(addr); err == nil {
+		addr = parsed.Address
+	}
+	addr = strings.Trim(addr, "<>")
+
+	at := strings.LastIndex(addr, "@")
+	if at < 0 {
+		return "", false
+	}
+	_, extension, found := strings.Cut(addr[:at], "+")
+	eq := strings.LastIndex(extension, "=")
+	if !found || eq <= 0 || eq == len(extension)-1 {
+		return "", false
+	}
+	return extension[:eq] + "@" + extension[eq+1:], true
+}
+
+// perRecipient splits the envelope of the email into one email per recipient. Each keeps the recipient
+// in its To, Cc, or Bcc field; the assembled message keeps every recipient in its header.
+func (e Email) perRecipient() []Email {
+	var emails []Email
+	for _, field := range []func(*Email) *[]string{
+		func(e *Email) *[]string { return &e.To },
+		func(e *Email) *[]string { return &e.Cc },
+		func(e *Email) *[]string { return &e.Bcc },
+	} {
+		for _, addr := range *field(&e) {
+			single := e
+			single.To, single.Cc, single.Bcc = nil, nil, nil
+			*field(&single) = []string{addr}
+			emails = append(emails, single)
+		}
+	}
+	return emails
+}
@@ -0,0 +1,214 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Event types. EventDelivered, EventFailed, EventDeadLettered, and EventDeferred are terminal: every send ends
+// with one of them. EventBounced and EventComplained arrive later from a BouncePoller, see BouncePoller.SetEvents.
+const (
+	// EventQueued is sent when the message was assembled and its delivery starts.
+	EventQueued = "queued"
+	// EventRetried is sent when an attempt failed temporarily and another one follows.
+	EventRetried = "retried"
+	// EventDelivered is sent when the server accepted the message.
+	EventDelivered = "delivered"
+	// EventFailed is sent when the message was rejected permanently.
+	EventFailed = "failed"
+	// EventDeadLettered is sent when every attempt failed temporarily and the message was given up on.
+	EventDeadLettered = "dead_lettered"
+	// EventDeferred is sent when the server asked to come back later, e.g. by greylisting, and the email was
+	// queued to be sent again, see WithGreylisting.
+	EventDeferred = "deferred"
+	// EventBounced is sent when a delivery status notification reports that a recipient failed permanently.
+	EventBounced = "bounced"
+	// EventComplained is sent when a feedback report says that a recipient complained, e.g. marked it as spam.
+	EventComplained = "complained"
+)
+
+// WebhookEvent is the JSON payload of a send event.
+type WebhookEvent struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	MessageID string    `json:"message_id"`
+	From      string    `json:"from"`
+	To        []string  `json:"to"`
+	Cc        []string  `json:"cc,omitempty"`
+	// Recipients are the envelope recipients of the transaction, including Bcc.
+	Recipients []string `json:"recipients,omitempty"`
+	Subject    string   `json:"subject"`
+	Profile    string   `json:"profile,omitempty"`
+	// Attempts is the number of attempts made so far.
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+	// Response is the server's reply to the message, e.g. "250 2.0.0 Ok: queued as 4F3A", or the reply
+	// that rejected it.
+	Response string `json:"response,omitempty"`
+	// Status is the enhanced status code of a bounce, e.g. "5.1.1", or the feedback type of a complaint.
+	Status string `json:"status,omitempty"`
+}
+
+// Webhook posts delivery events to a URL. Events are delivered in the background and retried
+// with backoff when the endpoint is unreachable or answers with 429 or 5xx. WithWebhook posts the terminal
+// events; pass the webhook to WithEvents to post every event.
+type Webhook struct {
+	URL string
+	// Secret signs every request with HMAC-SHA256 when set. The X-Webhook-Signature header holds
+	// "sha256=" followed by the hex digest of the X-Webhook-Timestamp value, a dot, and the body.
+	Secret string
+	// Client is used to send requests; it defaults to a client with a 10 second timeout.
+	Client *http.Client
+	// Retry controls redelivery; the zero value makes 3 attempts starting with a 1 second backoff.
+	Retry RetryPolicy
+
+	wg sync.WaitGroup
+}
+
+// webhookStatusError is returned when the endpoint answers with a non-2xx status.
+type webhookStatusError struct {
+	code int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook error, endpoint answered %d", e.code)
+}
+
+// temporary reports whether the status is worth retrying.
+func (e *webhookStatusError) temporary() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= 500
+}
+
+// Notify posts the event, retrying temporary failures, and blocks until it is delivered or given up on.
+func (w *Webhook) Notify(ctx context.Context, event WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("webhook error, failed to encode event; %w", err)
+	}
+
+	policy := w.Retry
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = w.post(ctx, body)
+		if err == nil || attempt >= policy.MaxAttempts {
+			return err
+		}
+		if statusErr, ok := err.(*webhookStatusError); ok && !statusErr.temporary() {
+			return err
+		}
+
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Wait blocks until every event queued by the client has been delivered or given up on.
+func (w *Webhook) Wait() {
+	w.wg.Wait()
+}
+
+// post sends a single signed request.
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook error, failed to create request; %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if w.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		req.Header.Set("X-Webhook-Timestamp", timestamp)
+		req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook error, failed to post event; %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &webhookStatusError{code: resp.StatusCode}
+	}
+	return nil
+}
+
+// event returns an event of the given type for the email and its assembled message.
+func (c *SMTP) event(eventType string, email Email, msg *Message) WebhookEvent {
+	event := WebhookEvent{
+		Type:       eventType,
+		Time:       time.Now(),
+		MessageID:  msg.Get("Message-ID"),
+		From:       c.senderAddress,
+		To:         email.To,
+		Cc:         email.Cc,
+		Recipients: email.recipients(),
+		Subject:    email.Subject,
+	}
+	if email.Profile != nil {
+		event.Profile = email.Profile.Name
+	}
+	return event
+}
+
+// notify emits the terminal event of a send.
+func (c *SMTP) notify(email Email, msg *Message, attempts int, sendErr error) {
+	if len(c.events) == 0 {
+		return
+	}
+
+	event := c.event(EventDelivered, email, msg)
+	event.Attempts = attempts
+	event.Response = msg.reply
+	var deferred *DeferredError
+	switch {
+	case errors.As(sendErr, &deferred):
+		event.Type = EventDeferred
+		event.Error = deferred.Err.Error()
+		event.Response = errorReply(deferred.Err)
+	case sendErr != nil:
+		event.Type = EventFailed
+		if IsTemporary(sendErr) {
+			event.Type = EventDeadLettered
+		}
+		event.Error = sendErr.Error()
+		event.Response = errorReply(sendErr)
+	}
+	c.emit(event)
+}
+
+// errorReply returns the server reply carried by err, or an empty string.
+func errorReply(err error) string {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return strconv.Itoa(protoErr.Code) + " " + protoErr.Msg
+	}
+	return ""
+}